@@ -0,0 +1,44 @@
+package authentication
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+)
+
+// how many random bytes go into a generated account secret
+const accountSecretSize = 16
+
+// derives the Kerberos key Quazal clients use to decrypt their tickets from the account's password
+// the password is MD5 hashed 65000 times, plus a few more depending on the PID
+func DeriveKerberosKey(pid uint32, secret string) []byte {
+	key := []byte(secret)
+
+	for i := 0; i < 65000+(int(pid)%1024); i++ {
+		digest := md5.Sum(key)
+		key = digest[:]
+	}
+
+	return key
+}
+
+// generates a random secret for an account, to be handed to a client that can be configured with its own password
+func GenerateAccountSecret() (string, error) {
+	secret := make([]byte, accountSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
+
+// the password a stock client uses for an account that doesn't have its own secret
+// Wii clients use their friend code, everything else uses the password built into the game, which is USERPASSWORD
+func PlatformSecret(wiiFriendCode string) string {
+	if wiiFriendCode != "" {
+		return wiiFriendCode
+	}
+
+	return os.Getenv("USERPASSWORD")
+}
@@ -0,0 +1,200 @@
+package authentication
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"math/big"
+)
+
+// a short Weierstrass curve y^2 = x^3 + ax + b over a prime field
+// the curves tickets are signed with aren't in crypto/elliptic, so signatures are verified with plain big.Int arithmetic
+// this is only ever used to verify public signatures, so it doesn't need to be constant time
+type Curve struct {
+	Name   string
+	P      *big.Int
+	A      *big.Int
+	B      *big.Int
+	Gx, Gy *big.Int
+	N      *big.Int
+}
+
+func hexInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("invalid curve constant " + s)
+	}
+	return n
+}
+
+// the curve PSN signs tickets with, also known as NIST P-192
+var Secp192r1 = &Curve{
+	Name: "secp192r1",
+	P:    hexInt("fffffffffffffffffffffffffffffffeffffffffffffffff"),
+	A:    hexInt("fffffffffffffffffffffffffffffffefffffffffffffffc"),
+	B:    hexInt("64210519e59c80e70fa7e9ab72243049feb8deecc146b9b1"),
+	Gx:   hexInt("188da80eb03090f67cbf20eb43a18800f4ff0afd82ff1012"),
+	Gy:   hexInt("07192b95ffc8da78631011ed6b24cdd573f977a11e794811"),
+	N:    hexInt("ffffffffffffffffffffffff99def836146bc9b1b4d22831"),
+}
+
+// the curve RPCN signs tickets with
+var Secp224k1 = &Curve{
+	Name: "secp224k1",
+	P:    hexInt("fffffffffffffffffffffffffffffffffffffffffffffffeffffe56d"),
+	A:    big.NewInt(0),
+	B:    big.NewInt(5),
+	Gx:   hexInt("a1455b334df099df30fc28a169a467e9e47075a90f7e650eb6b7a45c"),
+	Gy:   hexInt("7e089fed7fba344282cafbd6f7e319f7c0b0bd59e2ca4bdb556d61a5"),
+	N:    hexInt("010000000000000000000000000001dce8d2ec6184caf0a971769fb1f7"),
+}
+
+// wraps one of the curves from crypto/elliptic, which all have a = -3
+func CurveFromParams(params *elliptic.CurveParams) *Curve {
+	return &Curve{
+		Name: params.Name,
+		P:    params.P,
+		A:    new(big.Int).Sub(params.P, big.NewInt(3)),
+		B:    params.B,
+		Gx:   params.Gx,
+		Gy:   params.Gy,
+		N:    params.N,
+	}
+}
+
+// whether (x, y) is a point on the curve
+func (curve *Curve) IsOnCurve(x *big.Int, y *big.Int) bool {
+	if x.Sign() < 0 || x.Cmp(curve.P) >= 0 || y.Sign() < 0 || y.Cmp(curve.P) >= 0 {
+		return false
+	}
+
+	left := new(big.Int).Mul(y, y)
+	left.Mod(left, curve.P)
+
+	right := new(big.Int).Mul(x, x)
+	right.Add(right, curve.A)
+	right.Mul(right, x)
+	right.Add(right, curve.B)
+	right.Mod(right, curve.P)
+
+	return left.Cmp(right) == 0
+}
+
+// adds two points in affine coordinates, nil is the point at infinity
+func (curve *Curve) add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	if x1 == nil {
+		return x2, y2
+	}
+	if x2 == nil {
+		return x1, y1
+	}
+
+	var slope *big.Int
+	if x1.Cmp(x2) == 0 {
+		sum := new(big.Int).Add(y1, y2)
+		if sum.Mod(sum, curve.P).Sign() == 0 {
+			return nil, nil
+		}
+
+		// doubling: (3x^2 + a) / 2y
+		numerator := new(big.Int).Mul(x1, x1)
+		numerator.Mul(numerator, big.NewInt(3))
+		numerator.Add(numerator, curve.A)
+		denominator := new(big.Int).Lsh(y1, 1)
+		slope = numerator.Mul(numerator, new(big.Int).ModInverse(denominator.Mod(denominator, curve.P), curve.P))
+	} else {
+		numerator := new(big.Int).Sub(y2, y1)
+		denominator := new(big.Int).Sub(x2, x1)
+		slope = numerator.Mul(numerator, new(big.Int).ModInverse(denominator.Mod(denominator, curve.P), curve.P))
+	}
+	slope.Mod(slope, curve.P)
+
+	x3 := new(big.Int).Mul(slope, slope)
+	x3.Sub(x3, x1)
+	x3.Sub(x3, x2)
+	x3.Mod(x3, curve.P)
+
+	y3 := new(big.Int).Sub(x1, x3)
+	y3.Mul(y3, slope)
+	y3.Sub(y3, y1)
+	y3.Mod(y3, curve.P)
+
+	return x3, y3
+}
+
+// multiplies a point by a scalar with double-and-add, nil is the point at infinity
+func (curve *Curve) ScalarMult(x *big.Int, y *big.Int, k *big.Int) (*big.Int, *big.Int) {
+	var rx, ry *big.Int
+
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		rx, ry = curve.add(rx, ry, rx, ry)
+		if k.Bit(i) == 1 {
+			rx, ry = curve.add(rx, ry, x, y)
+		}
+	}
+
+	return rx, ry
+}
+
+// parses an uncompressed SEC 1 public key, 0x04 followed by X and Y
+func (curve *Curve) UnmarshalPublicKey(data []byte) (*big.Int, *big.Int, bool) {
+	size := (curve.P.BitLen() + 7) / 8
+	if len(data) != 1+2*size || data[0] != 4 {
+		return nil, nil, false
+	}
+
+	x := new(big.Int).SetBytes(data[1 : 1+size])
+	y := new(big.Int).SetBytes(data[1+size:])
+	if !curve.IsOnCurve(x, y) {
+		return nil, nil, false
+	}
+
+	return x, y, true
+}
+
+// splits a signature into r and s, accepting both ASN.1 DER and the raw r || s form
+// PSN tickets zero-pad the DER signature out to the size of the signature field, so trailing zeros are allowed after it
+func parseSignature(signature []byte) (*big.Int, *big.Int, bool) {
+	var der struct {
+		R, S *big.Int
+	}
+	if rest, err := asn1.Unmarshal(signature, &der); err == nil && bytes.Count(rest, []byte{0}) == len(rest) {
+		return der.R, der.S, true
+	}
+
+	// otherwise r and s are back to back in a fixed-size field
+	if len(signature) == 0 || len(signature)%2 != 0 {
+		return nil, nil, false
+	}
+	half := len(signature) / 2
+	return new(big.Int).SetBytes(signature[:half]), new(big.Int).SetBytes(signature[half:]), true
+}
+
+// checks an ECDSA signature over a digest
+func (curve *Curve) Verify(x *big.Int, y *big.Int, digest []byte, signature []byte) bool {
+	r, s, ok := parseSignature(signature)
+	if !ok || r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(curve.N) >= 0 || s.Cmp(curve.N) >= 0 {
+		return false
+	}
+
+	// use the leftmost bits of the digest if it's longer than the order
+	e := new(big.Int).SetBytes(digest)
+	if excess := len(digest)*8 - curve.N.BitLen(); excess > 0 {
+		e.Rsh(e, uint(excess))
+	}
+
+	w := new(big.Int).ModInverse(s, curve.N)
+	u1 := new(big.Int).Mul(e, w)
+	u1.Mod(u1, curve.N)
+	u2 := new(big.Int).Mul(r, w)
+	u2.Mod(u2, curve.N)
+
+	x1, y1 := curve.ScalarMult(curve.Gx, curve.Gy, u1)
+	x2, y2 := curve.ScalarMult(x, y, u2)
+	rx, _ := curve.add(x1, y1, x2, y2)
+	if rx == nil {
+		return false
+	}
+
+	return new(big.Int).Mod(rx, curve.N).Cmp(r) == 0
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"time"
 )
 
 type NPTicket struct {
@@ -94,6 +95,14 @@ func (f *NPTicketFooter) Bytes() []byte {
 	return buf.Bytes()
 }
 
+// the console type that presents tickets with this ticket's cipher ID, 3 for RPCN tickets on RPCS3 and 1 for everything else
+func (t *NPTicket) ConsoleType() int {
+	if t.Footer.CipherID == RPCNTicketCipherID {
+		return 3
+	}
+	return 1
+}
+
 type NPTicketDeserializer struct{}
 
 func (d *NPTicketDeserializer) Deserialize(data []byte) (NPTicket, error) {
@@ -153,3 +162,156 @@ func (d *NPTicketDeserializer) Deserialize(data []byte) (NPTicket, error) {
 	ticket.Footer = *footer
 	return *ticket, nil
 }
+
+// the data types of the fields in an NPTicket body
+const (
+	npTicketTypeEmpty  = 0
+	npTicketTypeU32    = 1
+	npTicketTypeU64    = 2
+	npTicketTypeString = 4
+	npTicketTypeTime   = 7
+	npTicketTypeBinary = 8
+)
+
+// the fields of an NPTicket body, in the order they appear in the ticket
+type NPTicketBody struct {
+	Serial    []byte
+	IssuerID  uint32
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	UserID    uint64
+	OnlineID  string
+	Region    string
+	Domain    string
+	ServiceID string
+	Status    uint32
+}
+
+type npTicketField struct {
+	Type uint16
+	Data []byte
+}
+
+// splits a ticket body into its typed fields
+func readNPTicketFields(body []byte) ([]npTicketField, error) {
+	fields := []npTicketField{}
+
+	for offset := 0; offset < len(body); {
+		if offset+4 > len(body) {
+			return nil, fmt.Errorf("NPTicket field header extends past body at offset %d", offset)
+		}
+		fieldType := binary.BigEndian.Uint16(body[offset : offset+2])
+		fieldSize := int(binary.BigEndian.Uint16(body[offset+2 : offset+4]))
+		offset += 4
+
+		if offset+fieldSize > len(body) {
+			return nil, fmt.Errorf("NPTicket field extends past body: need %d bytes at offset %d, body is %d bytes", fieldSize, offset, len(body))
+		}
+		fields = append(fields, npTicketField{Type: fieldType, Data: body[offset : offset+fieldSize]})
+		offset += fieldSize
+	}
+
+	return fields, nil
+}
+
+// strings in tickets are fixed-size and padded with zeroes
+func npTicketString(data []byte) string {
+	return string(bytes.TrimRight(data, "\x00"))
+}
+
+// parses the body into its typed fields, fields after the status are ignored
+func (t *NPTicket) ParseBody() (NPTicketBody, error) {
+	fields, err := readNPTicketFields(t.Body)
+	if err != nil {
+		return NPTicketBody{}, err
+	}
+
+	expected := []uint16{
+		npTicketTypeBinary, // serial
+		npTicketTypeU32,    // issuer ID
+		npTicketTypeTime,   // issued at
+		npTicketTypeTime,   // expires at
+		npTicketTypeU64,    // user ID
+		npTicketTypeString, // online ID
+		npTicketTypeBinary, // region
+		npTicketTypeString, // domain
+		npTicketTypeBinary, // service ID
+		npTicketTypeU32,    // status
+	}
+	if len(fields) < len(expected) {
+		return NPTicketBody{}, fmt.Errorf("NPTicket body has %d fields, need at least %d", len(fields), len(expected))
+	}
+
+	for i, fieldType := range expected {
+		field := fields[i]
+		if field.Type != fieldType {
+			return NPTicketBody{}, fmt.Errorf("NPTicket body field %d has type %d, expected %d", i, field.Type, fieldType)
+		}
+
+		size := 0
+		switch fieldType {
+		case npTicketTypeU32:
+			size = 4
+		case npTicketTypeU64, npTicketTypeTime:
+			size = 8
+		}
+		if size != 0 && len(field.Data) != size {
+			return NPTicketBody{}, fmt.Errorf("NPTicket body field %d is %d bytes, expected %d", i, len(field.Data), size)
+		}
+	}
+
+	return NPTicketBody{
+		Serial:    fields[0].Data,
+		IssuerID:  binary.BigEndian.Uint32(fields[1].Data),
+		IssuedAt:  time.UnixMilli(int64(binary.BigEndian.Uint64(fields[2].Data))),
+		ExpiresAt: time.UnixMilli(int64(binary.BigEndian.Uint64(fields[3].Data))),
+		UserID:    binary.BigEndian.Uint64(fields[4].Data),
+		OnlineID:  npTicketString(fields[5].Data),
+		Region:    npTicketString(fields[6].Data),
+		Domain:    npTicketString(fields[7].Data),
+		ServiceID: npTicketString(fields[8].Data),
+		Status:    binary.BigEndian.Uint32(fields[9].Data),
+	}, nil
+}
+
+func writeNPTicketField(buf *bytes.Buffer, fieldType uint16, data []byte) {
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint16(header[:2], fieldType)
+	binary.BigEndian.PutUint16(header[2:], uint16(len(data)))
+	buf.Write(header)
+	buf.Write(data)
+}
+
+// pads a string to the fixed size the ticket uses for it
+func npTicketPadded(s string, size int) []byte {
+	data := make([]byte, size)
+	copy(data, s)
+	return data
+}
+
+func (b *NPTicketBody) Bytes() []byte {
+	u32 := func(v uint32) []byte {
+		data := make([]byte, 4)
+		binary.BigEndian.PutUint32(data, v)
+		return data
+	}
+	u64 := func(v uint64) []byte {
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint64(data, v)
+		return data
+	}
+
+	buf := bytes.Buffer{}
+	writeNPTicketField(&buf, npTicketTypeBinary, b.Serial)
+	writeNPTicketField(&buf, npTicketTypeU32, u32(b.IssuerID))
+	writeNPTicketField(&buf, npTicketTypeTime, u64(uint64(b.IssuedAt.UnixMilli())))
+	writeNPTicketField(&buf, npTicketTypeTime, u64(uint64(b.ExpiresAt.UnixMilli())))
+	writeNPTicketField(&buf, npTicketTypeU64, u64(b.UserID))
+	writeNPTicketField(&buf, npTicketTypeString, npTicketPadded(b.OnlineID, 32))
+	writeNPTicketField(&buf, npTicketTypeBinary, npTicketPadded(b.Region, 4))
+	writeNPTicketField(&buf, npTicketTypeString, npTicketPadded(b.Domain, 4))
+	writeNPTicketField(&buf, npTicketTypeBinary, npTicketPadded(b.ServiceID, 24))
+	writeNPTicketField(&buf, npTicketTypeU32, u32(b.Status))
+
+	return buf.Bytes()
+}
@@ -0,0 +1,213 @@
+package authentication
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownTicketIssuer    = errors.New("ticket was signed by an unknown issuer")
+	ErrInvalidTicketSignature = errors.New("ticket signature is invalid")
+	ErrTicketExpired          = errors.New("ticket has expired")
+	ErrWrongTicketService     = errors.New("ticket is for a different service")
+)
+
+// the cipher IDs in the footer of tickets from each issuer
+const (
+	PSNTicketCipherID  = 0x719F1D4A
+	RPCNTicketCipherID = 0x5250434E // "RPCN"
+)
+
+// someone who signs NPTickets, along with how they sign them
+type NPTicketIssuer struct {
+	Name        string
+	ConsoleType int // the console type tickets from this issuer are presented by, 1 for PS3 and 3 for RPCS3
+	CipherID    uint32
+	Curve       *Curve
+	Hash        func(data []byte) []byte
+	PublicKeyX  *big.Int
+	PublicKeyY  *big.Int
+
+	// PSN signs everything from the ticket version up to the signature, RPCN only signs the body section
+	SignsWholeTicket bool
+}
+
+// the bytes of a ticket that an issuer's signature covers
+func (issuer *NPTicketIssuer) SignedData(ticket *NPTicket) []byte {
+	data := ticket.Bytes()
+	bodyEnd := 20 + int(ticket.BodySize)
+
+	if issuer.SignsWholeTicket {
+		// the footer header plus the cipher ID and signature headers come before the signature itself
+		return data[8 : bodyEnd+16]
+	}
+
+	return data[16:bodyEnd]
+}
+
+func sha1Digest(data []byte) []byte {
+	digest := sha1.Sum(data)
+	return digest[:]
+}
+
+func sha224Digest(data []byte) []byte {
+	digest := sha256.Sum224(data)
+	return digest[:]
+}
+
+// builds an issuer from a hex-encoded uncompressed public key
+func newNPTicketIssuer(issuer NPTicketIssuer, publicKeyHex string) (NPTicketIssuer, error) {
+	publicKey, err := hex.DecodeString(strings.TrimSpace(publicKeyHex))
+	if err != nil {
+		return issuer, fmt.Errorf("%s public key is not hex: %v", issuer.Name, err)
+	}
+
+	x, y, ok := issuer.Curve.UnmarshalPublicKey(publicKey)
+	if !ok {
+		return issuer, fmt.Errorf("%s public key is not an uncompressed point on %s", issuer.Name, issuer.Curve.Name)
+	}
+
+	issuer.PublicKeyX = x
+	issuer.PublicKeyY = y
+	return issuer, nil
+}
+
+// the issuer for tickets from the real PSN, signed with ECDSA-SHA1 on secp192r1
+func PSNTicketIssuer(publicKeyHex string) (NPTicketIssuer, error) {
+	return newNPTicketIssuer(NPTicketIssuer{
+		Name:             "PSN",
+		ConsoleType:      1,
+		CipherID:         PSNTicketCipherID,
+		Curve:            Secp192r1,
+		Hash:             sha1Digest,
+		SignsWholeTicket: true,
+	}, publicKeyHex)
+}
+
+// the issuer for tickets from RPCN, signed with ECDSA-SHA224 on secp224k1
+func RPCNTicketIssuer(publicKeyHex string) (NPTicketIssuer, error) {
+	return newNPTicketIssuer(NPTicketIssuer{
+		Name:        "RPCN",
+		ConsoleType: 3,
+		CipherID:    RPCNTicketCipherID,
+		Curve:       Secp224k1,
+		Hash:        sha224Digest,
+	}, publicKeyHex)
+}
+
+// verifies NPTickets locally, without calling out to anything
+type NPTicketVerifier struct {
+	Issuers []NPTicketIssuer
+
+	// the service IDs tickets are accepted for, e.g. UP8802-BLUS30463_00, any service is accepted if empty
+	ServiceIDs []string
+
+	// the current time, time.Now if nil
+	Now func() time.Time
+}
+
+// creates a verifier from NPTICKETPSNPUBLICKEY and NPTICKETRPCNPUBLICKEY, which are hex-encoded uncompressed public keys
+// NPTICKETSERVICEIDS optionally restricts which service IDs are accepted, separated by commas
+// returns nil if neither key is set
+func NewNPTicketVerifierFromEnv() (*NPTicketVerifier, error) {
+	verifier := &NPTicketVerifier{}
+
+	if key := os.Getenv("NPTICKETPSNPUBLICKEY"); key != "" {
+		issuer, err := PSNTicketIssuer(key)
+		if err != nil {
+			return nil, err
+		}
+		verifier.Issuers = append(verifier.Issuers, issuer)
+	}
+
+	if key := os.Getenv("NPTICKETRPCNPUBLICKEY"); key != "" {
+		issuer, err := RPCNTicketIssuer(key)
+		if err != nil {
+			return nil, err
+		}
+		verifier.Issuers = append(verifier.Issuers, issuer)
+	}
+
+	if len(verifier.Issuers) == 0 {
+		return nil, nil
+	}
+
+	for _, serviceID := range strings.Split(os.Getenv("NPTICKETSERVICEIDS"), ",") {
+		if serviceID = strings.TrimSpace(serviceID); serviceID != "" {
+			verifier.ServiceIDs = append(verifier.ServiceIDs, serviceID)
+		}
+	}
+
+	return verifier, nil
+}
+
+// whether there is a key for tickets presented by the console type
+func (verifier *NPTicketVerifier) HandlesConsoleType(consoleType int) bool {
+	for _, issuer := range verifier.Issuers {
+		if issuer.ConsoleType == consoleType {
+			return true
+		}
+	}
+	return false
+}
+
+// parses a ticket as sent in RegisterEx, including the two leading little-endian sizes, and checks that it was signed by the issuer for the console type, hasn't expired and is for an accepted service
+func (verifier *NPTicketVerifier) Verify(ticketData []byte, consoleType int) (NPTicketBody, error) {
+	deserializer := &NPTicketDeserializer{}
+	ticket, err := deserializer.Deserialize(ticketData)
+	if err != nil {
+		return NPTicketBody{}, err
+	}
+
+	var issuer *NPTicketIssuer
+	for i := range verifier.Issuers {
+		if verifier.Issuers[i].CipherID == ticket.Footer.CipherID && verifier.Issuers[i].ConsoleType == consoleType {
+			issuer = &verifier.Issuers[i]
+			break
+		}
+	}
+	if issuer == nil {
+		return NPTicketBody{}, fmt.Errorf("%w: cipher ID %08X for console type %d", ErrUnknownTicketIssuer, ticket.Footer.CipherID, consoleType)
+	}
+
+	digest := issuer.Hash(issuer.SignedData(&ticket))
+	if !issuer.Curve.Verify(issuer.PublicKeyX, issuer.PublicKeyY, digest, ticket.Footer.Signature) {
+		return NPTicketBody{}, fmt.Errorf("%w: not signed by %s", ErrInvalidTicketSignature, issuer.Name)
+	}
+
+	// only trust what's in the body once the signature checks out
+	body, err := ticket.ParseBody()
+	if err != nil {
+		return NPTicketBody{}, err
+	}
+
+	now := time.Now()
+	if verifier.Now != nil {
+		now = verifier.Now()
+	}
+	if now.After(body.ExpiresAt) {
+		return body, fmt.Errorf("%w: expired at %v", ErrTicketExpired, body.ExpiresAt)
+	}
+
+	if len(verifier.ServiceIDs) != 0 {
+		accepted := false
+		for _, serviceID := range verifier.ServiceIDs {
+			if body.ServiceID == serviceID {
+				accepted = true
+				break
+			}
+		}
+		if !accepted {
+			return body, fmt.Errorf("%w: %q", ErrWrongTicketService, body.ServiceID)
+		}
+	}
+
+	return body, nil
+}
@@ -1,32 +1,172 @@
 package authentication
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-type TicketVerifier struct {
-	TicketVerifierEndpoint string
+const (
+	defaultRemoteVerifierTimeout      = 5 * time.Second
+	defaultRemoteVerifierRetries      = 2
+	defaultRemoteVerifierBackoff      = 250 * time.Millisecond
+	defaultRemoteVerifierFailureLimit = 5
+	defaultRemoteVerifierCooldown     = 30 * time.Second
+)
+
+// asks an external HTTP service whether a ticket is valid
+// the ticket is POSTed as a form with the base64 ticket in "ticket" and the console type in "platform"
+// 200 means the ticket is valid, any other 4xx means it isn't, and anything else is retried with backoff
+// after enough consecutive failures the circuit breaker opens and tickets are passed on to the next verifier without calling out
+type RemoteVerifier struct {
+	Endpoint string
+
+	Timeout      time.Duration // per attempt
+	Retries      int           // attempts after the first one
+	Backoff      time.Duration // before the first retry, doubled for each one after
+	FailureLimit int           // consecutive failures before the breaker opens
+	Cooldown     time.Duration // how long the breaker stays open
+
+	// the HTTP client used to call the endpoint, http.DefaultClient if nil
+	Client *http.Client
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	openUntil           time.Time
 }
 
-func (verifier *TicketVerifier) VerifyTicket(ticketData []byte, consoleType int) bool {
-	encodedTicket := base64.StdEncoding.EncodeToString(ticketData)
-	urlEncodedTicket := url.QueryEscape(encodedTicket)
+// creates a remote verifier with the default timeout, retries and circuit breaker
+func NewRemoteVerifier(endpoint string) *RemoteVerifier {
+	return &RemoteVerifier{
+		Endpoint:     endpoint,
+		Timeout:      defaultRemoteVerifierTimeout,
+		Retries:      defaultRemoteVerifierRetries,
+		Backoff:      defaultRemoteVerifierBackoff,
+		FailureLimit: defaultRemoteVerifierFailureLimit,
+		Cooldown:     defaultRemoteVerifierCooldown,
+	}
+}
 
-	verifyURL := fmt.Sprintf("%s?ticket=%s&platform=%d", verifier.TicketVerifierEndpoint, urlEncodedTicket, consoleType)
-	resp, err := http.Get(verifyURL)
+func (verifier *RemoteVerifier) Name() string {
+	return "remote"
+}
+
+// the part of the ticket the external service expects, which is what older versions sent
+// PS3 and RPCS3 tickets lose the two leading sizes, Wii tokens are sent as is, and Xbox has no ticket to send
+func remoteTicketData(ticketData []byte, consoleType int) []byte {
+	switch consoleType {
+	case 1, 3:
+		if len(ticketData) < 8 {
+			return nil
+		}
+		return ticketData[8:]
+	case 2:
+		return ticketData
+	default:
+		return nil
+	}
+}
+
+// whether calls are currently being skipped because the service kept failing
+func (verifier *RemoteVerifier) breakerOpen(now time.Time) bool {
+	verifier.mu.Lock()
+	defer verifier.mu.Unlock()
+
+	return now.Before(verifier.openUntil)
+}
+
+func (verifier *RemoteVerifier) recordResult(failed bool, now time.Time) {
+	verifier.mu.Lock()
+	defer verifier.mu.Unlock()
+
+	if !failed {
+		verifier.consecutiveFailures = 0
+		return
+	}
+
+	verifier.consecutiveFailures++
+	if verifier.FailureLimit > 0 && verifier.consecutiveFailures >= verifier.FailureLimit {
+		// once the cooldown is over the next ticket goes through, and a single failure opens the breaker again
+		verifier.openUntil = now.Add(verifier.Cooldown)
+		verifier.consecutiveFailures = verifier.FailureLimit - 1
+	}
+}
+
+// makes one request, returning whether the service gave a verdict and what it was
+func (verifier *RemoteVerifier) attempt(ctx context.Context, form url.Values) (bool, error) {
+	client := verifier.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	timeout := verifier.Timeout
+	if timeout <= 0 {
+		timeout = defaultRemoteVerifierTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, verifier.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
 	if err != nil {
-		log.Println("Failed to verify ticket:", err)
-		return false
+		return false, err
 	}
 	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
 
-	if resp.StatusCode == http.StatusOK {
-		return true
-	} else {
-		return false
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true, nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusRequestTimeout:
+		return true, fmt.Errorf("%w: verifier responded with status %d", ErrTicketRejected, resp.StatusCode)
+	default:
+		return false, fmt.Errorf("verifier responded with status %d", resp.StatusCode)
 	}
 }
+
+func (verifier *RemoteVerifier) VerifyTicket(ctx context.Context, ticketData []byte, consoleType int) error {
+	if verifier.breakerOpen(time.Now()) {
+		return fmt.Errorf("%w: %s has failed too many times in a row", ErrVerifierUnavailable, verifier.Endpoint)
+	}
+
+	form := url.Values{}
+	form.Set("ticket", base64.StdEncoding.EncodeToString(remoteTicketData(ticketData, consoleType)))
+	form.Set("platform", strconv.Itoa(consoleType))
+
+	backoff := verifier.Backoff
+	var lastErr error
+
+	for attempt := 0; attempt <= verifier.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				verifier.recordResult(true, time.Now())
+				return fmt.Errorf("%w: %v", ErrVerifierUnavailable, ctx.Err())
+			}
+			backoff *= 2
+		}
+
+		gotVerdict, err := verifier.attempt(ctx, form)
+		if gotVerdict {
+			verifier.recordResult(false, time.Now())
+			return err
+		}
+		lastErr = err
+	}
+
+	verifier.recordResult(true, time.Now())
+	return fmt.Errorf("%w: %v after %d attempts", ErrVerifierUnavailable, lastErr, verifier.Retries+1)
+}
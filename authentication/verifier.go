@@ -0,0 +1,353 @@
+package authentication
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// returned by a verifier that can't give a verdict on a ticket, e.g. because it's down or doesn't handle the console type
+	// the chain moves on to the next verifier when it sees this
+	ErrVerifierUnavailable = errors.New("ticket verifier unavailable")
+
+	// returned by a verifier that looked at a ticket and decided it isn't valid
+	ErrTicketRejected = errors.New("ticket rejected")
+)
+
+// something that can check the ticket a console presents in RegisterEx
+// VerifyTicket returns nil if the ticket is valid, an error wrapping ErrVerifierUnavailable if it can't tell, and any other error if the ticket is invalid
+type Verifier interface {
+	Name() string
+	VerifyTicket(ctx context.Context, ticketData []byte, consoleType int) error
+}
+
+// accepts every ticket, for development servers and as a fail-open last resort at the end of a chain
+type AllowAllVerifier struct{}
+
+func (verifier *AllowAllVerifier) Name() string {
+	return "allowall"
+}
+
+func (verifier *AllowAllVerifier) VerifyTicket(ctx context.Context, ticketData []byte, consoleType int) error {
+	return nil
+}
+
+func (verifier *NPTicketVerifier) Name() string {
+	return "npticket"
+}
+
+// lets the local NPTicket checks be part of a chain, tickets from consoles without a configured issuer are passed on
+// chains only give a verdict, so RegisterEx parses the body of an accepted ticket itself to check whose it is
+func (verifier *NPTicketVerifier) VerifyTicket(ctx context.Context, ticketData []byte, consoleType int) error {
+	if !verifier.HandlesConsoleType(consoleType) {
+		return fmt.Errorf("%w: no NPTicket issuer for console type %d", ErrVerifierUnavailable, consoleType)
+	}
+
+	_, err := verifier.Verify(ticketData, consoleType)
+	return err
+}
+
+// the verdicts verifiers and chains are counted by
+const (
+	VerdictAccepted    = "accepted"
+	VerdictRejected    = "rejected"
+	VerdictUnavailable = "unavailable"
+	VerdictCached      = "cached"
+)
+
+func verdictForError(err error) string {
+	switch {
+	case err == nil:
+		return VerdictAccepted
+	case errors.Is(err, ErrVerifierUnavailable):
+		return VerdictUnavailable
+	default:
+		return VerdictRejected
+	}
+}
+
+// how many times a verifier gave a verdict for a console type
+// the verifier is "chain" for the final verdict of a whole chain
+type VerifierMetric struct {
+	ConsoleType int    `json:"console_type"`
+	Verifier    string `json:"verifier"`
+	Verdict     string `json:"verdict"`
+	Count       uint64 `json:"count"`
+}
+
+type verifierMetricKey struct {
+	consoleType int
+	verifier    string
+	verdict     string
+}
+
+// counts the verdicts given by verifiers and chains
+type VerifierMetrics struct {
+	mu     sync.Mutex
+	counts map[verifierMetricKey]uint64
+}
+
+// the metrics every chain created from the environment records to
+var DefaultVerifierMetrics = NewVerifierMetrics()
+
+func NewVerifierMetrics() *VerifierMetrics {
+	return &VerifierMetrics{counts: make(map[verifierMetricKey]uint64)}
+}
+
+func (metrics *VerifierMetrics) record(consoleType int, verifier string, verdict string) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	metrics.counts[verifierMetricKey{consoleType, verifier, verdict}]++
+}
+
+// every count recorded so far, sorted by console type, verifier and verdict
+func (metrics *VerifierMetrics) Snapshot() []VerifierMetric {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	snapshot := make([]VerifierMetric, 0, len(metrics.counts))
+	for key, count := range metrics.counts {
+		snapshot = append(snapshot, VerifierMetric{ConsoleType: key.consoleType, Verifier: key.verifier, Verdict: key.verdict, Count: count})
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		a, b := snapshot[i], snapshot[j]
+		if a.ConsoleType != b.ConsoleType {
+			return a.ConsoleType < b.ConsoleType
+		}
+		if a.Verifier != b.Verifier {
+			return a.Verifier < b.Verifier
+		}
+		return a.Verdict < b.Verdict
+	})
+
+	return snapshot
+}
+
+// the most tickets the cache keeps before starting over
+const maxCachedVerifications = 4096
+
+// remembers tickets that were recently accepted, so reconnecting doesn't mean verifying the same ticket again
+// rejections are never cached
+type VerificationCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[[sha256.Size]byte]time.Time // expiry by ticket hash
+}
+
+func NewVerificationCache(ttl time.Duration) *VerificationCache {
+	return &VerificationCache{ttl: ttl, entries: make(map[[sha256.Size]byte]time.Time)}
+}
+
+func verificationCacheKey(ticketData []byte, consoleType int) [sha256.Size]byte {
+	return sha256.Sum256(append([]byte{byte(consoleType)}, ticketData...))
+}
+
+func (cache *VerificationCache) contains(ticketData []byte, consoleType int, now time.Time) bool {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	key := verificationCacheKey(ticketData, consoleType)
+	expiry, ok := cache.entries[key]
+	if ok && now.After(expiry) {
+		delete(cache.entries, key)
+		return false
+	}
+
+	return ok
+}
+
+func (cache *VerificationCache) add(ticketData []byte, consoleType int, now time.Time) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if len(cache.entries) >= maxCachedVerifications {
+		for key, expiry := range cache.entries {
+			if now.After(expiry) {
+				delete(cache.entries, key)
+			}
+		}
+
+		// everything is still fresh, so make room the simple way
+		if len(cache.entries) >= maxCachedVerifications {
+			cache.entries = make(map[[sha256.Size]byte]time.Time)
+		}
+	}
+
+	cache.entries[verificationCacheKey(ticketData, consoleType)] = now.Add(cache.ttl)
+}
+
+// runs verifiers in order until one of them accepts or rejects the ticket
+// if none of them can give a verdict the ticket is rejected, put an AllowAllVerifier last to let it through instead
+type VerifierChain struct {
+	ConsoleType int
+	Verifiers   []Verifier
+	Cache       *VerificationCache // optional
+	Metrics     *VerifierMetrics   // optional
+}
+
+func (chain *VerifierChain) Name() string {
+	return "chain"
+}
+
+func (chain *VerifierChain) VerifyTicket(ctx context.Context, ticketData []byte, consoleType int) error {
+	_, err := chain.Verify(ctx, ticketData, consoleType)
+	return err
+}
+
+// like VerifyTicket, but also returns the name of the verifier that accepted the ticket
+// a ticket accepted from the cache was accepted by something other than allowall, since those aren't cached
+func (chain *VerifierChain) Verify(ctx context.Context, ticketData []byte, consoleType int) (string, error) {
+	now := time.Now()
+
+	if chain.Cache != nil && chain.Cache.contains(ticketData, consoleType, now) {
+		chain.recordVerdict(consoleType, chain.Name(), VerdictCached)
+		return VerdictCached, nil
+	}
+
+	for _, verifier := range chain.Verifiers {
+		err := verifier.VerifyTicket(ctx, ticketData, consoleType)
+		verdict := verdictForError(err)
+		chain.recordVerdict(consoleType, verifier.Name(), verdict)
+
+		if verdict == VerdictUnavailable {
+			log.Printf("Ticket verifier %s could not verify a ticket for console type %d, trying the next one: %v", verifier.Name(), consoleType, err)
+			continue
+		}
+
+		chain.recordVerdict(consoleType, chain.Name(), verdict)
+
+		// allowall never looked at the ticket, so there is nothing worth remembering
+		if _, allowAll := verifier.(*AllowAllVerifier); err == nil && chain.Cache != nil && !allowAll {
+			chain.Cache.add(ticketData, consoleType, now)
+		}
+
+		return verifier.Name(), err
+	}
+
+	chain.recordVerdict(consoleType, chain.Name(), VerdictUnavailable)
+	return "", fmt.Errorf("%w: none of the %d verifiers for console type %d gave a verdict", ErrVerifierUnavailable, len(chain.Verifiers), consoleType)
+}
+
+func (chain *VerifierChain) recordVerdict(consoleType int, verifier string, verdict string) {
+	if chain.Metrics != nil {
+		chain.Metrics.record(consoleType, verifier, verdict)
+	}
+}
+
+// the environment variables that configure the chain for each console type, and the switches older versions used instead
+var verifierChainEnvVars = map[int][2]string{
+	0: {"XBOX360TICKETVERIFIERS", "VERIFY_XBOX360_TICKETS"},
+	1: {"PS3TICKETVERIFIERS", "VERIFY_PS3_TICKETS"},
+	2: {"WIITICKETVERIFIERS", "VERIFY_WII_TICKETS"},
+	3: {"RPCS3TICKETVERIFIERS", "VERIFY_RPCS3_TICKETS"},
+}
+
+// reads a duration like "5s" from the environment, falling back to a default if it isn't set
+func durationFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a duration: %v", name, err)
+	}
+	return duration, nil
+}
+
+// creates the verifier chain for each console type from the environment
+// XBOX360TICKETVERIFIERS, PS3TICKETVERIFIERS, WIITICKETVERIFIERS and RPCS3TICKETVERIFIERS list the verifiers to try in order, separated by commas:
+//
+//	npticket  local NPTicket checks, see NewNPTicketVerifierFromEnv
+//	remote    the service at TICKETVERIFIERENDPOINT, with TICKETVERIFIERTIMEOUT per attempt and TICKETVERIFIERRETRIES retries
+//	allowall  accepts everything
+//
+// a console type without a list falls back to the old VERIFY_*_TICKETS switch, which means npticket then remote, using whichever is configured
+// console types without any verifiers are left out, and their tickets aren't checked
+// accepted tickets are cached for TICKETVERIFICATIONCACHETTL, 5m by default, 0 turns the cache off
+func NewVerifierChainsFromEnv() (map[int]*VerifierChain, error) {
+	npTicketVerifier, err := NewNPTicketVerifierFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var remoteVerifier *RemoteVerifier
+	if endpoint := os.Getenv("TICKETVERIFIERENDPOINT"); endpoint != "" {
+		remoteVerifier = NewRemoteVerifier(endpoint)
+
+		if remoteVerifier.Timeout, err = durationFromEnv("TICKETVERIFIERTIMEOUT", defaultRemoteVerifierTimeout); err != nil {
+			return nil, err
+		}
+		if retries := os.Getenv("TICKETVERIFIERRETRIES"); retries != "" {
+			if remoteVerifier.Retries, err = strconv.Atoi(retries); err != nil || remoteVerifier.Retries < 0 {
+				return nil, fmt.Errorf("TICKETVERIFIERRETRIES must be a non-negative number, got %q", retries)
+			}
+		}
+	}
+
+	cacheTTL, err := durationFromEnv("TICKETVERIFICATIONCACHETTL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	var cache *VerificationCache
+	if cacheTTL > 0 {
+		cache = NewVerificationCache(cacheTTL)
+	}
+
+	chains := map[int]*VerifierChain{}
+
+	for consoleType, envVars := range verifierChainEnvVars {
+		var names []string
+		if list := os.Getenv(envVars[0]); list != "" {
+			names = strings.Split(list, ",")
+		} else if legacy := os.Getenv(envVars[1]); legacy == "1" || legacy == "true" {
+			if npTicketVerifier != nil && npTicketVerifier.HandlesConsoleType(consoleType) {
+				names = append(names, "npticket")
+			}
+			if remoteVerifier != nil {
+				names = append(names, "remote")
+			}
+		}
+
+		chain := &VerifierChain{ConsoleType: consoleType, Cache: cache, Metrics: DefaultVerifierMetrics}
+
+		for _, name := range names {
+			switch strings.TrimSpace(name) {
+			case "npticket":
+				if npTicketVerifier == nil {
+					return nil, fmt.Errorf("%s uses npticket, but neither NPTICKETPSNPUBLICKEY nor NPTICKETRPCNPUBLICKEY is set", envVars[0])
+				}
+				chain.Verifiers = append(chain.Verifiers, npTicketVerifier)
+			case "remote":
+				if remoteVerifier == nil {
+					return nil, fmt.Errorf("%s uses remote, but TICKETVERIFIERENDPOINT is not set", envVars[0])
+				}
+				chain.Verifiers = append(chain.Verifiers, remoteVerifier)
+			case "allowall":
+				log.Printf("Tickets from console type %d can be accepted without verification", consoleType)
+				chain.Verifiers = append(chain.Verifiers, &AllowAllVerifier{})
+			case "":
+			default:
+				return nil, fmt.Errorf("%s has unknown ticket verifier %q", envVars[0], name)
+			}
+		}
+
+		if len(chain.Verifiers) != 0 {
+			chains[consoleType] = chain
+		}
+	}
+
+	return chains, nil
+}
@@ -0,0 +1,47 @@
+package binarydata
+
+import (
+	"os"
+	"strings"
+)
+
+// where the filesystem store keeps binary data, BASEBINARYDATAPATH or binary_data if that isn't set
+func BasePath() string {
+	basePath := os.Getenv("BASEBINARYDATAPATH")
+
+	if basePath == "" {
+		basePath = "binary_data"
+	}
+
+	return basePath
+}
+
+// the extension binary data is stored with for each console type, since the art formats differ per console
+// RPCS3 uses the same art as PS3
+func PlatformExtension(consoleType int) (string, bool) {
+	switch consoleType {
+	case 0:
+		return "png_xbox", true
+	case 1, 3:
+		return "png_ps3", true
+	case 2:
+		return "png_wii", true
+	}
+
+	return "", false
+}
+
+// makes a client-supplied key like a setlist GUID safe to use as a directory name
+func SanitizePath(path string) string {
+	// List of invalid characters except the path separators and drive letter colon
+	invalidChars := []string{"*", "?", "\"", "<", ">", "|", "\r", "\n", "\x0a", "\x0d", "\x00", "."}
+
+	for _, char := range invalidChars {
+		path = strings.ReplaceAll(path, char, "_")
+	}
+
+	// Block path traversal sequences
+	path = strings.ReplaceAll(path, "..", "__")
+
+	return path
+}
@@ -0,0 +1,128 @@
+package binarydata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keeps binary data as files under a base path, laid out the same as the keys
+type FilesystemStore struct {
+	basePath string
+}
+
+func NewFilesystemStore(basePath string) *FilesystemStore {
+	return &FilesystemStore{basePath: filepath.Clean(basePath)}
+}
+
+// turns a key into a path, making sure it can't escape the base path
+func (store *FilesystemStore) path(key string) (string, error) {
+	filePath := filepath.Join(store.basePath, filepath.FromSlash(key))
+
+	if filePath != store.basePath && !strings.HasPrefix(filePath, store.basePath+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path: %s", filePath)
+	}
+
+	return filePath, nil
+}
+
+func (store *FilesystemStore) Put(ctx context.Context, key string, data []byte) error {
+	filePath, err := store.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	// write somewhere else first so a reader never sees half a file
+	tempPath := filePath + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, filePath)
+}
+
+func (store *FilesystemStore) Get(ctx context.Context, key string) ([]byte, error) {
+	filePath, err := store.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return data, err
+}
+
+func (store *FilesystemStore) Delete(ctx context.Context, key string) error {
+	filePath, err := store.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// clean up the directory of the setlist, battle or band once its last revision is gone, this fails harmlessly if it isn't empty
+	os.Remove(filepath.Dir(filePath))
+
+	return nil
+}
+
+func (store *FilesystemStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects := []ObjectInfo{}
+
+	// only walk the directory the prefix is in, e.g. just one setlist's revisions for "setlist_art/<guid>/"
+	root := store.basePath
+	if idx := strings.LastIndex(prefix, "/"); idx > 0 {
+		prefixPath, err := store.path(prefix[:idx])
+		if err != nil {
+			return nil, err
+		}
+		root = prefixPath
+	}
+
+	err := filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// nothing has been stored there yet
+			if errors.Is(err, fs.ErrNotExist) && filePath == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if entry.IsDir() || strings.HasSuffix(filePath, ".tmp") {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(store.basePath, filePath)
+		if err != nil {
+			return err
+		}
+
+		key := filepath.ToSlash(relativePath)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModifiedAt: info.ModTime()})
+		return nil
+	})
+
+	return objects, err
+}
@@ -0,0 +1,115 @@
+package binarydata
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// keeps binary data in MongoDB GridFS, using the key as the file name
+type GridFSStore struct {
+	bucket *gridfs.Bucket
+}
+
+// a document in the GridFS files collection
+type gridFSFile struct {
+	ID         interface{} `bson:"_id"`
+	Length     int64       `bson:"length"`
+	UploadDate time.Time   `bson:"uploadDate"`
+	Name       string      `bson:"filename"`
+}
+
+func NewGridFSStore(database *mongo.Database) (*GridFSStore, error) {
+	bucket, err := gridfs.NewBucket(database, options.GridFSBucket().SetName("binary_data"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &GridFSStore{bucket: bucket}, nil
+}
+
+// finds every file stored under the given filter
+func (store *GridFSStore) find(ctx context.Context, filter bson.M) ([]gridFSFile, error) {
+	cursor, err := store.bucket.FindContext(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var files []gridFSFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (store *GridFSStore) Put(ctx context.Context, key string, data []byte) error {
+	// GridFS keeps every upload of a file name, so remember what was there to delete it once the new upload is in
+	previous, err := store.find(ctx, bson.M{"filename": key})
+	if err != nil {
+		return err
+	}
+
+	if _, err := store.bucket.UploadFromStream(key, bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	for _, file := range previous {
+		if err := store.bucket.DeleteContext(ctx, file.ID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *GridFSStore) Get(ctx context.Context, key string) ([]byte, error) {
+	stream, err := store.bucket.OpenDownloadStreamByName(key)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	return io.ReadAll(stream)
+}
+
+func (store *GridFSStore) Delete(ctx context.Context, key string) error {
+	files, err := store.find(ctx, bson.M{"filename": key})
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := store.bucket.DeleteContext(ctx, file.ID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *GridFSStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	files, err := store.find(ctx, bson.M{"filename": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}})
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []ObjectInfo{}
+	for _, file := range files {
+		objects = append(objects, ObjectInfo{Key: file.Name, Size: file.Length, ModifiedAt: file.UploadDate})
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,225 @@
+package binarydata
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// keeps binary data in an S3-compatible object store such as AWS S3, MinIO or Cloudflare R2
+// requests use path-style addressing and are signed with AWS Signature Version 4, leave the keys empty for stores that don't need signing
+type S3Store struct {
+	Endpoint  string // e.g. https://s3.us-east-1.amazonaws.com or http://localhost:9000
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+
+	// the HTTP client used to talk to the store, http.DefaultClient if nil
+	Client *http.Client
+}
+
+// the parts of a ListObjectsV2 response that are used
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// percent-encodes a string the way signature version 4 expects, leaving slashes alone if asked to
+func s3Escape(s string, keepSlashes bool) string {
+	var escaped strings.Builder
+
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			escaped.WriteByte(b)
+		case b == '/' && keepSlashes:
+			escaped.WriteByte(b)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+
+	return escaped.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// sends a request for a key in the bucket, an empty key addresses the bucket itself
+func (store *S3Store) do(ctx context.Context, method string, key string, query map[string]string, body []byte) (*http.Response, error) {
+	canonicalURI := "/" + s3Escape(store.Bucket, false)
+	if key != "" {
+		canonicalURI += "/" + s3Escape(key, true)
+	}
+
+	queryKeys := make([]string, 0, len(query))
+	for queryKey := range query {
+		queryKeys = append(queryKeys, queryKey)
+	}
+	sort.Strings(queryKeys)
+
+	queryParts := make([]string, 0, len(queryKeys))
+	for _, queryKey := range queryKeys {
+		queryParts = append(queryParts, s3Escape(queryKey, false)+"="+s3Escape(query[queryKey], false))
+	}
+	canonicalQuery := strings.Join(queryParts, "&")
+
+	requestURL := strings.TrimRight(store.Endpoint, "/") + canonicalURI
+	if canonicalQuery != "" {
+		requestURL += "?" + canonicalQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	payloadHash := sha256.Sum256(body)
+	payloadHashHex := hex.EncodeToString(payloadHash[:])
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+
+	req.Header.Set("x-amz-content-sha256", payloadHashHex)
+	req.Header.Set("x-amz-date", amzDate)
+
+	if store.AccessKey != "" {
+		date := now.Format("20060102")
+		scope := date + "/" + store.Region + "/s3/aws4_request"
+		signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+
+		canonicalRequest := strings.Join([]string{
+			method,
+			canonicalURI,
+			canonicalQuery,
+			"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHashHex + "\nx-amz-date:" + amzDate + "\n",
+			signedHeaders,
+			payloadHashHex,
+		}, "\n")
+		canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+		stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+		signingKey := hmacSHA256([]byte("AWS4"+store.SecretKey), date)
+		signingKey = hmacSHA256(signingKey, store.Region)
+		signingKey = hmacSHA256(signingKey, "s3")
+		signingKey = hmacSHA256(signingKey, "aws4_request")
+
+		req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+			store.AccessKey, scope, signedHeaders, hex.EncodeToString(hmacSHA256(signingKey, stringToSign))))
+	}
+
+	client := store.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return client.Do(req)
+}
+
+// turns an unexpected response into an error
+func s3Error(method string, key string, res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return fmt.Errorf("s3 %s %s failed with status %d: %s", method, key, res.StatusCode, strings.TrimSpace(string(body)))
+}
+
+func (store *S3Store) Put(ctx context.Context, key string, data []byte) error {
+	res, err := store.do(ctx, http.MethodPut, key, nil, data)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return s3Error(http.MethodPut, key, res)
+	}
+
+	return nil
+}
+
+func (store *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	res, err := store.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, s3Error(http.MethodGet, key, res)
+	}
+
+	return io.ReadAll(res.Body)
+}
+
+func (store *S3Store) Delete(ctx context.Context, key string) error {
+	res, err := store.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusNotFound {
+		return s3Error(http.MethodDelete, key, res)
+	}
+
+	return nil
+}
+
+func (store *S3Store) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects := []ObjectInfo{}
+	continuationToken := ""
+
+	for {
+		query := map[string]string{"list-type": "2", "prefix": prefix}
+		if continuationToken != "" {
+			query["continuation-token"] = continuationToken
+		}
+
+		res, err := store.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode != http.StatusOK {
+			err := s3Error(http.MethodGet, "?list-type=2", res)
+			res.Body.Close()
+			return nil, err
+		}
+
+		var result s3ListBucketResult
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			objects = append(objects, ObjectInfo{Key: object.Key, Size: object.Size, ModifiedAt: object.LastModified})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
@@ -0,0 +1,115 @@
+package binarydata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// returned by Store.Get when there is nothing stored under a key
+var ErrNotFound = errors.New("binary data not found")
+
+// what a store knows about an object without reading it
+type ObjectInfo struct {
+	Key        string
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// somewhere binary data blobs can be kept, objects are keyed by <type>/<key>/<revision>.<platform extension>
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// returns ErrNotFound if nothing is stored under the key
+	Get(ctx context.Context, key string) ([]byte, error)
+	// deleting a key that doesn't exist is not an error
+	Delete(ctx context.Context, key string) error
+	// lists every object whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+var (
+	defaultStore   Store
+	defaultStoreMu sync.RWMutex
+)
+
+// sets the store the servers and REST API keep binary data in
+func SetDefaultStore(store Store) {
+	defaultStoreMu.Lock()
+	defer defaultStoreMu.Unlock()
+
+	defaultStore = store
+}
+
+// the store binary data is kept in, the filesystem under BasePath unless SetDefaultStore was called
+func DefaultStore() Store {
+	defaultStoreMu.RLock()
+	defer defaultStoreMu.RUnlock()
+
+	if defaultStore == nil {
+		return NewFilesystemStore(BasePath())
+	}
+
+	return defaultStore
+}
+
+// builds the store named by BINARYDATASTORE, which is filesystem (the default), gridfs or s3
+// the S3 store is configured with BINARYDATAS3ENDPOINT, BINARYDATAS3BUCKET, BINARYDATAS3REGION, BINARYDATAS3ACCESSKEY and BINARYDATAS3SECRETKEY
+func NewStoreFromEnv(database *mongo.Database) (Store, error) {
+	switch os.Getenv("BINARYDATASTORE") {
+	case "", "filesystem":
+		return NewFilesystemStore(BasePath()), nil
+	case "gridfs":
+		return NewGridFSStore(database)
+	case "s3":
+		store := &S3Store{
+			Endpoint:  os.Getenv("BINARYDATAS3ENDPOINT"),
+			Bucket:    os.Getenv("BINARYDATAS3BUCKET"),
+			Region:    os.Getenv("BINARYDATAS3REGION"),
+			AccessKey: os.Getenv("BINARYDATAS3ACCESSKEY"),
+			SecretKey: os.Getenv("BINARYDATAS3SECRETKEY"),
+		}
+		if store.Endpoint == "" || store.Bucket == "" {
+			return nil, errors.New("the s3 binary data store needs BINARYDATAS3ENDPOINT and BINARYDATAS3BUCKET")
+		}
+		if store.Region == "" {
+			store.Region = "us-east-1"
+		}
+		return store, nil
+	}
+
+	return nil, fmt.Errorf("unknown binary data store %q", os.Getenv("BINARYDATASTORE"))
+}
+
+// builds the key one revision of some binary data is stored under
+// key has to be sanitized already if it came from a client
+func Key(dataType string, key string, revision int64, platformExtension string) string {
+	return path.Join(dataType, key, fmt.Sprintf("%d.%s", revision, platformExtension))
+}
+
+// splits a key built by Key back into its parts, returns false if it isn't one
+func ParseKey(objectKey string) (dataType string, key string, revision int64, platformExtension string, ok bool) {
+	parts := strings.Split(objectKey, "/")
+	if len(parts) != 3 {
+		return "", "", 0, "", false
+	}
+
+	revisionStr, platformExtension, found := strings.Cut(parts[2], ".")
+	if !found {
+		return "", "", 0, "", false
+	}
+
+	revision, err := strconv.ParseInt(revisionStr, 10, 64)
+	if err != nil {
+		return "", "", 0, "", false
+	}
+
+	return parts[0], parts[1], revision, platformExtension, true
+}
@@ -0,0 +1,534 @@
+package binarydata
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// the art the game uploads is a Milo bitmap: a 32 byte header followed by the texture data, which is laid out differently on each console
+//
+//	0x00 u8   version, always 1
+//	0x01 u8   bits per pixel
+//	0x02 u32  encoding
+//	0x06 u8   mip map count
+//	0x07 u16  width
+//	0x09 u16  height
+//	0x0B u16  bytes per line
+//	0x0D      padding up to 0x20
+//
+// the header is big-endian on Wii and little-endian everywhere else
+// Xbox 360 textures are DXT with every 16-bit word byte-swapped, PS3 textures are plain DXT, and Wii textures are CMPR (DXT1 in 8x8 tiles)
+const textureHeaderSize = 0x20
+
+const (
+	textureEncodingRGBA = 3
+	textureEncodingDXT1 = 8
+	textureEncodingDXT5 = 24
+	textureEncodingCMPR = 72
+)
+
+// the largest texture that will be decoded, art in RB3 is at most 512x512
+const maxTextureDimension = 2048
+
+// the largest art a player can upload
+const maxUploadDimension = 512
+
+var ErrUnsupportedTexture = errors.New("unsupported texture format")
+
+// returned by ValidateTexture for uploads that aren't a well-formed texture for the console that sent them
+var ErrInvalidTexture = errors.New("invalid texture")
+
+// every console-specific art format, one per console type
+var PlatformExtensions = []string{"png_xbox", "png_ps3", "png_wii"}
+
+type textureHeader struct {
+	Version      int
+	BitsPerPixel int
+	Encoding     int
+	MipMaps      int
+	Width        int
+	Height       int
+}
+
+func textureByteOrder(platformExtension string) binary.ByteOrder {
+	if platformExtension == "png_wii" {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+func readTextureHeader(data []byte, platformExtension string) (textureHeader, error) {
+	if len(data) < textureHeaderSize {
+		return textureHeader{}, errors.New("texture is too short for its header")
+	}
+
+	order := textureByteOrder(platformExtension)
+	header := textureHeader{
+		Version:      int(data[0]),
+		BitsPerPixel: int(data[1]),
+		Encoding:     int(order.Uint32(data[2:6])),
+		MipMaps:      int(data[6]),
+		Width:        int(order.Uint16(data[7:9])),
+		Height:       int(order.Uint16(data[9:11])),
+	}
+
+	if header.Width == 0 || header.Height == 0 || header.Width > maxTextureDimension || header.Height > maxTextureDimension {
+		return header, fmt.Errorf("invalid texture size %dx%d", header.Width, header.Height)
+	}
+
+	return header, nil
+}
+
+func writeTextureHeader(header textureHeader, platformExtension string) []byte {
+	order := textureByteOrder(platformExtension)
+	data := make([]byte, textureHeaderSize)
+
+	data[0] = 1
+	data[1] = byte(header.BitsPerPixel)
+	order.PutUint32(data[2:6], uint32(header.Encoding))
+	data[6] = 0 // no mip maps
+	order.PutUint16(data[7:9], uint16(header.Width))
+	order.PutUint16(data[9:11], uint16(header.Height))
+	order.PutUint16(data[11:13], uint16(header.Width*header.BitsPerPixel/8))
+
+	return data
+}
+
+// rounds up to a multiple of n
+func roundUp(value int, n int) int {
+	return (value + n - 1) / n * n
+}
+
+// how many bytes one mip level of a texture takes up, or false if the encoding isn't one the console uses
+func textureLevelSize(header textureHeader, width int, height int, platformExtension string) (int, bool) {
+	switch {
+	case header.Encoding == textureEncodingRGBA && header.BitsPerPixel == 32 && platformExtension != "png_wii":
+		return width * height * 4, true
+	case header.Encoding == textureEncodingDXT1 && header.BitsPerPixel == 4 && platformExtension != "png_wii":
+		return roundUp(width, 4) * roundUp(height, 4) / 2, true
+	case header.Encoding == textureEncodingDXT5 && header.BitsPerPixel == 8 && platformExtension != "png_wii":
+		return roundUp(width, 4) * roundUp(height, 4), true
+	case header.Encoding == textureEncodingCMPR && header.BitsPerPixel == 4 && platformExtension == "png_wii":
+		return roundUp(width, 8) * roundUp(height, 8) / 2, true
+	}
+	return 0, false
+}
+
+// checks that an upload is a texture the console that sent it could have made
+// the header has to be well-formed, the size has to be something RB3 uses, and the data has to be as long as the header says it is
+func ValidateTexture(data []byte, platformExtension string) error {
+	header, err := readTextureHeader(data, platformExtension)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTexture, err)
+	}
+
+	if header.Version != 1 {
+		return fmt.Errorf("%w: unknown header version %d", ErrInvalidTexture, header.Version)
+	}
+
+	if header.Width > maxUploadDimension || header.Height > maxUploadDimension {
+		return fmt.Errorf("%w: %dx%d is larger than %dx%d", ErrInvalidTexture, header.Width, header.Height, maxUploadDimension, maxUploadDimension)
+	}
+
+	// every level halves in size, so there can't be more of them than there are bits in the largest side
+	if header.MipMaps > 9 {
+		return fmt.Errorf("%w: %d mip maps", ErrInvalidTexture, header.MipMaps)
+	}
+
+	expected := textureHeaderSize
+	width, height := header.Width, header.Height
+	for level := 0; level <= header.MipMaps; level++ {
+		size, ok := textureLevelSize(header, width, height, platformExtension)
+		if !ok {
+			return fmt.Errorf("%w: encoding %d at %d bpp is not used on %s", ErrInvalidTexture, header.Encoding, header.BitsPerPixel, platformExtension)
+		}
+		expected += size
+		width, height = max(width/2, 1), max(height/2, 1)
+	}
+
+	// some tools pad the end of the file, but anything more than another header's worth is not a texture
+	if len(data) < expected || len(data) > expected+textureHeaderSize {
+		return fmt.Errorf("%w: %d bytes for a %dx%d texture that should be %d", ErrInvalidTexture, len(data), header.Width, header.Height, expected)
+	}
+
+	return nil
+}
+
+// decodes art uploaded from a console into a canonical RGBA image
+func DecodeTexture(data []byte, platformExtension string) (*image.NRGBA, error) {
+	header, err := readTextureHeader(data, platformExtension)
+	if err != nil {
+		return nil, err
+	}
+
+	pixels := data[textureHeaderSize:]
+	img := image.NewNRGBA(image.Rect(0, 0, header.Width, header.Height))
+
+	switch {
+	case header.Encoding == textureEncodingRGBA && header.BitsPerPixel == 32:
+		if len(pixels) < header.Width*header.Height*4 {
+			return nil, errors.New("texture is too short for its size")
+		}
+		copy(img.Pix, pixels[:header.Width*header.Height*4])
+
+	case header.Encoding == textureEncodingDXT1 && platformExtension != "png_wii",
+		header.Encoding == textureEncodingDXT5 && platformExtension != "png_wii":
+		blockSize := 8
+		if header.Encoding == textureEncodingDXT5 {
+			blockSize = 16
+		}
+		blocksWide := roundUp(header.Width, 4) / 4
+		blocksHigh := roundUp(header.Height, 4) / 4
+		if len(pixels) < blocksWide*blocksHigh*blockSize {
+			return nil, errors.New("texture is too short for its size")
+		}
+
+		block := make([]byte, blockSize)
+		for by := 0; by < blocksHigh; by++ {
+			for bx := 0; bx < blocksWide; bx++ {
+				offset := (by*blocksWide + bx) * blockSize
+				copy(block, pixels[offset:offset+blockSize])
+				if platformExtension == "png_xbox" {
+					swap16(block)
+				}
+
+				var colors [16]color.NRGBA
+				if header.Encoding == textureEncodingDXT5 {
+					colors = decodeDXT5Block(block)
+				} else {
+					colors = decodeDXT1Block(block)
+				}
+				setBlock(img, bx*4, by*4, colors)
+			}
+		}
+
+	case header.Encoding == textureEncodingCMPR && platformExtension == "png_wii":
+		tilesWide := roundUp(header.Width, 8) / 8
+		tilesHigh := roundUp(header.Height, 8) / 8
+		if len(pixels) < tilesWide*tilesHigh*32 {
+			return nil, errors.New("texture is too short for its size")
+		}
+
+		offset := 0
+		for ty := 0; ty < tilesHigh; ty++ {
+			for tx := 0; tx < tilesWide; tx++ {
+				// each tile is 4 DXT1 blocks, left to right then top to bottom
+				for sub := 0; sub < 4; sub++ {
+					block := cmprToDXT1(pixels[offset : offset+8])
+					offset += 8
+					setBlock(img, tx*8+(sub%2)*4, ty*8+(sub/2)*4, decodeDXT1Block(block))
+				}
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: encoding %d at %d bpp for %s", ErrUnsupportedTexture, header.Encoding, header.BitsPerPixel, platformExtension)
+	}
+
+	return img, nil
+}
+
+// encodes a canonical image as art for a console
+// Xbox and PS3 get DXT5 so alpha survives, Wii gets CMPR which only has 1-bit alpha
+func EncodeTexture(img image.Image, platformExtension string) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 || width > maxTextureDimension || height > maxTextureDimension {
+		return nil, fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+
+	nrgba := toNRGBA(img)
+
+	switch platformExtension {
+	case "png_xbox", "png_ps3":
+		data := writeTextureHeader(textureHeader{BitsPerPixel: 8, Encoding: textureEncodingDXT5, Width: width, Height: height}, platformExtension)
+		for by := 0; by < roundUp(height, 4); by += 4 {
+			for bx := 0; bx < roundUp(width, 4); bx += 4 {
+				block := encodeDXT5Block(getBlock(nrgba, bx, by))
+				if platformExtension == "png_xbox" {
+					swap16(block)
+				}
+				data = append(data, block...)
+			}
+		}
+		return data, nil
+
+	case "png_wii":
+		data := writeTextureHeader(textureHeader{BitsPerPixel: 4, Encoding: textureEncodingCMPR, Width: width, Height: height}, platformExtension)
+		for ty := 0; ty < roundUp(height, 8); ty += 8 {
+			for tx := 0; tx < roundUp(width, 8); tx += 8 {
+				for sub := 0; sub < 4; sub++ {
+					block := encodeDXT1Block(getBlock(nrgba, tx+(sub%2)*4, ty+(sub/2)*4), true)
+					data = append(data, dxt1ToCMPR(block)...)
+				}
+			}
+		}
+		return data, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedTexture, platformExtension)
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) {
+		return nrgba
+	}
+
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			nrgba.Set(x, y, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+
+	return nrgba
+}
+
+// swaps the bytes of every 16-bit word, the Xbox 360 stores DXT blocks this way
+func swap16(data []byte) {
+	for i := 0; i+1 < len(data); i += 2 {
+		data[i], data[i+1] = data[i+1], data[i]
+	}
+}
+
+// writes a decoded 4x4 block into an image, leaving out any pixels past its edge
+func setBlock(img *image.NRGBA, x int, y int, colors [16]color.NRGBA) {
+	for i, c := range colors {
+		px, py := x+i%4, y+i/4
+		if px < img.Rect.Dx() && py < img.Rect.Dy() {
+			img.SetNRGBA(px, py, c)
+		}
+	}
+}
+
+// reads a 4x4 block out of an image, repeating the edge pixels for blocks that hang off it
+func getBlock(img *image.NRGBA, x int, y int) [16]color.NRGBA {
+	var colors [16]color.NRGBA
+	for i := range colors {
+		px, py := x+i%4, y+i/4
+		if px >= img.Rect.Dx() {
+			px = img.Rect.Dx() - 1
+		}
+		if py >= img.Rect.Dy() {
+			py = img.Rect.Dy() - 1
+		}
+		colors[i] = img.NRGBAAt(px, py)
+	}
+	return colors
+}
+
+func unpack565(c uint16) color.NRGBA {
+	r := uint8(c>>11) & 0x1F
+	g := uint8(c>>5) & 0x3F
+	b := uint8(c) & 0x1F
+	return color.NRGBA{R: r<<3 | r>>2, G: g<<2 | g>>4, B: b<<3 | b>>2, A: 255}
+}
+
+func pack565(c color.NRGBA) uint16 {
+	return uint16(c.R>>3)<<11 | uint16(c.G>>2)<<5 | uint16(c.B>>3)
+}
+
+func mix(a color.NRGBA, b color.NRGBA, weightA int, weightB int) color.NRGBA {
+	total := weightA + weightB
+	return color.NRGBA{
+		R: uint8((int(a.R)*weightA + int(b.R)*weightB) / total),
+		G: uint8((int(a.G)*weightA + int(b.G)*weightB) / total),
+		B: uint8((int(a.B)*weightA + int(b.B)*weightB) / total),
+		A: 255,
+	}
+}
+
+// the 4 colours a DXT1 block can pick from, c0 <= c1 switches to 3 colours and transparent black
+func dxt1Palette(c0 uint16, c1 uint16, alwaysFourColors bool) [4]color.NRGBA {
+	p0, p1 := unpack565(c0), unpack565(c1)
+
+	if c0 > c1 || alwaysFourColors {
+		return [4]color.NRGBA{p0, p1, mix(p0, p1, 2, 1), mix(p0, p1, 1, 2)}
+	}
+
+	return [4]color.NRGBA{p0, p1, mix(p0, p1, 1, 1), {}}
+}
+
+func decodeDXT1Colors(block []byte, alwaysFourColors bool) [16]color.NRGBA {
+	c0 := binary.LittleEndian.Uint16(block[0:2])
+	c1 := binary.LittleEndian.Uint16(block[2:4])
+	indices := binary.LittleEndian.Uint32(block[4:8])
+	palette := dxt1Palette(c0, c1, alwaysFourColors)
+
+	var colors [16]color.NRGBA
+	for i := range colors {
+		colors[i] = palette[(indices>>(2*i))&3]
+	}
+	return colors
+}
+
+func decodeDXT1Block(block []byte) [16]color.NRGBA {
+	return decodeDXT1Colors(block, false)
+}
+
+func decodeDXT5Block(block []byte) [16]color.NRGBA {
+	a0, a1 := int(block[0]), int(block[1])
+
+	var alphas [8]uint8
+	alphas[0], alphas[1] = uint8(a0), uint8(a1)
+	if a0 > a1 {
+		for i := 1; i < 7; i++ {
+			alphas[i+1] = uint8(((7-i)*a0 + i*a1) / 7)
+		}
+	} else {
+		for i := 1; i < 5; i++ {
+			alphas[i+1] = uint8(((5-i)*a0 + i*a1) / 5)
+		}
+		alphas[6], alphas[7] = 0, 255
+	}
+
+	var alphaIndices uint64
+	for i := 0; i < 6; i++ {
+		alphaIndices |= uint64(block[2+i]) << (8 * i)
+	}
+
+	// the colour half of a DXT5 block never uses the transparent mode
+	colors := decodeDXT1Colors(block[8:16], true)
+	for i := range colors {
+		colors[i].A = alphas[(alphaIndices>>(3*i))&7]
+	}
+	return colors
+}
+
+func colorDistance(a color.NRGBA, b color.NRGBA) int {
+	dr, dg, db := int(a.R)-int(b.R), int(a.G)-int(b.G), int(a.B)-int(b.B)
+	return dr*dr + dg*dg + db*db
+}
+
+// encodes a 4x4 block as DXT1, picking the two corners of its colour bounding box as the endpoints
+// with punchThrough set, pixels under half alpha become transparent using the 3 colour mode
+func encodeDXT1Block(colors [16]color.NRGBA, punchThrough bool) []byte {
+	transparent := false
+	minColor := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	maxColor := color.NRGBA{A: 255}
+	for _, c := range colors {
+		if punchThrough && c.A < 128 {
+			transparent = true
+			continue
+		}
+		minColor.R, maxColor.R = min(minColor.R, c.R), max(maxColor.R, c.R)
+		minColor.G, maxColor.G = min(minColor.G, c.G), max(maxColor.G, c.G)
+		minColor.B, maxColor.B = min(minColor.B, c.B), max(maxColor.B, c.B)
+	}
+
+	c0, c1 := pack565(maxColor), pack565(minColor)
+	if transparent {
+		// 3 colour mode needs c0 <= c1
+		if c0 > c1 {
+			c0, c1 = c1, c0
+		}
+	} else if c0 < c1 {
+		c0, c1 = c1, c0
+	}
+
+	block := make([]byte, 8)
+	binary.LittleEndian.PutUint16(block[0:2], c0)
+	binary.LittleEndian.PutUint16(block[2:4], c1)
+
+	palette := dxt1Palette(c0, c1, !transparent)
+	usable := 4
+	if transparent || c0 == c1 {
+		usable = 3
+	}
+
+	var indices uint32
+	for i, c := range colors {
+		index := 3
+		if !(punchThrough && c.A < 128) || !transparent {
+			index = 0
+			best := colorDistance(c, palette[0])
+			for p := 1; p < usable; p++ {
+				if distance := colorDistance(c, palette[p]); distance < best {
+					index, best = p, distance
+				}
+			}
+		}
+		indices |= uint32(index) << (2 * i)
+	}
+	binary.LittleEndian.PutUint32(block[4:8], indices)
+
+	return block
+}
+
+// encodes a 4x4 block as DXT5, with 8 interpolated alpha levels between the lowest and highest alpha in the block
+func encodeDXT5Block(colors [16]color.NRGBA) []byte {
+	a0, a1 := uint8(0), uint8(255)
+	for _, c := range colors {
+		a0, a1 = max(a0, c.A), min(a1, c.A)
+	}
+
+	block := make([]byte, 16)
+	block[0], block[1] = a0, a1
+
+	if a0 > a1 {
+		var alphas [8]int
+		alphas[0], alphas[1] = int(a0), int(a1)
+		for i := 1; i < 7; i++ {
+			alphas[i+1] = ((7-i)*int(a0) + i*int(a1)) / 7
+		}
+
+		var alphaIndices uint64
+		for i, c := range colors {
+			index, best := 0, 256
+			for a, alpha := range alphas {
+				distance := int(c.A) - alpha
+				if distance < 0 {
+					distance = -distance
+				}
+				if distance < best {
+					index, best = a, distance
+				}
+			}
+			alphaIndices |= uint64(index) << (3 * i)
+		}
+		for i := 0; i < 6; i++ {
+			block[2+i] = byte(alphaIndices >> (8 * i))
+		}
+	}
+
+	// the colour half has to be in 4 colour mode, which encodeDXT1Block gives us without punch through
+	copy(block[8:], encodeDXT1Block(colors, false))
+
+	return block
+}
+
+// reverses the order of the 2-bit indices in a byte
+func reverseIndices(b byte) byte {
+	return b>>6 | (b>>2)&0x0C | (b<<2)&0x30 | b<<6
+}
+
+// converts a Wii CMPR sub-block to a standard DXT1 block
+// CMPR stores its colours big-endian and the pixels of each row in the opposite order within the byte
+func cmprToDXT1(sub []byte) []byte {
+	block := make([]byte, 8)
+	block[0], block[1] = sub[1], sub[0]
+	block[2], block[3] = sub[3], sub[2]
+	for row := 0; row < 4; row++ {
+		block[4+row] = reverseIndices(sub[4+row])
+	}
+	return block
+}
+
+// the reverse of cmprToDXT1
+func dxt1ToCMPR(block []byte) []byte {
+	// the conversion is its own inverse
+	return cmprToDXT1(block)
+}
+
+// the image art is replaced with when a moderator takes it down, a plain dark grey square
+func Placeholder(width int, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 0x30, 0x30, 0x30, 0xFF
+	}
+	return img
+}
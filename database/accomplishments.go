@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"log"
+	"rb3server/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// the accomplishment goals RB3 keeps leaderboards for
+// the game sends each of these as lb_goal_value_<goal ID> in accomplishment/record
+var DefaultAccomplishmentGoalIDs = []string{
+	"campaign_metascore",
+	"acc_tourgoldlocal1",
+	"acc_tourgoldlocal2",
+	"acc_tourgoldregional1",
+	"acc_tourgoldregional2",
+	"acc_tourgoldcontinental1",
+	"acc_tourgoldcontinental2",
+	"acc_tourgoldcontinental3",
+	"acc_tourgoldglobal1",
+	"acc_tourgoldglobal2",
+	"acc_tourgoldglobal3",
+	"acc_overdrivemaintain3",
+	"acc_overdrivecareer",
+	"acc_careersaves",
+	"acc_millionpoints",
+	"acc_bassstreaklarge",
+	"acc_hopothreehundredbass",
+	"acc_drumfill170",
+	"acc_drumstreaklong",
+	"acc_deployguitarfour",
+	"acc_guitarstreaklarge",
+	"acc_hopoonethousand",
+	"acc_doubleawesomealot",
+	"acc_tripleawesomealot",
+	"acc_keystreaklong",
+	"acc_probassstreakepic",
+	"acc_prodrumroll3",
+	"acc_prodrumstreaklong",
+	"acc_proguitarstreakepic",
+	"acc_prokeystreaklong",
+	"acc_deployvocals",
+	"acc_deployvocalsonehundred",
+}
+
+// returns every accomplishment goal ID we keep leaderboards for, the built in ones plus any added in the config
+func GetAccomplishmentGoalIDs(ctx context.Context) []string {
+	goalIDs := append([]string{}, DefaultAccomplishmentGoalIDs...)
+
+	config, err := GetCachedConfig(ctx)
+	if err != nil {
+		log.Printf("Could not get config for accomplishment goals: %v", err)
+		return goalIDs
+	}
+
+	seen := make(map[string]bool, len(goalIDs))
+	for _, goalID := range goalIDs {
+		seen[goalID] = true
+	}
+	for _, goalID := range config.AccomplishmentGoalIDs {
+		if goalID != "" && !seen[goalID] {
+			seen[goalID] = true
+			goalIDs = append(goalIDs, goalID)
+		}
+	}
+
+	return goalIDs
+}
+
+// records a player's values for a set of accomplishment goals, keyed by goal ID
+// each goal is its own document so concurrent records from different players never overwrite each other
+func RecordAccomplishmentScores(ctx context.Context, database *mongo.Database, pid int, scores map[string]int) error {
+	if len(scores) == 0 {
+		return nil
+	}
+
+	now := time.Now().Unix()
+
+	writes := make([]mongo.WriteModel, 0, len(scores))
+	for accID, score := range scores {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"acc_id": accID, "pid": pid}).
+			SetUpdate(bson.M{"$set": models.AccomplishmentScore{
+				AccID:     accID,
+				PID:       pid,
+				Score:     score,
+				UpdatedAt: now,
+			}}).
+			SetUpsert(true))
+	}
+
+	_, err := database.Collection("accomplishment_scores").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// creates the indexes accomplishment leaderboards rely on, this is a no-op if they already exist
+func EnsureAccomplishmentIndexes(ctx context.Context, database *mongo.Database) error {
+	_, err := database.Collection("accomplishment_scores").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// one entry per player per goal
+			Keys:    bson.D{{Key: "acc_id", Value: 1}, {Key: "pid", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// leaderboard ordering
+			Keys: bson.D{{Key: "acc_id", Value: 1}, {Key: "score", Value: -1}},
+		},
+		{
+			// ban cleanup and account lookups
+			Keys: bson.D{{Key: "pid", Value: 1}},
+		},
+	})
+
+	return err
+}
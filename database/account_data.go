@@ -0,0 +1,273 @@
+package database
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"rb3server/binarydata"
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// what battle standings show in place of a player whose account was erased
+const erasedPlayerName = "Deleted Player"
+
+// how many documents erasing an account deleted or changed, by what was erased
+type AccountErasure map[string]int64
+
+// the latest revision of a piece of binary data a player uploaded
+type BinaryDataExport struct {
+	models.BinaryDataObject
+	Data []byte `json:"data"`
+}
+
+// everything stored about a player, as returned by ExportAccountData
+type AccountExport struct {
+	User                 models.User                   `json:"user"`
+	Scores               []models.Score                `json:"scores"`
+	QuarantinedScores    []models.QuarantinedScore     `json:"quarantined_scores"`
+	Bands                []models.Band                 `json:"bands"`
+	Characters           []models.Character            `json:"characters"`
+	Setlists             []models.Setlist              `json:"setlists"`
+	FollowedSetlists     []string                      `json:"followed_setlists"` // GUIDs
+	AccomplishmentScores []models.AccomplishmentScore  `json:"accomplishment_scores"`
+	BattleHistory        []models.BattleHistoryEntry   `json:"battle_history"`
+	BattleRejections     []models.BattleScoreRejection `json:"battle_rejections"`
+	BinaryData           []BinaryDataExport            `json:"binary_data"`
+	BinaryDataModeration []models.BinaryDataModeration `json:"binary_data_moderation"`
+	Gatherings           []models.Gathering            `json:"gatherings"`
+}
+
+// the binary data that goes away with an account, which is anything it uploaded plus the art of its bands and setlists
+func accountBinaryDataFilter(ctx context.Context, database *mongo.Database, pid int) (bson.M, error) {
+	bandIDs, err := database.Collection("bands").Distinct(ctx, "band_id", bson.M{"owner_pid": pid})
+	if err != nil {
+		return nil, err
+	}
+	bandKeys := bson.A{}
+	for _, bandID := range bandIDs {
+		switch v := bandID.(type) {
+		case int32:
+			bandKeys = append(bandKeys, strconv.Itoa(int(v)))
+		case int64:
+			bandKeys = append(bandKeys, strconv.Itoa(int(v)))
+		}
+	}
+
+	setlistGUIDs, err := database.Collection("setlists").Distinct(ctx, "guid", bson.M{"pid": pid, "type": bson.M{"$nin": battleSetlistTypes}})
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.M{"$or": bson.A{
+		bson.M{"owner_pid": pid},
+		bson.M{"type": "band_logo", "key": bson.M{"$in": bandKeys}},
+		bson.M{"type": "setlist_art", "key": bson.M{"$in": setlistGUIDs}},
+	}}, nil
+}
+
+// deletes everything stored about a player, for when they delete their account or ask for it to be erased
+// battles they created are handed over to the server instead, since other players' scores are on them, and their
+// placements in archived battle results are anonymized
+// the user document goes last, so an erasure that fails part way can be run again to finish it
+func EraseAccount(ctx context.Context, database *mongo.Database, store binarydata.Store, pid int) (AccountErasure, error) {
+	erased := AccountErasure{}
+
+	var user models.User
+	if err := database.Collection("users").FindOne(ctx, bson.M{"pid": pid}).Decode(&user); err != nil {
+		return erased, err
+	}
+
+	// binary data has to be found before the bands and setlists it belongs to are gone
+	binaryDataFilter, err := accountBinaryDataFilter(ctx, database, pid)
+	if err != nil {
+		return erased, err
+	}
+
+	cursor, err := database.Collection("binary_data_objects").Find(ctx, binaryDataFilter)
+	if err != nil {
+		return erased, err
+	}
+	var objects []models.BinaryDataObject
+	if err := cursor.All(ctx, &objects); err != nil {
+		return erased, err
+	}
+
+	for _, object := range objects {
+		for _, revision := range object.Revisions {
+			key := binarydata.Key(object.Type, object.Key, revision.Revision, object.PlatformExtension)
+			if err := store.Delete(ctx, key); err != nil && err != binarydata.ErrNotFound {
+				return erased, err
+			}
+			erased["binary_data_blobs"]++
+		}
+	}
+
+	deletions := []struct {
+		name       string
+		collection string
+		filter     interface{}
+	}{
+		{"binary_data", "binary_data_objects", binaryDataFilter},
+		{"binary_data_moderation", "binary_data_moderation", bson.M{"owner_pid": pid}},
+		{"scores", "scores", bson.M{"pid": pid}},
+		{"quarantined_scores", "quarantined_scores", bson.M{"score.pid": pid}},
+		{"accomplishment_scores", "accomplishment_scores", bson.M{"pid": pid}},
+		{"battle_history", "battle_history", bson.M{"pid": pid}},
+		{"bands", "bands", bson.M{"owner_pid": pid}},
+		{"characters", "characters", bson.M{"owner_pid": pid}},
+		{"setlists", "setlists", bson.M{"pid": pid, "type": bson.M{"$nin": battleSetlistTypes}}},
+		{"gatherings", "gatherings", bson.M{"creator": user.Username}},
+		// old usernames of accounts merged into this one would otherwise still log in to the erased PID
+		{"username_redirects", "username_redirects", bson.M{"pid": pid}},
+	}
+
+	for _, deletion := range deletions {
+		res, err := database.Collection(deletion.collection).DeleteMany(ctx, deletion.filter)
+		if err != nil {
+			return erased, err
+		}
+		erased[deletion.name] = res.DeletedCount
+	}
+
+	updates := []struct {
+		name       string
+		collection string
+		filter     interface{}
+		update     interface{}
+		options    *options.UpdateOptions
+	}{
+		// battles stay up for everyone else who played them
+		{"battles", "setlists", bson.M{"pid": pid, "type": bson.M{"$in": battleSetlistTypes}},
+			bson.M{"$set": bson.M{"pid": 0, "owner": "Harmonix", "owner_guid": ""}}, nil},
+		{"battle_results", "battle_results", bson.M{"standings.pid": pid},
+			bson.M{"$set": bson.M{"standings.$[standing].pid": 0, "standings.$[standing].name": erasedPlayerName}},
+			options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"standing.pid": pid}}})},
+		{"band_scores", "scores", bson.M{"band_pids": pid}, bson.M{"$pull": bson.M{"band_pids": pid}}, nil},
+		{"battle_rejections", "battle_score_rejections", bson.M{"pids": pid}, bson.M{"$pull": bson.M{"pids": pid}}, nil},
+		{"followed_setlists", "setlists", bson.M{"followers": pid}, bson.M{"$pull": bson.M{"followers": pid}, "$inc": bson.M{"follower_count": -1}}, nil},
+		// the sync still counted, just not who it was
+		{"synced_setlists", "setlists", bson.M{"synced_by": pid}, bson.M{"$pull": bson.M{"synced_by": pid}}, nil},
+		{"friends", "users", bson.M{"friends": pid}, bson.M{"$pull": bson.M{"friends": pid}}, nil},
+	}
+
+	for _, update := range updates {
+		res, err := database.Collection(update.collection).UpdateMany(ctx, update.filter, update.update, update.options)
+		if err != nil {
+			return erased, err
+		}
+		erased[update.name] = res.ModifiedCount
+	}
+
+	// rejections that were only about this player have nothing left to show
+	res, err := database.Collection("battle_score_rejections").DeleteMany(ctx, bson.M{"pids": bson.M{"$size": 0}})
+	if err != nil {
+		return erased, err
+	}
+	erased["battle_rejections"] += res.DeletedCount
+
+	res, err = database.Collection("users").DeleteOne(ctx, bson.M{"pid": pid})
+	if err != nil {
+		return erased, err
+	}
+	erased["users"] = res.DeletedCount
+
+	InvalidateConsoleTypePIDsCache(user.ConsoleType)
+
+	log.Printf("Erased account %s (PID %d): %v\n", user.Username, pid, erased)
+
+	return erased, nil
+}
+
+// finds every document in a collection matching a filter
+func findAll(ctx context.Context, database *mongo.Database, collection string, filter interface{}, results interface{}) error {
+	cursor, err := database.Collection(collection).Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return cursor.All(ctx, results)
+}
+
+// gathers everything stored about a player, so it can be handed to them
+func ExportAccountData(ctx context.Context, database *mongo.Database, store binarydata.Store, pid int) (*AccountExport, error) {
+	export := &AccountExport{
+		Scores:               []models.Score{},
+		QuarantinedScores:    []models.QuarantinedScore{},
+		Bands:                []models.Band{},
+		Characters:           []models.Character{},
+		Setlists:             []models.Setlist{},
+		FollowedSetlists:     []string{},
+		AccomplishmentScores: []models.AccomplishmentScore{},
+		BattleHistory:        []models.BattleHistoryEntry{},
+		BattleRejections:     []models.BattleScoreRejection{},
+		BinaryData:           []BinaryDataExport{},
+		BinaryDataModeration: []models.BinaryDataModeration{},
+		Gatherings:           []models.Gathering{},
+	}
+
+	if err := database.Collection("users").FindOne(ctx, bson.M{"pid": pid}).Decode(&export.User); err != nil {
+		return nil, err
+	}
+
+	queries := []struct {
+		collection string
+		filter     interface{}
+		results    interface{}
+	}{
+		{"scores", bson.M{"pid": pid}, &export.Scores},
+		{"quarantined_scores", bson.M{"score.pid": pid}, &export.QuarantinedScores},
+		{"bands", bson.M{"owner_pid": pid}, &export.Bands},
+		{"characters", bson.M{"owner_pid": pid}, &export.Characters},
+		{"setlists", bson.M{"pid": pid}, &export.Setlists},
+		{"accomplishment_scores", bson.M{"pid": pid}, &export.AccomplishmentScores},
+		{"battle_history", bson.M{"pid": pid}, &export.BattleHistory},
+		{"battle_score_rejections", bson.M{"pids": pid}, &export.BattleRejections},
+		{"binary_data_moderation", bson.M{"owner_pid": pid}, &export.BinaryDataModeration},
+		{"gatherings", bson.M{"creator": export.User.Username}, &export.Gatherings},
+	}
+
+	for _, query := range queries {
+		if err := findAll(ctx, database, query.collection, query.filter, query.results); err != nil {
+			return nil, err
+		}
+	}
+
+	followed, err := database.Collection("setlists").Distinct(ctx, "guid", bson.M{"followers": pid})
+	if err != nil {
+		return nil, err
+	}
+	for _, guid := range followed {
+		if guid, ok := guid.(string); ok {
+			export.FollowedSetlists = append(export.FollowedSetlists, guid)
+		}
+	}
+
+	var objects []models.BinaryDataObject
+	if err := findAll(ctx, database, "binary_data_objects", bson.M{"owner_pid": pid}, &objects); err != nil {
+		return nil, err
+	}
+
+	for _, object := range objects {
+		if len(object.Revisions) == 0 {
+			continue
+		}
+
+		latest := object.Revisions[len(object.Revisions)-1]
+		data, err := store.Get(ctx, binarydata.Key(object.Type, object.Key, latest.Revision, object.PlatformExtension))
+		if err == binarydata.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		export.BinaryData = append(export.BinaryData, BinaryDataExport{BinaryDataObject: object, Data: data})
+	}
+
+	return export, nil
+}
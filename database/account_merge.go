@@ -0,0 +1,442 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// returned when asked to merge an account into itself
+var ErrMergeIntoSelf = errors.New("cannot merge an account into itself")
+
+// what happened to the documents of a kind where both players could have one for the same thing
+type MergeConflicts struct {
+	Moved    int `json:"moved"`    // only the old account had one, so it was moved over
+	Replaced int `json:"replaced"` // the old account's was better, so it replaced the new account's
+	Dropped  int `json:"dropped"`  // the new account's was at least as good, so the old account's was deleted
+}
+
+// what merging one account into another does, or would do on a dry run
+type AccountMergeReport struct {
+	FromPID      int    `json:"from_pid"`
+	FromUsername string `json:"from_username"`
+	ToPID        int    `json:"to_pid"`
+	ToUsername   string `json:"to_username"`
+	DryRun       bool   `json:"dry_run"`
+	Transaction  bool   `json:"transaction"` // whether the merge ran in a transaction, which needs a replica set
+
+	Scores               MergeConflicts `json:"scores"`
+	AccomplishmentScores MergeConflicts `json:"accomplishment_scores"`
+	BattleHistory        MergeConflicts `json:"battle_history"`
+
+	// how many documents were moved to the new account, by what they are
+	Moved map[string]int64 `json:"moved"`
+}
+
+// one of a player's documents that the other player can also have one of, as far as merging is concerned
+type mergeCandidate struct {
+	id    primitive.ObjectID
+	key   string // documents with the same key are for the same thing
+	value int    // the bigger value is kept
+}
+
+// the documents to move over to the new account and the ones to delete from either account
+type mergePlan struct {
+	move   []primitive.ObjectID
+	delete []primitive.ObjectID
+	MergeConflicts
+}
+
+// works out which documents to keep when both players have one with the same key
+func planMerge(from []mergeCandidate, to []mergeCandidate) mergePlan {
+	existing := make(map[string]mergeCandidate, len(to))
+	for _, candidate := range to {
+		existing[candidate.key] = candidate
+	}
+
+	var plan mergePlan
+	for _, candidate := range from {
+		other, ok := existing[candidate.key]
+		switch {
+		case !ok:
+			plan.move = append(plan.move, candidate.id)
+			plan.Moved++
+		case candidate.value > other.value:
+			plan.move = append(plan.move, candidate.id)
+			plan.delete = append(plan.delete, other.id)
+			plan.Replaced++
+		default:
+			plan.delete = append(plan.delete, candidate.id)
+			plan.Dropped++
+		}
+	}
+
+	return plan
+}
+
+// finds the documents of a player that can conflict with the other player's
+// a document that can't be decoded stops the merge, since guessing at it could drop a better score
+func findMergeCandidates(ctx context.Context, database *mongo.Database, collection string, pid int, candidate func(bson.Raw) (mergeCandidate, error)) ([]mergeCandidate, error) {
+	cursor, err := database.Collection(collection).Find(ctx, bson.M{"pid": pid})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	candidates := []mergeCandidate{}
+	for cursor.Next(ctx) {
+		c, err := candidate(cursor.Current)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode a document in %s for PID %d: %w", collection, pid, err)
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, cursor.Err()
+}
+
+// scores are stored one per song and role, or one per battle, with the difficulty of whichever was best
+// so the kept score carries its own difficulty with it
+func scoreMergeCandidate(raw bson.Raw) (mergeCandidate, error) {
+	var score struct {
+		ID           primitive.ObjectID `bson:"_id"`
+		models.Score `bson:",inline"`
+	}
+	if err := bson.Unmarshal(raw, &score); err != nil {
+		return mergeCandidate{}, err
+	}
+
+	key := fmt.Sprintf("song:%d:%d", score.SongID, score.RoleID)
+	if score.BattleID != 0 {
+		key = fmt.Sprintf("battle:%d", score.BattleID)
+	}
+
+	return mergeCandidate{id: score.ID, key: key, value: score.Score.Score}, nil
+}
+
+func accomplishmentMergeCandidate(raw bson.Raw) (mergeCandidate, error) {
+	var score struct {
+		ID                         primitive.ObjectID `bson:"_id"`
+		models.AccomplishmentScore `bson:",inline"`
+	}
+	if err := bson.Unmarshal(raw, &score); err != nil {
+		return mergeCandidate{}, err
+	}
+
+	return mergeCandidate{id: score.ID, key: score.AccID, value: score.Score}, nil
+}
+
+// a lower rank is a better placement
+func battleHistoryMergeCandidate(raw bson.Raw) (mergeCandidate, error) {
+	var entry struct {
+		ID                        primitive.ObjectID `bson:"_id"`
+		models.BattleHistoryEntry `bson:",inline"`
+	}
+	if err := bson.Unmarshal(raw, &entry); err != nil {
+		return mergeCandidate{}, err
+	}
+
+	return mergeCandidate{id: entry.ID, key: fmt.Sprint(entry.BattleID), value: -entry.Rank}, nil
+}
+
+// an update that hands documents from one account over to another
+type mergeUpdate struct {
+	name       string
+	collection string
+	filter     interface{}
+	update     interface{}
+	options    *options.UpdateOptions
+}
+
+// the updates that hand everything but the conflicting documents over, in the order they have to run
+func mergeUpdates(from models.User, to models.User) []mergeUpdate {
+	fromPID, toPID := int(from.PID), int(to.PID)
+
+	replacePID := func(field string) *options.UpdateOptions {
+		return options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{field: fromPID}}})
+	}
+
+	return []mergeUpdate{
+		{"bands", "bands", bson.M{"owner_pid": fromPID}, bson.M{"$set": bson.M{"owner_pid": toPID}}, nil},
+		{"characters", "characters", bson.M{"owner_pid": fromPID}, bson.M{"$set": bson.M{"owner_pid": toPID}}, nil},
+		{"setlists", "setlists", bson.M{"pid": fromPID, "type": bson.M{"$nin": battleSetlistTypes}},
+			bson.M{"$set": bson.M{"pid": toPID, "owner": to.Username, "owner_guid": to.GUID}}, nil},
+		{"battles", "setlists", bson.M{"pid": fromPID, "type": bson.M{"$in": battleSetlistTypes}},
+			bson.M{"$set": bson.M{"pid": toPID, "owner": to.Username, "owner_guid": to.GUID}}, nil},
+		{"binary_data", "binary_data_objects", bson.M{"owner_pid": fromPID}, bson.M{"$set": bson.M{"owner_pid": toPID}}, nil},
+		{"binary_data_moderation", "binary_data_moderation", bson.M{"owner_pid": fromPID}, bson.M{"$set": bson.M{"owner_pid": toPID}}, nil},
+		{"quarantined_scores", "quarantined_scores", bson.M{"score.pid": fromPID}, bson.M{"$set": bson.M{"score.pid": toPID}}, nil},
+		{"battle_results", "battle_results", bson.M{"standings.pid": fromPID},
+			bson.M{"$set": bson.M{"standings.$[standing].pid": toPID}}, replacePID("standing.pid")},
+		{"band_scores", "scores", bson.M{"band_pids": fromPID},
+			bson.M{"$set": bson.M{"band_pids.$[p]": toPID}}, replacePID("p")},
+		{"battle_rejections", "battle_score_rejections", bson.M{"pids": fromPID},
+			bson.M{"$set": bson.M{"pids.$[p]": toPID}}, replacePID("p")},
+		// setlists both players follow only keep one follower, the rest follow the new account instead
+		{"followed_setlists", "setlists", bson.M{"followers": bson.M{"$all": bson.A{fromPID, toPID}}},
+			bson.M{"$pull": bson.M{"followers": fromPID}, "$inc": bson.M{"follower_count": -1}}, nil},
+		{"followed_setlists", "setlists", bson.M{"$and": bson.A{bson.M{"followers": fromPID}, bson.M{"followers": bson.M{"$ne": toPID}}}},
+			bson.M{"$set": bson.M{"followers.$[f]": toPID}}, replacePID("f")},
+		// setlists synced to both accounts stay counted twice, but only the new account is remembered as having synced them
+		{"synced_setlists", "setlists", bson.M{"synced_by": bson.M{"$all": bson.A{fromPID, toPID}}},
+			bson.M{"$pull": bson.M{"synced_by": fromPID}}, nil},
+		{"synced_setlists", "setlists", bson.M{"$and": bson.A{bson.M{"synced_by": fromPID}, bson.M{"synced_by": bson.M{"$ne": toPID}}}},
+			bson.M{"$set": bson.M{"synced_by.$[s]": toPID}}, replacePID("s")},
+		// same for other players who were friends with the old account
+		{"friends", "users", bson.M{"pid": bson.M{"$nin": bson.A{fromPID, toPID}}, "friends": bson.M{"$all": bson.A{fromPID, toPID}}},
+			bson.M{"$pull": bson.M{"friends": fromPID}}, nil},
+		{"friends", "users", bson.M{"$and": bson.A{bson.M{"pid": bson.M{"$nin": bson.A{fromPID, toPID}}}, bson.M{"friends": fromPID}, bson.M{"friends": bson.M{"$ne": toPID}}}},
+			bson.M{"$set": bson.M{"friends.$[f]": toPID}}, replacePID("f")},
+		// older redirects follow the account along
+		{"username_redirects", "username_redirects", bson.M{"pid": fromPID}, bson.M{"$set": bson.M{"pid": toPID}}, nil},
+	}
+}
+
+// the new account picks up the old account's friends and groups, and its web link if it doesn't have one
+func mergedUserUpdate(from models.User, to models.User) bson.M {
+	friends := bson.A{}
+	for _, friend := range from.Friends {
+		if friend != int(from.PID) && friend != int(to.PID) {
+			friends = append(friends, friend)
+		}
+	}
+
+	groups := bson.A{}
+	for _, group := range from.Groups {
+		groups = append(groups, group)
+	}
+
+	update := bson.M{
+		"$addToSet": bson.M{
+			"friends": bson.M{"$each": friends},
+			"groups":  bson.M{"$each": groups},
+		},
+	}
+
+	if to.WebIdentity == "" && from.WebIdentity != "" {
+		update["$set"] = bson.M{"web_identity": from.WebIdentity, "web_linked_at": from.WebLinkedAt}
+	}
+
+	return update
+}
+
+// transactions need a replica set or a sharded cluster, standalone servers refuse them
+func transactionsUnsupported(err error) bool {
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) {
+		return commandErr.Code == 20 || strings.Contains(commandErr.Message, "Transaction numbers are only allowed")
+	}
+
+	return false
+}
+
+// moves everything from one account to another, for players who moved platforms or renamed, and leaves a redirect so
+// the old username logs in to the new account
+// scores, accomplishments and battle placements both accounts have keep whichever is better
+// on a dry run nothing is changed and the report says what would be
+// the merge runs in a transaction where the deployment supports them, otherwise every step can be run again and the old
+// user document goes last, so a merge that fails part way can be run again to finish it
+func MergeAccounts(ctx context.Context, database *mongo.Database, fromPID int, toPID int, dryRun bool) (*AccountMergeReport, error) {
+	if fromPID == toPID {
+		return nil, ErrMergeIntoSelf
+	}
+
+	if dryRun {
+		return mergeAccounts(ctx, database, fromPID, toPID, true)
+	}
+
+	session, err := database.Client().StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return mergeAccounts(sessionCtx, database, fromPID, toPID, false)
+	})
+	if transactionsUnsupported(err) {
+		log.Printf("Transactions are not supported by this deployment, merging PID %d into PID %d without one\n", fromPID, toPID)
+		return mergeAccounts(ctx, database, fromPID, toPID, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report := result.(*AccountMergeReport)
+	report.Transaction = true
+
+	return report, nil
+}
+
+func mergeAccounts(ctx context.Context, database *mongo.Database, fromPID int, toPID int, dryRun bool) (*AccountMergeReport, error) {
+	usersCollection := database.Collection("users")
+
+	var from, to models.User
+	if err := usersCollection.FindOne(ctx, bson.M{"pid": fromPID}).Decode(&from); err != nil {
+		return nil, err
+	}
+	if err := usersCollection.FindOne(ctx, bson.M{"pid": toPID}).Decode(&to); err != nil {
+		return nil, err
+	}
+
+	report := &AccountMergeReport{
+		FromPID:      fromPID,
+		FromUsername: from.Username,
+		ToPID:        toPID,
+		ToUsername:   to.Username,
+		DryRun:       dryRun,
+		Moved:        map[string]int64{},
+	}
+
+	conflicts := []struct {
+		collection string
+		candidate  func(bson.Raw) (mergeCandidate, error)
+		result     *MergeConflicts
+	}{
+		{"scores", scoreMergeCandidate, &report.Scores},
+		{"accomplishment_scores", accomplishmentMergeCandidate, &report.AccomplishmentScores},
+		{"battle_history", battleHistoryMergeCandidate, &report.BattleHistory},
+	}
+
+	// everything is planned before anything is written, so a document that can't be read stops the merge before it starts
+	plans := make([]mergePlan, len(conflicts))
+	for i, conflict := range conflicts {
+		fromCandidates, err := findMergeCandidates(ctx, database, conflict.collection, fromPID, conflict.candidate)
+		if err != nil {
+			return nil, err
+		}
+		toCandidates, err := findMergeCandidates(ctx, database, conflict.collection, toPID, conflict.candidate)
+		if err != nil {
+			return nil, err
+		}
+
+		plans[i] = planMerge(fromCandidates, toCandidates)
+		*conflict.result = plans[i].MergeConflicts
+	}
+
+	if !dryRun {
+		for i, conflict := range conflicts {
+			plan := plans[i]
+			collection := database.Collection(conflict.collection)
+			if len(plan.delete) > 0 {
+				if _, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": plan.delete}}); err != nil {
+					return nil, err
+				}
+			}
+			if len(plan.move) > 0 {
+				if _, err := collection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": plan.move}}, bson.M{"$set": bson.M{"pid": toPID}}); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for _, update := range mergeUpdates(from, to) {
+		if dryRun {
+			count, err := database.Collection(update.collection).CountDocuments(ctx, update.filter)
+			if err != nil {
+				return nil, err
+			}
+			report.Moved[update.name] += count
+			continue
+		}
+
+		res, err := database.Collection(update.collection).UpdateMany(ctx, update.filter, update.update, update.options)
+		if err != nil {
+			return nil, err
+		}
+		report.Moved[update.name] += res.ModifiedCount
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	if _, err := usersCollection.UpdateOne(ctx, bson.M{"pid": toPID}, mergedUserUpdate(from, to)); err != nil {
+		return nil, err
+	}
+	if _, err := usersCollection.UpdateOne(ctx, bson.M{"pid": toPID}, bson.M{"$pull": bson.M{"friends": fromPID}}); err != nil {
+		return nil, err
+	}
+
+	// gatherings are only kept around while the player is online, so there is nothing worth moving
+	if _, err := database.Collection("gatherings").DeleteMany(ctx, bson.M{"creator": from.Username}); err != nil {
+		return nil, err
+	}
+
+	_, err := database.Collection("username_redirects").UpdateOne(ctx,
+		bson.M{"username": strings.ToLower(from.Username)},
+		bson.M{"$set": bson.M{"pid": toPID, "created_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := usersCollection.DeleteOne(ctx, bson.M{"pid": fromPID}); err != nil {
+		return nil, err
+	}
+
+	InvalidateConsoleTypePIDsCache(from.ConsoleType)
+
+	log.Printf("Merged account %s (PID %d) into %s (PID %d): scores %+v, accomplishments %+v, battle history %+v, moved %v\n",
+		from.Username, fromPID, to.Username, toPID, report.Scores, report.AccomplishmentScores, report.BattleHistory, report.Moved)
+
+	return report, nil
+}
+
+// gets the account an old username was merged into, returning mongo.ErrNoDocuments if it wasn't
+func GetUsernameRedirect(ctx context.Context, database *mongo.Database, username string) (int, error) {
+	var redirect models.UsernameRedirect
+	if err := database.Collection("username_redirects").FindOne(ctx, bson.M{"username": strings.ToLower(username)}).Decode(&redirect); err != nil {
+		return 0, err
+	}
+
+	return redirect.PID, nil
+}
+
+// finds a user by username, following the redirect left behind if the account was merged into another one
+func FindUserByUsername(ctx context.Context, database *mongo.Database, username string) (*models.User, error) {
+	usersCollection := database.Collection("users")
+
+	var user models.User
+	err := usersCollection.FindOne(ctx, CaseInsensitiveUsername(username)).Decode(&user)
+	if err == nil {
+		return &user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	pid, err := GetUsernameRedirect(ctx, database, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := usersCollection.FindOne(ctx, bson.M{"pid": pid}).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// creates the index username redirects are looked up by, this is a no-op if it already exists
+func EnsureUsernameRedirectIndexes(ctx context.Context, database *mongo.Database) error {
+	_, err := database.Collection("username_redirects").Indexes().CreateOne(ctx, mongo.IndexModel{
+		// usernames are stored lowercased, since they are case-insensitive
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	return err
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// the PIDs the game asks for messages with, by the PID of the player who should get them
+// messages on the Wii go between Master Users, so Wii players get theirs through the machine that created their profile
+// everyone else, and players that no longer exist, keep their own PID
+func GetMessageRecipientPIDs(ctx context.Context, database *mongo.Database, pids []int) (map[int]uint32, error) {
+	recipients := make(map[int]uint32, len(pids))
+	for _, pid := range pids {
+		recipients[pid] = uint32(pid)
+	}
+
+	cursor, err := database.Collection("users").Find(ctx,
+		bson.M{"pid": bson.M{"$in": pids}, "console_type": 2, "created_by_machine_id": bson.M{"$gt": 0}},
+		options.Find().SetProjection(bson.M{"pid": 1, "created_by_machine_id": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		recipients[int(user.PID)] = uint32(user.CreatedByMachineID)
+	}
+
+	return recipients, nil
+}
+
+// stores the messages sent to battle winners so they can be loaded again after a restart
+func SaveBattleWinnerMessages(ctx context.Context, database *mongo.Database, messages []models.BattleWinnerMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, 0, len(messages))
+	for _, message := range messages {
+		documents = append(documents, message)
+	}
+
+	_, err := database.Collection("battle_winner_messages").InsertMany(ctx, documents)
+	return err
+}
+
+// gets every battle winner message that hasn't expired or been deleted by the game
+func GetPendingBattleWinnerMessages(ctx context.Context, database *mongo.Database) ([]models.BattleWinnerMessage, error) {
+	cursor, err := database.Collection("battle_winner_messages").Find(ctx, bson.M{"expires_at": bson.M{"$gt": time.Now()}})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []models.BattleWinnerMessage{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// forgets battle winner messages the game has deleted, IDs that aren't battle winner messages are ignored
+func DeleteBattleWinnerMessages(ctx context.Context, database *mongo.Database, recipientPID uint32, messageIDs []uint32) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	_, err := database.Collection("battle_winner_messages").DeleteMany(ctx, bson.M{"recipient_pid": recipientPID, "message_id": bson.M{"$in": messageIDs}})
+	return err
+}
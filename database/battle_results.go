@@ -0,0 +1,246 @@
+package database
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// how many placements go into the archived standings when the config doesn't say otherwise
+const defaultBattleResultStandings = 10
+
+// the setlist types that are battles, 1002 being the global Harmonix battles
+var battleSetlistTypes = []int{1000, 1001, 1002}
+
+// archives the final standings of every battle that has closed but hasn't been archived yet
+// returns the newly archived results so the caller can announce the winners
+func ArchiveClosedBattles() []models.BattleResult {
+	setlistsCollection := GocentralDatabase.Collection("setlists")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	cursor, err := setlistsCollection.Find(ctx, bson.M{"type": bson.M{"$in": battleSetlistTypes}})
+	if err != nil {
+		log.Println("Could not get battles for archival:", err)
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var closed []models.Setlist
+	for cursor.Next(ctx) {
+		var setlist models.Setlist
+		if err := cursor.Decode(&setlist); err != nil {
+			continue
+		}
+		if isExpired, _ := GetSetlistBattleExpiryInfo(setlist); isExpired {
+			closed = append(closed, setlist)
+		}
+	}
+
+	var archived []models.BattleResult
+	for _, battle := range closed {
+		result, isNew, err := ArchiveBattleResult(ctx, GocentralDatabase, battle)
+		if err != nil {
+			log.Printf("Could not archive results of battle %d: %v", battle.SetlistID, err)
+			continue
+		}
+		if isNew {
+			archived = append(archived, *result)
+		}
+	}
+
+	if len(archived) != 0 {
+		log.Printf("Archived results of %d closed battles.\n", len(archived))
+	}
+
+	return archived
+}
+
+// freezes the standings of a closed battle into battle_results and every participant's placement into battle_history
+// archiving a battle that is already archived returns the existing result and false
+func ArchiveBattleResult(ctx context.Context, database *mongo.Database, battle models.Setlist) (*models.BattleResult, bool, error) {
+	existing, err := GetBattleResult(ctx, database, battle.SetlistID)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+
+	standingsCount := defaultBattleResultStandings
+	if config, err := GetCachedConfig(ctx); err == nil && config.BattleResultStandings > 0 {
+		standingsCount = config.BattleResultStandings
+	}
+
+	// ties go to whoever got the score first
+	cursor, err := database.Collection("scores").Find(ctx, bson.M{"battle_id": battle.SetlistID},
+		options.Find().SetSort(bson.D{{Key: "score", Value: -1}, {Key: "submitted_at", Value: 1}, {Key: "pid", Value: 1}}))
+	if err != nil {
+		return nil, false, err
+	}
+	defer cursor.Close(ctx)
+
+	var scores []models.Score
+	if err := cursor.All(ctx, &scores); err != nil {
+		return nil, false, err
+	}
+
+	_, endedAt := GetSetlistBattleExpiryInfo(battle)
+
+	result := models.BattleResult{
+		BattleID:     battle.SetlistID,
+		Type:         battle.Type,
+		Title:        battle.Title,
+		Description:  battle.Desc,
+		Owner:        battle.Owner,
+		Instrument:   battle.Instrument,
+		SongIDs:      battle.SongIDs,
+		SongNames:    battle.SongNames,
+		StartedAt:    battle.Created,
+		EndedAt:      endedAt.Unix(),
+		ArchivedAt:   time.Now().Unix(),
+		Participants: len(scores),
+		Standings:    []models.BattleStanding{},
+		Hidden:       battle.Hidden,
+	}
+
+	topPIDs := []int{}
+	for idx := 0; idx < len(scores) && idx < standingsCount; idx++ {
+		topPIDs = append(topPIDs, scores[idx].OwnerPID)
+	}
+
+	names, err := GetConsolePrefixedUsernamesByPIDs(ctx, database, topPIDs)
+	if err != nil {
+		log.Println("Could not get names for battle standings:", err)
+		names = map[int]string{}
+	}
+
+	for idx, pid := range topPIDs {
+		name, ok := names[pid]
+		if !ok {
+			name = "Unnamed Player"
+		}
+		result.Standings = append(result.Standings, models.BattleStanding{
+			Rank:  idx + 1,
+			PID:   pid,
+			Name:  name,
+			Score: scores[idx].Score,
+		})
+	}
+
+	// history goes in first so a failure part way through is picked up again on the next run
+	if len(scores) != 0 {
+		writes := make([]mongo.WriteModel, 0, len(scores))
+		for idx, score := range scores {
+			writes = append(writes, mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"battle_id": battle.SetlistID, "pid": score.OwnerPID}).
+				SetReplacement(models.BattleHistoryEntry{
+					BattleID:   battle.SetlistID,
+					PID:        score.OwnerPID,
+					Title:      battle.Title,
+					Instrument: battle.Instrument,
+					Rank:       idx + 1,
+					Score:      score.Score,
+					EndedAt:    result.EndedAt,
+					Hidden:     battle.Hidden,
+				}).
+				SetUpsert(true))
+		}
+
+		if _, err := database.Collection("battle_history").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if _, err := database.Collection("battle_results").InsertOne(ctx, result); err != nil {
+		// another instance beat us to it
+		if mongo.IsDuplicateKeyError(err) {
+			existing, err := GetBattleResult(ctx, database, battle.SetlistID)
+			return existing, false, err
+		}
+		return nil, false, err
+	}
+
+	return &result, true, nil
+}
+
+// hides or unhides the archived result of a battle and every placement in it, which is a no-op if it hasn't been archived
+func SetBattleResultHidden(ctx context.Context, database *mongo.Database, battleID int, hidden bool) error {
+	update := bson.M{"$set": bson.M{"hidden": true}}
+	if !hidden {
+		update = bson.M{"$unset": bson.M{"hidden": ""}}
+	}
+
+	if _, err := database.Collection("battle_results").UpdateOne(ctx, bson.M{"battle_id": battleID}, update); err != nil {
+		return err
+	}
+
+	_, err := database.Collection("battle_history").UpdateMany(ctx, bson.M{"battle_id": battleID}, update)
+	return err
+}
+
+// gets the archived result of a battle, returns nil if the battle hasn't been archived
+func GetBattleResult(ctx context.Context, database *mongo.Database, battleID int) (*models.BattleResult, error) {
+	var result models.BattleResult
+	err := database.Collection("battle_results").FindOne(ctx, bson.M{"battle_id": battleID}).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// creates the indexes battle archives rely on, this is a no-op if they already exist
+func EnsureBattleResultIndexes(ctx context.Context, database *mongo.Database) error {
+	_, err := database.Collection("battle_results").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// a battle is only ever archived once
+			Keys:    bson.D{{Key: "battle_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// newest results first
+			Keys: bson.D{{Key: "ended_at", Value: -1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("battle_history").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "battle_id", Value: 1}, {Key: "pid", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// a player's history, newest first
+			Keys: bson.D{{Key: "pid", Value: 1}, {Key: "ended_at", Value: -1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("battle_winner_messages").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "recipient_pid", Value: 1}, {Key: "message_id", Value: 1}},
+		},
+		{
+			// messages the game never deleted go away once they expire
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+
+	return err
+}
@@ -0,0 +1,266 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// inserts a new global Harmonix battle, filling in everything that is the same for every Harmonix battle
+// battle.Created is when the battle starts, players won't see it before then
+func InsertHarmonixBattle(ctx context.Context, database *mongo.Database, battle models.Setlist) (int, error) {
+	battleID, err := GetNextSetlistID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	battle.SetlistID = battleID
+	battle.PID = 0
+	battle.Type = 1002
+	battle.Owner = "Harmonix"
+	battle.Shared = "t"
+	battle.SongNames = GetSongNamesInOrder(ctx, database, battle.SongIDs)
+
+	if _, err := database.Collection("setlists").InsertOne(ctx, battle); err != nil {
+		return 0, err
+	}
+
+	return battleID, nil
+}
+
+// whether a battle has started yet, battles scheduled ahead of time are hidden from players until they do
+func IsBattleStarted(battle models.Setlist) bool {
+	return time.Now().Unix() >= battle.Created
+}
+
+// returns the first start time of a recurring battle that is strictly after the given time
+func NextBattleOccurrence(recurrence models.BattleRecurrence, after time.Time) time.Time {
+	after = after.UTC()
+	next := time.Date(after.Year(), after.Month(), after.Day(), recurrence.Hour, recurrence.Minute, 0, 0, time.UTC)
+
+	step := 1
+	if recurrence.Frequency == "weekly" {
+		step = 7
+		next = next.AddDate(0, 0, (recurrence.Weekday-int(next.Weekday())+7)%7)
+	}
+
+	for !next.After(after) {
+		next = next.AddDate(0, 0, step)
+	}
+
+	return next
+}
+
+// picks the songs for the next battle a template opens
+// queued song lists are only looked at here, the caller removes it from the queue once the battle exists
+func PickBattleSongs(ctx context.Context, database *mongo.Database, pool models.BattleSongPool) ([]int, error) {
+	switch pool.Strategy {
+	case "fixed":
+		if len(pool.SongIDs) == 0 {
+			return nil, errors.New("fixed song pool has no songs")
+		}
+		return pool.SongIDs, nil
+	case "queue":
+		if len(pool.Queue) != 0 && len(pool.Queue[0]) != 0 {
+			return pool.Queue[0], nil
+		}
+		// fall back on the fixed songs if the queue ran dry
+		if len(pool.SongIDs) != 0 {
+			return pool.SongIDs, nil
+		}
+		return nil, errors.New("song queue is empty")
+	case "random":
+		return pickRandomBattleSongs(ctx, database, pool)
+	}
+
+	return nil, fmt.Errorf("unknown song pool strategy %q", pool.Strategy)
+}
+
+func pickRandomBattleSongs(ctx context.Context, database *mongo.Database, pool models.BattleSongPool) ([]int, error) {
+	match := bson.M{}
+
+	if pool.Genre != "" {
+		match["genre"] = bson.M{"$regex": "^" + regexp.QuoteMeta(pool.Genre) + "$", "$options": "i"}
+	}
+
+	if pool.MinTier > 0 || pool.MaxTier > 0 {
+		tierInstrument := pool.TierInstrument
+		if tierInstrument == "" {
+			tierInstrument = "band"
+		}
+
+		tierRange := bson.M{}
+		if pool.MinTier > 0 {
+			tierRange["$gte"] = pool.MinTier
+		}
+		if pool.MaxTier > 0 {
+			tierRange["$lte"] = pool.MaxTier
+		}
+		match["tiers."+tierInstrument] = tierRange
+	}
+
+	cursor, err := database.Collection("songs").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sample", Value: bson.M{"size": pool.Count}}},
+		{{Key: "$project", Value: bson.M{"song_id": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var songs []models.Song
+	if err := cursor.All(ctx, &songs); err != nil {
+		return nil, err
+	}
+
+	if len(songs) == 0 {
+		return nil, errors.New("no songs in the song catalog match the random song pool")
+	}
+
+	songIDs := make([]int, 0, len(songs))
+	for _, song := range songs {
+		songIDs = append(songIDs, song.SongID)
+	}
+
+	return songIDs, nil
+}
+
+// creates the battles of every enabled battle template that is due to open one
+func ScheduleTemplateBattles() {
+	templatesCollection := GocentralDatabase.Collection("battle_templates")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	cursor, err := templatesCollection.Find(ctx, bson.M{"enabled": true})
+	if err != nil {
+		log.Println("Could not get battle templates for scheduling:", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var templates []models.BattleTemplate
+	if err := cursor.All(ctx, &templates); err != nil {
+		log.Println("Could not decode battle templates:", err)
+		return
+	}
+
+	createdCount := 0
+
+	for _, template := range templates {
+		battleID, err := scheduleTemplateBattle(ctx, GocentralDatabase, template)
+		if err != nil {
+			log.Printf("Could not schedule battle for battle template %d: %v", template.TemplateID, err)
+			continue
+		}
+		if battleID != 0 {
+			createdCount++
+		}
+	}
+
+	if createdCount != 0 {
+		log.Printf("Created %d scheduled battles.\n", createdCount)
+	}
+}
+
+// creates the next battle of a template if it is due, returns the new battle's ID or 0 if nothing was created
+func scheduleTemplateBattle(ctx context.Context, database *mongo.Database, template models.BattleTemplate) (int, error) {
+	templatesCollection := database.Collection("battle_templates")
+
+	duration := time.Duration(template.Recurrence.DurationHours) * time.Hour
+	createAhead := time.Duration(template.Recurrence.CreateAheadHours) * time.Hour
+
+	lastStartAt := template.LastStartAt
+	if lastStartAt == 0 {
+		lastStartAt = template.CreatedAt
+	}
+
+	now := time.Now()
+
+	for {
+		start := NextBattleOccurrence(template.Recurrence, time.Unix(lastStartAt, 0))
+		if now.Before(start.Add(-createAhead)) {
+			return 0, nil
+		}
+
+		// whole occurrences that were missed while the server was down are skipped rather than opened late
+		missed := now.After(start.Add(duration))
+
+		var songIDs []int
+		if !missed {
+			var err error
+			songIDs, err = PickBattleSongs(ctx, database, template.SongPool)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		// claim this occurrence so it is only ever created once, even with more than one server running housekeeping
+		res, err := templatesCollection.UpdateOne(ctx,
+			bson.M{"template_id": template.TemplateID, "last_start_at": template.LastStartAt},
+			bson.M{"$set": bson.M{"last_start_at": start.Unix()}},
+		)
+		if err != nil {
+			return 0, err
+		}
+		if res.ModifiedCount == 0 {
+			return 0, nil
+		}
+		template.LastStartAt = start.Unix()
+		lastStartAt = start.Unix()
+
+		if missed {
+			log.Printf("Skipped battle template %d occurrence at %v since it already ended", template.TemplateID, start)
+			continue
+		}
+
+		battleID, err := InsertHarmonixBattle(ctx, database, models.Setlist{
+			Created:      start.Unix(),
+			Title:        template.Title,
+			Desc:         template.Description,
+			SongIDs:      songIDs,
+			TimeEndVal:   int(duration.Seconds()),
+			TimeEndUnits: "seconds",
+			Flags:        template.Flags,
+			Instrument:   template.Instrument,
+			TemplateID:   template.TemplateID,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		update := bson.M{"$set": bson.M{"last_battle_id": battleID}}
+		if template.SongPool.Strategy == "queue" && len(template.SongPool.Queue) != 0 {
+			update["$pop"] = bson.M{"song_pool.queue": -1}
+		}
+		if _, err := templatesCollection.UpdateOne(ctx, bson.M{"template_id": template.TemplateID}, update); err != nil {
+			log.Printf("Could not update battle template %d after creating battle %d: %v", template.TemplateID, battleID, err)
+		}
+
+		log.Printf("Battle template %d created battle #%d titled '%s' starting at %v", template.TemplateID, battleID, template.Title, start)
+		return battleID, nil
+	}
+}
+
+// gets a battle template by its ID, returns nil if it doesn't exist
+func GetBattleTemplate(ctx context.Context, database *mongo.Database, templateID int) (*models.BattleTemplate, error) {
+	var template models.BattleTemplate
+	err := database.Collection("battle_templates").FindOne(ctx, bson.M{"template_id": templateID}).Decode(&template)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
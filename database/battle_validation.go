@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// what a client said about a battle score it submitted
+// older clients and some battle types don't send everything, so anything zeroed or empty is not checked
+// TODO: difficulties aren't checked yet. battles carry a Flags field that is passed through to the game as is,
+// but what its bits mean is unknown, so there is nothing to check a submitted diff_id against until that is worked out
+type BattleSubmission struct {
+	SongID   int
+	RoleIDs  []int
+	BandMask int
+}
+
+// gets a battle by its ID, returns nil if it doesn't exist or isn't a battle
+func GetBattle(ctx context.Context, database *mongo.Database, battleID int) (*models.Setlist, error) {
+	var battle models.Setlist
+	err := database.Collection("setlists").FindOne(ctx, bson.M{"setlist_id": battleID, "type": bson.M{"$in": battleSetlistTypes}}).Decode(&battle)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &battle, nil
+}
+
+// checks a battle score submission against the rules of the battle, returns the reasons it breaks them if any
+func ValidateBattleSubmission(battle *models.Setlist, submission BattleSubmission) []string {
+	if battle == nil {
+		return []string{"battle does not exist"}
+	}
+
+	reasons := []string{}
+
+	if battle.Hidden {
+		reasons = append(reasons, "battle has been hidden by an admin")
+	}
+
+	if !IsBattleStarted(*battle) {
+		reasons = append(reasons, "battle has not started yet")
+	}
+
+	if isExpired, _ := GetSetlistBattleExpiryInfo(*battle); isExpired {
+		reasons = append(reasons, "battle has expired")
+	}
+
+	if submission.SongID != 0 {
+		inBattle := false
+		for _, songID := range battle.SongIDs {
+			if songID == submission.SongID {
+				inBattle = true
+				break
+			}
+		}
+		if !inBattle {
+			reasons = append(reasons, fmt.Sprintf("song %d is not part of the battle", submission.SongID))
+		}
+	}
+
+	if battle.Instrument != 0 {
+		for _, roleID := range submission.RoleIDs {
+			// band scores are checked against every instrument that played
+			instrumentMask := 1 << roleID
+			if roleID == 10 {
+				instrumentMask = submission.BandMask
+			}
+			if instrumentMask&^battle.Instrument != 0 {
+				reasons = append(reasons, fmt.Sprintf("role %d is not allowed in the battle", roleID))
+			}
+		}
+	}
+
+	return reasons
+}
+
+// records a rejected battle score submission in the audit collection
+func LogBattleScoreRejection(ctx context.Context, database *mongo.Database, rejection models.BattleScoreRejection) error {
+	rejection.RejectedAt = time.Now().Unix()
+
+	_, err := database.Collection("battle_score_rejections").InsertOne(ctx, rejection)
+	return err
+}
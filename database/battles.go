@@ -0,0 +1,25 @@
+package database
+
+import (
+	"context"
+
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// counts every battle a player has created, expired ones included
+func CountBattlesForPID(ctx context.Context, database *mongo.Database, pid int) (int, error) {
+	count, err := database.Collection("setlists").CountDocuments(ctx, bson.M{"pid": pid, "type": bson.M{"$in": battleSetlistTypes}})
+	return int(count), err
+}
+
+// returns how many battles a player may create, their own override if an admin set one or the limit from the config
+func GetBattleLimitForUser(user models.User, config *models.Config) int {
+	if user.BattleLimit != nil {
+		return *user.BattleLimit
+	}
+
+	return config.BattleLimit
+}
@@ -0,0 +1,373 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image/png"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"rb3server/binarydata"
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultBinaryDataRevisionRetention = 3
+const defaultBinaryDataQuotaBytes = 16 << 20
+
+// binary data this new is never garbage collected, since the game can upload art before the setlist it belongs to is saved
+const binaryDataGCGracePeriod = 24 * time.Hour
+
+// listing every blob is expensive, so garbage collection runs far less often than the other housekeeping tasks
+const binaryDataGCInterval = 1 * time.Hour
+
+var lastBinaryDataGC time.Time
+
+// every piece of art is also kept as a plain PNG, which is what the web gets
+const CanonicalImageExtension = "png"
+
+// returned by StoreBinaryData when saving would put a player over their binary data quota
+var ErrBinaryDataQuotaExceeded = errors.New("binary data quota exceeded")
+
+func EnsureBinaryDataIndexes(ctx context.Context, database *mongo.Database) error {
+	_, err := database.Collection("binary_data_objects").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "type", Value: 1}, {Key: "key", Value: 1}, {Key: "platform_extension", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// quota lookups
+			Keys: bson.D{{Key: "owner_pid", Value: 1}},
+		},
+	})
+	return err
+}
+
+// how many bytes of binary data a player has stored across every revision they uploaded
+func GetBinaryDataUsage(ctx context.Context, database *mongo.Database, pid int) (int64, error) {
+	cursor, err := database.Collection("binary_data_objects").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"owner_pid": pid}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$total_size"}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Total int64 `bson:"total"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	return results[0].Total, nil
+}
+
+// saves a revision of some binary data to the store and records its hash in the revision history
+// only the newest revisions are kept, older ones are deleted from the store, and players can't go over their quota
+// ownerPID 0 is for admin uploads, which don't count against any quota
+func StoreBinaryData(ctx context.Context, database *mongo.Database, store binarydata.Store, dataType string, key string, revision int64, platformExtension string, ownerPID int, data []byte) error {
+	objectsCollection := database.Collection("binary_data_objects")
+	filter := bson.M{"type": dataType, "key": key, "platform_extension": platformExtension}
+
+	var object models.BinaryDataObject
+	err := objectsCollection.FindOne(ctx, filter).Decode(&object)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	hash := sha256.Sum256(data)
+	newRevision := models.BinaryDataRevision{
+		Revision:   revision,
+		Hash:       hex.EncodeToString(hash[:]),
+		Size:       int64(len(data)),
+		UploadedAt: time.Now().Unix(),
+	}
+
+	// the game saves the same art again whenever the setlist is saved, there is no need to write it twice
+	for _, existing := range object.Revisions {
+		if existing.Revision == revision && existing.Hash == newRevision.Hash {
+			return nil
+		}
+	}
+
+	retention := defaultBinaryDataRevisionRetention
+	quota := int64(defaultBinaryDataQuotaBytes)
+	if config, err := GetCachedConfig(ctx); err == nil {
+		if config.BinaryDataRevisionRetention > 0 {
+			retention = config.BinaryDataRevisionRetention
+		}
+		if config.BinaryDataQuotaBytes > 0 {
+			quota = config.BinaryDataQuotaBytes
+		}
+	}
+
+	// work out which revisions are kept once this one is in
+	revisions := []models.BinaryDataRevision{newRevision}
+	for _, existing := range object.Revisions {
+		if existing.Revision != revision {
+			revisions = append(revisions, existing)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision < revisions[j].Revision })
+
+	var dropped []models.BinaryDataRevision
+	if len(revisions) > retention {
+		dropped = revisions[:len(revisions)-retention]
+		revisions = revisions[len(revisions)-retention:]
+	}
+
+	totalSize := int64(0)
+	for _, kept := range revisions {
+		totalSize += kept.Size
+	}
+
+	if ownerPID != 0 {
+		usage, err := GetBinaryDataUsage(ctx, database, ownerPID)
+		if err != nil {
+			return err
+		}
+		// this object's old size only counts if it was this player's to begin with
+		if object.OwnerPID == ownerPID {
+			usage -= object.TotalSize
+		}
+		if usage+totalSize > quota {
+			return ErrBinaryDataQuotaExceeded
+		}
+	}
+
+	if err := store.Put(ctx, binarydata.Key(dataType, key, revision, platformExtension), data); err != nil {
+		return err
+	}
+
+	for _, old := range dropped {
+		if old.Revision == revision {
+			continue
+		}
+		if err := store.Delete(ctx, binarydata.Key(dataType, key, old.Revision, platformExtension)); err != nil {
+			log.Printf("Could not delete old revision %d of %s %s: %v", old.Revision, dataType, key, err)
+		}
+	}
+
+	_, err = objectsCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+		"owner_pid":  ownerPID,
+		"revisions":  revisions,
+		"total_size": totalSize,
+		"updated_at": newRevision.UploadedAt,
+	}}, options.Update().SetUpsert(true))
+
+	return err
+}
+
+// converts art uploaded from one console into every other console's format, plus the canonical PNG
+// the conversions are admin-owned so they don't count against the uploader's quota
+func ConvertBinaryData(ctx context.Context, database *mongo.Database, store binarydata.Store, dataType string, key string, revision int64, fromExtension string, data []byte) error {
+	img, err := binarydata.DecodeTexture(data, fromExtension)
+	if err != nil {
+		return err
+	}
+
+	for _, platformExtension := range binarydata.PlatformExtensions {
+		if platformExtension == fromExtension {
+			continue
+		}
+
+		converted, err := binarydata.EncodeTexture(img, platformExtension)
+		if err != nil {
+			return err
+		}
+
+		if err := StoreBinaryData(ctx, database, store, dataType, key, revision, platformExtension, 0, converted); err != nil {
+			return err
+		}
+	}
+
+	var canonical bytes.Buffer
+	if err := png.Encode(&canonical, img); err != nil {
+		return err
+	}
+
+	return StoreBinaryData(ctx, database, store, dataType, key, revision, CanonicalImageExtension, 0, canonical.Bytes())
+}
+
+// loads a revision of some binary data in the given format, converting it from another console's upload if it only exists there
+// returns binarydata.ErrNotFound if the revision doesn't exist in any format
+func LoadBinaryData(ctx context.Context, database *mongo.Database, store binarydata.Store, dataType string, key string, revision int64, platformExtension string) ([]byte, error) {
+	data, err := store.Get(ctx, binarydata.Key(dataType, key, revision, platformExtension))
+	if err != binarydata.ErrNotFound {
+		return data, err
+	}
+
+	// art saved before conversion existed only has the format of the console that uploaded it
+	for _, fromExtension := range binarydata.PlatformExtensions {
+		if fromExtension == platformExtension {
+			continue
+		}
+
+		source, err := store.Get(ctx, binarydata.Key(dataType, key, revision, fromExtension))
+		if err != nil {
+			continue
+		}
+
+		if err := ConvertBinaryData(ctx, database, store, dataType, key, revision, fromExtension, source); err != nil {
+			return nil, err
+		}
+
+		return store.Get(ctx, binarydata.Key(dataType, key, revision, platformExtension))
+	}
+
+	return nil, binarydata.ErrNotFound
+}
+
+// loads the newest revision of some art as a PNG
+func LoadCanonicalImage(ctx context.Context, database *mongo.Database, store binarydata.Store, dataType string, key string) ([]byte, error) {
+	objects, err := store.List(ctx, dataType+"/"+key+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	latest := int64(-1)
+	for _, object := range objects {
+		_, objectKey, revision, _, ok := binarydata.ParseKey(object.Key)
+		if ok && objectKey == key && revision > latest {
+			latest = revision
+		}
+	}
+
+	if latest < 0 {
+		return nil, binarydata.ErrNotFound
+	}
+
+	return LoadBinaryData(ctx, database, store, dataType, key, latest, CanonicalImageExtension)
+}
+
+// whether the setlist, battle or band a piece of binary data belongs to still exists
+// unknown types are always treated as owned so nothing is deleted by mistake
+func binaryDataOwnerExists(ctx context.Context, database *mongo.Database, dataType string, key string) (bool, error) {
+	var filter bson.M
+	var collection string
+
+	switch dataType {
+	case "setlist_art":
+		collection = "setlists"
+		filter = bson.M{"guid": key}
+	case "battle_art":
+		battleID, err := strconv.Atoi(key)
+		if err != nil {
+			return false, nil
+		}
+		collection = "setlists"
+		filter = bson.M{"setlist_id": battleID, "type": bson.M{"$in": battleSetlistTypes}}
+	case "band_logo":
+		bandID, err := strconv.Atoi(key)
+		if err != nil {
+			return false, nil
+		}
+		collection = "bands"
+		filter = bson.M{"band_id": bandID}
+	default:
+		return true, nil
+	}
+
+	count, err := database.Collection(collection).CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+// deletes binary data whose setlist, battle or band no longer exists from the store, along with its revision history
+// returns how many blobs were deleted
+func CollectOrphanedBinaryData(ctx context.Context, database *mongo.Database, store binarydata.Store, now time.Time) (int, error) {
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	type owner struct {
+		dataType string
+		key      string
+	}
+
+	ownerExists := map[owner]bool{}
+	// owners with blobs that are too new to delete yet keep their revision history
+	ownerHasRecentBlobs := map[owner]bool{}
+	deleted := 0
+
+	for _, object := range objects {
+		dataType, key, _, _, ok := binarydata.ParseKey(object.Key)
+		if !ok {
+			continue
+		}
+
+		o := owner{dataType, key}
+		exists, checked := ownerExists[o]
+		if !checked {
+			exists, err = binaryDataOwnerExists(ctx, database, dataType, key)
+			if err != nil {
+				return deleted, err
+			}
+			ownerExists[o] = exists
+		}
+
+		if exists {
+			continue
+		}
+
+		if now.Sub(object.ModifiedAt) < binaryDataGCGracePeriod {
+			ownerHasRecentBlobs[o] = true
+			continue
+		}
+
+		if err := store.Delete(ctx, object.Key); err != nil {
+			log.Printf("Could not delete orphaned binary data %s: %v", object.Key, err)
+			ownerHasRecentBlobs[o] = true
+			continue
+		}
+		deleted++
+	}
+
+	for o, exists := range ownerExists {
+		if exists || ownerHasRecentBlobs[o] {
+			continue
+		}
+		if _, err := database.Collection("binary_data_objects").DeleteMany(ctx, bson.M{"type": o.dataType, "key": o.key}); err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// housekeeping task that garbage collects binary data for setlists, battles and bands that have been deleted
+func CleanupOrphanedBinaryData() {
+	if time.Since(lastBinaryDataGC) < binaryDataGCInterval {
+		return
+	}
+	lastBinaryDataGC = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	deleted, err := CollectOrphanedBinaryData(ctx, GocentralDatabase, binarydata.DefaultStore(), time.Now())
+	if err != nil {
+		log.Println("Could not garbage collect binary data:", err)
+	}
+
+	if deleted != 0 {
+		log.Printf("Deleted %d orphaned binary data blobs.\n", deleted)
+	}
+}
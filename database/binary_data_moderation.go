@@ -0,0 +1,125 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"time"
+
+	"rb3server/binarydata"
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// the kinds of binary data players can put in front of everyone else, battle art is only uploaded by whoever runs the battle
+var moderatedBinaryDataTypes = map[string]bool{
+	"band_logo":   true,
+	"setlist_art": true,
+}
+
+// the size of the placeholder when the art being replaced can't be read
+const defaultPlaceholderSize = 256
+
+// puts a newly uploaded revision of a band logo or setlist art in the moderation queue
+// other types and revisions that are already queued are ignored
+func QueueBinaryDataForModeration(ctx context.Context, database *mongo.Database, dataType string, key string, revision int64, platformExtension string, ownerPID int) error {
+	if !moderatedBinaryDataTypes[dataType] {
+		return nil
+	}
+
+	moderationCollection := database.Collection("binary_data_moderation")
+
+	// the game saves the same art again whenever the setlist is saved, so only the first upload of a revision is queued
+	count, err := moderationCollection.CountDocuments(ctx, bson.M{"type": dataType, "key": key, "revision": revision})
+	if err != nil {
+		return err
+	}
+	if count != 0 {
+		return nil
+	}
+
+	moderationID, err := GetNextModerationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = moderationCollection.InsertOne(ctx, models.BinaryDataModeration{
+		ModerationID:      moderationID,
+		Type:              dataType,
+		Key:               key,
+		Revision:          revision,
+		PlatformExtension: platformExtension,
+		OwnerPID:          ownerPID,
+		UploadedAt:        time.Now().Unix(),
+		Status:            "pending",
+	})
+
+	return err
+}
+
+// returns nil if there is no moderation entry with that ID
+func GetBinaryDataModeration(ctx context.Context, database *mongo.Database, moderationID int) (*models.BinaryDataModeration, error) {
+	var moderation models.BinaryDataModeration
+
+	err := database.Collection("binary_data_moderation").FindOne(ctx, bson.M{"moderation_id": moderationID}).Decode(&moderation)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &moderation, nil
+}
+
+// approves uploaded art, leaving it as it is
+func ApproveBinaryData(ctx context.Context, database *mongo.Database, moderation *models.BinaryDataModeration, note string) error {
+	return setModerationStatus(ctx, database, moderation.ModerationID, "approved", note)
+}
+
+// replaces uploaded art with a placeholder of the same size in every platform's format and as a PNG
+// the revision history keeps the hash of the original upload, so the game saving the same art again doesn't bring it back
+func ReplaceBinaryDataWithPlaceholder(ctx context.Context, database *mongo.Database, store binarydata.Store, moderation *models.BinaryDataModeration, note string) error {
+	width, height := defaultPlaceholderSize, defaultPlaceholderSize
+
+	original, err := store.Get(ctx, binarydata.Key(moderation.Type, moderation.Key, moderation.Revision, moderation.PlatformExtension))
+	if err == nil {
+		if img, err := binarydata.DecodeTexture(original, moderation.PlatformExtension); err == nil {
+			width, height = img.Bounds().Dx(), img.Bounds().Dy()
+		}
+	}
+
+	placeholder := binarydata.Placeholder(width, height)
+
+	for _, platformExtension := range binarydata.PlatformExtensions {
+		data, err := binarydata.EncodeTexture(placeholder, platformExtension)
+		if err != nil {
+			return err
+		}
+		if err := store.Put(ctx, binarydata.Key(moderation.Type, moderation.Key, moderation.Revision, platformExtension), data); err != nil {
+			return err
+		}
+	}
+
+	var canonical bytes.Buffer
+	if err := png.Encode(&canonical, placeholder); err != nil {
+		return err
+	}
+	if err := store.Put(ctx, binarydata.Key(moderation.Type, moderation.Key, moderation.Revision, CanonicalImageExtension), canonical.Bytes()); err != nil {
+		return err
+	}
+
+	return setModerationStatus(ctx, database, moderation.ModerationID, "replaced", note)
+}
+
+func setModerationStatus(ctx context.Context, database *mongo.Database, moderationID int, status string, note string) error {
+	_, err := database.Collection("binary_data_moderation").UpdateOne(ctx, bson.M{"moderation_id": moderationID}, bson.M{"$set": bson.M{
+		"status":      status,
+		"reviewed_at": time.Now().Unix(),
+		"review_note": note,
+	}})
+
+	return err
+}
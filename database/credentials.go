@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"rb3server/authentication"
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// the size of the keys Quazal clients derive from their password
+const kerberosKeySize = 16
+
+// decodes a stored Kerberos key, returning nil for accounts that use the platform's password
+func DecodeKerberosKey(stored string) []byte {
+	key, err := hex.DecodeString(stored)
+	if err != nil || len(key) != kerberosKeySize {
+		return nil
+	}
+
+	return key
+}
+
+// gets the stored Kerberos key for a user or, if there is no user with the PID, a machine
+// returns nil if the account doesn't exist or uses the platform's password
+func GetKerberosKey(ctx context.Context, database *mongo.Database, pid uint32) ([]byte, error) {
+	var user models.User
+	err := database.Collection("users").FindOne(ctx, bson.M{"pid": pid}).Decode(&user)
+	if err == nil {
+		return DecodeKerberosKey(user.KerberosKey), nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	var machine models.Machine
+	err = database.Collection("machines").FindOne(ctx, bson.M{"machine_id": pid}).Decode(&machine)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeKerberosKey(machine.KerberosKey), nil
+}
+
+// the fields that store a Kerberos key, or remove it if the key is nil
+func kerberosKeyUpdate(key []byte) bson.M {
+	if key == nil {
+		return bson.M{"$unset": bson.M{"kerberos_key": "", "kerberos_key_rotated_at": ""}}
+	}
+
+	return bson.M{"$set": bson.M{"kerberos_key": hex.EncodeToString(key), "kerberos_key_rotated_at": time.Now()}}
+}
+
+// stores the Kerberos key for a user, nil makes them go back to the platform's password
+func SetUserKerberosKey(ctx context.Context, database *mongo.Database, pid int, key []byte) error {
+	res, err := database.Collection("users").UpdateOne(ctx, bson.M{"pid": pid}, kerberosKeyUpdate(key))
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// stores the Kerberos key for a Wii, nil makes it go back to its friend code
+func SetMachineKerberosKey(ctx context.Context, database *mongo.Database, machineID int, key []byte) error {
+	res, err := database.Collection("machines").UpdateOne(ctx, bson.M{"machine_id": machineID}, kerberosKeyUpdate(key))
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// gives a user a newly generated secret, returning it so it can be handed to the player's client
+// the old secret or the platform's password stops working straight away
+func RotateUserSecret(ctx context.Context, database *mongo.Database, pid int) (string, error) {
+	secret, err := authentication.GenerateAccountSecret()
+	if err != nil {
+		return "", err
+	}
+
+	if err := SetUserKerberosKey(ctx, database, pid, authentication.DeriveKerberosKey(uint32(pid), secret)); err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// gives a Wii a newly generated secret, returning it so it can be handed to the machine
+func RotateMachineSecret(ctx context.Context, database *mongo.Database, machineID int) (string, error) {
+	secret, err := authentication.GenerateAccountSecret()
+	if err != nil {
+		return "", err
+	}
+
+	if err := SetMachineKerberosKey(ctx, database, machineID, authentication.DeriveKerberosKey(uint32(machineID), secret)); err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
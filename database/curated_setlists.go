@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// generates a random GUID for setlists the server creates itself
+func GenerateSetlistGUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	// version 4, variant 1
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// inserts a new curated Harmonix Recommends setlist, filling in everything that is the same for every curated setlist
+func InsertCuratedSetlist(ctx context.Context, database *mongo.Database, setlist models.Setlist) (int, error) {
+	setlistID, err := GetNextSetlistID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	setlist.SetlistID = setlistID
+	setlist.PID = 0
+	setlist.Type = 2
+	setlist.Owner = "Harmonix"
+	setlist.Shared = "t"
+	setlist.GUID = GenerateSetlistGUID()
+	setlist.Created = time.Now().Unix()
+	setlist.SongNames = GetSongNamesInOrder(ctx, database, setlist.SongIDs)
+
+	if _, err := database.Collection("setlists").InsertOne(ctx, setlist); err != nil {
+		return 0, err
+	}
+
+	return setlistID, nil
+}
+
+// whether a curated setlist should be shown to a client on the given console type and region right now
+// this lets the community team schedule a themed setlist for a single week ahead of time, or only for one platform or region
+func IsCuratedSetlistVisible(setlist models.Setlist, consoleType int, region string, now time.Time) bool {
+	if setlist.Retired {
+		return false
+	}
+
+	if setlist.VisibleFrom != 0 && now.Unix() < setlist.VisibleFrom {
+		return false
+	}
+
+	if setlist.VisibleUntil != 0 && now.Unix() >= setlist.VisibleUntil {
+		return false
+	}
+
+	if len(setlist.Platforms) != 0 {
+		// RPCS3 gets the same setlists as PS3
+		if consoleType == 3 {
+			consoleType = 1
+		}
+
+		found := false
+		for _, platform := range setlist.Platforms {
+			if platform == consoleType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(setlist.Regions) != 0 {
+		found := false
+		for _, r := range setlist.Regions {
+			if strings.EqualFold(r, region) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
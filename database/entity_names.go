@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const defaultEntityNameMaxLength = 32
+
+// why a character or band name was refused, if it was
+type NameCheckResult int
+
+const (
+	NameAllowed NameCheckResult = iota
+	NameInvalid                 // empty, too long or has characters that can't be shown
+	NameProfane                 // contains something the profanity filter rejects
+	NameTaken                   // another player's character or band already has it
+)
+
+// checks a name is something the game can show, which means valid UTF-8 without control characters and not just whitespace
+func isValidEntityName(name string, maxLength int) bool {
+	if strings.TrimSpace(name) == "" || !utf8.ValidString(name) || utf8.RuneCountInString(name) > maxLength {
+		return false
+	}
+
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checks a name, returning the name to store, which has anything the profanity filter masks replaced with asterisks
+func checkEntityName(ctx context.Context, database *mongo.Database, collection string, name string, pid int) (NameCheckResult, string, error) {
+	config, err := GetCachedConfig(ctx)
+	if err != nil {
+		log.Printf("Could not get config for name check: %v", err)
+		config = &models.Config{}
+	}
+
+	maxLength := defaultEntityNameMaxLength
+	if config.EntityNameMaxLength > 0 {
+		maxLength = config.EntityNameMaxLength
+	}
+
+	if !isValidEntityName(name, maxLength) {
+		return NameInvalid, name, nil
+	}
+
+	filtered, err := FilterText(ctx, name)
+	if err != nil {
+		return NameAllowed, name, err
+	}
+	if filtered.Rejected() {
+		return NameProfane, name, nil
+	}
+	name = filtered.Masked
+
+	if config.UniqueEntityNames {
+		// players can reuse names across their own characters and bands
+		count, err := database.Collection(collection).CountDocuments(ctx, bson.M{
+			"name":      bson.M{"$regex": "^" + regexp.QuoteMeta(name) + "$", "$options": "i"},
+			"owner_pid": bson.M{"$ne": pid},
+		})
+		if err != nil {
+			return NameAllowed, name, err
+		}
+		if count > 0 {
+			return NameTaken, name, nil
+		}
+	}
+
+	return NameAllowed, name, nil
+}
+
+// checks whether a player can give one of their characters a name, and what the name should be stored as
+func CheckCharacterName(ctx context.Context, database *mongo.Database, name string, pid int) (NameCheckResult, string, error) {
+	return checkEntityName(ctx, database, "characters", name, pid)
+}
+
+// checks whether a player can give their band a name, and what the name should be stored as
+func CheckBandName(ctx context.Context, database *mongo.Database, name string, pid int) (NameCheckResult, string, error) {
+	return checkEntityName(ctx, database, "bands", name, pid)
+}
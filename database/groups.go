@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// privileges that groups can grant, check them with HasPrivilege rather than checking for specific groups
+// unlimited_battles, create_harmonix_battles and create_harmonix_setlists change what happens in-game for members, so they are
+// only granted by groups that exist for them rather than being bundled into broader groups like admin
+const (
+	PrivilegeUnlimitedBattles       = "unlimited_battles"
+	PrivilegeCreateHarmonixBattles  = "create_harmonix_battles"
+	PrivilegeCreateHarmonixSetlists = "create_harmonix_setlists"
+	PrivilegeVerifiedBadge          = "verified_badge"
+	PrivilegeModeratorMessaging     = "moderator_messaging"
+)
+
+// every group users can be put in
+var GroupCatalog = []models.Group{
+	{
+		ID:          "admin",
+		Name:        "Administrator",
+		Description: "Server administrators. Doesn't change anything in-game, add them to battle_admin or setlist_curator as well for that.",
+		Privileges: []string{
+			PrivilegeVerifiedBadge,
+			PrivilegeModeratorMessaging,
+		},
+	},
+	{
+		ID:          "battle_admin",
+		Name:        "Battle Administrator",
+		Description: "Battles they create in-game are global Harmonix battles, and they aren't held to the battle limit.",
+		Privileges:  []string{PrivilegeUnlimitedBattles, PrivilegeCreateHarmonixBattles},
+	},
+	{
+		ID:          "setlist_curator",
+		Name:        "Setlist Curator",
+		Description: "Every setlist they create or update in-game becomes a Harmonix Recommends setlist shown to everyone.",
+		Privileges:  []string{PrivilegeCreateHarmonixSetlists},
+	},
+	{
+		ID:          "moderator",
+		Name:        "Moderator",
+		Description: "Community moderators who can message players on behalf of the server.",
+		Privileges:  []string{PrivilegeModeratorMessaging, PrivilegeVerifiedBadge},
+	},
+	{
+		ID:          "verified",
+		Name:        "Verified",
+		Description: "Well known community members, shown with a verified badge.",
+		Privileges:  []string{PrivilegeVerifiedBadge},
+	},
+}
+
+// gets a group from the catalog by its ID
+func GetGroup(groupID string) (models.Group, bool) {
+	for _, group := range GroupCatalog {
+		if group.ID == groupID {
+			return group, true
+		}
+	}
+
+	return models.Group{}, false
+}
+
+// returns the IDs of every group that grants a privilege
+func GetGroupsWithPrivilege(privilege string) []string {
+	groupIDs := []string{}
+	for _, group := range GroupCatalog {
+		for _, groupPrivilege := range group.Privileges {
+			if groupPrivilege == privilege {
+				groupIDs = append(groupIDs, group.ID)
+				break
+			}
+		}
+	}
+
+	return groupIDs
+}
+
+// whether a PID is in any group that grants a privilege
+// this is the one place privileges should be checked so new groups and privileges only need adding to the catalog
+func HasPrivilege(pid int, privilege string) bool {
+	if pid == 0 {
+		return false
+	}
+
+	groupIDs := GetGroupsWithPrivilege(privilege)
+	if len(groupIDs) == 0 {
+		return false
+	}
+
+	count, err := GocentralDatabase.Collection("users").CountDocuments(context.TODO(), bson.M{"pid": pid, "groups": bson.M{"$in": groupIDs}})
+	if err != nil {
+		return false
+	}
+
+	return count > 0
+}
+
+// puts a user in a group, returns false if the user doesn't exist
+func AddUserToGroup(ctx context.Context, database *mongo.Database, pid int, groupID string) (bool, error) {
+	res, err := database.Collection("users").UpdateOne(ctx, bson.M{"pid": pid}, bson.M{"$addToSet": bson.M{"groups": groupID}})
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount != 0, nil
+}
+
+// takes a user out of a group, returns false if the user doesn't exist
+func RemoveUserFromGroup(ctx context.Context, database *mongo.Database, pid int, groupID string) (bool, error) {
+	res, err := database.Collection("users").UpdateOne(ctx, bson.M{"pid": pid}, bson.M{"$pull": bson.M{"groups": groupID}})
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount != 0, nil
+}
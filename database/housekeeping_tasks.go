@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -123,6 +124,93 @@ func CleanupInvalidScores() {
 	}
 }
 
+// moves scores already on the leaderboards for some songs that are impossible according to the song catalog into quarantine
+// this catches scores that were recorded before limits were imported for their song, new scores are checked when they are submitted
+// so this only needs to run after limits are imported rather than as part of housekeeping
+func QuarantineImplausibleScores(ctx context.Context, songIDs []int) int {
+	songsCollection := GocentralDatabase.Collection("songs")
+	scoresCollection := GocentralDatabase.Collection("scores")
+
+	cursor, err := songsCollection.Find(ctx, bson.M{"song_id": bson.M{"$in": songIDs}, "limits.0": bson.M{"$exists": true}})
+	if err != nil {
+		log.Println("Could not get songs with limits: ", err)
+		return 0
+	}
+	defer cursor.Close(ctx)
+
+	var songs []models.Song
+	if err := cursor.All(ctx, &songs); err != nil {
+		log.Println("Could not decode songs with limits: ", err)
+		return 0
+	}
+
+	quarantinedCount := 0
+
+	for _, song := range songs {
+		for _, limits := range song.Limits {
+			// only look at scores that could possibly break the limits rather than every score on the song
+			filter := bson.M{
+				"song_id":   song.SongID,
+				"role_id":   limits.RoleID,
+				"diff_id":   limits.DiffID,
+				"battle_id": bson.M{"$not": bson.M{"$gt": 0}},
+			}
+
+			if limits.NoteCount != 0 {
+				conditions := bson.A{}
+				if limits.MaxScore > 0 {
+					conditions = append(conditions, bson.M{"score": bson.M{"$gt": limits.MaxScore}})
+				}
+				for idx, threshold := range limits.StarThresholds {
+					conditions = append(conditions, bson.M{"stars": idx + 1, "score": bson.M{"$lt": threshold}})
+				}
+				if len(conditions) == 0 {
+					continue
+				}
+				filter["$or"] = conditions
+			}
+
+			scoresCursor, err := scoresCollection.Find(ctx, filter)
+			if err != nil {
+				log.Println("Could not get scores to validate: ", err)
+				continue
+			}
+
+			var scores []struct {
+				ID           primitive.ObjectID `bson:"_id"`
+				models.Score `bson:",inline"`
+			}
+			err = scoresCursor.All(ctx, &scores)
+			scoresCursor.Close(ctx)
+			if err != nil {
+				log.Println("Could not decode scores to validate: ", err)
+				continue
+			}
+
+			for _, score := range scores {
+				reasons := ValidateScoreAgainstLimits(score.Score, &limits)
+				if len(reasons) == 0 {
+					continue
+				}
+
+				if err := QuarantineScore(ctx, GocentralDatabase, score.Score, reasons); err != nil {
+					log.Println("Could not quarantine score: ", err)
+					continue
+				}
+
+				if _, err := scoresCollection.DeleteOne(ctx, bson.M{"_id": score.ID}); err != nil {
+					log.Println("Could not delete quarantined score: ", err)
+					continue
+				}
+
+				quarantinedCount++
+			}
+		}
+	}
+
+	return quarantinedCount
+}
+
 func DeleteExpiredBattles() {
 	setlistsCollection := GocentralDatabase.Collection("setlists")
 
@@ -142,14 +230,20 @@ func DeleteExpiredBattles() {
 		var setlist models.Setlist
 		cursor.Decode(&setlist)
 
-		isExpired, expiryTime := GetBattleExpiryInfo(setlist.SetlistID)
+		isExpired, expiryTime := GetSetlistBattleExpiryInfo(setlist)
 
 		if isExpired {
 			// allow players 3 days to view the leaderboards of the setlist before it is nuked
-			// the game itself should prevent recording scores at this time, but we should add a check for this in the battle score record too
+			// battle score records for expired battles are rejected, so the standings can't change during this time
 			expiredTime := expiryTime.Add(3 * 24 * time.Hour)
 
 			if time.Now().After(expiredTime) {
+				// never lose the standings, ArchiveClosedBattles normally got to it long before now
+				if _, _, err := ArchiveBattleResult(ctx, GocentralDatabase, setlist); err != nil {
+					log.Printf("Could not archive results of battle %d, not deleting it: %v", setlist.SetlistID, err)
+					continue
+				}
+
 				_, err := setlistsCollection.DeleteOne(ctx, bson.M{"setlist_id": setlist.SetlistID})
 				if err != nil {
 					log.Println("Could not delete expired battle: ", err)
@@ -159,7 +253,7 @@ func DeleteExpiredBattles() {
 
 				// delete all scores associated with this setlist
 				scoresCollection := GocentralDatabase.Collection("scores")
-				_, err = scoresCollection.DeleteMany(ctx, bson.M{"setlist_id": setlist.SetlistID})
+				_, err = scoresCollection.DeleteMany(ctx, bson.M{"battle_id": setlist.SetlistID})
 
 				if err != nil {
 					log.Println("Could not delete scores associated with expired battle: ", err)
@@ -218,7 +312,7 @@ func CleanupBannedUserAccomplishments() {
 		return
 	}
 
-	accomplishmentsCollection := GocentralDatabase.Collection("accomplishments")
+	accomplishmentsCollection := GocentralDatabase.Collection("accomplishment_scores")
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
@@ -237,112 +331,14 @@ func CleanupBannedUserAccomplishments() {
 		return
 	}
 
-	// Get the accomplishments document
-	var accomplishments models.Accomplishments
-	err = accomplishmentsCollection.FindOne(ctx, bson.M{}).Decode(&accomplishments)
+	res, err := accomplishmentsCollection.DeleteMany(ctx, bson.M{"pid": bson.M{"$in": bannedPIDs}})
 	if err != nil {
-		if err != mongo.ErrNoDocuments {
-			log.Println("Could not get accomplishments for banned user cleanup:", err)
-		}
+		log.Println("Could not delete accomplishments for banned users:", err)
 		return
 	}
 
-	// Helper to check if a PID is banned
-	isBanned := func(pid int) bool {
-		for _, bannedPID := range bannedPIDs {
-			if pid == bannedPID {
-				return true
-			}
-		}
-		return false
-	}
-
-	// Helper to filter out banned PIDs from a slice
-	filterEntries := func(entries []models.AccomplishmentScoreEntry) []models.AccomplishmentScoreEntry {
-		filtered := make([]models.AccomplishmentScoreEntry, 0, len(entries))
-		for _, entry := range entries {
-			if !isBanned(entry.PID) {
-				filtered = append(filtered, entry)
-			}
-		}
-		return filtered
-	}
-
-	// Filter all accomplishment leaderboards
-	accomplishments.LBGoalValueCampaignMetascore = filterEntries(accomplishments.LBGoalValueCampaignMetascore)
-	accomplishments.LBGoalValueAccTourgoldlocal1 = filterEntries(accomplishments.LBGoalValueAccTourgoldlocal1)
-	accomplishments.LBGoalValueAccTourgoldlocal2 = filterEntries(accomplishments.LBGoalValueAccTourgoldlocal2)
-	accomplishments.LBGoalValueAccTourgoldregional1 = filterEntries(accomplishments.LBGoalValueAccTourgoldregional1)
-	accomplishments.LBGoalValueAccTourgoldregional2 = filterEntries(accomplishments.LBGoalValueAccTourgoldregional2)
-	accomplishments.LBGoalValueAccTourgoldcontinental1 = filterEntries(accomplishments.LBGoalValueAccTourgoldcontinental1)
-	accomplishments.LBGoalValueAccTourgoldcontinental2 = filterEntries(accomplishments.LBGoalValueAccTourgoldcontinental2)
-	accomplishments.LBGoalValueAccTourgoldcontinental3 = filterEntries(accomplishments.LBGoalValueAccTourgoldcontinental3)
-	accomplishments.LBGoalValueAccTourgoldglobal1 = filterEntries(accomplishments.LBGoalValueAccTourgoldglobal1)
-	accomplishments.LBGoalValueAccTourgoldglobal2 = filterEntries(accomplishments.LBGoalValueAccTourgoldglobal2)
-	accomplishments.LBGoalValueAccTourgoldglobal3 = filterEntries(accomplishments.LBGoalValueAccTourgoldglobal3)
-	accomplishments.LBGoalValueAccOverdrivemaintain3 = filterEntries(accomplishments.LBGoalValueAccOverdrivemaintain3)
-	accomplishments.LBGoalValueAccOverdrivecareer = filterEntries(accomplishments.LBGoalValueAccOverdrivecareer)
-	accomplishments.LBGoalValueAccCareersaves = filterEntries(accomplishments.LBGoalValueAccCareersaves)
-	accomplishments.LBGoalValueAccMillionpoints = filterEntries(accomplishments.LBGoalValueAccMillionpoints)
-	accomplishments.LBGoalValueAccBassstreaklarge = filterEntries(accomplishments.LBGoalValueAccBassstreaklarge)
-	accomplishments.LBGoalValueAccHopothreehundredbass = filterEntries(accomplishments.LBGoalValueAccHopothreehundredbass)
-	accomplishments.LBGoalValueAccDrumfill170 = filterEntries(accomplishments.LBGoalValueAccDrumfill170)
-	accomplishments.LBGoalValueAccDrumstreaklong = filterEntries(accomplishments.LBGoalValueAccDrumstreaklong)
-	accomplishments.LBGoalValueAccDeployguitarfour = filterEntries(accomplishments.LBGoalValueAccDeployguitarfour)
-	accomplishments.LBGoalValueAccGuitarstreaklarge = filterEntries(accomplishments.LBGoalValueAccGuitarstreaklarge)
-	accomplishments.LBGoalValueAccHopoonethousand = filterEntries(accomplishments.LBGoalValueAccHopoonethousand)
-	accomplishments.LBGoalValueAccDoubleawesomealot = filterEntries(accomplishments.LBGoalValueAccDoubleawesomealot)
-	accomplishments.LBGoalValueAccTripleawesomealot = filterEntries(accomplishments.LBGoalValueAccTripleawesomealot)
-	accomplishments.LBGoalValueAccKeystreaklong = filterEntries(accomplishments.LBGoalValueAccKeystreaklong)
-	accomplishments.LBGoalValueAccProbassstreakepic = filterEntries(accomplishments.LBGoalValueAccProbassstreakepic)
-	accomplishments.LBGoalValueAccProdrumroll3 = filterEntries(accomplishments.LBGoalValueAccProdrumroll3)
-	accomplishments.LBGoalValueAccProdrumstreaklong = filterEntries(accomplishments.LBGoalValueAccProdrumstreaklong)
-	accomplishments.LBGoalValueAccProguitarstreakepic = filterEntries(accomplishments.LBGoalValueAccProguitarstreakepic)
-	accomplishments.LBGoalValueAccProkeystreaklong = filterEntries(accomplishments.LBGoalValueAccProkeystreaklong)
-	accomplishments.LBGoalValueAccDeployvocals = filterEntries(accomplishments.LBGoalValueAccDeployvocals)
-	accomplishments.LBGoalValueAccDeployvocalsonehundred = filterEntries(accomplishments.LBGoalValueAccDeployvocalsonehundred)
-
-	// Update the document
-	update := bson.M{
-		"$set": bson.M{
-			"lb_goal_value_campaign_metascore":         accomplishments.LBGoalValueCampaignMetascore,
-			"lb_goal_value_acc_tourgoldlocal1":         accomplishments.LBGoalValueAccTourgoldlocal1,
-			"lb_goal_value_acc_tourgoldlocal2":         accomplishments.LBGoalValueAccTourgoldlocal2,
-			"lb_goal_value_acc_tourgoldregional1":      accomplishments.LBGoalValueAccTourgoldregional1,
-			"lb_goal_value_acc_tourgoldregional2":      accomplishments.LBGoalValueAccTourgoldregional2,
-			"lb_goal_value_acc_tourgoldcontinental1":   accomplishments.LBGoalValueAccTourgoldcontinental1,
-			"lb_goal_value_acc_tourgoldcontinental2":   accomplishments.LBGoalValueAccTourgoldcontinental2,
-			"lb_goal_value_acc_tourgoldcontinental3":   accomplishments.LBGoalValueAccTourgoldcontinental3,
-			"lb_goal_value_acc_tourgoldglobal1":        accomplishments.LBGoalValueAccTourgoldglobal1,
-			"lb_goal_value_acc_tourgoldglobal2":        accomplishments.LBGoalValueAccTourgoldglobal2,
-			"lb_goal_value_acc_tourgoldglobal3":        accomplishments.LBGoalValueAccTourgoldglobal3,
-			"lb_goal_value_acc_overdrivemaintain3":     accomplishments.LBGoalValueAccOverdrivemaintain3,
-			"lb_goal_value_acc_overdrivecareer":        accomplishments.LBGoalValueAccOverdrivecareer,
-			"lb_goal_value_acc_careersaves":            accomplishments.LBGoalValueAccCareersaves,
-			"lb_goal_value_acc_millionpoints":          accomplishments.LBGoalValueAccMillionpoints,
-			"lb_goal_value_acc_bassstreaklarge":        accomplishments.LBGoalValueAccBassstreaklarge,
-			"lb_goal_value_acc_hopothreehundredbass":   accomplishments.LBGoalValueAccHopothreehundredbass,
-			"lb_goal_value_acc_drumfill170":            accomplishments.LBGoalValueAccDrumfill170,
-			"lb_goal_value_acc_drumstreaklong":         accomplishments.LBGoalValueAccDrumstreaklong,
-			"lb_goal_value_acc_deployguitarfour":       accomplishments.LBGoalValueAccDeployguitarfour,
-			"lb_goal_value_acc_guitarstreaklarge":      accomplishments.LBGoalValueAccGuitarstreaklarge,
-			"lb_goal_value_acc_hopoonethousand":        accomplishments.LBGoalValueAccHopoonethousand,
-			"lb_goal_value_acc_doubleawesomealot":      accomplishments.LBGoalValueAccDoubleawesomealot,
-			"lb_goal_value_acc_tripleawesomealot":      accomplishments.LBGoalValueAccTripleawesomealot,
-			"lb_goal_value_acc_keystreaklong":          accomplishments.LBGoalValueAccKeystreaklong,
-			"lb_goal_value_acc_probassstreakepic":      accomplishments.LBGoalValueAccProbassstreakepic,
-			"lb_goal_value_acc_prodrumroll3":           accomplishments.LBGoalValueAccProdrumroll3,
-			"lb_goal_value_acc_prodrumstreaklong":      accomplishments.LBGoalValueAccProdrumstreaklong,
-			"lb_goal_value_acc_proguitarstreakepic":    accomplishments.LBGoalValueAccProguitarstreakepic,
-			"lb_goal_value_acc_prokeystreaklong":       accomplishments.LBGoalValueAccProkeystreaklong,
-			"lb_goal_value_acc_deployvocals":           accomplishments.LBGoalValueAccDeployvocals,
-			"lb_goal_value_acc_deployvocalsonehundred": accomplishments.LBGoalValueAccDeployvocalsonehundred,
-		},
-	}
-
-	_, err = accomplishmentsCollection.UpdateOne(ctx, bson.M{}, update)
-	if err != nil {
-		log.Println("Could not update accomplishments after banned user cleanup:", err)
+	if res.DeletedCount > 0 {
+		log.Printf("CleanupBannedUserAccomplishments: Removed %d accomplishment entries from banned users.\n", res.DeletedCount)
 	}
 }
 
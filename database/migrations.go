@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Data migrations that bring documents written by older versions of GoCentral up to date with the current models.
+// These run on every startup, so every migration must either only touch documents that still need it and be safe to run
+// repeatedly, or record that it has finished with markMigrationCompleted and skip itself once it has.
+func RunMigrations() {
+	MigrateScoreProvenance()
+	MigrateAccomplishments()
+}
+
+// names one-off migrations are recorded under in the config's completed_migrations
+const scoreProvenanceMigration = "score_provenance"
+
+// whether a one-off migration has already finished
+func migrationCompleted(ctx context.Context, name string) (bool, error) {
+	count, err := GocentralDatabase.Collection("config").CountDocuments(ctx, bson.M{"completed_migrations": name})
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// records that a one-off migration has finished so it isn't run again
+func markMigrationCompleted(ctx context.Context, name string) {
+	_, err := GocentralDatabase.Collection("config").UpdateOne(ctx, bson.M{}, bson.M{"$addToSet": bson.M{"completed_migrations": name}})
+	if err != nil {
+		log.Printf("Could not record that the %s migration has finished: %v\n", name, err)
+		return
+	}
+
+	InvalidateConfigCache()
+}
+
+// creates the indexes the admin score search filters on, this is a no-op if they already exist
+func EnsureScoreSearchIndexes(ctx context.Context, database *mongo.Database) error {
+	_, err := database.Collection("scores").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// search results are always newest first
+			Keys: bson.D{{Key: "submitted_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "console_type", Value: 1}, {Key: "submitted_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "machine_id", Value: 1}, {Key: "submitted_at", Value: -1}},
+		},
+	})
+
+	return err
+}
+
+// backfills the provenance fields on scores that were recorded before we stored them
+// submitted_at comes from the creation time embedded in the score's ObjectID, and console_type from the owner's console type
+// machine_id, session_guid, region and band_pids cannot be recovered so they are left unset
+// every score written since provenance was added already has these, so this only runs until it has finished once
+func MigrateScoreProvenance() {
+	scoresCollection := GocentralDatabase.Collection("scores")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if err := EnsureScoreSearchIndexes(ctx, GocentralDatabase); err != nil {
+		log.Printf("Could not create score search indexes: %v\n", err)
+	}
+
+	completed, err := migrationCompleted(ctx, scoreProvenanceMigration)
+	if err != nil {
+		log.Printf("Could not check whether scores need their provenance backfilled: %v\n", err)
+		return
+	}
+	if completed {
+		return
+	}
+
+	// ObjectIDs hold the time they were generated, which is when the score was first inserted
+	// not perfect since later improvements reuse the same document, but it is the best we have
+	res, err := scoresCollection.UpdateMany(ctx, bson.M{"submitted_at": bson.M{"$exists": false}}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{
+			{Key: "submitted_at", Value: bson.D{{Key: "$toLong", Value: bson.D{{Key: "$divide", Value: bson.A{
+				bson.D{{Key: "$toLong", Value: bson.D{{Key: "$toDate", Value: "$_id"}}}},
+				1000,
+			}}}}}},
+		}}},
+	})
+	if err != nil {
+		log.Println("Could not backfill score submission times:", err)
+		return
+	}
+
+	submittedAtCount := int(res.ModifiedCount)
+	consoleTypeCount := 0
+	failed := false
+
+	// 0 = Xbox 360, 1 = PS3, 2 = Wii, 3 = RPCS3
+	for consoleType := 0; consoleType <= 3; consoleType++ {
+		pidsMap, err := GetPIDsByConsoleType(ctx, GocentralDatabase, consoleType)
+		if err != nil {
+			log.Printf("Could not get PIDs for console type %d while backfilling scores: %v\n", consoleType, err)
+			failed = true
+			continue
+		}
+
+		pids := make([]int, 0, len(pidsMap))
+		for pid := range pidsMap {
+			pids = append(pids, pid)
+		}
+
+		// Wii Master Users live in the machines collection rather than the users collection
+		if consoleType == 2 {
+			machineIDs, err := GocentralDatabase.Collection("machines").Distinct(ctx, "machine_id", bson.M{})
+			if err != nil {
+				log.Println("Could not get machine IDs while backfilling scores:", err)
+				failed = true
+			}
+			for _, machineID := range machineIDs {
+				switch v := machineID.(type) {
+				case int32:
+					pids = append(pids, int(v))
+				case int64:
+					pids = append(pids, int(v))
+				}
+			}
+		}
+
+		if len(pids) == 0 {
+			continue
+		}
+
+		res, err := scoresCollection.UpdateMany(ctx,
+			bson.M{"console_type": bson.M{"$exists": false}, "pid": bson.M{"$in": pids}},
+			bson.M{"$set": bson.M{"console_type": consoleType}},
+		)
+		if err != nil {
+			log.Printf("Could not backfill console type %d on scores: %v\n", consoleType, err)
+			failed = true
+			continue
+		}
+		consoleTypeCount += int(res.ModifiedCount)
+	}
+
+	if submittedAtCount != 0 || consoleTypeCount != 0 {
+		log.Printf("Backfilled submission times on %d scores and console types on %d scores.\n", submittedAtCount, consoleTypeCount)
+	}
+
+	// try again on the next startup if anything went wrong
+	if !failed {
+		markMigrationCompleted(ctx, scoreProvenanceMigration)
+	}
+}
+
+// moves accomplishment leaderboards out of the old single document in the accomplishments collection,
+// which held one array of {pid, score} per goal, into one accomplishment_scores document per player per goal
+// the old document is deleted once everything has been copied, so this only does anything once
+func MigrateAccomplishments() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if err := EnsureAccomplishmentIndexes(ctx, GocentralDatabase); err != nil {
+		log.Printf("Could not create accomplishment indexes: %v\n", err)
+	}
+
+	legacyCollection := GocentralDatabase.Collection("accomplishments")
+
+	var legacy map[string]bson.RawValue
+	err := legacyCollection.FindOne(ctx, bson.M{}).Decode(&legacy)
+	if err == mongo.ErrNoDocuments {
+		return
+	}
+	if err != nil {
+		log.Printf("Could not get legacy accomplishments document: %v\n", err)
+		return
+	}
+
+	// the old document doesn't know when anything was recorded, so everything gets the time it was migrated
+	now := time.Now().Unix()
+
+	writes := []mongo.WriteModel{}
+	for field, value := range legacy {
+		accID, ok := strings.CutPrefix(field, "lb_goal_value_")
+		if !ok {
+			continue
+		}
+
+		var entries []struct {
+			PID   int `bson:"pid"`
+			Score int `bson:"score"`
+		}
+		if err := value.Unmarshal(&entries); err != nil {
+			log.Printf("Could not decode legacy accomplishments for %s: %v\n", accID, err)
+			continue
+		}
+
+		for _, entry := range entries {
+			// $setOnInsert so anything recorded since the migration started wins over the old value
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"acc_id": accID, "pid": entry.PID}).
+				SetUpdate(bson.M{"$setOnInsert": bson.M{
+					"acc_id":     accID,
+					"pid":        entry.PID,
+					"score":      entry.Score,
+					"updated_at": now,
+				}}).
+				SetUpsert(true))
+		}
+	}
+
+	migratedCount := 0
+	if len(writes) > 0 {
+		res, err := GocentralDatabase.Collection("accomplishment_scores").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			// keep the old document around so the migration is retried on the next startup
+			log.Printf("Could not migrate accomplishments: %v\n", err)
+			return
+		}
+		migratedCount = int(res.UpsertedCount)
+	}
+
+	if _, err := legacyCollection.DeleteOne(ctx, bson.M{"_id": legacy["_id"]}); err != nil {
+		log.Printf("Could not delete legacy accomplishments document: %v\n", err)
+		return
+	}
+
+	log.Printf("Migrated %d accomplishment entries to one document per player per goal.\n", migratedCount)
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"strconv"
+
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// finds a player by username, or by PID if no one has that username, returning mongo.ErrNoDocuments if neither exists
+func FindUserByNameOrPID(ctx context.Context, database *mongo.Database, nameOrPID string) (*models.User, error) {
+	user, err := FindUserByUsername(ctx, database, nameOrPID)
+	if err != mongo.ErrNoDocuments {
+		return user, err
+	}
+
+	pid, convErr := strconv.Atoi(nameOrPID)
+	if convErr != nil {
+		return nil, err
+	}
+
+	var byPID models.User
+	if err := database.Collection("users").FindOne(ctx, bson.M{"pid": pid}).Decode(&byPID); err != nil {
+		return nil, err
+	}
+
+	return &byPID, nil
+}
+
+// opts a player out of a public profile, or back in, returning mongo.ErrNoDocuments if they don't exist
+func SetProfileHidden(ctx context.Context, database *mongo.Database, pid int, hidden bool) error {
+	update := bson.M{"$unset": bson.M{"profile_hidden": ""}}
+	if hidden {
+		update = bson.M{"$set": bson.M{"profile_hidden": true}}
+	}
+
+	res, err := database.Collection("users").UpdateOne(ctx, bson.M{"pid": pid}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	globalRankCache  map[int]int
+	globalRankMu     sync.RWMutex
+	globalRankExpiry time.Time
+
+	roleRankCache  map[int]map[int]int // roleID -> (PID -> rank)
+	roleRankMu     sync.RWMutex
+	roleRankExpiry map[int]time.Time
+
+	rankCacheTTL = 5 * time.Minute
+)
+
+// ranks every player by the sum of their scores matching a filter, best first
+func rankPlayersByTotalScore(ctx context.Context, database *mongo.Database, match bson.D) (map[int]int, error) {
+	pipeline := mongo.Pipeline{}
+	if len(match) > 0 {
+		pipeline = append(pipeline, bson.D{{"$match", match}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{"$group", bson.D{{"_id", "$pid"}, {"totalScore", bson.D{{"$sum", "$score"}}}}}},
+		bson.D{{"$sort", bson.D{{"totalScore", -1}}}},
+	)
+
+	cursor, err := database.Collection("scores").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		ID         int `bson:"_id"`
+		TotalScore int `bson:"totalScore"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	ranks := make(map[int]int, len(results))
+	for i, result := range results {
+		ranks[result.ID] = i + 1
+	}
+
+	return ranks, nil
+}
+
+// gets a player's overall rank by the sum of all their scores, as shown on the ticker
+// players without any scores rank right after everyone who has some
+// ranks are cached for a few minutes since working them out means going over every score
+func GetGlobalRank(ctx context.Context, database *mongo.Database, pid int) (int, error) {
+	globalRankMu.RLock()
+	if globalRankCache != nil && time.Now().Before(globalRankExpiry) {
+		rank, ok := globalRankCache[pid]
+		total := len(globalRankCache)
+		globalRankMu.RUnlock()
+		if ok {
+			return rank, nil
+		}
+		return total + 1, nil
+	}
+	globalRankMu.RUnlock()
+
+	newCache, err := rankPlayersByTotalScore(ctx, database, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	globalRankMu.Lock()
+	globalRankCache = newCache
+	globalRankExpiry = time.Now().Add(rankCacheTTL)
+	globalRankMu.Unlock()
+
+	if rank, ok := newCache[pid]; ok {
+		return rank, nil
+	}
+	return len(newCache) + 1, nil
+}
+
+// gets a player's rank on one instrument by the sum of their scores on it, as shown on the ticker
+// cached the same way as GetGlobalRank
+func GetRoleRank(ctx context.Context, database *mongo.Database, roleID int, pid int) (int, error) {
+	roleRankMu.RLock()
+	if roleRankCache != nil {
+		if expiry, ok := roleRankExpiry[roleID]; ok && time.Now().Before(expiry) {
+			if ranks, ok := roleRankCache[roleID]; ok {
+				rank, found := ranks[pid]
+				total := len(ranks)
+				roleRankMu.RUnlock()
+				if found {
+					return rank, nil
+				}
+				return total + 1, nil
+			}
+		}
+	}
+	roleRankMu.RUnlock()
+
+	newRanks, err := rankPlayersByTotalScore(ctx, database, bson.D{{"role_id", roleID}})
+	if err != nil {
+		return 0, err
+	}
+
+	roleRankMu.Lock()
+	if roleRankCache == nil {
+		roleRankCache = make(map[int]map[int]int)
+		roleRankExpiry = make(map[int]time.Time)
+	}
+	roleRankCache[roleID] = newRanks
+	roleRankExpiry[roleID] = time.Now().Add(rankCacheTTL)
+	roleRankMu.Unlock()
+
+	if rank, ok := newRanks[pid]; ok {
+		return rank, nil
+	}
+	return len(newRanks) + 1, nil
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"rb3server/models"
+	"rb3server/profanity"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// profanity filter cache, rebuilt from the config whenever the config cache is
+var (
+	profanityFilterCache       *profanity.Filter
+	profanityFilterCacheMu     sync.RWMutex
+	profanityFilterCacheExpiry time.Time
+)
+
+// the entries the filter is built from, with every profanity_list word turned into a substring entry that rejects
+func ProfanityEntries(config *models.Config) []models.ProfanityEntry {
+	entries := make([]models.ProfanityEntry, 0, len(config.ProfanityFilters)+len(config.ProfanityList))
+	entries = append(entries, config.ProfanityFilters...)
+
+	for _, word := range config.ProfanityList {
+		if word == "" {
+			continue
+		}
+		entries = append(entries, models.ProfanityEntry{Term: word, Match: profanity.MatchSubstring, Severity: profanity.SeverityReject})
+	}
+
+	return entries
+}
+
+// returns the profanity filter for the current config
+// errors if the config can't be read, so callers refuse the text instead of letting it through unchecked
+func GetProfanityFilter(ctx context.Context) (*profanity.Filter, error) {
+	profanityFilterCacheMu.RLock()
+	if profanityFilterCache != nil && time.Now().Before(profanityFilterCacheExpiry) {
+		filter := profanityFilterCache
+		profanityFilterCacheMu.RUnlock()
+		return filter, nil
+	}
+	profanityFilterCacheMu.RUnlock()
+
+	config, err := GetCachedConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := profanity.NewFilter(ProfanityEntries(config), config.ProfanityAllowlist)
+	if err != nil {
+		log.Printf("Some profanity filter entries were skipped: %v", err)
+	}
+
+	profanityFilterCacheMu.Lock()
+	profanityFilterCache = filter
+	profanityFilterCacheExpiry = time.Now().Add(configCacheTTL)
+	profanityFilterCacheMu.Unlock()
+
+	return filter, nil
+}
+
+// invalidates the profanity filter cache, InvalidateConfigCache already does this
+func InvalidateProfanityFilterCache() {
+	profanityFilterCacheMu.Lock()
+	profanityFilterCache = nil
+	profanityFilterCacheMu.Unlock()
+}
+
+// runs some text a player wrote through the profanity filter
+// errors if the filter can't be built, in which case the text should be refused
+func FilterText(ctx context.Context, text string) (profanity.Result, error) {
+	filter, err := GetProfanityFilter(ctx)
+	if err != nil {
+		return profanity.Result{}, err
+	}
+
+	return filter.Check(text), nil
+}
+
+// adds an entry to the profanity filter, replacing any entry with the same term and match
+// the entry should already have been checked with profanity.ValidateEntry
+func AddProfanityEntry(ctx context.Context, database *mongo.Database, entry models.ProfanityEntry) error {
+	configCollection := database.Collection("config")
+
+	if _, err := configCollection.UpdateOne(ctx, bson.M{}, bson.M{"$pull": bson.M{"profanity_filters": bson.M{"term": entry.Term, "match": entry.Match}}}); err != nil {
+		return err
+	}
+	if _, err := configCollection.UpdateOne(ctx, bson.M{}, bson.M{"$push": bson.M{"profanity_filters": entry}}); err != nil {
+		return err
+	}
+
+	InvalidateConfigCache()
+
+	return nil
+}
+
+// removes an entry from the profanity filter, returning false if there was no such entry
+// removing a substring entry also takes the term off the old profanity list, since that is where it may have come from
+func RemoveProfanityEntry(ctx context.Context, database *mongo.Database, term string, match string) (bool, error) {
+	pull := bson.M{"profanity_filters": bson.M{"term": term, "match": match}}
+	if match == profanity.MatchSubstring {
+		pull["profanity_list"] = term
+	}
+
+	res, err := database.Collection("config").UpdateOne(ctx, bson.M{}, bson.M{"$pull": pull})
+	if err != nil {
+		return false, err
+	}
+
+	InvalidateConfigCache()
+
+	return res.ModifiedCount > 0, nil
+}
+
+// adds a word the profanity filter never flags
+func AddProfanityAllowlistWord(ctx context.Context, database *mongo.Database, word string) error {
+	_, err := database.Collection("config").UpdateOne(ctx, bson.M{}, bson.M{"$addToSet": bson.M{"profanity_allowlist": word}})
+	if err != nil {
+		return err
+	}
+
+	InvalidateConfigCache()
+
+	return nil
+}
+
+// removes a word from the profanity allowlist, returning false if it wasn't on it
+func RemoveProfanityAllowlistWord(ctx context.Context, database *mongo.Database, word string) (bool, error) {
+	res, err := database.Collection("config").UpdateOne(ctx, bson.M{}, bson.M{"$pull": bson.M{"profanity_allowlist": word}})
+	if err != nil {
+		return false, err
+	}
+
+	InvalidateConfigCache()
+
+	return res.ModifiedCount > 0, nil
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"rb3server/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// returns the limits for a part of a song, or nil if none have been imported for it
+func GetSongLimits(song *models.Song, roleID int, diffID int) *models.SongLimits {
+	if song == nil {
+		return nil
+	}
+
+	for i := range song.Limits {
+		if song.Limits[i].RoleID == roleID && song.Limits[i].DiffID == diffID {
+			return &song.Limits[i]
+		}
+	}
+
+	return nil
+}
+
+// checks a score against the limits of the part it was played on and returns why it is implausible
+// an empty result means the score looks fine, or that there are no limits to check it against
+func ValidateScoreAgainstLimits(score models.Score, limits *models.SongLimits) []string {
+	reasons := []string{}
+
+	if limits == nil {
+		return reasons
+	}
+
+	if limits.NoteCount == 0 {
+		reasons = append(reasons, "song has no notes for this part and difficulty")
+		return reasons
+	}
+
+	if limits.MaxScore > 0 && score.Score > limits.MaxScore {
+		reasons = append(reasons, fmt.Sprintf("score %d is above the maximum possible score of %d", score.Score, limits.MaxScore))
+	}
+
+	// the stars claimed have to have actually been earned by the score
+	if score.Stars > 0 && score.Stars <= len(limits.StarThresholds) {
+		threshold := limits.StarThresholds[score.Stars-1]
+		if score.Score < threshold {
+			reasons = append(reasons, fmt.Sprintf("%d stars claimed but score %d is below the %d needed", score.Stars, score.Score, threshold))
+		}
+	}
+
+	return reasons
+}
+
+// checks a score against the song catalog and returns why it is implausible
+// songs that aren't in the catalog or don't have limits can't be checked, so they always pass
+func CheckScorePlausibility(ctx context.Context, database *mongo.Database, score models.Score) []string {
+	song, err := GetSongByID(ctx, database, score.SongID)
+	if err != nil {
+		log.Printf("Could not get song %d to validate score: %v", score.SongID, err)
+		return []string{}
+	}
+
+	return ValidateScoreAgainstLimits(score, GetSongLimits(song, score.RoleID, score.DiffID))
+}
+
+// puts a score into the quarantine collection for a moderator to review
+func QuarantineScore(ctx context.Context, database *mongo.Database, score models.Score, reasons []string) error {
+	quarantineID, err := GetNextQuarantineID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("quarantined_scores").InsertOne(ctx, models.QuarantinedScore{
+		QuarantineID:  quarantineID,
+		Score:         score,
+		Reasons:       reasons,
+		QuarantinedAt: time.Now().Unix(),
+		Status:        "pending",
+	})
+
+	return err
+}
+
+// returns a quarantined score by ID, or nil if it doesn't exist
+func GetQuarantinedScore(ctx context.Context, database *mongo.Database, quarantineID int) (*models.QuarantinedScore, error) {
+	var quarantined models.QuarantinedScore
+
+	err := database.Collection("quarantined_scores").FindOne(ctx, bson.M{"quarantine_id": quarantineID}).Decode(&quarantined)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &quarantined, nil
+}
+
+// approves a quarantined score and puts it on the leaderboards, unless the player has since set a higher score on the same part
+func ApproveQuarantinedScore(ctx context.Context, database *mongo.Database, quarantined *models.QuarantinedScore, note string) error {
+	score := quarantined.Score
+	scoresCollection := database.Collection("scores")
+
+	filter := bson.M{"song_id": score.SongID, "pid": score.OwnerPID, "role_id": score.RoleID}
+
+	var existingScore models.Score
+	err := scoresCollection.FindOne(ctx, filter).Decode(&existingScore)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	if err == mongo.ErrNoDocuments || score.Score > existingScore.Score {
+		_, err = scoresCollection.UpdateOne(ctx, filter, bson.M{"$set": score}, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+
+	return setQuarantineStatus(ctx, database, quarantined.QuarantineID, "approved", note)
+}
+
+// rejects a quarantined score, keeping it around so the submission can still be looked at later
+func RejectQuarantinedScore(ctx context.Context, database *mongo.Database, quarantined *models.QuarantinedScore, note string) error {
+	return setQuarantineStatus(ctx, database, quarantined.QuarantineID, "rejected", note)
+}
+
+func setQuarantineStatus(ctx context.Context, database *mongo.Database, quarantineID int, status string, note string) error {
+	_, err := database.Collection("quarantined_scores").UpdateOne(ctx, bson.M{"quarantine_id": quarantineID}, bson.M{"$set": bson.M{
+		"status":      status,
+		"reviewed_at": time.Now().Unix(),
+		"review_note": note,
+	}})
+
+	return err
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// the setlist types players can discover and follow, battles are left out since they have their own lists
+var discoverableSetlistTypes = []int{0, 1, 2}
+
+// filter for the shared setlists anyone can find through search, leaving out curated setlists that are retired or not scheduled right now
+func DiscoverableSetlistsFilter(now time.Time) bson.M {
+	return bson.M{
+		"shared":  "t",
+		"type":    bson.M{"$in": discoverableSetlistTypes},
+		"retired": bson.M{"$ne": true},
+		"$and": bson.A{
+			bson.M{"$or": bson.A{bson.M{"visible_from": bson.M{"$exists": false}}, bson.M{"visible_from": bson.M{"$lte": now.Unix()}}}},
+			bson.M{"$or": bson.A{bson.M{"visible_until": bson.M{"$exists": false}}, bson.M{"visible_until": bson.M{"$gt": now.Unix()}}}},
+		},
+	}
+}
+
+// makes a player follow a shared setlist by its GUID so it shows up in their songlists/get
+// returns false if there is no shared setlist with that GUID, following a setlist twice is not an error
+func FollowSetlist(ctx context.Context, database *mongo.Database, guid string, pid int) (bool, error) {
+	setlistsCollection := database.Collection("setlists")
+	filter := bson.M{"guid": guid, "shared": "t", "type": bson.M{"$in": discoverableSetlistTypes}}
+
+	notFollowing := bson.M{"followers": bson.M{"$ne": pid}}
+	for key, value := range filter {
+		notFollowing[key] = value
+	}
+
+	res, err := setlistsCollection.UpdateOne(ctx, notFollowing,
+		bson.M{"$push": bson.M{"followers": pid}, "$inc": bson.M{"follower_count": 1}},
+	)
+	if err != nil {
+		return false, err
+	}
+	if res.MatchedCount != 0 {
+		return true, nil
+	}
+
+	// either the setlist doesn't exist or the player already follows it
+	count, err := setlistsCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+// stops a player following a setlist, returns false if they weren't following it
+func UnfollowSetlist(ctx context.Context, database *mongo.Database, guid string, pid int) (bool, error) {
+	res, err := database.Collection("setlists").UpdateOne(ctx,
+		bson.M{"guid": guid, "followers": pid},
+		bson.M{"$pull": bson.M{"followers": pid}, "$inc": bson.M{"follower_count": -1}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount != 0, nil
+}
+
+// counts a player having the given setlists synced to them towards their popularity
+// the game fetches setlists every time it polls, so each player only counts once per setlist
+func RecordSetlistSyncs(ctx context.Context, database *mongo.Database, pid int, setlistIDs []int) error {
+	if len(setlistIDs) == 0 || pid == 0 {
+		return nil
+	}
+
+	_, err := database.Collection("setlists").UpdateMany(ctx,
+		bson.M{"setlist_id": bson.M{"$in": setlistIDs}, "synced_by": bson.M{"$ne": pid}},
+		bson.M{"$addToSet": bson.M{"synced_by": pid}, "$inc": bson.M{"sync_count": 1}},
+	)
+	return err
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"log"
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// returns the song with the given ID from the song catalog, or nil if it has not been imported
+func GetSongByID(ctx context.Context, database *mongo.Database, songID int) (*models.Song, error) {
+	var song models.Song
+
+	err := database.Collection("songs").FindOne(ctx, bson.M{"song_id": songID}).Decode(&song)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &song, nil
+}
+
+// returns a map of song IDs to song titles for the given songs
+// songs that have not been imported are simply missing from the map
+func GetSongNamesByIDs(ctx context.Context, database *mongo.Database, songIDs []int) (map[int]string, error) {
+	if len(songIDs) == 0 {
+		return make(map[int]string), nil
+	}
+
+	songsCollection := database.Collection("songs")
+	filter := bson.M{"song_id": bson.M{"$in": songIDs}}
+
+	opts := options.Find().SetProjection(bson.M{"song_id": 1, "title": 1})
+
+	cursor, err := songsCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	songNameMap := make(map[int]string)
+	for cursor.Next(ctx) {
+		var song struct {
+			SongID int    `bson:"song_id"`
+			Title  string `bson:"title"`
+		}
+		if err := cursor.Decode(&song); err != nil {
+			log.Printf("Failed to decode song for song name map: %v", err)
+			continue
+		}
+		songNameMap[song.SongID] = song.Title
+	}
+
+	return songNameMap, cursor.Err()
+}
+
+// returns song titles in the same order as the given song IDs, e.g. for filling in Setlist.SongNames
+// unknown songs get an empty name, which is what the game got before we had a catalog
+func GetSongNamesInOrder(ctx context.Context, database *mongo.Database, songIDs []int) []string {
+	names := make([]string, len(songIDs))
+
+	songNameMap, err := GetSongNamesByIDs(ctx, database, songIDs)
+	if err != nil {
+		log.Printf("Could not get song names for setlist: %v", err)
+		return names
+	}
+
+	for i, songID := range songIDs {
+		names[i] = songNameMap[songID]
+	}
+
+	return names
+}
+
+// inserts or updates songs in the catalog keyed by song_id, returning how many were newly added and how many were updated
+// limits are only overwritten if the song being imported has them, so re-importing a songs.dta keeps previously imported limits
+func UpsertSongs(ctx context.Context, database *mongo.Database, songs []models.Song) (int, int, error) {
+	if len(songs) == 0 {
+		return 0, 0, nil
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(songs))
+	for _, song := range songs {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"song_id": song.SongID}).
+			SetUpdate(bson.M{"$set": song}).
+			SetUpsert(true))
+	}
+
+	res, err := database.Collection("songs").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(res.UpsertedCount), int(res.MatchedCount), nil
+}
+
+// replaces the plausibility limits of a song, returning false if the song isn't in the catalog
+func SetSongLimits(ctx context.Context, database *mongo.Database, songID int, limits []models.SongLimits) (bool, error) {
+	res, err := database.Collection("songs").UpdateOne(ctx, bson.M{"song_id": songID}, bson.M{"$set": bson.M{"limits": limits}})
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
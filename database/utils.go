@@ -32,6 +32,19 @@ var (
 	configCacheTTL    = 30 * time.Second
 )
 
+// most played song cache - finding it means grouping every score, which is too slow to do every time the game asks for a fact
+type mostPlayedSong struct {
+	SongID int `bson:"_id"`
+	Count  int `bson:"count"`
+}
+
+var (
+	mostPlayedSongCache       *mostPlayedSong
+	mostPlayedSongCacheMu     sync.RWMutex
+	mostPlayedSongCacheExpiry time.Time
+	mostPlayedSongCacheTTL    = time.Hour
+)
+
 // console type PIDs cache - caches PIDs by console type to avoid repeated DB queries for leaderboards
 var (
 	consoleTypePIDsCache       = make(map[int]map[int]bool) // consoleType -> map of PIDs
@@ -87,6 +100,8 @@ func InvalidateConfigCache() {
 	configCacheMu.Lock()
 	configCache = nil
 	configCacheMu.Unlock()
+
+	InvalidateProfanityFilterCache()
 }
 
 // atomically increments and returns the next PID
@@ -114,6 +129,21 @@ func GetNextMachineID(ctx context.Context) (int, error) {
 	return getNextCounter(ctx, "last_machine_id")
 }
 
+// atomically increments and returns the next quarantined score ID
+func GetNextQuarantineID(ctx context.Context) (int, error) {
+	return getNextCounter(ctx, "last_quarantine_id")
+}
+
+// atomically increments and returns the next battle template ID
+func GetNextBattleTemplateID(ctx context.Context) (int, error) {
+	return getNextCounter(ctx, "last_battle_template_id")
+}
+
+// atomically increments and returns the next binary data moderation ID
+func GetNextModerationID(ctx context.Context) (int, error) {
+	return getNextCounter(ctx, "last_moderation_id")
+}
+
 // atomically increments a counter field and returns the new value.
 // this can be used for any generic counter (such as machine ID or setlist ID etc. etc. etc.)
 // kind of shit but eh
@@ -152,6 +182,12 @@ func getNextCounter(ctx context.Context, field string) (int, error) {
 		return config.LastSetlistID, nil
 	case "last_machine_id":
 		return config.LastMachineID, nil
+	case "last_quarantine_id":
+		return config.LastQuarantineID, nil
+	case "last_battle_template_id":
+		return config.LastBattleTemplateID, nil
+	case "last_moderation_id":
+		return config.LastModerationID, nil
 	default:
 		return 0, nil
 	}
@@ -242,23 +278,34 @@ func GetConsolePrefixedUsernameForPID(pid int) string {
 	_ = usersCollection.FindOne(nil, bson.M{"pid": pid}).Decode(&user)
 
 	if user.Username != "" {
-		switch user.ConsoleType {
-		case 0:
-			return user.Username + " [360]"
-		case 1:
-			return user.Username + " [PS3]"
-		case 2:
-			return user.Username + " [Wii]"
-		case 3:
-			return user.Username + " [RPCS3]"
-		default:
-			return user.Username
-		}
+		return ConsolePrefixedUsername(user.Username, user.ConsoleType)
 	} else {
 		return "Unnamed Player"
 	}
 }
 
+// short names of the consoles players connect from, by console type
+var consoleNames = map[int]string{
+	0: "360",
+	1: "PS3",
+	2: "Wii",
+	3: "RPCS3",
+}
+
+// gets the short name of a console type, e.g. "PS3", or an empty string if it isn't known
+func ConsoleName(consoleType int) string {
+	return consoleNames[consoleType]
+}
+
+// adds the console a player is on to their username, e.g. "Player [360]"
+func ConsolePrefixedUsername(username string, consoleType int) string {
+	if name := ConsoleName(consoleType); name != "" {
+		return username + " [" + name + "]"
+	}
+
+	return username
+}
+
 // returns a map of usernames with console specific prefixes for a list of PIDs
 func GetConsolePrefixedUsernamesByPIDs(ctx context.Context, database *mongo.Database, pids []int) (map[int]string, error) {
 	if len(pids) == 0 {
@@ -290,21 +337,7 @@ func GetConsolePrefixedUsernamesByPIDs(ctx context.Context, database *mongo.Data
 			continue
 		}
 
-		// do the same prefix logic as the single-user function
-		var prefixedName string
-		switch user.ConsoleType {
-		case 0:
-			prefixedName = user.Username + " [360]"
-		case 1:
-			prefixedName = user.Username + " [PS3]"
-		case 2:
-			prefixedName = user.Username + " [Wii]"
-		case 3:
-			prefixedName = user.Username + " [RPCS3]"
-		default:
-			prefixedName = user.Username
-		}
-		usernameMap[user.PID] = prefixedName
+		usernameMap[user.PID] = ConsolePrefixedUsername(user.Username, user.ConsoleType)
 	}
 
 	return usernameMap, cursor.Err()
@@ -386,8 +419,8 @@ func GetBandNamesByOwnerPIDs(ctx context.Context, database *mongo.Database, owne
 
 // gets a random fact about the DB
 func GetCoolFact() string {
-	// generate a random number between 0-3
-	var num int = rand.Intn(4)
+	// generate a random number between 0-4
+	var num int = rand.Intn(5)
 
 	// check if local date is March 31
 	if time.Now().Month() == time.March && time.Now().Day() == 31 {
@@ -464,21 +497,69 @@ func GetCoolFact() string {
 		}
 
 		return "Players on this server have named " + strconv.FormatInt(count, 10) + " " + pluralize(count, "band", "bands") + "!"
+	case 4:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		mostPlayed, err := getMostPlayedSong(ctx)
+		if err != nil {
+			return "The most played song on this server is unknown because something broke trying to calculate it!"
+		}
+		if mostPlayed == nil {
+			return "Nobody has set a score on this server yet, so there is no most played song!"
+		}
+
+		// only worth mentioning if we actually know the song's name
+		song, err := GetSongByID(ctx, GocentralDatabase, mostPlayed.SongID)
+		if err != nil || song == nil || song.Title == "" {
+			return "Players on this server have set scores on a lot of songs, but the catalog doesn't know the name of the most played one!"
+		}
+
+		if song.Artist != "" {
+			return "The most played song on this server is " + song.Title + " by " + song.Artist + ", with " + strconv.Itoa(mostPlayed.Count) + " " + pluralize(int64(mostPlayed.Count), "score", "scores") + "!"
+		}
+		return "The most played song on this server is " + song.Title + ", with " + strconv.Itoa(mostPlayed.Count) + " " + pluralize(int64(mostPlayed.Count), "score", "scores") + "!"
 	}
 
 	// this should never happen
 	return "Rock Band 3 is a game released by Harmonix in 2010. It is the third main game in the Rock Band series."
 }
 
-const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+// finds the song with the most scores on it, returning nil if nobody has set a score yet
+func getMostPlayedSong(ctx context.Context) (*mostPlayedSong, error) {
+	mostPlayedSongCacheMu.RLock()
+	if time.Now().Before(mostPlayedSongCacheExpiry) {
+		cached := mostPlayedSongCache
+		mostPlayedSongCacheMu.RUnlock()
+		return cached, nil
+	}
+	mostPlayedSongCacheMu.RUnlock()
+
+	cursor, err := GocentralDatabase.Collection("scores").Aggregate(ctx, bson.A{
+		bson.M{"$group": bson.M{"_id": "$song_id", "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.M{"count": -1}},
+		bson.M{"$limit": 1},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []mostPlayedSong
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
 
-// generates a 10 digit alphanumeric link code
-func GenerateLinkCode(length int) string {
-	linkCode := make([]byte, length)
-	for i := range linkCode {
-		linkCode[i] = charset[rand.Intn(len(charset))]
+	var mostPlayed *mostPlayedSong
+	if len(result) > 0 {
+		mostPlayed = &result[0]
 	}
-	return string(linkCode)
+
+	mostPlayedSongCacheMu.Lock()
+	mostPlayedSongCache = mostPlayed
+	mostPlayedSongCacheExpiry = time.Now().Add(mostPlayedSongCacheTTL)
+	mostPlayedSongCacheMu.Unlock()
+
+	return mostPlayed, nil
 }
 
 // checks if a particular PID is a friend of another
@@ -532,6 +613,11 @@ func GetBattleExpiryInfo(battleID int) (bool, time.Time) {
 
 	_ = setlistsCollection.FindOne(context.TODO(), bson.M{"setlist_id": battleID}).Decode(&battle)
 
+	return GetSetlistBattleExpiryInfo(battle)
+}
+
+// same as GetBattleExpiryInfo, for when the battle's setlist has already been fetched
+func GetSetlistBattleExpiryInfo(battle models.Setlist) (bool, time.Time) {
 	createdTime := time.Unix(battle.Created, 0)
 
 	var expiredTime time.Time
@@ -661,6 +747,21 @@ func IsUsernameBanned(username string) bool {
 	return false
 }
 
+// returns the PIDs for users with a specific console type as a slice, for use in $in filters
+func GetPIDListByConsoleType(ctx context.Context, database *mongo.Database, consoleType int) ([]int, error) {
+	consolePIDs, err := GetPIDsByConsoleType(ctx, database, consoleType)
+	if err != nil {
+		return nil, err
+	}
+
+	pidList := make([]int, 0, len(consolePIDs))
+	for pid := range consolePIDs {
+		pidList = append(pidList, pid)
+	}
+
+	return pidList, nil
+}
+
 // returns a map of PIDs for users with a specific console type
 // uses a TTL cache to avoid repeated DB queries
 func GetPIDsByConsoleType(ctx context.Context, database *mongo.Database, consoleType int) (map[int]bool, error) {
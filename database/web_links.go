@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"time"
+
+	"rb3server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// returned when a link code doesn't exist, has already been used or has expired
+var ErrInvalidLinkCode = errors.New("link code is invalid or has expired")
+
+const (
+	linkCodeLength                 = 10
+	defaultLinkCodeLifetimeMinutes = 30
+
+	// how many times a new link code is generated if it happens to collide with one another player holds
+	linkCodeAttempts = 5
+)
+
+const linkCodeCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// generates an alphanumeric link code of the given length
+// link codes are what a website links a game account with, so they come from crypto/rand rather than anything guessable
+func GenerateLinkCode(length int) (string, error) {
+	linkCode := make([]byte, 0, length)
+	buf := make([]byte, length)
+
+	for len(linkCode) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+
+		// bytes past the largest multiple of the charset length are dropped so every character is equally likely
+		for _, b := range buf {
+			if int(b) >= 256-256%len(linkCodeCharset) {
+				continue
+			}
+			linkCode = append(linkCode, linkCodeCharset[int(b)%len(linkCodeCharset)])
+			if len(linkCode) == length {
+				break
+			}
+		}
+	}
+
+	return string(linkCode), nil
+}
+
+// creates the index link codes are redeemed by, which also stops two players holding the same code
+// this is a no-op if it already exists
+func EnsureLinkCodeIndexes(ctx context.Context, database *mongo.Database) error {
+	// link codes handed out before they could expire have no expiry, and older servers could give the same one to more than one player
+	// those codes can't be redeemed anymore, so they are cleared to keep them from breaking the unique index
+	_, err := database.Collection("users").UpdateMany(ctx,
+		bson.M{"link_code": bson.M{"$gt": ""}, "link_code_expires_at": bson.M{"$exists": false}},
+		bson.M{"$unset": bson.M{"link_code": ""}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("users").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "link_code", Value: 1}},
+		// most players have no link code, or an empty one, so only the ones that have one are indexed
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"link_code": bson.M{"$gt": ""}}),
+	})
+
+	return err
+}
+
+// how long link codes can be used for
+func LinkCodeLifetime(config *models.Config) time.Duration {
+	minutes := defaultLinkCodeLifetimeMinutes
+	if config != nil && config.LinkCodeLifetimeMinutes > 0 {
+		minutes = config.LinkCodeLifetimeMinutes
+	}
+
+	return time.Duration(minutes) * time.Minute
+}
+
+// gets the link code to show a player in-game, generating a new one if they don't have one or it has expired
+func GetOrRegenerateLinkCode(ctx context.Context, database *mongo.Database, pid int, lifetime time.Duration) (string, error) {
+	usersCollection := database.Collection("users")
+
+	var user models.User
+	if err := usersCollection.FindOne(ctx, bson.M{"pid": pid}).Decode(&user); err != nil {
+		return "", err
+	}
+
+	if user.LinkCode != "" && time.Now().Before(user.LinkCodeExpiresAt) {
+		return user.LinkCode, nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		linkCode, err := GenerateLinkCode(linkCodeLength)
+		if err != nil {
+			return "", err
+		}
+
+		_, err = usersCollection.UpdateOne(ctx, bson.M{"pid": pid}, bson.M{"$set": bson.M{
+			"link_code":            linkCode,
+			"link_code_expires_at": time.Now().Add(lifetime),
+		}})
+		if mongo.IsDuplicateKeyError(err) && attempt < linkCodeAttempts {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		return linkCode, nil
+	}
+}
+
+// links the account a link code belongs to with a web account, using up the code
+// a web account can be linked to several game accounts, but each game account only to one web account
+func RedeemLinkCode(ctx context.Context, database *mongo.Database, linkCode string, webIdentity string) (*models.User, error) {
+	if linkCode == "" {
+		return nil, ErrInvalidLinkCode
+	}
+
+	now := time.Now()
+
+	var user models.User
+	err := database.Collection("users").FindOneAndUpdate(ctx,
+		bson.M{"link_code": linkCode, "link_code_expires_at": bson.M{"$gt": now}},
+		bson.M{
+			"$set":   bson.M{"web_identity": webIdentity, "web_linked_at": now},
+			"$unset": bson.M{"link_code": "", "link_code_expires_at": ""},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvalidLinkCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// unlinks a game account from a web account, returning false if it wasn't linked to it
+func UnlinkWebIdentity(ctx context.Context, database *mongo.Database, pid int, webIdentity string) (bool, error) {
+	res, err := database.Collection("users").UpdateOne(ctx,
+		bson.M{"pid": pid, "web_identity": webIdentity},
+		bson.M{"$unset": bson.M{"web_identity": "", "web_linked_at": ""}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount > 0, nil
+}
+
+// gets every game account linked to a web account
+func GetUsersForWebIdentity(ctx context.Context, database *mongo.Database, webIdentity string) ([]models.User, error) {
+	cursor, err := database.Collection("users").Find(ctx, bson.M{"web_identity": webIdentity}, options.Find().SetSort(bson.D{{Key: "web_linked_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// checks whether a game account has been linked to a web account
+func IsWebLinked(ctx context.Context, database *mongo.Database, pid int) (bool, error) {
+	count, err := database.Collection("users").CountDocuments(ctx, bson.M{"pid": pid, "web_identity": bson.M{"$nin": bson.A{nil, ""}}})
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// checks whether a game account is linked to a particular web account
+func IsWebLinkedTo(ctx context.Context, database *mongo.Database, pid int, webIdentity string) (bool, error) {
+	if webIdentity == "" {
+		return false, nil
+	}
+
+	count, err := database.Collection("users").CountDocuments(ctx, bson.M{"pid": pid, "web_identity": webIdentity})
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,17 @@
+package models
+
+// a battle score submission that broke the battle's rules, kept so moderators can see who is trying what
+type BattleScoreRejection struct {
+	BattleID    int      `json:"battle_id" bson:"battle_id"`
+	PIDs        []int    `json:"pids" bson:"pids"`
+	Score       int      `json:"score" bson:"score"`
+	SongID      int      `json:"song_id,omitempty" bson:"song_id,omitempty"`
+	RoleIDs     []int    `json:"role_ids,omitempty" bson:"role_ids,omitempty"`
+	DiffIDs     []int    `json:"diff_ids,omitempty" bson:"diff_ids,omitempty"`
+	BandMask    int      `json:"band_mask,omitempty" bson:"band_mask,omitempty"`
+	Reasons     []string `json:"reasons" bson:"reasons"`
+	ConsoleType int      `json:"console_type" bson:"console_type"`
+	MachineID   string   `json:"machine_id" bson:"machine_id"`
+	SessionGUID string   `json:"session_guid" bson:"session_guid"`
+	RejectedAt  int64    `json:"rejected_at" bson:"rejected_at"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// one placement in the frozen final standings of a battle
+type BattleStanding struct {
+	Rank  int    `json:"rank" bson:"rank"`
+	PID   int    `json:"pid" bson:"pid"`
+	Name  string `json:"name" bson:"name"`
+	Score int    `json:"score" bson:"score"`
+}
+
+// the final standings of a battle, archived when it closes so they outlive the battle itself
+// names are stored as they were when the battle closed so renames and deleted accounts don't change the results
+type BattleResult struct {
+	BattleID     int              `json:"battle_id" bson:"battle_id"`
+	Type         int              `json:"type" bson:"type"`
+	Title        string           `json:"title" bson:"title"`
+	Description  string           `json:"description" bson:"description"`
+	Owner        string           `json:"owner" bson:"owner"`
+	Instrument   int              `json:"instrument" bson:"instrument"`
+	SongIDs      []int            `json:"song_ids" bson:"s_ids"`
+	SongNames    []string         `json:"song_names" bson:"s_names"`
+	StartedAt    int64            `json:"started_at" bson:"started_at"`
+	EndedAt      int64            `json:"ended_at" bson:"ended_at"`
+	ArchivedAt   int64            `json:"archived_at" bson:"archived_at"`
+	Participants int              `json:"participants" bson:"participants"`
+	Standings    []BattleStanding `json:"standings" bson:"standings"`
+	Hidden       bool             `json:"hidden" bson:"hidden,omitempty"` // hidden by an admin, kept out of everything public
+}
+
+// a single player's placement in an archived battle, every participant gets one so history isn't limited to the top standings
+type BattleHistoryEntry struct {
+	BattleID   int    `json:"battle_id" bson:"battle_id"`
+	PID        int    `json:"pid" bson:"pid"`
+	Title      string `json:"title" bson:"title"`
+	Instrument int    `json:"instrument" bson:"instrument"`
+	Rank       int    `json:"rank" bson:"rank"`
+	Score      int    `json:"score" bson:"score"`
+	EndedAt    int64  `json:"ended_at" bson:"ended_at"`
+	Hidden     bool   `json:"hidden" bson:"hidden,omitempty"` // the battle was hidden by an admin
+}
+
+// an in-game message telling a battle winner how they placed
+// kept until the game deletes it or it expires, so winners still get it if the server restarts before they log in
+type BattleWinnerMessage struct {
+	MessageID     uint32    `json:"message_id" bson:"message_id"`
+	RecipientPID  uint32    `json:"recipient_pid" bson:"recipient_pid"` // the PID the game asks for messages with, the machine ID for Wii players
+	BattleID      int       `json:"battle_id" bson:"battle_id"`
+	Subject       string    `json:"subject" bson:"subject"`
+	TextBody      string    `json:"text_body" bson:"text_body"`
+	ReceptionTime uint64    `json:"reception_time" bson:"reception_time"` // packed NEX DateTime
+	ExpiresAt     time.Time `json:"expires_at" bson:"expires_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+// when a battle template opens a new battle, all times are UTC
+type BattleRecurrence struct {
+	Frequency     string `json:"frequency" bson:"frequency"` // "daily" or "weekly"
+	Weekday       int    `json:"weekday" bson:"weekday"`     // 0 = Sunday, only used for weekly battles
+	Hour          int    `json:"hour" bson:"hour"`
+	Minute        int    `json:"minute" bson:"minute"`
+	DurationHours int    `json:"duration_hours" bson:"duration_hours"`
+
+	// how long before it starts the battle gets created, it stays hidden from players until it starts
+	CreateAheadHours int `json:"create_ahead_hours" bson:"create_ahead_hours"`
+}
+
+// where a battle template gets the songs for each battle it opens
+type BattleSongPool struct {
+	Strategy string `json:"strategy" bson:"strategy"` // "fixed", "random" or "queue"
+
+	// fixed: every battle uses these songs
+	SongIDs []int `json:"song_ids,omitempty" bson:"song_ids,omitempty"`
+
+	// random: picks Count songs from the song catalog, optionally limited to a genre and a tier range on one instrument
+	Count          int    `json:"count,omitempty" bson:"count,omitempty"`
+	Genre          string `json:"genre,omitempty" bson:"genre,omitempty"`
+	TierInstrument string `json:"tier_instrument,omitempty" bson:"tier_instrument,omitempty"` // defaults to "band"
+	MinTier        int    `json:"min_tier,omitempty" bson:"min_tier,omitempty"`
+	MaxTier        int    `json:"max_tier,omitempty" bson:"max_tier,omitempty"`
+
+	// queue: every battle takes the next song list off the front of the queue
+	Queue [][]int `json:"queue,omitempty" bson:"queue,omitempty"`
+}
+
+// a recurring Harmonix battle that housekeeping creates on a schedule, e.g. a weekly Battle Night
+type BattleTemplate struct {
+	TemplateID  int              `json:"template_id" bson:"template_id"`
+	Title       string           `json:"title" bson:"title"`
+	Description string           `json:"description" bson:"description"`
+	Instrument  int              `json:"instrument" bson:"instrument"`
+	Flags       int              `json:"flags" bson:"flags"`
+	Enabled     bool             `json:"enabled" bson:"enabled"`
+	Recurrence  BattleRecurrence `json:"recurrence" bson:"recurrence"`
+	SongPool    BattleSongPool   `json:"song_pool" bson:"song_pool"`
+	CreatedAt   int64            `json:"created_at" bson:"created_at"`
+
+	// start time of the most recent battle this template created, the next one is scheduled after it
+	LastStartAt  int64 `json:"last_start_at" bson:"last_start_at"`
+	LastBattleID int   `json:"last_battle_id" bson:"last_battle_id"`
+}
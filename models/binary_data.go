@@ -0,0 +1,35 @@
+package models
+
+// one kept revision of a piece of binary data
+type BinaryDataRevision struct {
+	Revision   int64  `json:"revision" bson:"revision"`
+	Hash       string `json:"hash" bson:"hash"` // hex SHA-256 of the data
+	Size       int64  `json:"size" bson:"size"`
+	UploadedAt int64  `json:"uploaded_at" bson:"uploaded_at"`
+}
+
+// setlist art, battle art or a band logo for one platform, along with the revisions of it that are kept in the blob store
+type BinaryDataObject struct {
+	Type              string               `json:"type" bson:"type"` // setlist_art, battle_art or band_logo
+	Key               string               `json:"key" bson:"key"`   // the setlist GUID, battle ID or band ID the data belongs to
+	PlatformExtension string               `json:"platform_extension" bson:"platform_extension"`
+	OwnerPID          int                  `json:"owner_pid" bson:"owner_pid"`   // who uploaded it and whose quota it counts against, 0 for admin uploads
+	Revisions         []BinaryDataRevision `json:"revisions" bson:"revisions"`   // oldest first
+	TotalSize         int64                `json:"total_size" bson:"total_size"` // the size of every kept revision
+	UpdatedAt         int64                `json:"updated_at" bson:"updated_at"`
+}
+
+// a newly uploaded band logo or piece of setlist art waiting on a moderator
+// approving it leaves it as is, replacing it swaps the art for a placeholder on every platform
+type BinaryDataModeration struct {
+	ModerationID      int    `json:"moderation_id" bson:"moderation_id"`
+	Type              string `json:"type" bson:"type"`
+	Key               string `json:"key" bson:"key"`
+	Revision          int64  `json:"revision" bson:"revision"`
+	PlatformExtension string `json:"platform_extension" bson:"platform_extension"` // the format it was uploaded in
+	OwnerPID          int    `json:"owner_pid" bson:"owner_pid"`
+	UploadedAt        int64  `json:"uploaded_at" bson:"uploaded_at"`
+	Status            string `json:"status" bson:"status"` // "pending", "approved" or "replaced"
+	ReviewedAt        int64  `json:"reviewed_at" bson:"reviewed_at"`
+	ReviewNote        string `json:"review_note" bson:"review_note"`
+}
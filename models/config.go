@@ -13,15 +13,71 @@ type BannedPlayer struct {
 	CreatedAt time.Time `json:"created_at" bson:"created_at"`
 }
 
+// one thing the profanity filter looks for in names, titles, descriptions and messages players write
+type ProfanityEntry struct {
+	Term     string `json:"term" bson:"term"`
+	Match    string `json:"match" bson:"match"`       // word (the default), substring or regex
+	Severity string `json:"severity" bson:"severity"` // reject (the default) refuses the text, mask replaces the match with asterisks
+}
+
 type Config struct {
-	ID              primitive.ObjectID `json:"_id" bson:"_id"`
-	LastPID         int                `json:"last_pid" bson:"last_pid"`
-	LastBandID      int                `json:"last_band_id" bson:"last_band_id"`
-	LastCharacterID int                `json:"last_character_id" bson:"last_character_id"`
-	LastSetlistID   int                `json:"last_setlist_id" bson:"last_setlist_id"`
-	ProfanityList   []string           `json:"profanity_list" bson:"profanity_list"`
-	BannedPlayers   []BannedPlayer     `json:"banned_players" bson:"banned_players"`
-	BattleLimit     int                `json:"battle_limit" bson:"battle_limit"`
-	LastMachineID   int                `json:"last_machine_id" bson:"last_machine_id"`
-	AdminAPIToken   string             `json:"admin_api_token" bson:"admin_api_token"`
+	ID                   primitive.ObjectID `json:"_id" bson:"_id"`
+	LastPID              int                `json:"last_pid" bson:"last_pid"`
+	LastBandID           int                `json:"last_band_id" bson:"last_band_id"`
+	LastCharacterID      int                `json:"last_character_id" bson:"last_character_id"`
+	LastSetlistID        int                `json:"last_setlist_id" bson:"last_setlist_id"`
+	ProfanityList        []string           `json:"profanity_list" bson:"profanity_list"`
+	BannedPlayers        []BannedPlayer     `json:"banned_players" bson:"banned_players"`
+	BattleLimit          int                `json:"battle_limit" bson:"battle_limit"`
+	LastMachineID        int                `json:"last_machine_id" bson:"last_machine_id"`
+	AdminAPIToken        string             `json:"admin_api_token" bson:"admin_api_token"`
+	LastQuarantineID     int                `json:"last_quarantine_id" bson:"last_quarantine_id"`
+	LastBattleTemplateID int                `json:"last_battle_template_id" bson:"last_battle_template_id"`
+	LastModerationID     int                `json:"last_moderation_id" bson:"last_moderation_id"`
+
+	// one-off data migrations that have finished, so they aren't run again on every startup
+	CompletedMigrations []string `json:"completed_migrations" bson:"completed_migrations"`
+
+	// when enabled, the in-game global leaderboards only show players on the same console as the player viewing them
+	PlatformOnlyLeaderboards bool `json:"platform_only_leaderboards" bson:"platform_only_leaderboards"`
+
+	// accomplishment goal IDs to keep leaderboards for on top of the ones RB3 ships with, e.g. for goals added by mods
+	AccomplishmentGoalIDs []string `json:"accomplishment_goal_ids" bson:"accomplishment_goal_ids"`
+
+	// how many placements are kept in the archived standings of a battle once it closes, defaults to 10 when unset
+	BattleResultStandings int `json:"battle_result_standings" bson:"battle_result_standings"`
+
+	// how many revisions of each piece of setlist art, battle art or band logo are kept, defaults to 3 when unset
+	BinaryDataRevisionRetention int `json:"binary_data_revision_retention" bson:"binary_data_revision_retention"`
+
+	// how many bytes of binary data each player can have stored across every revision they uploaded, defaults to 16 MB when unset
+	BinaryDataQuotaBytes int64 `json:"binary_data_quota_bytes" bson:"binary_data_quota_bytes"`
+
+	// how many pieces of art each player can upload in an hour, defaults to 20 when unset
+	BinaryDataUploadsPerHour int `json:"binary_data_uploads_per_hour" bson:"binary_data_uploads_per_hour"`
+
+	// when enabled, art that doesn't look like a texture the uploading console could have made is refused
+	// off by default so failures are only logged, the texture layouts haven't been checked against real uploads from every console yet
+	RejectInvalidTextures bool `json:"reject_invalid_textures" bson:"reject_invalid_textures"`
+
+	// the token the website uses to link the accounts of players signed in to it, web linking is disabled when unset
+	WebAPIToken string `json:"web_api_token" bson:"web_api_token"`
+
+	// how long a link code shown in-game can be used for, defaults to 30 when unset
+	LinkCodeLifetimeMinutes int `json:"link_code_lifetime_minutes" bson:"link_code_lifetime_minutes"`
+
+	// when enabled, every account is reported as linked to the web so the achievement for linking can be earned without a website
+	AlwaysReportWebLinked bool `json:"always_report_web_linked" bson:"always_report_web_linked"`
+
+	// what the profanity filter looks for, profanity_list is still honoured as substring entries that reject
+	ProfanityFilters []ProfanityEntry `json:"profanity_filters" bson:"profanity_filters"`
+
+	// words the profanity filter never flags, e.g. "assistant" when "ass" is a substring entry
+	ProfanityAllowlist []string `json:"profanity_allowlist" bson:"profanity_allowlist"`
+
+	// how many characters character and band names can have, defaults to 32 when unset
+	EntityNameMaxLength int `json:"entity_name_max_length" bson:"entity_name_max_length"`
+
+	// when enabled, players can't give a character or band a name another player's character or band already has
+	UniqueEntityNames bool `json:"unique_entity_names" bson:"unique_entity_names"`
 }
@@ -0,0 +1,9 @@
+package models
+
+// a group users can be put in, and the privileges being in it grants
+type Group struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Privileges  []string `json:"privileges"`
+}
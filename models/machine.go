@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type Machine struct {
 	ConsoleType   int    `json:"console_type" bson:"console_type"`
 	MachineID     int    `json:"machine_id" bson:"machine_id"`
@@ -7,4 +9,9 @@ type Machine struct {
 	WiiFriendCode string `json:"wii_friend_code" bson:"wii_friend_code"`
 	StationURL    string `json:"station_url" bson:"station_url"`
 	IntStationURL string `json:"int_station_url" bson:"int_station_url"`
+
+	// hex-encoded Kerberos key derived from the machine's own secret, the friend code is used when empty
+	// this is as good as a password for logging in, so it is never exposed
+	KerberosKey          string    `json:"-" bson:"kerberos_key,omitempty"`
+	KerberosKeyRotatedAt time.Time `json:"-" bson:"kerberos_key_rotated_at,omitempty"`
 }
@@ -0,0 +1,13 @@
+package models
+
+// a score submission that failed plausibility validation and is waiting on a moderator
+// approving it puts it on the leaderboards like it was submitted normally, rejecting it keeps it out
+type QuarantinedScore struct {
+	QuarantineID  int      `bson:"quarantine_id"`
+	Score         Score    `bson:"score"`
+	Reasons       []string `bson:"reasons"`
+	QuarantinedAt int64    `bson:"quarantined_at"`
+	Status        string   `bson:"status"` // "pending", "approved" or "rejected"
+	ReviewedAt    int64    `bson:"reviewed_at"`
+	ReviewNote    string   `bson:"review_note"`
+}
@@ -11,4 +11,13 @@ type Score struct {
 	BOI            int `bson:"boi"`
 	InstrumentMask int `bson:"instrument_mask"`
 	BattleID       int `bson:"battle_id"`
+
+	// provenance fields, recorded every time a score is stored
+	// scores recorded before these existed are backfilled by MigrateScoreProvenance where possible
+	SubmittedAt int64  `bson:"submitted_at"` // unix timestamp of when the score was submitted
+	ConsoleType int    `bson:"console_type"` // console type of the submitting client, same values as User.ConsoleType
+	MachineID   string `bson:"machine_id"`
+	SessionGUID string `bson:"session_guid"`
+	Region      string `bson:"region"`
+	BandPIDs    []int  `bson:"band_pids"` // the other PIDs that were part of the same submission
 }
@@ -9,7 +9,7 @@ type BattleScoreEntry struct {
 // represents a Setlist in the database
 // this is used for both setlists and battles, battles are really just setlists with a type of 1000/1001/1002
 type Setlist struct {
-	Created   int64    `bson:"created"` // unix timestamp of when the setlist was created
+	Created   int64    `bson:"created"` // unix timestamp of when the setlist was created, for battles this is when the battle starts and can be in the future
 	SetlistID int      `bson:"setlist_id"`
 	PID       int      `bson:"pid"`
 	Title     string   `bson:"title"`
@@ -28,4 +28,24 @@ type Setlist struct {
 	TimeEndUnits string `bson:"time_end_units"`
 	Flags        int    `bson:"flags"`
 	Instrument   int    `bson:"instrument"`
+	TemplateID   int    `bson:"template_id,omitempty"` // the battle template that scheduled this battle, if any
+
+	// hidden by an admin, e.g. for a profane title, players can't see the battle but its scores are kept
+	Hidden       bool   `bson:"hidden,omitempty"`
+	HiddenReason string `bson:"hidden_reason,omitempty"`
+
+	// curated Harmonix Recommends fields, only admins set these
+	SortOrder    int      `bson:"sort_order,omitempty"`    // lower sorts first in the game's list
+	VisibleFrom  int64    `bson:"visible_from,omitempty"`  // unix timestamp, players don't see the setlist before this if set
+	VisibleUntil int64    `bson:"visible_until,omitempty"` // unix timestamp, players don't see the setlist from this on if set
+	Platforms    []int    `bson:"platforms,omitempty"`     // console types that see the setlist, every console if empty
+	Regions      []string `bson:"regions,omitempty"`       // regions that see the setlist, every region if empty
+	Retired      bool     `bson:"retired,omitempty"`
+	ArtRevision  int      `bson:"art_revision,omitempty"` // the setlist_art revision to serve, bumped on every admin art upload
+
+	// discovery fields, used to rank setlists by popularity
+	SyncCount     int   `bson:"sync_count,omitempty"`     // how many players other than its owner the setlist was synced to
+	SyncedBy      []int `bson:"synced_by,omitempty"`      // those players, so a player is only counted once however often their game polls
+	Followers     []int `bson:"followers,omitempty"`      // players who follow the setlist see it even if they aren't friends with the owner
+	FollowerCount int   `bson:"follower_count,omitempty"` // kept alongside followers so setlists can be sorted by it
 }
@@ -0,0 +1,30 @@
+package models
+
+// represents a song in the song catalog, imported from songs.dta metadata
+type Song struct {
+	SongID    int    `json:"song_id" bson:"song_id"`
+	ShortName string `json:"shortname" bson:"shortname"`
+	Title     string `json:"title" bson:"title"`
+	Artist    string `json:"artist" bson:"artist"`
+	Album     string `json:"album" bson:"album"`
+	Year      int    `json:"year" bson:"year"`
+	Genre     string `json:"genre" bson:"genre"`
+	Source    string `json:"source" bson:"source"` // "rb3", "dlc" or "rbn"
+
+	// raw rank values from the DTA keyed by instrument (drum, guitar, bass, vocals, keys, real_keys, real_guitar, real_bass, band)
+	Ranks map[string]int `json:"ranks" bson:"ranks"`
+	// difficulty tiers derived from the ranks, 0 = no part, 1 = warmup through 7 = devils
+	Tiers map[string]int `json:"tiers" bson:"tiers"`
+
+	// plausibility limits for each part, imported separately from the DTA metadata since songs.dta doesn't have them
+	Limits []SongLimits `json:"limits,omitempty" bson:"limits,omitempty"`
+}
+
+// the most a score on one part of a song can legitimately be, used to catch impossible score submissions
+type SongLimits struct {
+	RoleID         int   `json:"role_id" bson:"role_id"`
+	DiffID         int   `json:"diff_id" bson:"diff_id"`
+	MaxScore       int   `json:"max_score" bson:"max_score"`             // highest possible score, including overdrive
+	NoteCount      int   `json:"note_count" bson:"note_count"`           // 0 means the part doesn't exist on this difficulty
+	StarThresholds []int `json:"star_thresholds" bson:"star_thresholds"` // minimum score for 1 through 5 stars, then gold stars
+}
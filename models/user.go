@@ -1,6 +1,10 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type User struct {
 	ID            primitive.ObjectID `json:"_id" bson:"_id"`
@@ -15,6 +19,31 @@ type User struct {
 	Groups        []string           `json:"groups" bson:"groups"`
 	USIDs         string             `json:"usids" bson:"usids"`
 
+	// link codes can only be used once and stop working at this time, older codes without an expiry count as expired
+	LinkCodeExpiresAt time.Time `json:"link_code_expires_at" bson:"link_code_expires_at,omitempty"`
+
+	// the web account this account was linked to with its link code, empty if it hasn't been linked
+	WebIdentity string    `json:"web_identity,omitempty" bson:"web_identity,omitempty"`
+	WebLinkedAt time.Time `json:"web_linked_at,omitempty" bson:"web_linked_at,omitempty"`
+
+	// players who opted out of a public profile on the website
+	ProfileHidden bool `json:"profile_hidden,omitempty" bson:"profile_hidden,omitempty"`
+
 	// machine stuff
 	CreatedByMachineID int `json:"created_by_machine_id" bson:"created_by_machine_id"`
+
+	// overrides the battle limit in the config for this player when set
+	BattleLimit *int `json:"battle_limit,omitempty" bson:"battle_limit,omitempty"`
+
+	// hex-encoded Kerberos key derived from the account's own secret, the platform's password is used when empty
+	// the secret itself is never stored, but the key is all a client needs to log in, so treat it like a password and never expose it
+	KerberosKey          string    `json:"-" bson:"kerberos_key,omitempty"`
+	KerberosKeyRotatedAt time.Time `json:"-" bson:"kerberos_key_rotated_at,omitempty"`
+}
+
+// left behind when an account is merged into another one, so its old username logs in to the account it was merged into
+type UsernameRedirect struct {
+	Username  string    `json:"username" bson:"username"` // lowercased
+	PID       int       `json:"pid" bson:"pid"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
 }
@@ -0,0 +1,281 @@
+package profanity
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"rb3server/models"
+)
+
+// how an entry is matched
+const (
+	MatchWord      = "word"      // whole words only, so "ass" doesn't flag "Band Assistant"
+	MatchSubstring = "substring" // anywhere in the text, which is how the old profanity list worked
+	MatchRegex     = "regex"     // a regular expression run against the text as written, ignoring case, so \d still matches digits
+)
+
+// what happens to text an entry matches
+const (
+	SeverityReject = "reject"
+	SeverityMask   = "mask"
+)
+
+// one entry that matched some text
+type Match struct {
+	Term     string `json:"term"`
+	Severity string `json:"severity"`
+	Text     string `json:"text"` // the part of the original text that matched
+}
+
+// what the filter made of some text
+type Result struct {
+	Severity string  `json:"severity"` // empty if nothing matched, reject if anything that rejects did, otherwise mask
+	Masked   string  `json:"masked"`   // the text with everything that matched replaced by asterisks
+	Matches  []Match `json:"matches"`
+}
+
+// whether the text should be refused outright
+func (result Result) Rejected() bool {
+	return result.Severity == SeverityReject
+}
+
+type compiledEntry struct {
+	entry models.ProfanityEntry
+	words []string       // for word entries
+	regex *regexp.Regexp // for substring and regex entries
+}
+
+// checks text players write against a set of entries
+// safe to use from several goroutines, build a new one when the entries change
+type Filter struct {
+	entries   []compiledEntry
+	allowlist map[string]bool
+}
+
+// fills in the defaults of an entry and checks it can be used
+func ValidateEntry(entry models.ProfanityEntry) (models.ProfanityEntry, error) {
+	entry.Term = strings.TrimSpace(entry.Term)
+	if entry.Term == "" {
+		return entry, errors.New("term is required")
+	}
+
+	if entry.Match == "" {
+		entry.Match = MatchWord
+	}
+	if entry.Severity == "" {
+		entry.Severity = SeverityReject
+	}
+
+	switch entry.Match {
+	case MatchWord, MatchSubstring:
+		if len(words(entry.Term)) == 0 {
+			return entry, fmt.Errorf("term %q has no letters or digits to match", entry.Term)
+		}
+	case MatchRegex:
+		if _, err := regexp.Compile(entry.Term); err != nil {
+			return entry, fmt.Errorf("term %q is not a valid regular expression: %w", entry.Term, err)
+		}
+	default:
+		return entry, fmt.Errorf("match must be %s, %s or %s", MatchWord, MatchSubstring, MatchRegex)
+	}
+
+	if entry.Severity != SeverityReject && entry.Severity != SeverityMask {
+		return entry, fmt.Errorf("severity must be %s or %s", SeverityReject, SeverityMask)
+	}
+
+	return entry, nil
+}
+
+// builds a filter, entries that can't be used are left out and reported in the returned error
+func NewFilter(entries []models.ProfanityEntry, allowlist []string) (*Filter, error) {
+	filter := &Filter{allowlist: make(map[string]bool, len(allowlist))}
+
+	for _, word := range allowlist {
+		for _, w := range words(word) {
+			filter.allowlist[w] = true
+		}
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		entry, err := ValidateEntry(entry)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		compiled := compiledEntry{entry: entry}
+		switch entry.Match {
+		case MatchWord:
+			compiled.words = words(entry.Term)
+		case MatchSubstring:
+			compiled.regex = regexp.MustCompile(regexp.QuoteMeta(string(normalize(entry.Term).runes)))
+		case MatchRegex:
+			compiled.regex = regexp.MustCompile("(?i)" + entry.Term)
+		}
+
+		filter.entries = append(filter.entries, compiled)
+	}
+
+	return filter, errors.Join(errs...)
+}
+
+// a matched span of normalized runes, along with the runes of the original text it covers
+type span struct {
+	start, end                 int
+	originalStart, originalEnd int
+	entry                      models.ProfanityEntry
+}
+
+// a span of normalized runes, with the original runes they came from
+func normalizedSpan(text normalizedText, start int, end int, entry models.ProfanityEntry) span {
+	return span{start, end, text.origins[start], text.origins[end-1] + 1, entry}
+}
+
+// a span of original runes, with the normalized runes that came from them
+// end is left at start if the original runes all folded away, e.g. a lone accent
+func originalSpan(text normalizedText, originalStart int, originalEnd int, entry models.ProfanityEntry) span {
+	s := span{start: len(text.runes), end: len(text.runes), originalStart: originalStart, originalEnd: originalEnd, entry: entry}
+
+	for i, origin := range text.origins {
+		if origin >= originalStart && s.start == len(text.runes) {
+			s.start, s.end = i, i
+		}
+		if origin >= originalStart && origin < originalEnd {
+			s.end = i + 1
+		}
+	}
+
+	return s
+}
+
+// whether a span of normalized text is nothing but part of an allowlisted word
+func (filter *Filter) allowed(tokens []token, s span) bool {
+	if s.end <= s.start {
+		return false
+	}
+
+	for _, t := range tokens {
+		if s.start >= t.start && s.end <= t.end {
+			return filter.allowlist[t.text] || filter.allowlist[t.trimmed]
+		}
+	}
+
+	return false
+}
+
+// finds every span of the text the entries match
+func (filter *Filter) spans(text normalizedText, tokens []token) []span {
+	var spans []span
+
+	// substring entries run against the normalized text and regex entries against the original text
+	// both give byte offsets, which have to be turned back into rune indexes
+	normalized := string(text.runes)
+	normalizedRuneAt := runeIndexes(text.runes)
+	original := string(text.original)
+	originalRuneAt := runeIndexes(text.original)
+
+	for _, compiled := range filter.entries {
+		if compiled.entry.Match == MatchWord {
+			for i := 0; i+len(compiled.words) <= len(tokens); i++ {
+				matched := span{entry: compiled.entry}
+				for j, word := range compiled.words {
+					t := tokens[i+j]
+					start, end := t.start, t.end
+					if t.text != word {
+						if t.trimmed != word {
+							matched.end = -1
+							break
+						}
+						// only the trimmed part matched, so the symbols around it aren't part of the match
+						start, end = t.trimmedStart, t.trimmedEnd
+					}
+
+					if j == 0 {
+						matched.start = start
+					}
+					matched.end = end
+				}
+				if matched.end > matched.start {
+					spans = append(spans, normalizedSpan(text, matched.start, matched.end, matched.entry))
+				}
+			}
+			continue
+		}
+
+		if compiled.entry.Match == MatchRegex {
+			for _, loc := range compiled.regex.FindAllStringIndex(original, -1) {
+				if loc[0] == loc[1] {
+					continue
+				}
+				spans = append(spans, originalSpan(text, originalRuneAt[loc[0]], originalRuneAt[loc[1]], compiled.entry))
+			}
+			continue
+		}
+
+		for _, loc := range compiled.regex.FindAllStringIndex(normalized, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			spans = append(spans, normalizedSpan(text, normalizedRuneAt[loc[0]], normalizedRuneAt[loc[1]], compiled.entry))
+		}
+	}
+
+	return spans
+}
+
+// maps every byte offset of the string made from some runes to the index of the rune it is in
+func runeIndexes(runes []rune) []int {
+	runeAt := make([]int, 0, len(runes)+1)
+	for i, r := range runes {
+		for b := 0; b < utf8.RuneLen(r); b++ {
+			runeAt = append(runeAt, i)
+		}
+	}
+
+	return append(runeAt, len(runes))
+}
+
+// checks some text, e.g. a band name or a setlist description
+func (filter *Filter) Check(text string) Result {
+	result := Result{Masked: text, Matches: []Match{}}
+	if filter == nil || text == "" {
+		return result
+	}
+
+	normalized := normalize(text)
+	tokens := normalized.tokens()
+
+	masked := append([]rune{}, normalized.original...)
+	for _, s := range filter.spans(normalized, tokens) {
+		if filter.allowed(tokens, s) {
+			continue
+		}
+
+		originalStart, originalEnd := s.originalStart, s.originalEnd
+
+		result.Matches = append(result.Matches, Match{
+			Term:     s.entry.Term,
+			Severity: s.entry.Severity,
+			Text:     string(normalized.original[originalStart:originalEnd]),
+		})
+
+		if s.entry.Severity == SeverityReject || result.Severity == "" {
+			result.Severity = s.entry.Severity
+		}
+
+		for i := originalStart; i < originalEnd; i++ {
+			if !unicode.IsSpace(masked[i]) {
+				masked[i] = '*'
+			}
+		}
+	}
+
+	result.Masked = string(masked)
+
+	return result
+}
@@ -0,0 +1,151 @@
+package profanity
+
+import (
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// digits and symbols people swap in for letters to get past filters
+var leetspeak = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'@': 'a',
+	'$': 's',
+	'!': 'i',
+	'+': 't',
+	'|': 'l',
+}
+
+// letters from other scripts that look the same as latin ones, which normalization leaves alone
+var lookalikes = map[rune]rune{
+	'а': 'a',
+	'в': 'b',
+	'е': 'e',
+	'і': 'i',
+	'к': 'k',
+	'м': 'm',
+	'н': 'h',
+	'о': 'o',
+	'р': 'p',
+	'с': 'c',
+	'т': 't',
+	'у': 'y',
+	'х': 'x',
+	'ο': 'o',
+	'α': 'a',
+	'ι': 'i',
+	'ν': 'v',
+}
+
+// a piece of text folded for matching, every folded rune remembers the rune of the original text it came from
+type normalizedText struct {
+	original []rune
+	runes    []rune
+	origins  []int  // index into original for every rune
+	symbolic []bool // whether the rune came from a leetspeak symbol rather than a letter or digit
+}
+
+// a run of letters and digits in normalized text
+type token struct {
+	start, end               int    // rune indexes into the normalized text
+	trimmedStart, trimmedEnd int    // rune indexes of the run without leetspeak symbols on either end
+	text                     string // the whole run
+	trimmed                  string // the run without leetspeak symbols on either end, so "word!" can still match "word"
+}
+
+// folds a single rune into what it is matched as, which can be no runes at all for marks like accents or several for
+// ligatures and other compatibility characters
+func foldRune(r rune) ([]rune, bool) {
+	if mapped, ok := leetspeak[r]; ok {
+		return []rune{mapped}, !unicode.IsDigit(r)
+	}
+
+	var folded []rune
+	for _, decomposed := range norm.NFKD.String(string(r)) {
+		if unicode.Is(unicode.Mn, decomposed) {
+			continue
+		}
+
+		decomposed = unicode.ToLower(decomposed)
+		if mapped, ok := lookalikes[decomposed]; ok {
+			decomposed = mapped
+		}
+		folded = append(folded, decomposed)
+	}
+
+	return folded, false
+}
+
+// lowercases text, strips accents, undoes leetspeak and lookalike letters
+func normalize(text string) normalizedText {
+	n := normalizedText{original: []rune(text)}
+
+	for i, r := range n.original {
+		folded, symbolic := foldRune(r)
+		for _, f := range folded {
+			n.runes = append(n.runes, f)
+			n.origins = append(n.origins, i)
+			n.symbolic = append(n.symbolic, symbolic)
+		}
+	}
+
+	return n
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// splits normalized text into its words
+func (n normalizedText) tokens() []token {
+	var tokens []token
+
+	for i := 0; i < len(n.runes); {
+		if !isWordRune(n.runes[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(n.runes) && isWordRune(n.runes[i]) {
+			i++
+		}
+
+		trimStart, trimEnd := start, i
+		for trimStart < trimEnd && n.symbolic[trimStart] {
+			trimStart++
+		}
+		for trimEnd > trimStart && n.symbolic[trimEnd-1] {
+			trimEnd--
+		}
+
+		tokens = append(tokens, token{
+			start:        start,
+			end:          i,
+			trimmedStart: trimStart,
+			trimmedEnd:   trimEnd,
+			text:         string(n.runes[start:i]),
+			trimmed:      string(n.runes[trimStart:trimEnd]),
+		})
+	}
+
+	return tokens
+}
+
+// the words of some text, for comparing them against a word entry or the allowlist
+func words(text string) []string {
+	tokens := normalize(text).tokens()
+
+	words := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		words = append(words, t.text)
+	}
+
+	return words
+}
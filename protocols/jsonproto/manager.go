@@ -59,6 +59,8 @@ func (mgr *ServicesManager) registerAll() {
 	// setlist creation
 	mgr.register(setlists.SetlistSyncService{})
 	mgr.register(setlists.SetlistUpdateService{})
+	mgr.register(setlists.SetlistFollowService{})
+	mgr.register(setlists.SetlistUnfollowService{})
 
 	// account linking
 	mgr.register(accountlink.AccountLinkService{})
@@ -68,6 +70,7 @@ func (mgr *ServicesManager) registerAll() {
 
 	// entities
 	mgr.register(character.CharacterUpdateService{})
+	mgr.register(character.CharacterNameCheckService{})
 	mgr.register(band.BandUpdateService{})
 	mgr.register(entities.GetLinkcodeService{})
 
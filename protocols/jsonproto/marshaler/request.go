@@ -174,3 +174,9 @@ func normalizeJson(data string) (map[string]interface{}, error) {
 
 	return m, nil
 }
+
+// returns every field in a JSON request keyed by field name, for requests whose fields aren't known ahead of time
+// numbers are float64, same as encoding/json
+func UnmarshalRequestFields(data string) (map[string]interface{}, error) {
+	return normalizeJson(data)
+}
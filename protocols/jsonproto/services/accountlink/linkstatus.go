@@ -1,7 +1,9 @@
 package accountlink
 
 import (
+	"context"
 	"log"
+	db "rb3server/database"
 	"rb3server/protocols/jsonproto/marshaler"
 	"rb3server/utils"
 
@@ -43,9 +45,23 @@ func (service AccountLinkService) Handle(data string, database *mongo.Database,
 		return "", err
 	}
 
+	linked := 1
+
+	// the toggle keeps the old behaviour of reporting everyone as linked, so the achievement can be earned without a website
+	config, err := db.GetCachedConfig(context.TODO())
+	if err != nil || !config.AlwaysReportWebLinked {
+		isLinked, err := db.IsWebLinked(context.TODO(), database, req.PID)
+		if err != nil {
+			log.Printf("Could not get web linked status for PID %d: %v", req.PID, err)
+		}
+		if !isLinked {
+			linked = 0
+		}
+	}
+
 	res := []AccountLinkResponse{{
 		req.PID,
-		1,
+		linked,
 	}}
 
 	return marshaler.MarshalResponse(service.Path(), res)
@@ -7,7 +7,6 @@ import (
 	"rb3server/models"
 	"rb3server/protocols/jsonproto/marshaler"
 	"rb3server/utils"
-	"strings"
 	"time"
 
 	"github.com/ihatecompvir/nex-go"
@@ -57,44 +56,25 @@ func (service BattleCreateService) Handle(data string, database *mongo.Database,
 		return "", err
 	}
 
-	// do a profanity check before updating the setlist
-	config, err := db.GetCachedConfig(context.TODO())
+	// run the battle name and description through the profanity filter, anything it masks is stored masked
+	name, err := db.FilterText(context.TODO(), req.Name)
 	if err != nil {
-		log.Printf("Could not get config: %v\n", err)
+		log.Printf("Could not get profanity filter: %v\n", err)
 		return marshaler.MarshalResponse(service.Path(), []BattleCreateResponse{{0, -1}})
 	}
-
-	// check if the setlist name or description contain anything in the profanity list
-	// NOTE: exercise caution with the profanity list. Putting "ass" on the list would mean that a setlist name like "Band Assistant" is not allowed.
-	// use your best judgment, it's up to you to define your own profanity list as a server host, GoCentral does not and will not ship with one
-	for _, profanity := range config.ProfanityList {
-		if profanity != "" && req.Name != "" && len(req.Name) >= len(profanity) {
-			lowerName := strings.ToLower(req.Name)
-			lowerProfanity := strings.ToLower(profanity)
-
-			if lowerName == lowerProfanity {
-				return marshaler.MarshalResponse(service.Path(), []BattleCreateResponse{{0xF, -1}})
-			}
-
-			if strings.Contains(lowerName, lowerProfanity) {
-				return marshaler.MarshalResponse(service.Path(), []BattleCreateResponse{{0xF, -1}})
-			}
-		}
-
-		// check description too
-		if profanity != "" && req.Description != "" && len(req.Description) >= len(profanity) {
-			lowerName := strings.ToLower(req.Description)
-			lowerProfanity := strings.ToLower(profanity)
-
-			if lowerName == lowerProfanity {
-				return marshaler.MarshalResponse(service.Path(), []BattleCreateResponse{{0x10, -1}})
-			}
-
-			if strings.Contains(lowerName, lowerProfanity) {
-				return marshaler.MarshalResponse(service.Path(), []BattleCreateResponse{{0x10, -1}})
-			}
-		}
+	if name.Rejected() {
+		return marshaler.MarshalResponse(service.Path(), []BattleCreateResponse{{0xF, -1}})
+	}
+	description, err := db.FilterText(context.TODO(), req.Description)
+	if err != nil {
+		log.Printf("Could not get profanity filter: %v\n", err)
+		return marshaler.MarshalResponse(service.Path(), []BattleCreateResponse{{0, -1}})
+	}
+	if description.Rejected() {
+		return marshaler.MarshalResponse(service.Path(), []BattleCreateResponse{{0x10, -1}})
 	}
+	req.Name = name.Masked
+	req.Description = description.Masked
 
 	newSetlistID, err := db.GetNextSetlistID(context.TODO())
 	if err != nil {
@@ -134,14 +114,13 @@ func (service BattleCreateService) Handle(data string, database *mongo.Database,
 	setlist.Created = time.Now().Unix()
 
 	// if user is a battles admin, they will create Harmonix battles
-	if db.IsPIDInGroup(req.PID, "battle_admin") {
+	if db.HasPrivilege(req.PID, db.PrivilegeCreateHarmonixBattles) {
 		setlist.Type = 1002
 	}
 
-	// create song names that are just empty strings for now
-	// TODO: create a song ID DB so we can store the proper names
-	// perhaps there is some way we can automatically create this, but I don't think the game ever sends song names
-	setlist.SongNames = make([]string, len(req.SongIDs))
+	// the game never sends song names, so fill them in from the song catalog
+	// songs that haven't been imported into the catalog just get an empty name
+	setlist.SongNames = db.GetSongNamesInOrder(context.TODO(), database, req.SongIDs)
 
 	update := bson.D{
 		{Key: "art_url", Value: setlist.ArtURL},
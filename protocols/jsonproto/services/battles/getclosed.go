@@ -78,6 +78,11 @@ func (service GetBattlesClosedService) Handle(data string, database *mongo.Datab
 
 		// battle setlist
 		if setlistToCopy.Type == 1000 || setlistToCopy.Type == 1001 || setlistToCopy.Type == 1002 {
+			// scheduled battles stay hidden until they start, and moderated battles stay hidden for good
+			if !db.IsBattleStarted(setlistToCopy) || setlistToCopy.Hidden {
+				continue
+			}
+
 			var battle GetBattlesClosedResponse
 			battle.ArtURL = setlistToCopy.ArtURL
 			battle.Desc = setlistToCopy.Desc
@@ -60,22 +60,21 @@ func (service LimitCheckService) Handle(data string, database *mongo.Database, c
 		return marshaler.MarshalResponse(service.Path(), []LimitCheckResponse{{0x16}})
 	}
 
-	if db.IsPIDInGroup(req.PID, "battle_admin") {
+	if db.HasPrivilege(req.PID, db.PrivilegeUnlimitedBattles) {
 		// if the user is a battle administrator, they can create as many battles as they want
 		// so do not check the limit
 		return marshaler.MarshalResponse(service.Path(), []LimitCheckResponse{{0}})
 	}
 
 	// find how many battles this user has created
-	// type must be either 1000, 1001, or 1002 so we don't catch normal setlists
-	setlistsCollection := database.Collection("setlists")
-	count, err := setlistsCollection.CountDocuments(context.TODO(), bson.M{"pid": req.PID, "type": bson.M{"$in": []int{1000, 1001, 1002}}})
+	count, err := db.CountBattlesForPID(context.TODO(), database, req.PID)
 	if err != nil {
 		log.Printf("Could not count setlists for user %d, could not check battle limit", req.PID)
 		return marshaler.MarshalResponse(service.Path(), []LimitCheckResponse{{0x16}})
 	}
 
-	if int(count) >= config.BattleLimit {
+	// admins can raise or lower the limit for individual players
+	if count >= db.GetBattleLimitForUser(user, config) {
 		return marshaler.MarshalResponse(service.Path(), []LimitCheckResponse{{0x16}})
 	}
 
@@ -7,8 +7,8 @@ import (
 	db "rb3server/database"
 	"rb3server/models"
 	"rb3server/protocols/jsonproto/marshaler"
+	"rb3server/protocols/jsonproto/services/entities"
 	"rb3server/utils"
-	"strings"
 
 	"github.com/ihatecompvir/nex-go"
 	"go.mongodb.org/mongo-driver/bson"
@@ -44,32 +44,6 @@ func (service BandUpdateService) Handle(data string, database *mongo.Database, c
 		return "", err
 	}
 
-	// do a profanity check before updating the band
-	var config models.Config
-	configCollection := database.Collection("config")
-	err = configCollection.FindOne(context.TODO(), bson.M{}).Decode(&config)
-	if err != nil {
-		log.Printf("Could not get config %v\n", err)
-	}
-
-	// check if the band name contains anything in the profanity list
-	// NOTE: exercise caution with the profanity list. Putting "ass" on the list would mean that a name like "Band Assistant" is not allowed.
-	// use your best judgment, it's up to you to define your own profanity list as a server host, GoCentral does not and will not ship with one
-	for _, profanity := range config.ProfanityList {
-		if profanity != "" && req.Name != "" && len(req.Name) >= len(profanity) {
-			lowerName := strings.ToLower(req.Name)
-			lowerProfanity := strings.ToLower(profanity)
-
-			if lowerName == lowerProfanity {
-				return marshaler.MarshalResponse(service.Path(), []BandUpdateResponse{{2}})
-			}
-
-			if strings.Contains(lowerName, lowerProfanity) {
-				return marshaler.MarshalResponse(service.Path(), []BandUpdateResponse{{2}})
-			}
-		}
-	}
-
 	validPIDres, err := utils.GetClientStoreSingleton().IsValidPID(client.Address().String(), uint32(req.PID))
 
 	if !validPIDres {
@@ -86,8 +60,24 @@ func (service BandUpdateService) Handle(data string, database *mongo.Database, c
 	bands := database.Collection("bands")
 	var band models.Band
 	err = bands.FindOne(nil, bson.M{"owner_pid": req.PID}).Decode(&band)
+	isNew := err != nil
 
-	if err != nil {
+	// new bands and renamed ones have to pass the name checks
+	if isNew || band.Name != req.Name {
+		result, name, err := db.CheckBandName(context.TODO(), database, req.Name, req.PID)
+		if err != nil {
+			log.Printf("Could not check band name %s for PID %v: %s\n", req.Name, req.PID, err)
+			return marshaler.MarshalResponse(service.Path(), []BandUpdateResponse{{0}})
+		}
+		if result != db.NameAllowed {
+			return marshaler.MarshalResponse(service.Path(), []BandUpdateResponse{{entities.NameCheckRetCode(result)}})
+		}
+
+		// anything the profanity filter masks is stored masked
+		req.Name = name
+	}
+
+	if isNew {
 
 		newBandID, err := db.GetNextBandID(context.Background())
 		if err != nil {
@@ -3,13 +3,12 @@ package character
 import (
 	"context"
 	"log"
-	"rb3server/models"
+	db "rb3server/database"
 	"rb3server/protocols/jsonproto/marshaler"
+	"rb3server/protocols/jsonproto/services/entities"
 	"rb3server/utils"
-	"strings"
 
 	"github.com/ihatecompvir/nex-go"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -31,7 +30,7 @@ type CharacterNameCheckService struct {
 }
 
 func (service CharacterNameCheckService) Path() string {
-	return "entities/character/update"
+	return "entities/character/namecheck"
 }
 
 func (service CharacterNameCheckService) Handle(data string, database *mongo.Database, client *nex.Client) (string, error) {
@@ -48,31 +47,12 @@ func (service CharacterNameCheckService) Handle(data string, database *mongo.Dat
 		return "", err
 	}
 
-	// do a profanity check before updating the band
-	var config models.Config
-	configCollection := database.Collection("config")
-	err = configCollection.FindOne(context.TODO(), bson.M{}).Decode(&config)
+	// the same checks run again when the character is saved, this just lets the game tell the player straight away
+	result, _, err := db.CheckCharacterName(context.TODO(), database, req.Name, req.PID)
 	if err != nil {
-		log.Printf("Could not get config %v\n", err)
+		log.Printf("Could not check character name %s for PID %v: %s\n", req.Name, req.PID, err)
+		return marshaler.MarshalResponse(service.Path(), []CharacterNameCheckResponse{{0}})
 	}
 
-	// check if the band name contains anything in the profanity list
-	// NOTE: exercise caution with the profanity list. Putting "ass" on the list would mean that a name like "Band Assistant" is not allowed.
-	// use your best judgment, it's up to you to define your own profanity list as a server host, GoCentral does not and will not ship with one
-	for _, profanity := range config.ProfanityList {
-		if profanity != "" && req.Name != "" && len(req.Name) >= len(profanity) {
-			lowerName := strings.ToLower(req.Name)
-			lowerProfanity := strings.ToLower(profanity)
-
-			if lowerName == lowerProfanity {
-				return marshaler.MarshalResponse(service.Path(), []CharacterNameCheckResponse{{2}})
-			}
-
-			if strings.Contains(lowerName, lowerProfanity) {
-				return marshaler.MarshalResponse(service.Path(), []CharacterNameCheckResponse{{2}})
-			}
-		}
-	}
-
-	return marshaler.MarshalResponse(service.Path(), []CharacterNameCheckResponse{{1}})
+	return marshaler.MarshalResponse(service.Path(), []CharacterNameCheckResponse{{entities.NameCheckRetCode(result)}})
 }
@@ -7,6 +7,7 @@ import (
 	db "rb3server/database"
 	"rb3server/models"
 	"rb3server/protocols/jsonproto/marshaler"
+	"rb3server/protocols/jsonproto/services/entities"
 	"rb3server/utils"
 
 	"github.com/ihatecompvir/nex-go"
@@ -60,8 +61,24 @@ func (service CharacterUpdateService) Handle(data string, database *mongo.Databa
 	characters := database.Collection("characters")
 	var character models.Character
 	err = characters.FindOne(nil, bson.M{"guid": req.GUID}).Decode(&character)
+	isNew := err != nil
 
-	if err != nil {
+	// new characters and renamed ones go through the same checks as the name check
+	if isNew || character.Name != req.Name {
+		result, name, err := db.CheckCharacterName(context.TODO(), database, req.Name, req.PID)
+		if err != nil {
+			log.Printf("Could not check character name %s for PID %v: %s\n", req.Name, req.PID, err)
+			return marshaler.MarshalResponse(service.Path(), []CharacterUpdateResponse{{0}})
+		}
+		if result != db.NameAllowed {
+			return marshaler.MarshalResponse(service.Path(), []CharacterUpdateResponse{{entities.NameCheckRetCode(result)}})
+		}
+
+		// anything the profanity filter masks is stored masked
+		req.Name = name
+	}
+
+	if isNew {
 
 		newCharacterID, err := db.GetNextCharacterID(context.Background())
 		if err != nil {
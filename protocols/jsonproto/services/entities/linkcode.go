@@ -1,13 +1,13 @@
 package entities
 
 import (
+	"context"
 	"log"
-	"rb3server/models"
+	db "rb3server/database"
 	"rb3server/protocols/jsonproto/marshaler"
 	"rb3server/utils"
 
 	"github.com/ihatecompvir/nex-go"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -33,14 +33,11 @@ func (service GetLinkcodeService) Path() string {
 func (service GetLinkcodeService) Handle(data string, database *mongo.Database, client *nex.Client) (string, error) {
 	var req GetLinkcodeRequest
 	err := marshaler.UnmarshalRequest(data, &req)
-
-	res := []GetLinkcodeResponse{{}}
-
 	if err != nil {
 		log.Println("Failed to unmarshal GetLinkcodeRequest:", err)
-		res = []GetLinkcodeResponse{{
+		return marshaler.MarshalResponse(service.Path(), []GetLinkcodeResponse{{
 			"Could not get link code, please try again later",
-		}}
+		}})
 	}
 
 	// make sure the client is asking for their own link code
@@ -51,30 +48,20 @@ func (service GetLinkcodeService) Handle(data string, database *mongo.Database,
 		return "", err
 	}
 
-	usersCollection := database.Collection("users")
-	var user models.User
-
-	result := usersCollection.FindOne(nil, bson.M{"pid": req.PID})
-
-	if result.Err() != nil {
-		log.Println("Could not find user with PID", req.PID)
-		res = []GetLinkcodeResponse{{
-			"Could not get link code, please try again later",
-		}}
+	config, err := db.GetCachedConfig(context.TODO())
+	if err != nil {
+		log.Println("Could not get config for link code lifetime:", err)
 	}
 
-	err = result.Decode(&user)
-
+	// codes are single-use and expire, so a new one is made whenever the last one was used or has run out
+	linkCode, err := db.GetOrRegenerateLinkCode(context.TODO(), database, req.PID, db.LinkCodeLifetime(config))
 	if err != nil {
-		log.Println("Could not decode user with PID", req.PID)
-		res = []GetLinkcodeResponse{{
-			"Could not get link code, please try again later",
-		}}
+		log.Printf("Could not get link code for PID %d: %v", req.PID, err)
+		linkCode = "Could not get link code, please try again later"
 	}
 
-	// Spoof account linking status, 12345 pid
-	res = []GetLinkcodeResponse{{
-		user.LinkCode,
+	res := []GetLinkcodeResponse{{
+		linkCode,
 	}}
 
 	return marshaler.MarshalResponse(service.Path(), res)
@@ -0,0 +1,23 @@
+package entities
+
+import (
+	db "rb3server/database"
+)
+
+// ret_code values the entity services send back for names
+// 1 is the only one the game accepts, 2 is what it has always been sent for names on the profanity list
+// these are the only two codes known to be handled, so names that are taken or invalid are refused with 2 as well
+const (
+	RetCodeNameAllowed  = 1
+	RetCodeNameRejected = 2
+)
+
+// turns the result of a character or band name check into the ret_code the game is sent
+// why a name was refused isn't passed on, since the game has no known code to tell the reasons apart
+func NameCheckRetCode(result db.NameCheckResult) int {
+	if result == db.NameAllowed {
+		return RetCodeNameAllowed
+	}
+
+	return RetCodeNameRejected
+}
@@ -3,7 +3,6 @@ package leaderboard
 import (
 	"context"
 	"log"
-	"rb3server/models"
 	"rb3server/protocols/jsonproto/marshaler"
 	"rb3server/utils"
 
@@ -48,22 +47,18 @@ func (service AccMaxrankGetService) Handle(data string, database *mongo.Database
 		return "", err
 	}
 
-	accomplishmentsCollection := database.Collection("accomplishments")
-
-	var accomplishments models.Accomplishments
-	err = accomplishmentsCollection.FindOne(context.TODO(), bson.M{"acc_id": req.AccID}).Decode(&accomplishments)
+	accomplishmentsCollection := database.Collection("accomplishment_scores")
 
+	numScores, err := accomplishmentsCollection.CountDocuments(context.TODO(), bson.M{"acc_id": req.AccID})
 	if err != nil {
 		return marshaler.MarshalResponse(service.Path(), []AccMaxrankGetResponse{{
 			0,
 		}})
 	}
 
-	accSlice := getAccomplishmentField(req.AccID, accomplishments)
-
 	// return the number of scores, aka the "max rank"
 	res := []AccMaxrankGetResponse{{
-		len(accSlice),
+		int(numScores),
 	}}
 
 	return marshaler.MarshalResponse(service.Path(), res)
@@ -7,11 +7,11 @@ import (
 	"rb3server/models"
 	"rb3server/protocols/jsonproto/marshaler"
 	"rb3server/utils"
-	"sort"
 
 	"github.com/ihatecompvir/nex-go"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type AccPlayerGetRequest struct {
@@ -41,77 +41,6 @@ type AccPlayerGetResponse struct {
 type AccPlayerGetService struct {
 }
 
-// a function that gets the acc_id and returns the proper field of the Accomplishments model
-// for example, campaign_metascore is LBGoalValueCampaignMetascore in models.Accomplishments
-func getAccomplishmentField(accID string, accomplishments models.Accomplishments) []models.AccomplishmentScoreEntry {
-	switch accID {
-	case "campaign_metascore":
-		return accomplishments.LBGoalValueCampaignMetascore
-	case "acc_tourgoldlocal1":
-		return accomplishments.LBGoalValueAccTourgoldlocal1
-	case "acc_tourgoldlocal2":
-		return accomplishments.LBGoalValueAccTourgoldlocal2
-	case "acc_tourgoldregional1":
-		return accomplishments.LBGoalValueAccTourgoldregional1
-	case "acc_tourgoldregional2":
-		return accomplishments.LBGoalValueAccTourgoldregional2
-	case "acc_tourgoldcontinental1":
-		return accomplishments.LBGoalValueAccTourgoldcontinental1
-	case "acc_tourgoldcontinental2":
-		return accomplishments.LBGoalValueAccTourgoldcontinental2
-	case "acc_tourgoldglobal1":
-		return accomplishments.LBGoalValueAccTourgoldglobal1
-	case "acc_tourgoldglobal2":
-		return accomplishments.LBGoalValueAccTourgoldglobal2
-	case "acc_tourgoldglobal3":
-		return accomplishments.LBGoalValueAccTourgoldglobal3
-	case "acc_overdrivemaintain3":
-		return accomplishments.LBGoalValueAccOverdrivemaintain3
-	case "acc_overdrivecareer":
-		return accomplishments.LBGoalValueAccOverdrivecareer
-	case "acc_careersaves":
-		return accomplishments.LBGoalValueAccCareersaves
-	case "acc_millionpoints":
-		return accomplishments.LBGoalValueAccMillionpoints
-	case "acc_bassstreaklarge":
-		return accomplishments.LBGoalValueAccBassstreaklarge
-	case "acc_hopothreehundredbass":
-		return accomplishments.LBGoalValueAccHopothreehundredbass
-	case "acc_drumfill170":
-		return accomplishments.LBGoalValueAccDrumfill170
-	case "acc_drumstreaklong":
-		return accomplishments.LBGoalValueAccDrumstreaklong
-	case "acc_deployguitarfour":
-		return accomplishments.LBGoalValueAccDeployguitarfour
-	case "acc_guitarstreaklarge":
-		return accomplishments.LBGoalValueAccGuitarstreaklarge
-	case "acc_keystreaklong":
-		return accomplishments.LBGoalValueAccKeystreaklong
-	case "acc_hopoonethousand":
-		return accomplishments.LBGoalValueAccHopoonethousand
-	case "acc_doubleawesomealot":
-		return accomplishments.LBGoalValueAccDoubleawesomealot
-	case "acc_tripleawesomealot":
-		return accomplishments.LBGoalValueAccTripleawesomealot
-	case "acc_probassstreakepic":
-		return accomplishments.LBGoalValueAccProbassstreakepic
-	case "acc_prodrumroll3":
-		return accomplishments.LBGoalValueAccProdrumroll3
-	case "acc_prodrumstreaklong":
-		return accomplishments.LBGoalValueAccProdrumstreaklong
-	case "acc_proguitarstreakepic":
-		return accomplishments.LBGoalValueAccProguitarstreakepic
-	case "acc_prokeystreaklong":
-		return accomplishments.LBGoalValueAccProkeystreaklong
-	case "acc_deployvocals":
-		return accomplishments.LBGoalValueAccDeployvocals
-	case "acc_deployvocalsonehundred":
-		return accomplishments.LBGoalValueAccDeployvocalsonehundred
-	default:
-		return []models.AccomplishmentScoreEntry{}
-	}
-}
-
 func (service AccPlayerGetService) Path() string {
 	return "leaderboards/acc_player/get"
 }
@@ -134,58 +63,77 @@ func (service AccPlayerGetService) Handle(data string, database *mongo.Database,
 	// fetch friends list for IsFriend marking
 	friendsMap, _ := db.GetFriendsForPID(context.Background(), database, req.PID000)
 
-	accomplishmentsCollection := database.Collection("accomplishments")
+	accomplishmentsCollection := database.Collection("accomplishment_scores")
+
+	// find the player's position on the leaderboard
+	// if the player has no score, just start with the first page
+	playerScoreIdx := int64(0)
+	var playerScore models.AccomplishmentScore
+	err = accomplishmentsCollection.FindOne(context.TODO(), bson.M{"acc_id": req.AccID, "pid": req.PID000}).Decode(&playerScore)
+	if err == nil {
+		playerScoreIdx, err = accomplishmentsCollection.CountDocuments(context.TODO(), bson.M{"acc_id": req.AccID, "score": bson.M{"$gt": playerScore.Score}})
+		if err != nil {
+			return marshaler.GenerateEmptyJSONResponse(service.Path()), nil
+		}
+	} else if err != mongo.ErrNoDocuments {
+		return marshaler.GenerateEmptyJSONResponse(service.Path()), nil
+	}
 
-	// FindOne the accomplishment scores
-	var accomplishments models.Accomplishments
-	err = accomplishmentsCollection.FindOne(context.TODO(), bson.M{}).Decode(&accomplishments)
+	// start and end idx must be in a window size of 20 otherwise the UI will act a bit buggy
+	startIdx := (playerScoreIdx / 20) * 20
+	limit := int64(20)
 
+	cursor, err := accomplishmentsCollection.Find(context.TODO(), bson.M{"acc_id": req.AccID}, &options.FindOptions{
+		Skip:  &startIdx,
+		Limit: &limit,
+		Sort:  bson.D{{Key: "score", Value: -1}, {Key: "pid", Value: 1}},
+	})
 	if err != nil {
 		return marshaler.GenerateEmptyJSONResponse(service.Path()), nil
 	}
 
-	res := []AccPlayerGetResponse{}
+	var accScores []models.AccomplishmentScore
+	if err = cursor.All(context.TODO(), &accScores); err != nil {
+		log.Println("Failed to decode accomplishment scores:", err)
+		return marshaler.GenerateEmptyJSONResponse(service.Path()), nil
+	}
 
-	accSlice := getAccomplishmentField(req.AccID, accomplishments)
+	playerPIDs := make([]int, 0, len(accScores))
+	for _, score := range accScores {
+		playerPIDs = append(playerPIDs, score.PID)
+	}
 
-	// sort acc scores by score
-	sort.Slice(accSlice, func(i, j int) bool {
-		return accSlice[i].Score > accSlice[j].Score
-	})
+	// grab console-prefixed usernames for all players at once
+	playerNames, _ := db.GetConsolePrefixedUsernamesByPIDs(context.Background(), database, playerPIDs)
 
-	// find the player's score idx in the sorted list
-	// if the player has no scores, just start with the first score
-	playerScoreIdx := 0
-	for idx, score := range accSlice {
-		if score.PID == req.PID000 {
-			playerScoreIdx = idx
-			break
-		}
-	}
+	res := []AccPlayerGetResponse{}
 
-	// start and end idx must be in a window size of 20 otherwise the UI will act a bit buggy
-	startIdx := (playerScoreIdx / 20) * 20
-	endIdx := min(len(accSlice), startIdx+20)
+	for i, score := range accScores {
+		name := playerNames[score.PID]
+		if name == "" {
+			name = "Unnamed Player"
+		}
 
-	for i := startIdx; i < endIdx; i++ {
-		score := accSlice[i]
 		isFriend := 0
 		if friendsMap[score.PID] {
 			isFriend = 1
 		}
+
+		rank := int(startIdx) + i + 1
+
 		res = append(res, AccPlayerGetResponse{
 			PID:          score.PID,
 			Score:        score.Score,
 			DiffID:       0,
-			Name:         db.GetConsolePrefixedUsernameForPID(score.PID),
+			Name:         name,
 			IsPercentile: 0,
 			IsFriend:     isFriend,
 			InstMask:     0,
 			NotesPct:     0,
 			UnnamedBand:  0,
 			PGUID:        "",
-			Rank:         i + 1,
-			ORank:        i + 1,
+			Rank:         rank,
+			ORank:        rank,
 		})
 	}
 
@@ -7,11 +7,11 @@ import (
 	"rb3server/models"
 	"rb3server/protocols/jsonproto/marshaler"
 	"rb3server/utils"
-	"sort"
 
 	"github.com/ihatecompvir/nex-go"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type AccRankRangeGetRequest struct {
@@ -66,35 +66,32 @@ func (service AccRankRangeGetService) Handle(data string, database *mongo.Databa
 	// fetch friends list for IsFriend marking
 	friendsMap, _ := db.GetFriendsForPID(context.Background(), database, req.PID000)
 
-	accomplishmentsCollection := database.Collection("accomplishments")
-
-	var accomplishments models.Accomplishments
-	err = accomplishmentsCollection.FindOne(context.TODO(), bson.M{}).Decode(&accomplishments)
-
-	if err != nil {
-		return marshaler.GenerateEmptyJSONResponse(service.Path()), nil
-	}
-
-	accSlice := getAccomplishmentField(req.AccID, accomplishments)
-
-	// sort acc scores by score
-	sort.Slice(accSlice, func(i, j int) bool {
-		return accSlice[i].Score > accSlice[j].Score
-	})
+	accomplishmentsCollection := database.Collection("accomplishment_scores")
 
 	// calculate what the actual range will be
-	start := req.StartRank - 1
+	start := int64(req.StartRank - 1)
 	if start < 0 {
 		start = 0
 	}
-	end := req.EndRank
-	if end > len(accSlice) {
-		end = len(accSlice)
+	numRows := int64(req.EndRank) - start
+	if numRows <= 0 {
+		return marshaler.GenerateEmptyJSONResponse(service.Path()), nil
+	}
+
+	cursor, err := accomplishmentsCollection.Find(context.TODO(), bson.M{"acc_id": req.AccID}, &options.FindOptions{
+		Skip:  &start,
+		Limit: &numRows,
+		Sort:  bson.D{{Key: "score", Value: -1}, {Key: "pid", Value: 1}},
+	})
+	if err != nil {
+		return marshaler.GenerateEmptyJSONResponse(service.Path()), nil
 	}
-	if start > end {
-		start = end
+
+	var visibleScores []models.AccomplishmentScore
+	if err = cursor.All(context.TODO(), &visibleScores); err != nil {
+		log.Println("Failed to decode accomplishment scores:", err)
+		return marshaler.GenerateEmptyJSONResponse(service.Path()), nil
 	}
-	visibleScores := accSlice[start:end]
 
 	// collect all the player PIDs we need to fetch
 	playerPIDs := make([]int, 0, len(visibleScores))
@@ -117,7 +114,7 @@ func (service AccRankRangeGetService) Handle(data string, database *mongo.Databa
 			name = "Unnamed Player"
 		}
 
-		rank := start + i + 1
+		rank := int(start) + i + 1
 
 		isFriend := 0
 		if friendsMap[score.PID] {
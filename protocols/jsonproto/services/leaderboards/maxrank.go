@@ -57,12 +57,18 @@ func (service MaxrankGetService) Handle(data string, database *mongo.Database, c
 
 	scoresCollection := database.Collection("scores")
 
+	// keep the rank count in line with rankrange when the server forces platform-only leaderboards
+	platformPIDs := getForcedPlatformPIDs(database, client)
+
 	var numScores int64
 
 	switch req.LBType {
 	case LBTypeNormal:
 		// Normal behavior - count documents matching song_id and role_id
 		filter := bson.M{"song_id": req.SongID, "role_id": req.RoleID}
+		if platformPIDs != nil {
+			filter["pid"] = bson.M{"$in": platformPIDs}
+		}
 		numScores, err = scoresCollection.CountDocuments(context.TODO(), filter)
 		if err != nil {
 			return marshaler.MarshalResponse(service.Path(), []MaxrankGetResponse{{0}})
@@ -83,6 +89,10 @@ func (service MaxrankGetService) Handle(data string, database *mongo.Database, c
 
 		matchStage = append(matchStage, bson.E{Key: "role_id", Value: req.RoleID})
 
+		if platformPIDs != nil {
+			matchStage = append(matchStage, bson.E{Key: "pid", Value: bson.D{{Key: "$in", Value: platformPIDs}}})
+		}
+
 		// Build pipeline
 		pipeline := mongo.Pipeline{}
 		if len(matchStage) > 0 {
@@ -0,0 +1,46 @@
+package leaderboard
+
+import (
+	"context"
+	"log"
+
+	db "rb3server/database"
+
+	"github.com/ihatecompvir/nex-go"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// console type -> the lb_mode the game uses to ask for that console's leaderboard
+// the reverse of the lb_mode -> console type maps used when the player picks a console leaderboard themselves
+var consoleTypeLBModes = map[int]int{0: 5, 1: 2, 2: 4, 3: 3}
+
+// returns the console type the client's global leaderboards should be limited to, if the server forces platform-only leaderboards
+func getForcedConsoleType(client *nex.Client) (int, bool) {
+	config, err := db.GetCachedConfig(context.Background())
+	if err != nil {
+		log.Printf("Could not get config for platform-only leaderboards: %v", err)
+		return 0, false
+	}
+
+	if !config.PlatformOnlyLeaderboards {
+		return 0, false
+	}
+
+	return client.Platform(), true
+}
+
+// returns the PIDs the client's global leaderboards should be limited to, or nil if they shouldn't be limited at all
+func getForcedPlatformPIDs(database *mongo.Database, client *nex.Client) []int {
+	consoleType, ok := getForcedConsoleType(client)
+	if !ok {
+		return nil
+	}
+
+	pids, err := db.GetPIDListByConsoleType(context.Background(), database, consoleType)
+	if err != nil {
+		log.Printf("Could not get PIDs for console type %d: %v", consoleType, err)
+		return []int{}
+	}
+
+	return pids
+}
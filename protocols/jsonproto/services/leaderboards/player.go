@@ -67,6 +67,13 @@ func (service PlayerGetService) Handle(data string, database *mongo.Database, cl
 	// fetch friends list for IsFriend marking and friends leaderboard filtering
 	friendsMap, _ := db.GetFriendsForPID(context.Background(), database, req.PID000)
 
+	// if the server forces platform-only leaderboards, the global leaderboard becomes the one for the client's console
+	if req.LBMode == 0 {
+		if consoleType, ok := getForcedConsoleType(client); ok {
+			req.LBMode = consoleTypeLBModes[consoleType]
+		}
+	}
+
 	scoresCollection := database.Collection("scores")
 
 	isAggregated := req.LBType == LBTypeTotalScore || req.LBType == LBTypeRB3Only
@@ -68,6 +68,9 @@ func (service RankRangeGetService) Handle(data string, database *mongo.Database,
 	// fetch friends list for IsFriend marking and friends leaderboard filtering
 	friendsMap, _ := db.GetFriendsForPID(context.Background(), database, req.PID000)
 
+	// nil unless the server forces platform-only leaderboards
+	platformPIDs := getForcedPlatformPIDs(database, client)
+
 	scoresCollection := database.Collection("scores")
 
 	startRank := int64(req.StartRank - 1)
@@ -94,6 +97,10 @@ func (service RankRangeGetService) Handle(data string, database *mongo.Database,
 
 		matchStage = append(matchStage, bson.E{Key: "role_id", Value: req.RoleID})
 
+		if platformPIDs != nil {
+			matchStage = append(matchStage, bson.E{Key: "pid", Value: bson.D{{Key: "$in", Value: platformPIDs}}})
+		}
+
 		// Build aggregation pipeline
 		// i genuinely hate mongo syntax
 		pipeline := mongo.Pipeline{}
@@ -122,6 +129,9 @@ func (service RankRangeGetService) Handle(data string, database *mongo.Database,
 		}
 	} else {
 		filter := bson.M{"song_id": req.SongID, "role_id": req.RoleID}
+		if platformPIDs != nil {
+			filter["pid"] = bson.M{"$in": platformPIDs}
+		}
 
 		cursor, err := scoresCollection.Find(context.TODO(), filter, &options.FindOptions{
 			Skip:  &startRank,
@@ -8,6 +8,7 @@ import (
 	"rb3server/protocols/jsonproto/marshaler"
 	"rb3server/utils"
 	"strconv"
+	"time"
 
 	"github.com/ihatecompvir/nex-go"
 	"go.mongodb.org/mongo-driver/bson"
@@ -24,6 +25,12 @@ type BattleScoreRecordRequest struct {
 	PIDs        []int  `json:"pidXXX"`
 	BattleID    int    `json:"battle_id"`
 	Slots       []int  `json:"slotXXX"`
+
+	// not every client sends these, they are only checked against the battle's rules when present
+	SongID   int   `json:"song_id"`
+	RoleIDs  []int `json:"role_idXXX"`
+	DiffIDs  []int `json:"diff_idXXX"`
+	BandMask int   `json:"band_mask"`
 }
 
 type BattleScoreRecordResponse struct {
@@ -63,13 +70,39 @@ func (service BattleScoreRecordService) Handle(data string, database *mongo.Data
 		return "", err
 	}
 
-	// make sure the player is not trying to submit a score for a battle that is expired
-	isExpired, _ := db.GetBattleExpiryInfo(req.BattleID)
-	if isExpired {
-		log.Println("Battle", req.BattleID, "is expired, rejecting battle score record")
+	// make sure the score follows the rules of the battle, e.g. the battle is open and the right instrument was played
+	battle, err := db.GetBattle(context.TODO(), database, req.BattleID)
+	if err != nil {
+		log.Println("Could not get battle", req.BattleID, "for battle score record:", err)
 		return "", err
 	}
 
+	if reasons := db.ValidateBattleSubmission(battle, db.BattleSubmission{
+		SongID:   req.SongID,
+		RoleIDs:  req.RoleIDs,
+		BandMask: req.BandMask,
+	}); len(reasons) > 0 {
+		log.Printf("Battle score record for battle %d broke the battle's rules, rejecting battle score record: %v", req.BattleID, reasons)
+
+		err := db.LogBattleScoreRejection(context.TODO(), database, models.BattleScoreRejection{
+			BattleID:    req.BattleID,
+			PIDs:        req.PIDs,
+			Score:       req.Score,
+			SongID:      req.SongID,
+			RoleIDs:     req.RoleIDs,
+			DiffIDs:     req.DiffIDs,
+			BandMask:    req.BandMask,
+			Reasons:     reasons,
+			ConsoleType: client.Platform(),
+			MachineID:   req.MachineID,
+			SessionGUID: req.SessionGUID,
+		})
+		if err != nil {
+			log.Println("Could not log battle score rejection:", err)
+		}
+		return "", nil
+	}
+
 	scoresCollection := database.Collection("scores")
 
 	scoreHigher := []bool{}
@@ -80,6 +113,12 @@ func (service BattleScoreRecordService) Handle(data string, database *mongo.Data
 		score.OwnerPID = pid
 		score.BattleID = req.BattleID
 		score.Score = req.Score
+		score.SubmittedAt = time.Now().Unix()
+		score.ConsoleType = client.Platform()
+		score.MachineID = req.MachineID
+		score.SessionGUID = req.SessionGUID
+		score.Region = req.Region
+		score.BandPIDs = getBandMatePIDs(req.PIDs, pid)
 
 		// Retrieve the existing score
 		var existingScore models.Score
@@ -98,6 +137,12 @@ func (service BattleScoreRecordService) Handle(data string, database *mongo.Data
 						{"battle_id", score.BattleID},
 						{"pid", score.OwnerPID},
 						{"score", score.Score},
+						{"submitted_at", score.SubmittedAt},
+						{"console_type", score.ConsoleType},
+						{"machine_id", score.MachineID},
+						{"session_guid", score.SessionGUID},
+						{"region", score.Region},
+						{"band_pids", score.BandPIDs},
 					}},
 				},
 				options.Update().SetUpsert(true),
@@ -7,6 +7,7 @@ import (
 	"rb3server/protocols/jsonproto/marshaler"
 	"rb3server/utils"
 	"strconv"
+	"time"
 
 	"github.com/ihatecompvir/nex-go"
 	"go.mongodb.org/mongo-driver/bson"
@@ -150,6 +151,14 @@ func (service ScoreRecordService) Handle(data string, database *mongo.Database,
 		Score.NotesPercent = req.Percents[idx]
 		Score.RoleID = req.RoleIDs[idx]
 
+		// provenance so we can later tell where and when a score came from
+		Score.SubmittedAt = time.Now().Unix()
+		Score.ConsoleType = client.Platform()
+		Score.MachineID = req.MachineID
+		Score.SessionGUID = req.SessionGUID
+		Score.Region = req.Region
+		Score.BandPIDs = getBandMatePIDs(req.PIDs, pid)
+
 		if req.RoleIDs[idx] == 10 {
 			Score.BOI = 0
 		} else {
@@ -157,6 +166,15 @@ func (service ScoreRecordService) Handle(data string, database *mongo.Database,
 			Score.InstrumentMask = instrumentMap[req.RoleIDs[idx]]
 		}
 
+		// scores that are impossible according to the song catalog go to the moderators instead of the leaderboards
+		if reasons := db.CheckScorePlausibility(context.TODO(), database, Score); len(reasons) > 0 {
+			log.Printf("Client-supplied score for song %d failed plausibility validation, quarantining score record: %v", req.SongID, reasons)
+			if err := db.QuarantineScore(context.TODO(), database, Score, reasons); err != nil {
+				log.Println("Could not quarantine score:", err)
+			}
+			continue
+		}
+
 		// Retrieve the existing score
 		var existingScore models.Score
 		err := scoresCollection.FindOne(context.TODO(), bson.M{"song_id": req.SongID, "pid": Score.OwnerPID, "role_id": Score.RoleID}).Decode(&existingScore)
@@ -180,6 +198,12 @@ func (service ScoreRecordService) Handle(data string, database *mongo.Database,
 						{"diff_id", Score.DiffID},
 						{"boi", Score.BOI},
 						{"instrument_mask", Score.InstrumentMask},
+						{"submitted_at", Score.SubmittedAt},
+						{"console_type", Score.ConsoleType},
+						{"machine_id", Score.MachineID},
+						{"session_guid", Score.SessionGUID},
+						{"region", Score.Region},
+						{"band_pids", Score.BandPIDs},
 					}},
 				},
 				options.Update().SetUpsert(true),
@@ -290,3 +314,19 @@ func (service ScoreRecordService) Handle(data string, database *mongo.Database,
 
 	return marshaler.MarshalResponse(service.Path(), res)
 }
+
+// returns the unique PIDs of everyone else that took part in a submission
+func getBandMatePIDs(pids []int, ownerPID int) []int {
+	bandPIDs := []int{}
+	seen := map[int]bool{ownerPID: true}
+
+	for _, pid := range pids {
+		if seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		bandPIDs = append(bandPIDs, pid)
+	}
+
+	return bandPIDs
+}
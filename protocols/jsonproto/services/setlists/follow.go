@@ -0,0 +1,92 @@
+package setlists
+
+import (
+	"context"
+	"log"
+	db "rb3server/database"
+	"rb3server/protocols/jsonproto/marshaler"
+	"rb3server/utils"
+
+	"github.com/ihatecompvir/nex-go"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type SetlistFollowRequest struct {
+	Region      string `json:"region"`
+	SystemMS    int    `json:"system_ms"`
+	MachineID   string `json:"machine_id"`
+	SessionGUID string `json:"session_guid"`
+	PID         int    `json:"pid"`
+	ListGUID    string `json:"list_guid"`
+}
+
+type SetlistFollowResponse struct {
+	RetCode int `json:"ret_code"`
+}
+
+// lets a player follow a shared setlist by its GUID, so it shows up in their songlists/get even if the owner isn't a friend
+type SetlistFollowService struct {
+}
+
+func (service SetlistFollowService) Path() string {
+	return "setlists/follow"
+}
+
+func (service SetlistFollowService) Handle(data string, database *mongo.Database, client *nex.Client) (string, error) {
+	var req SetlistFollowRequest
+	err := marshaler.UnmarshalRequest(data, &req)
+	if err != nil {
+		return "", err
+	}
+
+	validPIDres, _ := utils.GetClientStoreSingleton().IsValidPID(client.Address().String(), uint32(req.PID))
+
+	if !validPIDres {
+		log.Println("Client is attempting to follow a setlist without a valid server-assigned PID, rejecting call")
+		return "", nil
+	}
+
+	found, err := db.FollowSetlist(context.TODO(), database, req.ListGUID, req.PID)
+	if err != nil {
+		log.Printf("Error following setlist %s: %v", req.ListGUID, err)
+		return marshaler.MarshalResponse(service.Path(), []SetlistFollowResponse{{0x16}})
+	}
+
+	if !found {
+		log.Printf("Player with PID %d attempted to follow setlist %s which is not shared or does not exist", req.PID, req.ListGUID)
+		return marshaler.MarshalResponse(service.Path(), []SetlistFollowResponse{{0x16}})
+	}
+
+	return marshaler.MarshalResponse(service.Path(), []SetlistFollowResponse{{0}})
+}
+
+// stops a player following a setlist
+type SetlistUnfollowService struct {
+}
+
+func (service SetlistUnfollowService) Path() string {
+	return "setlists/unfollow"
+}
+
+func (service SetlistUnfollowService) Handle(data string, database *mongo.Database, client *nex.Client) (string, error) {
+	var req SetlistFollowRequest
+	err := marshaler.UnmarshalRequest(data, &req)
+	if err != nil {
+		return "", err
+	}
+
+	validPIDres, _ := utils.GetClientStoreSingleton().IsValidPID(client.Address().String(), uint32(req.PID))
+
+	if !validPIDres {
+		log.Println("Client is attempting to unfollow a setlist without a valid server-assigned PID, rejecting call")
+		return "", nil
+	}
+
+	// unfollowing a setlist that isn't followed is harmless, so only errors are reported back
+	if _, err := db.UnfollowSetlist(context.TODO(), database, req.ListGUID, req.PID); err != nil {
+		log.Printf("Error unfollowing setlist %s: %v", req.ListGUID, err)
+		return marshaler.MarshalResponse(service.Path(), []SetlistFollowResponse{{0x16}})
+	}
+
+	return marshaler.MarshalResponse(service.Path(), []SetlistFollowResponse{{0}})
+}
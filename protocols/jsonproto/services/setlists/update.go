@@ -7,7 +7,6 @@ import (
 	"rb3server/models"
 	"rb3server/protocols/jsonproto/marshaler"
 	"rb3server/utils"
-	"strings"
 	"time"
 
 	"github.com/ihatecompvir/nex-go"
@@ -56,33 +55,25 @@ func (service SetlistUpdateService) Handle(data string, database *mongo.Database
 		return "", nil
 	}
 
-	// Do a profanity check before updating the setlist
-	config, err := db.GetCachedConfig(context.TODO())
+	// Run the setlist name and description through the profanity filter, anything it masks is stored masked
+	name, err := db.FilterText(context.TODO(), req.Name)
 	if err != nil {
-		log.Printf("Could not get config: %v\n", err)
+		log.Printf("Could not get profanity filter: %v\n", err)
 		return marshaler.MarshalResponse(service.Path(), []SetlistUpdateResponse{{0}})
 	}
-
-	// Check if the setlist name or description contain anything in the profanity list
-	for _, profanity := range config.ProfanityList {
-		if profanity != "" && req.Name != "" && len(req.Name) >= len(profanity) {
-			lowerName := strings.ToLower(req.Name)
-			lowerProfanity := strings.ToLower(profanity)
-
-			if lowerName == lowerProfanity || strings.Contains(lowerName, lowerProfanity) {
-				return marshaler.MarshalResponse(service.Path(), []SetlistUpdateResponse{{0xF}})
-			}
-		}
-
-		if profanity != "" && req.Description != "" && len(req.Description) >= len(profanity) {
-			lowerDesc := strings.ToLower(req.Description)
-			lowerProfanity := strings.ToLower(profanity)
-
-			if lowerDesc == lowerProfanity || strings.Contains(lowerDesc, lowerProfanity) {
-				return marshaler.MarshalResponse(service.Path(), []SetlistUpdateResponse{{0x10}})
-			}
-		}
+	if name.Rejected() {
+		return marshaler.MarshalResponse(service.Path(), []SetlistUpdateResponse{{0xF}})
 	}
+	description, err := db.FilterText(context.TODO(), req.Description)
+	if err != nil {
+		log.Printf("Could not get profanity filter: %v\n", err)
+		return marshaler.MarshalResponse(service.Path(), []SetlistUpdateResponse{{0}})
+	}
+	if description.Rejected() {
+		return marshaler.MarshalResponse(service.Path(), []SetlistUpdateResponse{{0x10}})
+	}
+	req.Name = name.Masked
+	req.Description = description.Masked
 
 	users := database.Collection("users")
 	var user models.User
@@ -135,6 +126,11 @@ func (service SetlistUpdateService) Handle(data string, database *mongo.Database
 	setlist.Desc = req.Description
 	setlist.Title = req.Name
 	setlist.Type = 0
+
+	// setlist curators create Harmonix Recommends setlists that everyone sees
+	if db.HasPrivilege(req.PID, db.PrivilegeCreateHarmonixSetlists) {
+		setlist.Type = 2
+	}
 	setlist.Owner = user.Username
 	setlist.OwnerGUID = user.GUID
 	setlist.PID = req.PID
@@ -144,7 +140,8 @@ func (service SetlistUpdateService) Handle(data string, database *mongo.Database
 		setlist.Created = time.Now().Unix()
 	}
 
-	setlist.SongNames = make([]string, len(req.SongIDs))
+	// the game never sends song names, so fill them in from the song catalog
+	setlist.SongNames = db.GetSongNamesInOrder(context.TODO(), database, req.SongIDs)
 
 	filter := bson.M{"guid": req.ListGUID}
 	update := bson.M{
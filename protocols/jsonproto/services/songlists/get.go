@@ -11,6 +11,7 @@ import (
 	"github.com/ihatecompvir/nex-go"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	db "rb3server/database"
 )
@@ -78,7 +79,10 @@ func (service GetSonglistsService) Handle(data string, database *mongo.Database,
 
 	setlistCollection := database.Collection("setlists")
 
-	setlistCursor, err := setlistCollection.Find(context.TODO(), bson.D{{"shared", "t"}})
+	// curated setlists are shown in the order admins put them in
+	findOptions := options.Find().SetSort(bson.D{{"sort_order", 1}, {"setlist_id", 1}})
+
+	setlistCursor, err := setlistCollection.Find(context.TODO(), bson.D{{"shared", "t"}}, findOptions)
 
 	if err != nil {
 		log.Printf("Error getting songlists: %s", err)
@@ -87,6 +91,7 @@ func (service GetSonglistsService) Handle(data string, database *mongo.Database,
 	defer setlistCursor.Close(context.TODO())
 
 	jsonStrings := []string{}
+	syncedSetlistIDs := []int{}
 
 	for setlistCursor.Next(context.TODO()) {
 		var setlistToCopy models.Setlist
@@ -97,8 +102,13 @@ func (service GetSonglistsService) Handle(data string, database *mongo.Database,
 		if setlistToCopy.Type == 1 || setlistToCopy.Type == 2 || setlistToCopy.Type == 0 {
 
 			// always show "Harmonix Recommends" aka server-provided setlists which are intended to be global for all players
-			if setlistToCopy.Type != 2 {
-				// make sure we only get setlists created by our friends
+			if setlistToCopy.Type == 2 {
+				// unless they are scheduled for another week, retired, or meant for another platform or region
+				if !db.IsCuratedSetlistVisible(setlistToCopy, client.Platform(), req.Region, time.Now()) {
+					continue
+				}
+			} else if !isFollowedBy(setlistToCopy, req.PID000) {
+				// make sure we only get setlists created by our friends, or ones we follow
 				isFriendCreated, err := db.IsPIDAFriendOfPID(req.PID000, setlistToCopy.PID)
 
 				if err != nil {
@@ -110,6 +120,11 @@ func (service GetSonglistsService) Handle(data string, database *mongo.Database,
 				}
 			}
 
+			// anything synced to someone other than its owner counts towards how popular it is
+			if setlistToCopy.PID != req.PID000 {
+				syncedSetlistIDs = append(syncedSetlistIDs, setlistToCopy.SetlistID)
+			}
+
 			var setlist GetSonglistResponse
 			setlist.ArtURL = setlistToCopy.ArtURL
 			setlist.Desc = setlistToCopy.Desc
@@ -131,6 +146,11 @@ func (service GetSonglistsService) Handle(data string, database *mongo.Database,
 		// battle setlist
 		if setlistToCopy.Type == 1000 || setlistToCopy.Type == 1001 || setlistToCopy.Type == 1002 {
 
+			// scheduled battles stay hidden until they start, and moderated battles stay hidden for good
+			if !db.IsBattleStarted(setlistToCopy) || setlistToCopy.Hidden {
+				continue
+			}
+
 			// always show server-provided battles
 			if setlistToCopy.Type != 1002 {
 				// make sure we only get battles created by our friends
@@ -181,6 +201,21 @@ func (service GetSonglistsService) Handle(data string, database *mongo.Database,
 		}
 	}
 
+	if err := db.RecordSetlistSyncs(context.TODO(), database, req.PID000, syncedSetlistIDs); err != nil {
+		log.Printf("Could not record setlist syncs: %v", err)
+	}
+
 	resString, _ := marshaler.CombineJSONMethods(jsonStrings)
 	return resString, nil
 }
+
+// whether a player follows a setlist
+func isFollowedBy(setlist models.Setlist, pid int) bool {
+	for _, follower := range setlist.Followers {
+		if follower == pid {
+			return true
+		}
+	}
+
+	return false
+}
@@ -17,14 +17,6 @@ import (
 )
 
 var (
-	globalRankCache  map[int]int
-	globalRankMu     sync.RWMutex
-	globalRankExpiry time.Time
-
-	roleRankCache  map[int]map[int]int // roleID -> (PID -> rank)
-	roleRankMu     sync.RWMutex
-	roleRankExpiry map[int]time.Time
-
 	battleCountCache  int64
 	battleCountMu     sync.RWMutex
 	battleCountExpiry time.Time
@@ -32,111 +24,6 @@ var (
 	tickerCacheTTL = 5 * time.Minute
 )
 
-func getCachedGlobalRank(ctx context.Context, scoresCollection *mongo.Collection, pid int) (int, error) {
-	globalRankMu.RLock()
-	if globalRankCache != nil && time.Now().Before(globalRankExpiry) {
-		rank, ok := globalRankCache[pid]
-		total := len(globalRankCache)
-		globalRankMu.RUnlock()
-		if ok {
-			return rank, nil
-		}
-		return total + 1, nil
-	}
-	globalRankMu.RUnlock()
-
-	pipeline := mongo.Pipeline{
-		{{"$group", bson.D{{"_id", "$pid"}, {"totalScore", bson.D{{"$sum", "$score"}}}}}},
-		{{"$sort", bson.D{{"totalScore", -1}}}},
-	}
-
-	cursor, err := scoresCollection.Aggregate(ctx, pipeline)
-	if err != nil {
-		return 0, err
-	}
-	defer cursor.Close(ctx)
-
-	var results []struct {
-		ID         int `bson:"_id"`
-		TotalScore int `bson:"totalScore"`
-	}
-	if err := cursor.All(ctx, &results); err != nil {
-		return 0, err
-	}
-
-	newCache := make(map[int]int, len(results))
-	for i, result := range results {
-		newCache[result.ID] = i + 1
-	}
-
-	globalRankMu.Lock()
-	globalRankCache = newCache
-	globalRankExpiry = time.Now().Add(tickerCacheTTL)
-	globalRankMu.Unlock()
-
-	if rank, ok := newCache[pid]; ok {
-		return rank, nil
-	}
-	return len(newCache) + 1, nil
-}
-
-func getCachedRoleRank(ctx context.Context, scoresCollection *mongo.Collection, roleID int, pid int) (int, error) {
-	roleRankMu.RLock()
-	if roleRankCache != nil {
-		if expiry, ok := roleRankExpiry[roleID]; ok && time.Now().Before(expiry) {
-			if ranks, ok := roleRankCache[roleID]; ok {
-				rank, found := ranks[pid]
-				total := len(ranks)
-				roleRankMu.RUnlock()
-				if found {
-					return rank, nil
-				}
-				return total + 1, nil
-			}
-		}
-	}
-	roleRankMu.RUnlock()
-
-	rolePipeline := mongo.Pipeline{
-		{{"$match", bson.D{{"role_id", roleID}}}},
-		{{"$group", bson.D{{"_id", "$pid"}, {"totalScore", bson.D{{"$sum", "$score"}}}}}},
-		{{"$sort", bson.D{{"totalScore", -1}}}},
-	}
-
-	cursor, err := scoresCollection.Aggregate(ctx, rolePipeline)
-	if err != nil {
-		return 0, err
-	}
-	defer cursor.Close(ctx)
-
-	var results []struct {
-		ID         int `bson:"_id"`
-		TotalScore int `bson:"totalScore"`
-	}
-	if err := cursor.All(ctx, &results); err != nil {
-		return 0, err
-	}
-
-	newRanks := make(map[int]int, len(results))
-	for i, result := range results {
-		newRanks[result.ID] = i + 1
-	}
-
-	roleRankMu.Lock()
-	if roleRankCache == nil {
-		roleRankCache = make(map[int]map[int]int)
-		roleRankExpiry = make(map[int]time.Time)
-	}
-	roleRankCache[roleID] = newRanks
-	roleRankExpiry[roleID] = time.Now().Add(tickerCacheTTL)
-	roleRankMu.Unlock()
-
-	if rank, ok := newRanks[pid]; ok {
-		return rank, nil
-	}
-	return len(newRanks) + 1, nil
-}
-
 func getCachedBattleCount(ctx context.Context, setlistsCollection *mongo.Collection) (int64, error) {
 	battleCountMu.RLock()
 	if time.Now().Before(battleCountExpiry) {
@@ -215,14 +102,12 @@ func (service TickerInfoService) Handle(data string, database *mongo.Database, c
 		return "", err
 	}
 
-	scoresCollection := database.Collection("scores")
-
-	totalScoreRank, err := getCachedGlobalRank(ctx, scoresCollection, req.PID)
+	totalScoreRank, err := db.GetGlobalRank(ctx, database, req.PID)
 	if err != nil {
 		return "", err
 	}
 
-	roleRank, err := getCachedRoleRank(ctx, scoresCollection, req.RoleID, req.PID)
+	roleRank, err := db.GetRoleRank(ctx, database, req.RoleID, req.PID)
 	if err != nil {
 		return "", err
 	}
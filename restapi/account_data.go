@@ -0,0 +1,135 @@
+package restapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"rb3server/binarydata"
+	database "rb3server/database"
+)
+
+type WebAccountRequest struct {
+	WebIdentity string `json:"web_identity"`
+}
+
+// erases an account and responds with what was erased
+func eraseAccount(w http.ResponseWriter, r *http.Request, pid int) {
+	erased, err := database.EraseAccount(r.Context(), database.GocentralDatabase, binarydata.DefaultStore(), pid)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: could not erase account with PID %d: %v", pid, err)
+		sendError(w, http.StatusInternalServerError, "Failed to erase account")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"pid":     pid,
+		"erased":  erased,
+	})
+}
+
+// responds with everything stored about an account as a JSON file to download
+func exportAccount(w http.ResponseWriter, r *http.Request, pid int) {
+	export, err := database.ExportAccountData(r.Context(), database.GocentralDatabase, binarydata.DefaultStore(), pid)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: could not export account with PID %d: %v", pid, err)
+		sendError(w, http.StatusInternalServerError, "Failed to export account")
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"account-%d.json\"", pid))
+	sendJSON(w, http.StatusOK, export)
+}
+
+// checks that the account in the URL is linked to the web account asking about it
+func getLinkedPID(w http.ResponseWriter, r *http.Request, webIdentity string) (int, bool) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "pid"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid PID")
+		return 0, false
+	}
+
+	linked, err := database.IsWebLinkedTo(r.Context(), database.GocentralDatabase, pid, webIdentity)
+	if err != nil {
+		log.Printf("ERROR: could not check whether PID %d is linked to %s: %v", pid, webIdentity, err)
+		sendError(w, http.StatusInternalServerError, "Failed to check linked account")
+		return 0, false
+	}
+	if !linked {
+		sendError(w, http.StatusNotFound, "Account is not linked to this web account")
+		return 0, false
+	}
+
+	return pid, true
+}
+
+// Deletes a player's account along with their scores, bands, characters, setlists, accomplishments and art.
+// Battles they created are handed over to the server and their placements in battle results are anonymized.
+// Requires a valid admin API token in the Authorization header.
+func EraseAccountHandler(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "pid"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid PID")
+		return
+	}
+
+	eraseAccount(w, r, pid)
+}
+
+// Returns everything stored about a player as a JSON file.
+// Requires a valid admin API token in the Authorization header.
+func ExportAccountHandler(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "pid"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid PID")
+		return
+	}
+
+	exportAccount(w, r, pid)
+}
+
+// Lets a player signed in to the website delete one of the game accounts linked to them, the same way EraseAccountHandler does.
+// Requires a valid web API token in the Authorization header.
+func WebEraseAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var req WebAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	pid, ok := getLinkedPID(w, r, req.WebIdentity)
+	if !ok {
+		return
+	}
+
+	log.Printf("Web account %s asked for PID %d to be erased", req.WebIdentity, pid)
+
+	eraseAccount(w, r, pid)
+}
+
+// Lets a player signed in to the website download everything stored about one of the game accounts linked to them.
+// Requires a valid web API token in the Authorization header.
+func WebExportAccountHandler(w http.ResponseWriter, r *http.Request) {
+	webIdentity := r.URL.Query().Get("web_identity")
+
+	pid, ok := getLinkedPID(w, r, webIdentity)
+	if !ok {
+		return
+	}
+
+	exportAccount(w, r, pid)
+}
@@ -0,0 +1,62 @@
+package restapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	database "rb3server/database"
+)
+
+type MergeAccountsRequest struct {
+	FromUsername string `json:"from_username"` // the account that goes away
+	ToUsername   string `json:"to_username"`   // the account that is kept
+
+	// only reports what the merge would do unless this is set
+	Confirm bool `json:"confirm"`
+}
+
+// Merges one player's account into another, for players who moved platforms or renamed. Without confirm it only
+// reports what would be moved, so the report can be checked first. The old username keeps logging in to the kept account.
+// Requires a valid admin API token in the Authorization header.
+func MergeAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	var req MergeAccountsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.FromUsername == "" || req.ToUsername == "" {
+		sendError(w, http.StatusBadRequest, "from_username and to_username are required")
+		return
+	}
+
+	fromPID := database.GetPIDForUsername(req.FromUsername)
+	toPID := database.GetPIDForUsername(req.ToUsername)
+	if fromPID == 0 || toPID == 0 {
+		sendError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	report, err := database.MergeAccounts(r.Context(), database.GocentralDatabase, fromPID, toPID, !req.Confirm)
+	if err == database.ErrMergeIntoSelf {
+		sendError(w, http.StatusBadRequest, "Cannot merge an account into itself")
+		return
+	}
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: could not merge PID %d into PID %d: %v", fromPID, toPID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to merge accounts")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"report":  report,
+	})
+}
@@ -0,0 +1,114 @@
+package restapi
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	database "rb3server/database"
+	"rb3server/models"
+)
+
+// Lists the final standings of battles that have closed, newest first. Battles hidden by an admin are left out.
+// ?global=1 only returns Harmonix battles.
+func BattleResultsHandler(w http.ResponseWriter, r *http.Request) {
+	filter := bson.M{"hidden": bson.M{"$ne": true}}
+	if r.URL.Query().Get("global") == "1" {
+		filter["type"] = 1002
+	}
+
+	page, pageSize, ok := getPagination(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	resultsCollection := database.GocentralDatabase.Collection("battle_results")
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "ended_at", Value: -1}, {Key: "battle_id", Value: -1}}).
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+
+	cursor, err := resultsCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		log.Printf("ERROR: could not query battle results: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to query battle results")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	results := []models.BattleResult{}
+	if err := cursor.All(ctx, &results); err != nil {
+		log.Printf("ERROR: could not decode battle results: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to read battle results")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]models.BattleResult{"results": results})
+}
+
+// Returns the final standings of a single closed battle.
+func BattleResultHandler(w http.ResponseWriter, r *http.Request) {
+	battleID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid battle ID")
+		return
+	}
+
+	result, err := database.GetBattleResult(r.Context(), database.GocentralDatabase, battleID)
+	if err != nil {
+		log.Printf("ERROR: could not get battle result %d: %v", battleID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query battle result")
+		return
+	}
+	if result == nil || result.Hidden {
+		sendError(w, http.StatusNotFound, "Battle result not found")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+// Lists every closed battle a player took part in along with where they placed, newest first. Battles hidden by an admin are left out.
+func PlayerBattleHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "pid"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid PID")
+		return
+	}
+
+	page, pageSize, ok := getPagination(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	historyCollection := database.GocentralDatabase.Collection("battle_history")
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "ended_at", Value: -1}, {Key: "battle_id", Value: -1}}).
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+
+	cursor, err := historyCollection.Find(ctx, bson.M{"pid": pid, "hidden": bson.M{"$ne": true}}, findOptions)
+	if err != nil {
+		log.Printf("ERROR: could not query battle history for PID %d: %v", pid, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query battle history")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	history := []models.BattleHistoryEntry{}
+	if err := cursor.All(ctx, &history); err != nil {
+		log.Printf("ERROR: could not decode battle history: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to read battle history")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]models.BattleHistoryEntry{"battles": history})
+}
@@ -0,0 +1,256 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	database "rb3server/database"
+	"rb3server/models"
+)
+
+type QueueBattleSongsRequest struct {
+	SongIDs []int `json:"song_ids"`
+}
+
+// checks a battle template sent by an admin makes sense before it gets saved
+func validateBattleTemplate(template models.BattleTemplate) error {
+	if template.Title == "" {
+		return errors.New("title is required")
+	}
+
+	recurrence := template.Recurrence
+	if recurrence.Frequency != "daily" && recurrence.Frequency != "weekly" {
+		return errors.New("recurrence frequency must be daily or weekly")
+	}
+	if recurrence.Frequency == "weekly" && (recurrence.Weekday < 0 || recurrence.Weekday > 6) {
+		return errors.New("recurrence weekday must be between 0 (Sunday) and 6 (Saturday)")
+	}
+	if recurrence.Hour < 0 || recurrence.Hour > 23 || recurrence.Minute < 0 || recurrence.Minute > 59 {
+		return errors.New("recurrence hour or minute is out of range")
+	}
+	if recurrence.DurationHours <= 0 {
+		return errors.New("recurrence duration_hours must be greater than 0")
+	}
+	if recurrence.CreateAheadHours < 0 {
+		return errors.New("recurrence create_ahead_hours cannot be negative")
+	}
+
+	pool := template.SongPool
+	switch pool.Strategy {
+	case "fixed":
+		if len(pool.SongIDs) == 0 {
+			return errors.New("a fixed song pool needs at least one song_id")
+		}
+	case "random":
+		if pool.Count <= 0 {
+			return errors.New("a random song pool needs a count greater than 0")
+		}
+		if pool.MinTier > 0 && pool.MaxTier > 0 && pool.MinTier > pool.MaxTier {
+			return errors.New("min_tier cannot be greater than max_tier")
+		}
+	case "queue":
+	default:
+		return errors.New("song pool strategy must be fixed, random or queue")
+	}
+
+	return nil
+}
+
+// Lists every battle template.
+// Requires a valid admin API token in the Authorization header.
+func BattleTemplateListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	templatesCollection := database.GocentralDatabase.Collection("battle_templates")
+
+	cursor, err := templatesCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "template_id", Value: 1}}))
+	if err != nil {
+		log.Printf("ERROR: could not query battle templates: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to query battle templates")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	templates := []models.BattleTemplate{}
+	if err := cursor.All(ctx, &templates); err != nil {
+		log.Printf("ERROR: could not decode battle templates: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to read battle templates")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]models.BattleTemplate{"templates": templates})
+}
+
+// Creates a battle template that housekeeping uses to open a new Harmonix battle on a schedule.
+// Requires a valid admin API token in the Authorization header.
+func CreateBattleTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var template models.BattleTemplate
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := validateBattleTemplate(template); err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+
+	templateID, err := database.GetNextBattleTemplateID(ctx)
+	if err != nil {
+		log.Printf("ERROR: Could not get next battle template ID: %v", err)
+		sendError(w, http.StatusInternalServerError, "Could not generate battle template ID")
+		return
+	}
+
+	template.TemplateID = templateID
+	template.CreatedAt = time.Now().Unix()
+	template.LastStartAt = 0
+	template.LastBattleID = 0
+
+	if _, err := database.GocentralDatabase.Collection("battle_templates").InsertOne(ctx, template); err != nil {
+		log.Printf("Could not insert new battle template: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to create battle template")
+		return
+	}
+
+	nextStartAt := database.NextBattleOccurrence(template.Recurrence, time.Unix(template.CreatedAt, 0))
+
+	log.Printf("Created battle template #%d titled '%s'", templateID, template.Title)
+	sendJSON(w, http.StatusCreated, map[string]interface{}{
+		"success":       true,
+		"template_id":   templateID,
+		"next_start_at": nextStartAt.Unix(),
+	})
+}
+
+// Replaces the settings of a battle template. Battles it already created are left alone.
+// Requires a valid admin API token in the Authorization header.
+func UpdateBattleTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	templateID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid template ID")
+		return
+	}
+
+	var template models.BattleTemplate
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := validateBattleTemplate(template); err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := database.GocentralDatabase.Collection("battle_templates").UpdateOne(r.Context(),
+		bson.M{"template_id": templateID},
+		bson.M{"$set": bson.M{
+			"title":       template.Title,
+			"description": template.Description,
+			"instrument":  template.Instrument,
+			"flags":       template.Flags,
+			"enabled":     template.Enabled,
+			"recurrence":  template.Recurrence,
+			"song_pool":   template.SongPool,
+		}},
+	)
+	if err != nil {
+		log.Printf("ERROR: could not update battle template %d: %v", templateID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to update battle template")
+		return
+	}
+	if res.MatchedCount == 0 {
+		sendError(w, http.StatusNotFound, "Battle template not found")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"template_id": templateID,
+	})
+}
+
+// Deletes a battle template. Battles it already created are left alone.
+// Requires a valid admin API token in the Authorization header.
+func DeleteBattleTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	templateID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid template ID")
+		return
+	}
+
+	res, err := database.GocentralDatabase.Collection("battle_templates").DeleteOne(r.Context(), bson.M{"template_id": templateID})
+	if err != nil {
+		log.Printf("ERROR: could not delete battle template %d: %v", templateID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to delete battle template")
+		return
+	}
+	if res.DeletedCount == 0 {
+		sendError(w, http.StatusNotFound, "Battle template not found")
+		return
+	}
+
+	log.Printf("Deleted battle template #%d", templateID)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"template_id": templateID,
+	})
+}
+
+// Adds a song list to the end of a battle template's song queue, used by templates with the queue song pool strategy.
+// Requires a valid admin API token in the Authorization header.
+func QueueBattleSongsHandler(w http.ResponseWriter, r *http.Request) {
+	templateID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid template ID")
+		return
+	}
+
+	var req QueueBattleSongsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if len(req.SongIDs) == 0 {
+		sendError(w, http.StatusBadRequest, "At least one song_id is required")
+		return
+	}
+
+	ctx := r.Context()
+	templatesCollection := database.GocentralDatabase.Collection("battle_templates")
+
+	res, err := templatesCollection.UpdateOne(ctx, bson.M{"template_id": templateID}, bson.M{"$push": bson.M{"song_pool.queue": req.SongIDs}})
+	if err != nil {
+		log.Printf("ERROR: could not queue songs for battle template %d: %v", templateID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to queue songs")
+		return
+	}
+	if res.MatchedCount == 0 {
+		sendError(w, http.StatusNotFound, "Battle template not found")
+		return
+	}
+
+	template, err := database.GetBattleTemplate(ctx, database.GocentralDatabase, templateID)
+	if err != nil || template == nil {
+		log.Printf("ERROR: could not get battle template %d after queueing songs: %v", templateID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to read battle template")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"template_id":  templateID,
+		"queue_length": len(template.SongPool.Queue),
+	})
+}
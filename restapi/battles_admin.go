@@ -0,0 +1,538 @@
+package restapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	database "rb3server/database"
+	"rb3server/models"
+)
+
+// a battle as admins see it, including player-created and hidden battles
+type AdminBattleInfo struct {
+	BattleID     int      `json:"battle_id"`
+	Type         int      `json:"type"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	Owner        string   `json:"owner"`
+	PID          int      `json:"pid"`
+	StartsAt     int64    `json:"starts_at"`
+	ExpiresAt    int64    `json:"expires_at"`
+	Instrument   int      `json:"instrument"`
+	Flags        int      `json:"flags"`
+	SongIDs      []int    `json:"song_ids"`
+	SongNames    []string `json:"song_names"`
+	Hidden       bool     `json:"hidden"`
+	HiddenReason string   `json:"hidden_reason,omitempty"`
+	TemplateID   int      `json:"template_id,omitempty"`
+}
+
+// every field is optional, only the ones that are sent get changed
+type UpdateBattleRequest struct {
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	Instrument  *int       `json:"instrument"`
+	Flags       *int       `json:"flags"`
+	SongIDs     []int      `json:"song_ids"`
+}
+
+type TransferBattleRequest struct {
+	Username string `json:"username"`
+}
+
+type HideBattleRequest struct {
+	Reason string `json:"reason"`
+}
+
+type PlayerBattleCount struct {
+	PID         int    `json:"pid"`
+	Username    string `json:"username"`
+	BattleCount int    `json:"battle_count"`
+	BattleLimit int    `json:"battle_limit"`
+	HasOverride bool   `json:"has_override"`
+}
+
+type SetPlayerBattleLimitRequest struct {
+	Username    string `json:"username"`
+	BattleLimit *int   `json:"battle_limit"` // null removes the override
+}
+
+func newAdminBattleInfo(battle models.Setlist) AdminBattleInfo {
+	_, expiresAt := database.GetSetlistBattleExpiryInfo(battle)
+
+	return AdminBattleInfo{
+		BattleID:     battle.SetlistID,
+		Type:         battle.Type,
+		Title:        battle.Title,
+		Description:  battle.Desc,
+		Owner:        battle.Owner,
+		PID:          battle.PID,
+		StartsAt:     battle.Created,
+		ExpiresAt:    expiresAt.Unix(),
+		Instrument:   battle.Instrument,
+		Flags:        battle.Flags,
+		SongIDs:      battle.SongIDs,
+		SongNames:    battle.SongNames,
+		Hidden:       battle.Hidden,
+		HiddenReason: battle.HiddenReason,
+		TemplateID:   battle.TemplateID,
+	}
+}
+
+// gets the battle named by the {id} URL parameter, sends an error and returns false if it can't
+func getBattleFromURL(w http.ResponseWriter, r *http.Request) (*models.Setlist, bool) {
+	battleID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid battle ID")
+		return nil, false
+	}
+
+	battle, err := database.GetBattle(r.Context(), database.GocentralDatabase, battleID)
+	if err != nil {
+		log.Printf("ERROR: could not get battle %d: %v", battleID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query battle")
+		return nil, false
+	}
+	if battle == nil {
+		sendError(w, http.StatusNotFound, "Battle not found")
+		return nil, false
+	}
+
+	return battle, true
+}
+
+// Lists every battle, including player-created and hidden ones, newest first.
+// Optional filters: ?type=1000, ?username=foo for battles created by a player, ?hidden=1 or ?hidden=0
+// Requires a valid admin API token in the Authorization header.
+func AdminBattleListHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := bson.M{"type": bson.M{"$in": []int{1000, 1001, 1002}}}
+
+	if typeStr := query.Get("type"); typeStr != "" {
+		battleType, err := strconv.Atoi(typeStr)
+		if err != nil || battleType < 1000 || battleType > 1002 {
+			sendError(w, http.StatusBadRequest, "Invalid type")
+			return
+		}
+		filter["type"] = battleType
+	}
+
+	if username := query.Get("username"); username != "" {
+		pid := database.GetPIDForUsername(username)
+		if pid == 0 {
+			sendError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		filter["pid"] = pid
+	}
+
+	switch query.Get("hidden") {
+	case "":
+	case "1":
+		filter["hidden"] = true
+	case "0":
+		filter["hidden"] = bson.M{"$ne": true}
+	default:
+		sendError(w, http.StatusBadRequest, "Invalid hidden")
+		return
+	}
+
+	page, pageSize, ok := getPagination(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	setlistsCollection := database.GocentralDatabase.Collection("setlists")
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "setlist_id", Value: -1}}).
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+
+	cursor, err := setlistsCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		log.Printf("ERROR: could not query battles: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to query battles")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var setlists []models.Setlist
+	if err := cursor.All(ctx, &setlists); err != nil {
+		log.Printf("ERROR: could not decode battles: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to read battles")
+		return
+	}
+
+	battles := []AdminBattleInfo{}
+	for _, setlist := range setlists {
+		battles = append(battles, newAdminBattleInfo(setlist))
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]AdminBattleInfo{"battles": battles})
+}
+
+// Changes the title, description, expiry, instrument, flags or song list of a battle.
+// Requires a valid admin API token in the Authorization header.
+func UpdateBattleHandler(w http.ResponseWriter, r *http.Request) {
+	battle, ok := getBattleFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateBattleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	ctx := r.Context()
+	set := bson.M{}
+
+	if req.Title != nil {
+		if *req.Title == "" {
+			sendError(w, http.StatusBadRequest, "title cannot be empty")
+			return
+		}
+		set["title"] = *req.Title
+		battle.Title = *req.Title
+	}
+
+	if req.Description != nil {
+		set["desc"] = *req.Description
+		battle.Desc = *req.Description
+	}
+
+	if req.ExpiresAt != nil {
+		// the expiry is stored relative to the start of the battle
+		durationSeconds := req.ExpiresAt.Unix() - battle.Created
+		if durationSeconds <= 0 {
+			sendError(w, http.StatusBadRequest, "expires_at must be after the battle starts")
+			return
+		}
+		set["time_end_val"] = int(durationSeconds)
+		set["time_end_units"] = "seconds"
+		battle.TimeEndVal = int(durationSeconds)
+		battle.TimeEndUnits = "seconds"
+	}
+
+	if req.Instrument != nil {
+		set["instrument"] = *req.Instrument
+		battle.Instrument = *req.Instrument
+	}
+
+	if req.Flags != nil {
+		set["flags"] = *req.Flags
+		battle.Flags = *req.Flags
+	}
+
+	if req.SongIDs != nil {
+		if len(req.SongIDs) == 0 {
+			sendError(w, http.StatusBadRequest, "A battle needs at least one song_id")
+			return
+		}
+		battle.SongIDs = req.SongIDs
+		battle.SongNames = database.GetSongNamesInOrder(ctx, database.GocentralDatabase, req.SongIDs)
+		set["s_ids"] = battle.SongIDs
+		set["s_names"] = battle.SongNames
+	}
+
+	if len(set) == 0 {
+		sendError(w, http.StatusBadRequest, "Nothing to update")
+		return
+	}
+
+	_, err := database.GocentralDatabase.Collection("setlists").UpdateOne(ctx, bson.M{"setlist_id": battle.SetlistID}, bson.M{"$set": set})
+	if err != nil {
+		log.Printf("ERROR: could not update battle %d: %v", battle.SetlistID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to update battle")
+		return
+	}
+
+	log.Printf("Updated battle #%d", battle.SetlistID)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"battle":  newAdminBattleInfo(*battle),
+	})
+}
+
+// Gives a battle to another player.
+// Requires a valid admin API token in the Authorization header.
+func TransferBattleHandler(w http.ResponseWriter, r *http.Request) {
+	battle, ok := getBattleFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	var req TransferBattleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Username == "" {
+		sendError(w, http.StatusBadRequest, "Username is required")
+		return
+	}
+
+	ctx := r.Context()
+
+	pid := database.GetPIDForUsername(req.Username)
+	if pid == 0 {
+		sendError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	var user models.User
+	if err := database.GocentralDatabase.Collection("users").FindOne(ctx, bson.M{"pid": pid}).Decode(&user); err != nil {
+		log.Printf("ERROR: could not get user %d for battle transfer: %v", pid, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query user")
+		return
+	}
+
+	_, err := database.GocentralDatabase.Collection("setlists").UpdateOne(ctx,
+		bson.M{"setlist_id": battle.SetlistID},
+		bson.M{"$set": bson.M{"pid": pid, "owner": user.Username, "owner_guid": user.GUID}},
+	)
+	if err != nil {
+		log.Printf("ERROR: could not transfer battle %d: %v", battle.SetlistID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to transfer battle")
+		return
+	}
+
+	log.Printf("Transferred battle #%d from PID %d to %s (PID %d)", battle.SetlistID, battle.PID, user.Username, pid)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"battle_id": battle.SetlistID,
+		"pid":       pid,
+	})
+}
+
+// Hides a battle from players, e.g. for a profane title, without deleting it or its scores.
+// Requires a valid admin API token in the Authorization header.
+func HideBattleHandler(w http.ResponseWriter, r *http.Request) {
+	battle, ok := getBattleFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	var req HideBattleRequest
+	// the reason is optional so an empty body is fine
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && r.ContentLength > 0 {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	_, err := database.GocentralDatabase.Collection("setlists").UpdateOne(r.Context(),
+		bson.M{"setlist_id": battle.SetlistID},
+		bson.M{"$set": bson.M{"hidden": true, "hidden_reason": req.Reason}},
+	)
+	if err == nil {
+		// a battle that has closed is also in the archived results
+		err = database.SetBattleResultHidden(r.Context(), database.GocentralDatabase, battle.SetlistID, true)
+	}
+	if err != nil {
+		log.Printf("ERROR: could not hide battle %d: %v", battle.SetlistID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to hide battle")
+		return
+	}
+
+	log.Printf("Hid battle #%d: %s", battle.SetlistID, req.Reason)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"battle_id": battle.SetlistID,
+	})
+}
+
+// Makes a hidden battle visible to players again.
+// Requires a valid admin API token in the Authorization header.
+func UnhideBattleHandler(w http.ResponseWriter, r *http.Request) {
+	battle, ok := getBattleFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := database.GocentralDatabase.Collection("setlists").UpdateOne(r.Context(),
+		bson.M{"setlist_id": battle.SetlistID},
+		bson.M{"$unset": bson.M{"hidden": "", "hidden_reason": ""}},
+	)
+	if err == nil {
+		err = database.SetBattleResultHidden(r.Context(), database.GocentralDatabase, battle.SetlistID, false)
+	}
+	if err != nil {
+		log.Printf("ERROR: could not unhide battle %d: %v", battle.SetlistID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to unhide battle")
+		return
+	}
+
+	log.Printf("Unhid battle #%d", battle.SetlistID)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"battle_id": battle.SetlistID,
+	})
+}
+
+// Clears the leaderboard of a single battle by deleting all of its scores. The battle itself is kept.
+// Requires a valid admin API token in the Authorization header.
+func ResetBattleLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	battle, ok := getBattleFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := database.GocentralDatabase.Collection("scores").DeleteMany(r.Context(), bson.M{"battle_id": battle.SetlistID})
+	if err != nil {
+		log.Printf("ERROR: could not reset leaderboard of battle %d: %v", battle.SetlistID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to reset battle leaderboard")
+		return
+	}
+
+	log.Printf("Reset leaderboard of battle #%d (scores deleted: %d)", battle.SetlistID, res.DeletedCount)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":        true,
+		"battle_id":      battle.SetlistID,
+		"scores_deleted": res.DeletedCount,
+	})
+}
+
+// Lists how many battles each player has created against the battle limit that applies to them, most battles first.
+// Requires a valid admin API token in the Authorization header.
+func PlayerBattleCountsHandler(w http.ResponseWriter, r *http.Request) {
+	page, pageSize, ok := getPagination(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	config, err := database.GetCachedConfig(ctx)
+	if err != nil {
+		log.Printf("ERROR: could not get config for battle counts: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to get config")
+		return
+	}
+
+	// Harmonix battles have no owning player
+	cursor, err := database.GocentralDatabase.Collection("setlists").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"type": bson.M{"$in": []int{1000, 1001, 1002}}, "pid": bson.M{"$ne": 0}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$pid", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$skip", Value: (page - 1) * pageSize}},
+		{{Key: "$limit", Value: pageSize}},
+	})
+	if err != nil {
+		log.Printf("ERROR: could not count battles per player: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to count battles")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var counts []struct {
+		PID   int `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		log.Printf("ERROR: could not decode battle counts: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to read battle counts")
+		return
+	}
+
+	pids := make([]int, 0, len(counts))
+	for _, count := range counts {
+		pids = append(pids, count.PID)
+	}
+
+	usersCursor, err := database.GocentralDatabase.Collection("users").Find(ctx, bson.M{"pid": bson.M{"$in": pids}})
+	if err != nil {
+		log.Printf("ERROR: could not get users for battle counts: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to query users")
+		return
+	}
+	defer usersCursor.Close(ctx)
+
+	var users []models.User
+	if err := usersCursor.All(ctx, &users); err != nil {
+		log.Printf("ERROR: could not decode users for battle counts: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to read users")
+		return
+	}
+
+	usersByPID := make(map[int]models.User, len(users))
+	for _, user := range users {
+		usersByPID[int(user.PID)] = user
+	}
+
+	players := []PlayerBattleCount{}
+	for _, count := range counts {
+		user := usersByPID[count.PID]
+		players = append(players, PlayerBattleCount{
+			PID:         count.PID,
+			Username:    user.Username,
+			BattleCount: count.Count,
+			BattleLimit: database.GetBattleLimitForUser(user, config),
+			HasOverride: user.BattleLimit != nil,
+		})
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]PlayerBattleCount{"players": players})
+}
+
+// Overrides how many battles a single player can create, or goes back to the limit in the config when battle_limit is null.
+// Requires a valid admin API token in the Authorization header.
+func SetPlayerBattleLimitHandler(w http.ResponseWriter, r *http.Request) {
+	var req SetPlayerBattleLimitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Username == "" {
+		sendError(w, http.StatusBadRequest, "Username is required")
+		return
+	}
+
+	if req.BattleLimit != nil && *req.BattleLimit < 0 {
+		sendError(w, http.StatusBadRequest, "battle_limit cannot be negative")
+		return
+	}
+
+	pid := database.GetPIDForUsername(req.Username)
+	if pid == 0 {
+		sendError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	update := bson.M{"$unset": bson.M{"battle_limit": ""}}
+	if req.BattleLimit != nil {
+		update = bson.M{"$set": bson.M{"battle_limit": *req.BattleLimit}}
+	}
+
+	_, err := database.GocentralDatabase.Collection("users").UpdateOne(r.Context(), bson.M{"pid": pid}, update)
+	if err != nil {
+		log.Printf("ERROR: could not set battle limit for user %s: %v", req.Username, err)
+		sendError(w, http.StatusInternalServerError, "Failed to set battle limit")
+		return
+	}
+
+	if req.BattleLimit != nil {
+		log.Printf("Set battle limit for user %s (PID %d) to %d", req.Username, pid, *req.BattleLimit)
+	} else {
+		log.Printf("Removed battle limit override for user %s (PID %d)", req.Username, pid)
+	}
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"pid":          pid,
+		"battle_limit": req.BattleLimit,
+	})
+}
@@ -0,0 +1,84 @@
+package restapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	database "rb3server/database"
+)
+
+type RotateSecretRequest struct {
+	Username  string `json:"username"`
+	MachineID int    `json:"machine_id"` // for Wii Master Users, instead of a username
+
+	// go back to the platform's password instead of generating a new secret
+	UsePlatformPassword bool `json:"use_platform_password"`
+}
+
+// Gives a player or Wii a newly generated secret for logging in, or puts them back on the platform's password.
+// The secret is only returned once, the old one stops working straight away.
+// Requires a valid admin API token in the Authorization header.
+func RotateSecretHandler(w http.ResponseWriter, r *http.Request) {
+	var req RotateSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if (req.Username == "") == (req.MachineID == 0) {
+		sendError(w, http.StatusBadRequest, "Exactly one of username or machine_id is required")
+		return
+	}
+
+	var secret string
+	var err error
+	pid := req.MachineID
+
+	if req.Username != "" {
+		pid = database.GetPIDForUsername(req.Username)
+		if pid == 0 {
+			sendError(w, http.StatusNotFound, "User not found")
+			return
+		}
+
+		if req.UsePlatformPassword {
+			err = database.SetUserKerberosKey(r.Context(), database.GocentralDatabase, pid, nil)
+		} else {
+			secret, err = database.RotateUserSecret(r.Context(), database.GocentralDatabase, pid)
+		}
+	} else if req.UsePlatformPassword {
+		err = database.SetMachineKerberosKey(r.Context(), database.GocentralDatabase, req.MachineID, nil)
+	} else {
+		secret, err = database.RotateMachineSecret(r.Context(), database.GocentralDatabase, req.MachineID)
+	}
+
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Account not found")
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: could not rotate secret for PID %d: %v", pid, err)
+		sendError(w, http.StatusInternalServerError, "Failed to rotate secret")
+		return
+	}
+
+	if req.UsePlatformPassword {
+		log.Printf("PID %d now logs in with the platform's password", pid)
+		sendJSON(w, http.StatusOK, map[string]interface{}{
+			"success": true,
+			"pid":     pid,
+		})
+		return
+	}
+
+	log.Printf("Rotated the secret for PID %d", pid)
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"pid":     pid,
+		"secret":  secret,
+	})
+}
@@ -0,0 +1,546 @@
+package restapi
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rb3server/binarydata"
+	database "rb3server/database"
+	"rb3server/models"
+)
+
+// the most art a single upload can contain, across every platform
+const maxCuratedSetlistArtSize = 8 << 20
+
+// a curated Harmonix Recommends setlist as admins see it
+type CuratedSetlistInfo struct {
+	SetlistID    int      `json:"setlist_id"`
+	GUID         string   `json:"guid"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	SongIDs      []int    `json:"song_ids"`
+	SongNames    []string `json:"song_names"`
+	SortOrder    int      `json:"sort_order"`
+	VisibleFrom  int64    `json:"visible_from,omitempty"`
+	VisibleUntil int64    `json:"visible_until,omitempty"`
+	Platforms    []int    `json:"platforms"`
+	Regions      []string `json:"regions"`
+	Retired      bool     `json:"retired"`
+	ArtRevision  int      `json:"art_revision"`
+	CreatedAt    int64    `json:"created_at"`
+}
+
+type CreateCuratedSetlistRequest struct {
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	SongIDs      []int      `json:"song_ids"`
+	SortOrder    int        `json:"sort_order"`
+	VisibleFrom  *time.Time `json:"visible_from"`
+	VisibleUntil *time.Time `json:"visible_until"`
+	Platforms    []int      `json:"platforms"`
+	Regions      []string   `json:"regions"`
+}
+
+// every field is optional, only the ones that are sent get changed
+// an empty platforms or regions list targets everyone again
+type UpdateCuratedSetlistRequest struct {
+	Title       *string   `json:"title"`
+	Description *string   `json:"description"`
+	SongIDs     []int     `json:"song_ids"`
+	SortOrder   *int      `json:"sort_order"`
+	Platforms   *[]int    `json:"platforms"`
+	Regions     *[]string `json:"regions"`
+}
+
+// null clears that end of the window
+type ScheduleCuratedSetlistRequest struct {
+	VisibleFrom  *time.Time `json:"visible_from"`
+	VisibleUntil *time.Time `json:"visible_until"`
+}
+
+// setlist IDs in the order they should be shown in
+type ReorderCuratedSetlistsRequest struct {
+	SetlistIDs []int `json:"setlist_ids"`
+}
+
+func newCuratedSetlistInfo(setlist models.Setlist) CuratedSetlistInfo {
+	info := CuratedSetlistInfo{
+		SetlistID:    setlist.SetlistID,
+		GUID:         setlist.GUID,
+		Title:        setlist.Title,
+		Description:  setlist.Desc,
+		SongIDs:      setlist.SongIDs,
+		SongNames:    setlist.SongNames,
+		SortOrder:    setlist.SortOrder,
+		VisibleFrom:  setlist.VisibleFrom,
+		VisibleUntil: setlist.VisibleUntil,
+		Platforms:    setlist.Platforms,
+		Regions:      setlist.Regions,
+		Retired:      setlist.Retired,
+		ArtRevision:  setlist.ArtRevision,
+		CreatedAt:    setlist.Created,
+	}
+
+	if info.Platforms == nil {
+		info.Platforms = []int{}
+	}
+	if info.Regions == nil {
+		info.Regions = []string{}
+	}
+
+	return info
+}
+
+// checks the console types a curated setlist is targeted at, returns an error message if they are invalid
+func validateCuratedSetlistPlatforms(platforms []int) string {
+	for _, platform := range platforms {
+		// RPCS3 is targeted through PS3
+		if platform < 0 || platform > 2 {
+			return "platforms can only contain 0 (Xbox), 1 (PS3) or 2 (Wii)"
+		}
+	}
+
+	return ""
+}
+
+// gets the curated setlist named by the {id} URL parameter, sends an error and returns false if it can't
+func getCuratedSetlistFromURL(w http.ResponseWriter, r *http.Request) (*models.Setlist, bool) {
+	setlistID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid setlist ID")
+		return nil, false
+	}
+
+	var setlist models.Setlist
+	err = database.GocentralDatabase.Collection("setlists").FindOne(r.Context(), bson.M{"setlist_id": setlistID, "type": 2}).Decode(&setlist)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			sendError(w, http.StatusNotFound, "Curated setlist not found")
+			return nil, false
+		}
+		log.Printf("ERROR: could not get curated setlist %d: %v", setlistID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query curated setlist")
+		return nil, false
+	}
+
+	return &setlist, true
+}
+
+// Lists every curated Harmonix Recommends setlist in the order players see them.
+// Optional filter: ?retired=1 or ?retired=0
+// Requires a valid admin API token in the Authorization header.
+func CuratedSetlistListHandler(w http.ResponseWriter, r *http.Request) {
+	filter := bson.M{"type": 2}
+
+	switch r.URL.Query().Get("retired") {
+	case "":
+	case "1":
+		filter["retired"] = true
+	case "0":
+		filter["retired"] = bson.M{"$ne": true}
+	default:
+		sendError(w, http.StatusBadRequest, "Invalid retired")
+		return
+	}
+
+	ctx := r.Context()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "sort_order", Value: 1}, {Key: "setlist_id", Value: 1}})
+
+	cursor, err := database.GocentralDatabase.Collection("setlists").Find(ctx, filter, findOptions)
+	if err != nil {
+		log.Printf("ERROR: could not query curated setlists: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to query curated setlists")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var setlists []models.Setlist
+	if err := cursor.All(ctx, &setlists); err != nil {
+		log.Printf("ERROR: could not decode curated setlists: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to read curated setlists")
+		return
+	}
+
+	curated := []CuratedSetlistInfo{}
+	for _, setlist := range setlists {
+		curated = append(curated, newCuratedSetlistInfo(setlist))
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]CuratedSetlistInfo{"setlists": curated})
+}
+
+// Creates a curated Harmonix Recommends setlist that every targeted player sees.
+// Requires a valid admin API token in the Authorization header.
+func CreateCuratedSetlistHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateCuratedSetlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Title == "" {
+		sendError(w, http.StatusBadRequest, "title is required")
+		return
+	}
+
+	if len(req.SongIDs) == 0 {
+		sendError(w, http.StatusBadRequest, "A setlist needs at least one song_id")
+		return
+	}
+
+	if msg := validateCuratedSetlistPlatforms(req.Platforms); msg != "" {
+		sendError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	setlist := models.Setlist{
+		Title:     req.Title,
+		Desc:      req.Description,
+		SongIDs:   req.SongIDs,
+		SortOrder: req.SortOrder,
+		Platforms: req.Platforms,
+		Regions:   req.Regions,
+	}
+
+	if req.VisibleFrom != nil {
+		setlist.VisibleFrom = req.VisibleFrom.Unix()
+	}
+	if req.VisibleUntil != nil {
+		setlist.VisibleUntil = req.VisibleUntil.Unix()
+	}
+	if setlist.VisibleFrom != 0 && setlist.VisibleUntil != 0 && setlist.VisibleUntil <= setlist.VisibleFrom {
+		sendError(w, http.StatusBadRequest, "visible_until must be after visible_from")
+		return
+	}
+
+	ctx := r.Context()
+
+	setlistID, err := database.InsertCuratedSetlist(ctx, database.GocentralDatabase, setlist)
+	if err != nil {
+		log.Printf("ERROR: could not create curated setlist: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to create curated setlist")
+		return
+	}
+
+	log.Printf("Created curated setlist #%d %q", setlistID, req.Title)
+	sendJSON(w, http.StatusCreated, map[string]interface{}{
+		"success":    true,
+		"setlist_id": setlistID,
+	})
+}
+
+// Changes the title, description, songs, position or targeting of a curated setlist.
+// Requires a valid admin API token in the Authorization header.
+func UpdateCuratedSetlistHandler(w http.ResponseWriter, r *http.Request) {
+	setlist, ok := getCuratedSetlistFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateCuratedSetlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	ctx := r.Context()
+	set := bson.M{}
+
+	if req.Title != nil {
+		if *req.Title == "" {
+			sendError(w, http.StatusBadRequest, "title cannot be empty")
+			return
+		}
+		set["title"] = *req.Title
+		setlist.Title = *req.Title
+	}
+
+	if req.Description != nil {
+		set["desc"] = *req.Description
+		setlist.Desc = *req.Description
+	}
+
+	if req.SongIDs != nil {
+		if len(req.SongIDs) == 0 {
+			sendError(w, http.StatusBadRequest, "A setlist needs at least one song_id")
+			return
+		}
+		setlist.SongIDs = req.SongIDs
+		setlist.SongNames = database.GetSongNamesInOrder(ctx, database.GocentralDatabase, req.SongIDs)
+		set["s_ids"] = setlist.SongIDs
+		set["s_names"] = setlist.SongNames
+	}
+
+	if req.SortOrder != nil {
+		set["sort_order"] = *req.SortOrder
+		setlist.SortOrder = *req.SortOrder
+	}
+
+	if req.Platforms != nil {
+		if msg := validateCuratedSetlistPlatforms(*req.Platforms); msg != "" {
+			sendError(w, http.StatusBadRequest, msg)
+			return
+		}
+		set["platforms"] = *req.Platforms
+		setlist.Platforms = *req.Platforms
+	}
+
+	if req.Regions != nil {
+		set["regions"] = *req.Regions
+		setlist.Regions = *req.Regions
+	}
+
+	if len(set) == 0 {
+		sendError(w, http.StatusBadRequest, "Nothing to update")
+		return
+	}
+
+	_, err := database.GocentralDatabase.Collection("setlists").UpdateOne(ctx, bson.M{"setlist_id": setlist.SetlistID}, bson.M{"$set": set})
+	if err != nil {
+		log.Printf("ERROR: could not update curated setlist %d: %v", setlist.SetlistID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to update curated setlist")
+		return
+	}
+
+	log.Printf("Updated curated setlist #%d", setlist.SetlistID)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"setlist": newCuratedSetlistInfo(*setlist),
+	})
+}
+
+// Sets the order curated setlists are shown in. Setlists that aren't listed keep their position.
+// Requires a valid admin API token in the Authorization header.
+func ReorderCuratedSetlistsHandler(w http.ResponseWriter, r *http.Request) {
+	var req ReorderCuratedSetlistsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if len(req.SetlistIDs) == 0 {
+		sendError(w, http.StatusBadRequest, "setlist_ids is required")
+		return
+	}
+
+	ctx := r.Context()
+	setlistsCollection := database.GocentralDatabase.Collection("setlists")
+
+	count, err := setlistsCollection.CountDocuments(ctx, bson.M{"setlist_id": bson.M{"$in": req.SetlistIDs}, "type": 2})
+	if err != nil {
+		log.Printf("ERROR: could not count curated setlists: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to query curated setlists")
+		return
+	}
+	if int(count) != len(req.SetlistIDs) {
+		sendError(w, http.StatusBadRequest, "setlist_ids must be distinct curated setlists")
+		return
+	}
+
+	for i, setlistID := range req.SetlistIDs {
+		// start at 1 so a reordered setlist never looks like it was never given a position
+		_, err := setlistsCollection.UpdateOne(ctx, bson.M{"setlist_id": setlistID}, bson.M{"$set": bson.M{"sort_order": i + 1}})
+		if err != nil {
+			log.Printf("ERROR: could not reorder curated setlist %d: %v", setlistID, err)
+			sendError(w, http.StatusInternalServerError, "Failed to reorder curated setlists")
+			return
+		}
+	}
+
+	log.Printf("Reordered %d curated setlists", len(req.SetlistIDs))
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"reordered": len(req.SetlistIDs),
+	})
+}
+
+// Sets when players start and stop seeing a curated setlist, e.g. to rotate a themed setlist in for one week.
+// Requires a valid admin API token in the Authorization header.
+func ScheduleCuratedSetlistHandler(w http.ResponseWriter, r *http.Request) {
+	setlist, ok := getCuratedSetlistFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	var req ScheduleCuratedSetlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	setlist.VisibleFrom = 0
+	setlist.VisibleUntil = 0
+	if req.VisibleFrom != nil {
+		setlist.VisibleFrom = req.VisibleFrom.Unix()
+	}
+	if req.VisibleUntil != nil {
+		setlist.VisibleUntil = req.VisibleUntil.Unix()
+	}
+	if setlist.VisibleFrom != 0 && setlist.VisibleUntil != 0 && setlist.VisibleUntil <= setlist.VisibleFrom {
+		sendError(w, http.StatusBadRequest, "visible_until must be after visible_from")
+		return
+	}
+
+	_, err := database.GocentralDatabase.Collection("setlists").UpdateOne(r.Context(),
+		bson.M{"setlist_id": setlist.SetlistID},
+		bson.M{"$set": bson.M{"visible_from": setlist.VisibleFrom, "visible_until": setlist.VisibleUntil}},
+	)
+	if err != nil {
+		log.Printf("ERROR: could not schedule curated setlist %d: %v", setlist.SetlistID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to schedule curated setlist")
+		return
+	}
+
+	log.Printf("Scheduled curated setlist #%d from %d until %d", setlist.SetlistID, setlist.VisibleFrom, setlist.VisibleUntil)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"setlist": newCuratedSetlistInfo(*setlist),
+	})
+}
+
+// sets whether a curated setlist is retired, players never see retired setlists but admins can bring them back
+func setCuratedSetlistRetired(w http.ResponseWriter, r *http.Request, retired bool) {
+	setlist, ok := getCuratedSetlistFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"retired": true}}
+	if !retired {
+		update = bson.M{"$unset": bson.M{"retired": ""}}
+	}
+
+	_, err := database.GocentralDatabase.Collection("setlists").UpdateOne(r.Context(), bson.M{"setlist_id": setlist.SetlistID}, update)
+	if err != nil {
+		log.Printf("ERROR: could not set retired=%v on curated setlist %d: %v", retired, setlist.SetlistID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to update curated setlist")
+		return
+	}
+
+	log.Printf("Set retired=%v on curated setlist #%d", retired, setlist.SetlistID)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"setlist_id": setlist.SetlistID,
+		"retired":    retired,
+	})
+}
+
+// Retires a curated setlist so players no longer see it.
+// Requires a valid admin API token in the Authorization header.
+func RetireCuratedSetlistHandler(w http.ResponseWriter, r *http.Request) {
+	setCuratedSetlistRetired(w, r, true)
+}
+
+// Brings a retired curated setlist back.
+// Requires a valid admin API token in the Authorization header.
+func UnretireCuratedSetlistHandler(w http.ResponseWriter, r *http.Request) {
+	setCuratedSetlistRetired(w, r, false)
+}
+
+// Uploads new art for a curated setlist and bumps its art revision so clients fetch it again.
+// The body is a multipart form with one file per console, named png_xbox, png_ps3 and/or png_wii.
+// Consoles that aren't in the upload keep the art they had.
+// Requires a valid admin API token in the Authorization header.
+func UploadCuratedSetlistArtHandler(w http.ResponseWriter, r *http.Request) {
+	setlist, ok := getCuratedSetlistFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCuratedSetlistArtSize)
+	if err := r.ParseMultipartForm(maxCuratedSetlistArtSize); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid multipart form, art can be at most 8 MB")
+		return
+	}
+
+	rejectInvalidTextures := false
+	if config, err := database.GetCachedConfig(r.Context()); err == nil {
+		rejectInvalidTextures = config.RejectInvalidTextures
+	}
+
+	art := map[string][]byte{}
+	for _, extension := range binarydata.PlatformExtensions {
+		file, _, err := r.FormFile(extension)
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Failed to read "+extension)
+			return
+		}
+		if len(data) == 0 {
+			sendError(w, http.StatusBadRequest, extension+" is empty")
+			return
+		}
+		if err := binarydata.ValidateTexture(data, extension); err != nil {
+			if rejectInvalidTextures {
+				sendError(w, http.StatusBadRequest, extension+" is not a valid texture: "+err.Error())
+				return
+			}
+			log.Printf("Saving %s art for curated setlist #%d even though it failed texture validation: %v", extension, setlist.SetlistID, err)
+		}
+		art[extension] = data
+	}
+
+	if len(art) == 0 {
+		sendError(w, http.StatusBadRequest, "Upload at least one of "+strings.Join(binarydata.PlatformExtensions, ", "))
+		return
+	}
+
+	ctx := r.Context()
+	store := binarydata.DefaultStore()
+	artKey := binarydata.SanitizePath(setlist.GUID)
+	previousRevision := int64(setlist.ArtRevision)
+	newRevision := previousRevision + 1
+
+	for _, extension := range binarydata.PlatformExtensions {
+		data, uploaded := art[extension]
+		if !uploaded {
+			// carry the previous art for this console over to the new revision
+			if previousRevision == 0 {
+				continue
+			}
+			previous, err := store.Get(ctx, binarydata.Key("setlist_art", artKey, previousRevision, extension))
+			if err != nil {
+				continue
+			}
+			data = previous
+		}
+
+		if err := database.StoreBinaryData(ctx, database.GocentralDatabase, store, "setlist_art", artKey, newRevision, extension, 0, data); err != nil {
+			log.Printf("ERROR: could not save art for curated setlist %d: %v", setlist.SetlistID, err)
+			sendError(w, http.StatusInternalServerError, "Failed to save art")
+			return
+		}
+	}
+
+	_, err := database.GocentralDatabase.Collection("setlists").UpdateOne(ctx,
+		bson.M{"setlist_id": setlist.SetlistID},
+		bson.M{"$set": bson.M{"art_revision": newRevision}},
+	)
+	if err != nil {
+		log.Printf("ERROR: could not bump art revision of curated setlist %d: %v", setlist.SetlistID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to update curated setlist")
+		return
+	}
+
+	log.Printf("Uploaded art revision %d for curated setlist #%d", newRevision, setlist.SetlistID)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"setlist_id":   setlist.SetlistID,
+		"art_revision": newRevision,
+	})
+}
@@ -0,0 +1,211 @@
+package restapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	database "rb3server/database"
+	"rb3server/models"
+)
+
+type GroupInfo struct {
+	models.Group
+	MemberCount int64 `json:"member_count"`
+}
+
+type GroupMember struct {
+	PID      int    `json:"pid"`
+	Username string `json:"username"`
+}
+
+type PlayerGroupsResponse struct {
+	PID      int      `json:"pid"`
+	Username string   `json:"username"`
+	Groups   []string `json:"groups"`
+}
+
+type PlayerGroupRequest struct {
+	Username string `json:"username"`
+	Group    string `json:"group"`
+}
+
+// Lists every known group, the privileges it grants, and how many users are in it.
+// Requires a valid admin API token in the Authorization header.
+func GroupListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	usersCollection := database.GocentralDatabase.Collection("users")
+
+	groups := []GroupInfo{}
+	for _, group := range database.GroupCatalog {
+		count, err := usersCollection.CountDocuments(ctx, bson.M{"groups": group.ID})
+		if err != nil {
+			log.Printf("ERROR: could not count members of group %s: %v", group.ID, err)
+			sendError(w, http.StatusInternalServerError, "Failed to count group members")
+			return
+		}
+		groups = append(groups, GroupInfo{Group: group, MemberCount: count})
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]GroupInfo{"groups": groups})
+}
+
+// Lists the users in a group.
+// Requires a valid admin API token in the Authorization header.
+func GroupMembersHandler(w http.ResponseWriter, r *http.Request) {
+	groupID := chi.URLParam(r, "group")
+	if _, ok := database.GetGroup(groupID); !ok {
+		sendError(w, http.StatusNotFound, "Group not found")
+		return
+	}
+
+	page, pageSize, ok := getPagination(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "pid", Value: 1}}).
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize)).
+		SetProjection(bson.M{"pid": 1, "username": 1})
+
+	cursor, err := database.GocentralDatabase.Collection("users").Find(ctx, bson.M{"groups": groupID}, findOptions)
+	if err != nil {
+		log.Printf("ERROR: could not query members of group %s: %v", groupID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query group members")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		log.Printf("ERROR: could not decode members of group %s: %v", groupID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to read group members")
+		return
+	}
+
+	members := []GroupMember{}
+	for _, user := range users {
+		members = append(members, GroupMember{PID: int(user.PID), Username: user.Username})
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]GroupMember{"users": members})
+}
+
+// Lists the groups a player is in, e.g. ?username=foo
+// Requires a valid admin API token in the Authorization header.
+func PlayerGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.URL.Query().Get("username")
+	if username == "" {
+		sendError(w, http.StatusBadRequest, "Username is required")
+		return
+	}
+
+	pid := database.GetPIDForUsername(username)
+	if pid == 0 {
+		sendError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	var user models.User
+	if err := database.GocentralDatabase.Collection("users").FindOne(r.Context(), bson.M{"pid": pid}).Decode(&user); err != nil {
+		log.Printf("ERROR: could not get user %d: %v", pid, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query user")
+		return
+	}
+
+	groups := user.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+
+	sendJSON(w, http.StatusOK, PlayerGroupsResponse{PID: pid, Username: user.Username, Groups: groups})
+}
+
+// decodes and checks a request to change a player's groups, sends an error and returns false if it is invalid
+func decodePlayerGroupRequest(w http.ResponseWriter, r *http.Request) (PlayerGroupRequest, int, bool) {
+	var req PlayerGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return req, 0, false
+	}
+
+	if req.Username == "" || req.Group == "" {
+		sendError(w, http.StatusBadRequest, "Username and group are required")
+		return req, 0, false
+	}
+
+	if _, ok := database.GetGroup(req.Group); !ok {
+		sendError(w, http.StatusBadRequest, "Unknown group")
+		return req, 0, false
+	}
+
+	pid := database.GetPIDForUsername(req.Username)
+	if pid == 0 {
+		sendError(w, http.StatusNotFound, "User not found")
+		return req, 0, false
+	}
+
+	return req, pid, true
+}
+
+// Puts a player in a group.
+// Requires a valid admin API token in the Authorization header.
+func AddPlayerToGroupHandler(w http.ResponseWriter, r *http.Request) {
+	req, pid, ok := decodePlayerGroupRequest(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := database.AddUserToGroup(r.Context(), database.GocentralDatabase, pid, req.Group)
+	if err != nil {
+		log.Printf("ERROR: could not add user %s to group %s: %v", req.Username, req.Group, err)
+		sendError(w, http.StatusInternalServerError, "Failed to add user to group")
+		return
+	}
+	if !found {
+		sendError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	log.Printf("Added user %s (PID %d) to group %s", req.Username, pid, req.Group)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"pid":     pid,
+		"group":   req.Group,
+	})
+}
+
+// Takes a player out of a group.
+// Requires a valid admin API token in the Authorization header.
+func RemovePlayerFromGroupHandler(w http.ResponseWriter, r *http.Request) {
+	req, pid, ok := decodePlayerGroupRequest(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := database.RemoveUserFromGroup(r.Context(), database.GocentralDatabase, pid, req.Group)
+	if err != nil {
+		log.Printf("ERROR: could not remove user %s from group %s: %v", req.Username, req.Group, err)
+		sendError(w, http.StatusInternalServerError, "Failed to remove user from group")
+		return
+	}
+	if !found {
+		sendError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	log.Printf("Removed user %s (PID %d) from group %s", req.Username, pid, req.Group)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"pid":     pid,
+		"group":   req.Group,
+	})
+}
@@ -0,0 +1,84 @@
+package restapi
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"rb3server/binarydata"
+	database "rb3server/database"
+	"rb3server/models"
+)
+
+// sends the newest revision of some art as a PNG, converting it from whatever console uploaded it if needed
+func sendCanonicalImage(w http.ResponseWriter, r *http.Request, dataType string, key string) {
+	data, err := database.LoadCanonicalImage(r.Context(), database.GocentralDatabase, binarydata.DefaultStore(), dataType, key)
+	if err != nil {
+		if errors.Is(err, binarydata.ErrNotFound) {
+			sendError(w, http.StatusNotFound, "Image not found")
+			return
+		}
+		if errors.Is(err, binarydata.ErrUnsupportedTexture) {
+			sendError(w, http.StatusUnprocessableEntity, "Image is in a format that can't be converted")
+			return
+		}
+		log.Printf("ERROR: could not load %s %s: %v", dataType, key, err)
+		sendError(w, http.StatusInternalServerError, "Failed to load image")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Returns a band's logo as a PNG.
+func BandLogoHandler(w http.ResponseWriter, r *http.Request) {
+	bandID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid band ID")
+		return
+	}
+
+	sendCanonicalImage(w, r, "band_logo", strconv.Itoa(bandID))
+}
+
+// Returns the art of a shared setlist as a PNG.
+func SetlistArtHandler(w http.ResponseWriter, r *http.Request) {
+	setlistID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid setlist ID")
+		return
+	}
+
+	var setlist models.Setlist
+	err = database.GocentralDatabase.Collection("setlists").FindOne(r.Context(), bson.M{"setlist_id": setlistID, "shared": "t"}).Decode(&setlist)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			sendError(w, http.StatusNotFound, "Setlist not found")
+			return
+		}
+		log.Printf("ERROR: could not get setlist %d: %v", setlistID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query setlist")
+		return
+	}
+
+	sendCanonicalImage(w, r, "setlist_art", binarydata.SanitizePath(setlist.GUID))
+}
+
+// Returns the art of a battle as a PNG.
+func BattleArtHandler(w http.ResponseWriter, r *http.Request) {
+	battleID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid battle ID")
+		return
+	}
+
+	sendCanonicalImage(w, r, "battle_art", strconv.Itoa(battleID))
+}
@@ -0,0 +1,212 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rb3server/binarydata"
+	database "rb3server/database"
+	"rb3server/models"
+)
+
+type ModerationEntry struct {
+	ModerationID      int    `json:"moderation_id"`
+	Status            string `json:"status"`
+	Type              string `json:"type"`
+	Key               string `json:"key"`
+	Revision          int64  `json:"revision"`
+	PlatformExtension string `json:"platform_extension"`
+	OwnerPID          int    `json:"owner_pid"`
+	OwnerName         string `json:"owner_name"`
+	UploadedAt        int64  `json:"uploaded_at"`
+	ReviewedAt        int64  `json:"reviewed_at"`
+	ReviewNote        string `json:"review_note"`
+	ImageURL          string `json:"image_url"`
+}
+
+type ModerationReviewRequest struct {
+	Note string `json:"note"`
+}
+
+// Lists uploaded band logos and setlist art, newest first. Defaults to uploads still waiting on review, use ?status=approved or ?status=replaced to see reviewed ones.
+// Requires a valid admin API token in the Authorization header.
+func ModerationListHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "pending"
+	}
+	if status != "pending" && status != "approved" && status != "replaced" {
+		sendError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	page, pageSize, ok := getPagination(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	moderationCollection := database.GocentralDatabase.Collection("binary_data_moderation")
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "moderation_id", Value: -1}}).
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+
+	cursor, err := moderationCollection.Find(ctx, bson.M{"status": status}, findOptions)
+	if err != nil {
+		log.Printf("ERROR: could not query the moderation queue: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to query the moderation queue")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var moderations []models.BinaryDataModeration
+	if err := cursor.All(ctx, &moderations); err != nil {
+		log.Printf("ERROR: could not decode the moderation queue: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to read the moderation queue")
+		return
+	}
+
+	pids := make([]int, 0, len(moderations))
+	for _, m := range moderations {
+		pids = append(pids, m.OwnerPID)
+	}
+
+	userNameMap, err := database.GetConsolePrefixedUsernamesByPIDs(ctx, database.GocentralDatabase, pids)
+	if err != nil {
+		log.Println("Error fetching usernames:", err)
+		userNameMap = make(map[int]string)
+	}
+
+	entries := []ModerationEntry{}
+	for _, m := range moderations {
+		name, ok := userNameMap[m.OwnerPID]
+		if !ok {
+			name = "Unnamed Player"
+		}
+
+		entries = append(entries, ModerationEntry{
+			ModerationID:      m.ModerationID,
+			Status:            m.Status,
+			Type:              m.Type,
+			Key:               m.Key,
+			Revision:          m.Revision,
+			PlatformExtension: m.PlatformExtension,
+			OwnerPID:          m.OwnerPID,
+			OwnerName:         name,
+			UploadedAt:        m.UploadedAt,
+			ReviewedAt:        m.ReviewedAt,
+			ReviewNote:        m.ReviewNote,
+			ImageURL:          "/admin/moderation/" + strconv.Itoa(m.ModerationID) + "/image.png",
+		})
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]ModerationEntry{"uploads": entries})
+}
+
+// Returns the exact revision an entry in the moderation queue is for as a PNG, so it can be reviewed.
+// Requires a valid admin API token in the Authorization header.
+func ModerationImageHandler(w http.ResponseWriter, r *http.Request) {
+	moderation, ok := getModerationFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := database.LoadBinaryData(r.Context(), database.GocentralDatabase, binarydata.DefaultStore(), moderation.Type, moderation.Key, moderation.Revision, database.CanonicalImageExtension)
+	if err != nil {
+		if errors.Is(err, binarydata.ErrNotFound) {
+			sendError(w, http.StatusNotFound, "Image not found")
+			return
+		}
+		log.Printf("ERROR: could not load image for moderation entry %d: %v", moderation.ModerationID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to load image")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Approves an uploaded band logo or piece of setlist art, leaving it as it is.
+// Requires a valid admin API token in the Authorization header.
+func ApproveModerationHandler(w http.ResponseWriter, r *http.Request) {
+	reviewModeration(w, r, database.ApproveBinaryData, "approved")
+}
+
+// Replaces an uploaded band logo or piece of setlist art with a placeholder on every platform. Art that was already approved can still be replaced.
+// Requires a valid admin API token in the Authorization header.
+func ReplaceModerationHandler(w http.ResponseWriter, r *http.Request) {
+	reviewModeration(w, r, func(ctx context.Context, db *mongo.Database, moderation *models.BinaryDataModeration, note string) error {
+		return database.ReplaceBinaryDataWithPlaceholder(ctx, db, binarydata.DefaultStore(), moderation, note)
+	}, "replaced")
+}
+
+func getModerationFromURL(w http.ResponseWriter, r *http.Request) (*models.BinaryDataModeration, bool) {
+	moderationID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid moderation ID")
+		return nil, false
+	}
+
+	moderation, err := database.GetBinaryDataModeration(r.Context(), database.GocentralDatabase, moderationID)
+	if err != nil {
+		log.Printf("ERROR: could not get moderation entry %d: %v", moderationID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query moderation entry")
+		return nil, false
+	}
+	if moderation == nil {
+		sendError(w, http.StatusNotFound, "Moderation entry not found")
+		return nil, false
+	}
+
+	return moderation, true
+}
+
+type moderationReviewFunc func(ctx context.Context, db *mongo.Database, moderation *models.BinaryDataModeration, note string) error
+
+func reviewModeration(w http.ResponseWriter, r *http.Request, review moderationReviewFunc, action string) {
+	// the note is optional, so an empty body is fine
+	var req ModerationReviewRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	moderation, ok := getModerationFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	// once art is replaced the original is gone, so there is nothing left to approve
+	if moderation.Status == "replaced" || moderation.Status == action {
+		sendError(w, http.StatusConflict, "Upload has already been "+moderation.Status)
+		return
+	}
+
+	if err := review(r.Context(), database.GocentralDatabase, moderation, req.Note); err != nil {
+		log.Printf("ERROR: could not review moderation entry %d: %v", moderation.ModerationID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to review upload")
+		return
+	}
+
+	log.Printf("%s %s revision %d uploaded by PID %d was %s", moderation.Type, moderation.Key, moderation.Revision, moderation.OwnerPID, action)
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":       true,
+		"moderation_id": moderation.ModerationID,
+		"status":        action,
+	})
+}
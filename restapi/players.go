@@ -0,0 +1,321 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	database "rb3server/database"
+	"rb3server/models"
+)
+
+// how many of a player's best scores and latest battles their profile shows
+const (
+	profileTopScoreCount = 10
+	profileBattleCount   = 10
+)
+
+type ProfileBand struct {
+	BandID  int    `json:"band_id"`
+	Name    string `json:"name"`
+	LogoURL string `json:"logo_url,omitempty"` // empty if the band has no logo
+}
+
+type ProfileCharacter struct {
+	CharacterID int    `json:"character_id"`
+	Name        string `json:"name"`
+}
+
+// a player's rank on one instrument, by the sum of their scores on it
+type ProfileRoleRank struct {
+	RoleID int `json:"role_id"`
+	Rank   int `json:"rank"`
+}
+
+type ProfileScore struct {
+	SongID       int    `json:"song_id"`
+	SongName     string `json:"song_name"`
+	RoleID       int    `json:"role_id"`
+	DiffID       int    `json:"diff_id"`
+	Score        int    `json:"score"`
+	Stars        int    `json:"stars"`
+	NotesPercent int    `json:"notes_percent"`
+}
+
+type ProfileAccomplishment struct {
+	AccID string `json:"acc_id"`
+	Score int    `json:"score"`
+	Rank  int    `json:"rank"`
+}
+
+// what anyone can see about a player, which leaves out anything private like their friends, machines or web account
+type PlayerProfile struct {
+	PID             int                         `json:"pid"`
+	Name            string                      `json:"name"` // username with the console they are on, e.g. "Player [PS3]"
+	Platform        string                      `json:"platform"`
+	Bands           []ProfileBand               `json:"bands"`
+	Characters      []ProfileCharacter          `json:"characters"`
+	OverallRank     int                         `json:"overall_rank"` // 0 if the player has no scores
+	RoleRanks       []ProfileRoleRank           `json:"role_ranks"`
+	TopScores       []ProfileScore              `json:"top_scores"`
+	Battles         []models.BattleHistoryEntry `json:"battles"`
+	Setlists        []SetlistInfo               `json:"setlists"`
+	Accomplishments []ProfileAccomplishment     `json:"accomplishments"`
+}
+
+type ProfileVisibilityRequest struct {
+	WebIdentity string `json:"web_identity"`
+	Hidden      bool   `json:"hidden"`
+}
+
+// gathers everything a player's public profile shows
+func getPlayerProfile(ctx context.Context, db *mongo.Database, user *models.User) (*PlayerProfile, error) {
+	pid := int(user.PID)
+
+	profile := &PlayerProfile{
+		PID:             pid,
+		Name:            database.ConsolePrefixedUsername(user.Username, user.ConsoleType),
+		Platform:        database.ConsoleName(user.ConsoleType),
+		Bands:           []ProfileBand{},
+		Characters:      []ProfileCharacter{},
+		RoleRanks:       []ProfileRoleRank{},
+		TopScores:       []ProfileScore{},
+		Battles:         []models.BattleHistoryEntry{},
+		Setlists:        []SetlistInfo{},
+		Accomplishments: []ProfileAccomplishment{},
+	}
+
+	// bands
+	var bands []models.Band
+	if err := findAll(ctx, db, "bands", bson.M{"owner_pid": pid}, options.Find().SetProjection(bson.M{"art": 0}), &bands); err != nil {
+		return nil, err
+	}
+
+	bandKeys := make([]string, 0, len(bands))
+	for _, band := range bands {
+		bandKeys = append(bandKeys, strconv.Itoa(band.BandID))
+	}
+	logoKeys, err := db.Collection("binary_data_objects").Distinct(ctx, "key", bson.M{"type": "band_logo", "key": bson.M{"$in": bandKeys}})
+	if err != nil {
+		return nil, err
+	}
+	hasLogo := make(map[string]bool, len(logoKeys))
+	for _, key := range logoKeys {
+		if key, ok := key.(string); ok {
+			hasLogo[key] = true
+		}
+	}
+
+	for _, band := range bands {
+		profileBand := ProfileBand{BandID: band.BandID, Name: band.Name}
+		if hasLogo[strconv.Itoa(band.BandID)] {
+			profileBand.LogoURL = "/bands/" + strconv.Itoa(band.BandID) + "/logo.png"
+		}
+		profile.Bands = append(profile.Bands, profileBand)
+	}
+
+	// characters
+	var characters []models.Character
+	if err := findAll(ctx, db, "characters", bson.M{"owner_pid": pid}, options.Find().SetProjection(bson.M{"char_data": 0}), &characters); err != nil {
+		return nil, err
+	}
+	for _, character := range characters {
+		profile.Characters = append(profile.Characters, ProfileCharacter{CharacterID: character.CharacterID, Name: character.Name})
+	}
+
+	// ranks, only for the instruments the player has played
+	roleIDs, err := db.Collection("scores").Distinct(ctx, "role_id", bson.M{"pid": pid})
+	if err != nil {
+		return nil, err
+	}
+	if len(roleIDs) > 0 {
+		if profile.OverallRank, err = database.GetGlobalRank(ctx, db, pid); err != nil {
+			return nil, err
+		}
+	}
+	for _, roleID := range roleIDs {
+		var role int
+		switch v := roleID.(type) {
+		case int32:
+			role = int(v)
+		case int64:
+			role = int(v)
+		default:
+			continue
+		}
+
+		rank, err := database.GetRoleRank(ctx, db, role, pid)
+		if err != nil {
+			return nil, err
+		}
+		profile.RoleRanks = append(profile.RoleRanks, ProfileRoleRank{RoleID: role, Rank: rank})
+	}
+
+	// best song scores, battle scores show up under battles instead
+	var scores []models.Score
+	scoreOptions := options.Find().SetSort(bson.D{{Key: "score", Value: -1}}).SetLimit(profileTopScoreCount)
+	if err := findAll(ctx, db, "scores", bson.M{"pid": pid, "battle_id": bson.M{"$in": bson.A{0, nil}}}, scoreOptions, &scores); err != nil {
+		return nil, err
+	}
+
+	songIDs := make([]int, 0, len(scores))
+	for _, score := range scores {
+		songIDs = append(songIDs, score.SongID)
+	}
+	songNames, err := database.GetSongNamesByIDs(ctx, db, songIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, score := range scores {
+		profile.TopScores = append(profile.TopScores, ProfileScore{
+			SongID:       score.SongID,
+			SongName:     songNames[score.SongID],
+			RoleID:       score.RoleID,
+			DiffID:       score.DiffID,
+			Score:        score.Score,
+			Stars:        score.Stars,
+			NotesPercent: score.NotesPercent,
+		})
+	}
+
+	// latest battle placements, leaving out battles an admin hid
+	battleOptions := options.Find().SetSort(bson.D{{Key: "ended_at", Value: -1}, {Key: "battle_id", Value: -1}}).SetLimit(profileBattleCount)
+	if err := findAll(ctx, db, "battle_history", bson.M{"pid": pid, "hidden": bson.M{"$ne": true}}, battleOptions, &profile.Battles); err != nil {
+		return nil, err
+	}
+
+	// setlists anyone can find through search
+	setlistFilter := database.DiscoverableSetlistsFilter(time.Now())
+	setlistFilter["pid"] = pid
+	var setlists []models.Setlist
+	if err := findAll(ctx, db, "setlists", setlistFilter, options.Find().SetSort(bson.D{{Key: "created", Value: -1}}), &setlists); err != nil {
+		return nil, err
+	}
+	for _, setlist := range setlists {
+		profile.Setlists = append(profile.Setlists, newSetlistInfo(setlist))
+	}
+
+	// accomplishment standings, ranked the same way as the in-game accomplishment leaderboards
+	var accomplishments []models.AccomplishmentScore
+	if err := findAll(ctx, db, "accomplishment_scores", bson.M{"pid": pid}, options.Find().SetSort(bson.D{{Key: "acc_id", Value: 1}}), &accomplishments); err != nil {
+		return nil, err
+	}
+	for _, accomplishment := range accomplishments {
+		better, err := db.Collection("accomplishment_scores").CountDocuments(ctx, bson.M{"acc_id": accomplishment.AccID, "score": bson.M{"$gt": accomplishment.Score}})
+		if err != nil {
+			return nil, err
+		}
+		profile.Accomplishments = append(profile.Accomplishments, ProfileAccomplishment{
+			AccID: accomplishment.AccID,
+			Score: accomplishment.Score,
+			Rank:  int(better) + 1,
+		})
+	}
+
+	return profile, nil
+}
+
+// finds every document in a collection matching a filter
+func findAll(ctx context.Context, db *mongo.Database, collection string, filter interface{}, findOptions *options.FindOptions, results interface{}) error {
+	cursor, err := db.Collection(collection).Find(ctx, filter, findOptions)
+	if err != nil {
+		return err
+	}
+
+	return cursor.All(ctx, results)
+}
+
+// Returns a player's public profile by their username or PID: their bands, characters, ranks, best scores, battle
+// placements, shared setlists and accomplishment standings. Players who opted out of a public profile aren't found.
+func PlayerProfileHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	nameOrPID := chi.URLParam(r, "player")
+
+	user, err := database.FindUserByNameOrPID(ctx, database.GocentralDatabase, nameOrPID)
+	if err == mongo.ErrNoDocuments || (err == nil && user.ProfileHidden) {
+		sendError(w, http.StatusNotFound, "Player not found")
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: could not find player %s: %v", nameOrPID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query player")
+		return
+	}
+
+	profile, err := getPlayerProfile(ctx, database.GocentralDatabase, user)
+	if err != nil {
+		log.Printf("ERROR: could not get profile for PID %d: %v", user.PID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to get player profile")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, profile)
+}
+
+// hides or shows a player's public profile and sends the response, returning whether it was changed
+func setProfileHidden(w http.ResponseWriter, r *http.Request, pid int, hidden bool) bool {
+	err := database.SetProfileHidden(r.Context(), database.GocentralDatabase, pid, hidden)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "User not found")
+		return false
+	}
+	if err != nil {
+		log.Printf("ERROR: could not change profile visibility for PID %d: %v", pid, err)
+		sendError(w, http.StatusInternalServerError, "Failed to change profile visibility")
+		return false
+	}
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"pid":     pid,
+		"hidden":  hidden,
+	})
+	return true
+}
+
+// Lets a player signed in to the website hide the public profile of one of the game accounts linked to them, or show it again.
+// Requires a valid web API token in the Authorization header.
+func WebProfileVisibilityHandler(w http.ResponseWriter, r *http.Request) {
+	var req ProfileVisibilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	pid, ok := getLinkedPID(w, r, req.WebIdentity)
+	if !ok {
+		return
+	}
+
+	setProfileHidden(w, r, pid, req.Hidden)
+}
+
+// Hides a player's public profile, or shows it again, for players who can't do it themselves because they never linked the website.
+// Only hidden is read from the body.
+// Requires a valid admin API token in the Authorization header.
+func ProfileVisibilityHandler(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "pid"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid PID")
+		return
+	}
+
+	var req ProfileVisibilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if setProfileHidden(w, r, pid, req.Hidden) {
+		log.Printf("Set profile_hidden=%v for PID %d", req.Hidden, pid)
+	}
+}
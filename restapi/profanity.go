@@ -0,0 +1,194 @@
+package restapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"rb3server/database"
+	"rb3server/models"
+	"rb3server/profanity"
+)
+
+type ProfanityListsResponse struct {
+	Filters       []models.ProfanityEntry `json:"filters"` // what the filter actually runs, including the old profanity list as substring entries
+	Allowlist     []string                `json:"allowlist"`
+	ProfanityList []string                `json:"profanity_list"` // the old profanity list on its own
+}
+
+type ProfanityAllowlistRequest struct {
+	Word string `json:"word"`
+}
+
+type ProfanityTestRequest struct {
+	Text string `json:"text"`
+}
+
+// Lists the profanity filter entries and allowlist.
+// Requires a valid admin API token in the Authorization header.
+func ProfanityListsHandler(w http.ResponseWriter, r *http.Request) {
+	config, err := database.GetCachedConfig(r.Context())
+	if err != nil {
+		log.Printf("ERROR: could not get config for listing the profanity filter: %v", err)
+		sendError(w, http.StatusInternalServerError, "Could not retrieve profanity filter")
+		return
+	}
+
+	response := ProfanityListsResponse{
+		Filters:       database.ProfanityEntries(config),
+		Allowlist:     config.ProfanityAllowlist,
+		ProfanityList: config.ProfanityList,
+	}
+	if response.Allowlist == nil {
+		response.Allowlist = []string{}
+	}
+	if response.ProfanityList == nil {
+		response.ProfanityList = []string{}
+	}
+
+	sendJSON(w, http.StatusOK, response)
+}
+
+// Adds an entry to the profanity filter, replacing any entry with the same term and match.
+// Requires a valid admin API token in the Authorization header.
+func AddProfanityEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ProfanityEntry
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	entry, err := profanity.ValidateEntry(req)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid profanity filter entry: "+err.Error())
+		return
+	}
+
+	if err := database.AddProfanityEntry(r.Context(), database.GocentralDatabase, entry); err != nil {
+		log.Printf("ERROR: could not add profanity filter entry %q: %v", entry.Term, err)
+		sendError(w, http.StatusInternalServerError, "Failed to update profanity filter")
+		return
+	}
+
+	log.Printf("Added profanity filter entry %q (%s, %s)", entry.Term, entry.Match, entry.Severity)
+	sendJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"entry":   entry,
+	})
+}
+
+// Removes an entry from the profanity filter by its term and match, which defaults to word.
+// Requires a valid admin API token in the Authorization header.
+func RemoveProfanityEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ProfanityEntry
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	term := strings.TrimSpace(req.Term)
+	if term == "" {
+		sendError(w, http.StatusBadRequest, "Term is required")
+		return
+	}
+	match := req.Match
+	if match == "" {
+		match = profanity.MatchWord
+	}
+
+	removed, err := database.RemoveProfanityEntry(r.Context(), database.GocentralDatabase, term, match)
+	if err != nil {
+		log.Printf("ERROR: could not remove profanity filter entry %q: %v", term, err)
+		sendError(w, http.StatusInternalServerError, "Failed to update profanity filter")
+		return
+	}
+	if !removed {
+		sendError(w, http.StatusNotFound, "Profanity filter entry not found")
+		return
+	}
+
+	log.Printf("Removed profanity filter entry %q (%s)", term, match)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// Adds a word the profanity filter never flags.
+// Requires a valid admin API token in the Authorization header.
+func AddProfanityAllowlistHandler(w http.ResponseWriter, r *http.Request) {
+	var req ProfanityAllowlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	word := strings.TrimSpace(req.Word)
+	if word == "" {
+		sendError(w, http.StatusBadRequest, "Word is required")
+		return
+	}
+
+	if err := database.AddProfanityAllowlistWord(r.Context(), database.GocentralDatabase, word); err != nil {
+		log.Printf("ERROR: could not add %q to the profanity allowlist: %v", word, err)
+		sendError(w, http.StatusInternalServerError, "Failed to update profanity allowlist")
+		return
+	}
+
+	log.Printf("Added %q to the profanity allowlist", word)
+	sendJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"word":    word,
+	})
+}
+
+// Removes a word from the profanity allowlist.
+// Requires a valid admin API token in the Authorization header.
+func RemoveProfanityAllowlistHandler(w http.ResponseWriter, r *http.Request) {
+	var req ProfanityAllowlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	word := strings.TrimSpace(req.Word)
+	if word == "" {
+		sendError(w, http.StatusBadRequest, "Word is required")
+		return
+	}
+
+	removed, err := database.RemoveProfanityAllowlistWord(r.Context(), database.GocentralDatabase, word)
+	if err != nil {
+		log.Printf("ERROR: could not remove %q from the profanity allowlist: %v", word, err)
+		sendError(w, http.StatusInternalServerError, "Failed to update profanity allowlist")
+		return
+	}
+	if !removed {
+		sendError(w, http.StatusNotFound, "Word is not on the profanity allowlist")
+		return
+	}
+
+	log.Printf("Removed %q from the profanity allowlist", word)
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// Runs a string through the profanity filter, showing what matched and what players would see.
+// Requires a valid admin API token in the Authorization header.
+func TestProfanityHandler(w http.ResponseWriter, r *http.Request) {
+	var req ProfanityTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	result, err := database.FilterText(r.Context(), req.Text)
+	if err != nil {
+		log.Printf("ERROR: could not get profanity filter: %v", err)
+		sendError(w, http.StatusInternalServerError, "Could not retrieve profanity filter")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
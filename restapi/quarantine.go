@@ -0,0 +1,165 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	database "rb3server/database"
+	"rb3server/models"
+)
+
+type QuarantinedScoreEntry struct {
+	QuarantineID  int                  `json:"quarantine_id"`
+	Status        string               `json:"status"`
+	Reasons       []string             `json:"reasons"`
+	QuarantinedAt int64                `json:"quarantined_at"`
+	ReviewedAt    int64                `json:"reviewed_at"`
+	ReviewNote    string               `json:"review_note"`
+	Score         ScoreProvenanceEntry `json:"score"`
+}
+
+type QuarantineReviewRequest struct {
+	Note string `json:"note"`
+}
+
+// Lists quarantined scores, newest first. Defaults to scores still waiting on review, use ?status=approved or ?status=rejected to see reviewed ones.
+// Requires a valid admin API token in the Authorization header.
+func QuarantineListHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "pending"
+	}
+	if status != "pending" && status != "approved" && status != "rejected" {
+		sendError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	page, pageSize, ok := getPagination(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	quarantineCollection := database.GocentralDatabase.Collection("quarantined_scores")
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "quarantine_id", Value: -1}}).
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+
+	cursor, err := quarantineCollection.Find(ctx, bson.M{"status": status}, findOptions)
+	if err != nil {
+		log.Printf("ERROR: could not query quarantined scores: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to query quarantined scores")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var quarantined []models.QuarantinedScore
+	if err := cursor.All(ctx, &quarantined); err != nil {
+		log.Printf("ERROR: could not decode quarantined scores: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to read quarantined scores")
+		return
+	}
+
+	pids := make([]int, 0, len(quarantined))
+	for _, q := range quarantined {
+		pids = append(pids, q.Score.OwnerPID)
+	}
+
+	userNameMap, err := database.GetConsolePrefixedUsernamesByPIDs(ctx, database.GocentralDatabase, pids)
+	if err != nil {
+		log.Println("Error fetching usernames:", err)
+		userNameMap = make(map[int]string)
+	}
+
+	entries := []QuarantinedScoreEntry{}
+	for _, q := range quarantined {
+		name, ok := userNameMap[q.Score.OwnerPID]
+		if !ok {
+			name = "Unnamed Player"
+		}
+
+		entries = append(entries, QuarantinedScoreEntry{
+			QuarantineID:  q.QuarantineID,
+			Status:        q.Status,
+			Reasons:       q.Reasons,
+			QuarantinedAt: q.QuarantinedAt,
+			ReviewedAt:    q.ReviewedAt,
+			ReviewNote:    q.ReviewNote,
+			Score:         newScoreProvenanceEntry(q.Score, name),
+		})
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]QuarantinedScoreEntry{"scores": entries})
+}
+
+// Approves a quarantined score, putting it on the leaderboards.
+// Requires a valid admin API token in the Authorization header.
+func ApproveQuarantinedScoreHandler(w http.ResponseWriter, r *http.Request) {
+	reviewQuarantinedScore(w, r, database.ApproveQuarantinedScore, "approved")
+}
+
+// Rejects a quarantined score, keeping it off the leaderboards.
+// Requires a valid admin API token in the Authorization header.
+func RejectQuarantinedScoreHandler(w http.ResponseWriter, r *http.Request) {
+	reviewQuarantinedScore(w, r, database.RejectQuarantinedScore, "rejected")
+}
+
+type quarantineReviewFunc func(ctx context.Context, db *mongo.Database, quarantined *models.QuarantinedScore, note string) error
+
+func reviewQuarantinedScore(w http.ResponseWriter, r *http.Request, review quarantineReviewFunc, action string) {
+	quarantineID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid quarantine ID")
+		return
+	}
+
+	// the note is optional, so an empty body is fine
+	var req QuarantineReviewRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	quarantined, err := database.GetQuarantinedScore(ctx, database.GocentralDatabase, quarantineID)
+	if err != nil {
+		log.Printf("ERROR: could not get quarantined score %d: %v", quarantineID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query quarantined score")
+		return
+	}
+	if quarantined == nil {
+		sendError(w, http.StatusNotFound, "Quarantined score not found")
+		return
+	}
+	if quarantined.Status != "pending" {
+		sendError(w, http.StatusConflict, "Quarantined score has already been reviewed")
+		return
+	}
+
+	if err := review(ctx, database.GocentralDatabase, quarantined, req.Note); err != nil {
+		log.Printf("ERROR: could not review quarantined score %d: %v", quarantineID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to review quarantined score")
+		return
+	}
+
+	log.Printf("Quarantined score %d for PID %d was %s", quarantineID, quarantined.Score.OwnerPID, action)
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":       true,
+		"quarantine_id": quarantineID,
+		"status":        action,
+	})
+}
@@ -26,16 +26,17 @@ var (
 )
 
 type Stats struct {
-	Scores                     int64   `json:"scores"`
-	Machines                   int64   `json:"machines"`
-	Setlists                   int64   `json:"setlists"`
-	Characters                 int64   `json:"characters"`
-	Bands                      int64   `json:"bands"`
-	ActiveGatherings           int64   `json:"active_gatherings"`
-	ActiveGatheringsPS3        int64   `json:"active_gatherings_ps3"`
-	ActiveGatheringsWii        int64   `json:"active_gatherings_wii"`
-	MostPopularSongIDs         []int   `json:"most_popular_song_ids"`
-	MostPopularSongScoreCounts []int64 `json:"most_popular_song_score_counts"`
+	Scores                     int64    `json:"scores"`
+	Machines                   int64    `json:"machines"`
+	Setlists                   int64    `json:"setlists"`
+	Characters                 int64    `json:"characters"`
+	Bands                      int64    `json:"bands"`
+	ActiveGatherings           int64    `json:"active_gatherings"`
+	ActiveGatheringsPS3        int64    `json:"active_gatherings_ps3"`
+	ActiveGatheringsWii        int64    `json:"active_gatherings_wii"`
+	MostPopularSongIDs         []int    `json:"most_popular_song_ids"`
+	MostPopularSongScoreCounts []int64  `json:"most_popular_song_score_counts"`
+	MostPopularSongNames       []string `json:"most_popular_song_names"`
 }
 
 type LeaderboardEntry struct {
@@ -51,6 +52,7 @@ type LeaderboardEntry struct {
 	PGUID        string `json:"pguid"`
 	ORank        int    `json:"orank"`
 	Stars        int    `json:"stars"`
+	SongName     string `json:"song_name,omitempty"`
 }
 
 type BattleLeaderboardEntry struct {
@@ -62,22 +64,24 @@ type BattleLeaderboardEntry struct {
 }
 
 type GlobalBattleInfo struct {
-	BattleID    int    `json:"battle_id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	StartsAt    int64  `json:"starts_at"`
-	ExpiresAt   int64  `json:"expires_at"`
-	Instrument  int    `json:"instrument"`
-	SongIDs     []int  `json:"song_ids"`
+	BattleID    int      `json:"battle_id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	StartsAt    int64    `json:"starts_at"`
+	ExpiresAt   int64    `json:"expires_at"`
+	Instrument  int      `json:"instrument"`
+	SongIDs     []int    `json:"song_ids"`
+	SongNames   []string `json:"song_names"`
 }
 
 type CreateBattleRequest struct {
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	SongIDs     []int     `json:"song_ids"`
-	ExpiresAt   time.Time `json:"expires_at"`
-	Instrument  int       `json:"instrument"`
-	Flags       int       `json:"flags"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	SongIDs     []int      `json:"song_ids"`
+	StartsAt    *time.Time `json:"starts_at"` // optional, defaults to now
+	ExpiresAt   time.Time  `json:"expires_at"`
+	Instrument  int        `json:"instrument"`
+	Flags       int        `json:"flags"`
 }
 
 type DeleteBattleRequest struct {
@@ -112,6 +116,16 @@ func AddStandardHeaders(writer http.ResponseWriter) {
 
 // Checks for a valid API token in the Authorization header.
 func AdminTokenAuth(next http.Handler) http.Handler {
+	return tokenAuth(next, func(config *models.Config) string { return config.AdminAPIToken })
+}
+
+// Middleware for the endpoints the website uses on behalf of players signed in to it, which checks for the web API token in the config.
+func WebTokenAuth(next http.Handler) http.Handler {
+	return tokenAuth(next, func(config *models.Config) string { return config.WebAPIToken })
+}
+
+// checks the bearer token in the Authorization header against a token from the config, rejecting everything if that token isn't set
+func tokenAuth(next http.Handler, configToken func(config *models.Config) string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 
@@ -130,7 +144,8 @@ func AdminTokenAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		if config.AdminAPIToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(config.AdminAPIToken)) != 1 {
+		expected := configToken(config)
+		if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
 			sendError(w, http.StatusInternalServerError, "Could not verify authorization")
 			return
 		}
@@ -263,10 +278,17 @@ func StatsHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		mu.Lock()
+		songIDs := make([]int, 0, len(mostScoredSongs))
 		for _, song := range mostScoredSongs {
+			songIDs = append(songIDs, song.ID)
+		}
+		songNames := database.GetSongNamesInOrder(ctx, database.GocentralDatabase, songIDs)
+
+		mu.Lock()
+		for i, song := range mostScoredSongs {
 			stats.MostPopularSongIDs = append(stats.MostPopularSongIDs, song.ID)
 			stats.MostPopularSongScoreCounts = append(stats.MostPopularSongScoreCounts, song.Count)
+			stats.MostPopularSongNames = append(stats.MostPopularSongNames, songNames[i])
 		}
 		mu.Unlock()
 	}()
@@ -361,8 +383,8 @@ func BattleListHandler(w http.ResponseWriter, r *http.Request) {
 	setlistsCollection := database.GocentralDatabase.Collection("setlists")
 	ctx := r.Context()
 
-	// filter for Harmonix battles
-	filter := bson.M{"type": 1002}
+	// filter for Harmonix battles, leaving out any an admin has hidden
+	filter := bson.M{"type": 1002, "hidden": bson.M{"$ne": true}}
 	cursor, err := setlistsCollection.Find(ctx, filter)
 	if err != nil {
 		log.Printf("ERROR: could not query global battles: %v", err)
@@ -391,6 +413,7 @@ func BattleListHandler(w http.ResponseWriter, r *http.Request) {
 			ExpiresAt:   expiresAt.Unix(), // give unix time for expiry rather than some kind of string
 			Instrument:  setlist.Instrument,
 			SongIDs:     setlist.SongIDs,
+			SongNames:   setlist.SongNames,
 		}
 		battles = append(battles, battleInfo)
 	}
@@ -404,6 +427,42 @@ func BattleListHandler(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, http.StatusOK, map[string][]GlobalBattleInfo{"battles": battles})
 }
 
+// console types that can be passed as the platform query parameter, by name or by number
+var platformConsoleTypes = map[string]int{
+	"xbox":  0,
+	"ps3":   1,
+	"wii":   2,
+	"rpcs3": 3,
+	"0":     0,
+	"1":     1,
+	"2":     2,
+	"3":     3,
+}
+
+// parses the optional platform query parameter into the PIDs of everyone on that platform
+// returns nil PIDs if no platform was given, and false if the platform was invalid or the lookup failed (an error has already been sent)
+func getPlatformPIDs(w http.ResponseWriter, r *http.Request) ([]int, bool) {
+	platform := r.URL.Query().Get("platform")
+	if platform == "" {
+		return nil, true
+	}
+
+	consoleType, ok := platformConsoleTypes[strings.ToLower(platform)]
+	if !ok {
+		sendError(w, http.StatusBadRequest, "Invalid platform")
+		return nil, false
+	}
+
+	pids, err := database.GetPIDListByConsoleType(r.Context(), database.GocentralDatabase, consoleType)
+	if err != nil {
+		log.Printf("ERROR: could not get PIDs for console type %d: %v", consoleType, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query leaderboard data")
+		return nil, false
+	}
+
+	return pids, true
+}
+
 func LeaderboardHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	AddStandardHeaders(w)
@@ -448,13 +507,23 @@ func LeaderboardHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	platformPIDs, ok := getPlatformPIDs(w, r)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{"song_id": songID, "role_id": roleID}
+	if platformPIDs != nil {
+		filter["pid"] = bson.M{"$in": platformPIDs}
+	}
+
 	skip := int64((page - 1) * pageSize)
 	limit := int64(pageSize)
 	scoresCollection := database.GocentralDatabase.Collection("scores")
 
 	// Find scores for the song and role ID, sorted by score descending
 	findOptions := options.Find().SetSort(bson.M{"score": -1}).SetSkip(skip).SetLimit(limit)
-	cursor, err := scoresCollection.Find(context.TODO(), bson.M{"song_id": songID, "role_id": roleID}, findOptions)
+	cursor, err := scoresCollection.Find(context.TODO(), filter, findOptions)
 	if err != nil {
 		sendError(w, http.StatusInternalServerError, "Failed to query leaderboard data")
 		return
@@ -495,6 +564,15 @@ func LeaderboardHandler(w http.ResponseWriter, r *http.Request) {
 		userNameMap = make(map[int]string)
 	}
 
+	// every entry is for the same song so this only needs the one lookup
+	songName := ""
+	song, err := database.GetSongByID(ctx, database.GocentralDatabase, songID)
+	if err != nil {
+		log.Println("Error fetching song name:", err)
+	} else if song != nil {
+		songName = song.Title
+	}
+
 	// use cached stuff
 	// TODO: this is a bit messy
 	var leaderboard []LeaderboardEntry
@@ -531,6 +609,7 @@ func LeaderboardHandler(w http.ResponseWriter, r *http.Request) {
 			PGUID:        "",
 			ORank:        rank,
 			Stars:        score.Stars,
+			SongName:     songName,
 		}
 		leaderboard = append(leaderboard, entry)
 		rank++
@@ -580,12 +659,22 @@ func BattleLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	platformPIDs, ok := getPlatformPIDs(w, r)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{"battle_id": battleID}
+	if platformPIDs != nil {
+		filter["pid"] = bson.M{"$in": platformPIDs}
+	}
+
 	skip := int64((page - 1) * pageSize)
 	limit := int64(pageSize)
 	scoresCollection := database.GocentralDatabase.Collection("scores")
 
 	findOptions := options.Find().SetSort(bson.M{"score": -1}).SetSkip(skip).SetLimit(limit)
-	cursor, err := scoresCollection.Find(context.TODO(), bson.M{"battle_id": battleID}, findOptions)
+	cursor, err := scoresCollection.Find(context.TODO(), filter, findOptions)
 	if err != nil {
 		sendError(w, http.StatusInternalServerError, "Failed to query battle leaderboard data")
 		return
@@ -685,43 +774,31 @@ func CreateBattleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
-
-	// race condition prevention
-	newBattleID, err := database.GetNextSetlistID(ctx)
-	if err != nil {
-		log.Printf("ERROR: Could not get next setlist ID: %v", err)
-		sendError(w, http.StatusInternalServerError, "Could not generate battle ID")
-		return
+	// battles can be scheduled to start later, until then they are hidden from players
+	startsAt := time.Now()
+	if req.StartsAt != nil && req.StartsAt.After(startsAt) {
+		startsAt = *req.StartsAt
 	}
 
 	// calculate the expiry duration, in seconds
-	durationSeconds := int(time.Until(req.ExpiresAt).Seconds())
+	durationSeconds := int(req.ExpiresAt.Sub(startsAt).Seconds())
 	if durationSeconds <= 0 {
-		sendError(w, http.StatusBadRequest, "expires_at must be in the future")
+		sendError(w, http.StatusBadRequest, "expires_at must be after starts_at")
 		return
 	}
 
-	setlistCollection := database.GocentralDatabase.Collection("setlists")
+	ctx := r.Context()
 
-	newBattle := models.Setlist{
-		SetlistID:    newBattleID,
-		PID:          0,
+	newBattleID, err := database.InsertHarmonixBattle(ctx, database.GocentralDatabase, models.Setlist{
 		Title:        req.Title,
 		Desc:         req.Description,
-		Type:         1002,
-		Owner:        "Harmonix",
-		Shared:       "t",
 		SongIDs:      req.SongIDs,
-		SongNames:    make([]string, len(req.SongIDs)),
 		TimeEndVal:   durationSeconds,
 		TimeEndUnits: "seconds",
 		Flags:        req.Flags,
 		Instrument:   req.Instrument,
-		Created:      time.Now().Unix(),
-	}
-
-	_, err = setlistCollection.InsertOne(ctx, newBattle)
+		Created:      startsAt.Unix(),
+	})
 	if err != nil {
 		log.Printf("Could not insert new battle: %v", err)
 		sendError(w, http.StatusInternalServerError, "Failed to create battle")
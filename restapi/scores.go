@@ -0,0 +1,202 @@
+package restapi
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	database "rb3server/database"
+	"rb3server/models"
+)
+
+type ScoreProvenanceEntry struct {
+	PID            int    `json:"pid"`
+	Name           string `json:"name"`
+	SongID         int    `json:"song_id"`
+	RoleID         int    `json:"role_id"`
+	BattleID       int    `json:"battle_id"`
+	Score          int    `json:"score"`
+	Stars          int    `json:"stars"`
+	DiffID         int    `json:"diff_id"`
+	NotesPct       int    `json:"notes_pct"`
+	InstrumentMask int    `json:"inst_mask"`
+	SubmittedAt    int64  `json:"submitted_at"`
+	ConsoleType    int    `json:"console_type"`
+	MachineID      string `json:"machine_id"`
+	SessionGUID    string `json:"session_guid"`
+	Region         string `json:"region"`
+	BandPIDs       []int  `json:"band_pids"`
+}
+
+func newScoreProvenanceEntry(score models.Score, name string) ScoreProvenanceEntry {
+	return ScoreProvenanceEntry{
+		PID:            score.OwnerPID,
+		Name:           name,
+		SongID:         score.SongID,
+		RoleID:         score.RoleID,
+		BattleID:       score.BattleID,
+		Score:          score.Score,
+		Stars:          score.Stars,
+		DiffID:         score.DiffID,
+		NotesPct:       score.NotesPercent,
+		InstrumentMask: score.InstrumentMask,
+		SubmittedAt:    score.SubmittedAt,
+		ConsoleType:    score.ConsoleType,
+		MachineID:      score.MachineID,
+		SessionGUID:    score.SessionGUID,
+		Region:         score.Region,
+		BandPIDs:       score.BandPIDs,
+	}
+}
+
+// parses the optional page and page_size query parameters, using the same limits as the leaderboard endpoints
+func getPagination(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	page := 1
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		var err error
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			sendError(w, http.StatusBadRequest, "Invalid page number")
+			return 0, 0, false
+		}
+	}
+
+	pageSize := 20
+	if pageSizeStr := r.URL.Query().Get("page_size"); pageSizeStr != "" {
+		var err error
+		pageSize, err = strconv.Atoi(pageSizeStr)
+		if err != nil || pageSize < 1 || pageSize > 100 {
+			sendError(w, http.StatusBadRequest, "Invalid page_size")
+			return 0, 0, false
+		}
+	}
+
+	return page, pageSize, true
+}
+
+// Searches stored scores by their provenance so suspicious scores can be investigated.
+// All filters are optional and combined, e.g. ?username=foo&machine_id=bar&since=1700000000
+// Requires a valid admin API token in the Authorization header.
+func ScoreSearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := bson.M{}
+
+	// both name a player, so allowing both would mean one quietly replacing the other
+	if query.Get("username") != "" && query.Get("pid") != "" {
+		sendError(w, http.StatusBadRequest, "Use either username or pid, not both")
+		return
+	}
+
+	if username := query.Get("username"); username != "" {
+		pid := database.GetPIDForUsername(username)
+		if pid == 0 {
+			sendError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		filter["pid"] = pid
+	}
+
+	// integer filters map straight onto score fields
+	intFilters := map[string]string{
+		"pid":          "pid",
+		"song_id":      "song_id",
+		"role_id":      "role_id",
+		"battle_id":    "battle_id",
+		"console_type": "console_type",
+		"band_pid":     "band_pids",
+	}
+	for param, field := range intFilters {
+		valueStr := query.Get(param)
+		if valueStr == "" {
+			continue
+		}
+		value, err := strconv.Atoi(valueStr)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid "+param)
+			return
+		}
+		filter[field] = value
+	}
+
+	for _, field := range []string{"machine_id", "session_guid", "region"} {
+		if value := query.Get(field); value != "" {
+			filter[field] = value
+		}
+	}
+
+	// since and until are unix timestamps bounding submitted_at
+	submittedAtFilter := bson.M{}
+	if sinceStr := query.Get("since"); sinceStr != "" {
+		since, err := strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid since")
+			return
+		}
+		submittedAtFilter["$gte"] = since
+	}
+	if untilStr := query.Get("until"); untilStr != "" {
+		until, err := strconv.ParseInt(untilStr, 10, 64)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid until")
+			return
+		}
+		submittedAtFilter["$lte"] = until
+	}
+	if len(submittedAtFilter) > 0 {
+		filter["submitted_at"] = submittedAtFilter
+	}
+
+	page, pageSize, ok := getPagination(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	scoresCollection := database.GocentralDatabase.Collection("scores")
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "submitted_at", Value: -1}}).
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+
+	cursor, err := scoresCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		log.Printf("ERROR: could not search scores: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to query scores")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var scores []models.Score
+	if err := cursor.All(ctx, &scores); err != nil {
+		log.Printf("ERROR: could not decode scores: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to read scores")
+		return
+	}
+
+	pids := make([]int, 0, len(scores))
+	for _, score := range scores {
+		pids = append(pids, score.OwnerPID)
+	}
+
+	userNameMap, err := database.GetConsolePrefixedUsernamesByPIDs(ctx, database.GocentralDatabase, pids)
+	if err != nil {
+		log.Println("Error fetching usernames:", err)
+		userNameMap = make(map[int]string)
+	}
+
+	entries := []ScoreProvenanceEntry{}
+	for _, score := range scores {
+		name, ok := userNameMap[score.OwnerPID]
+		if !ok {
+			name = "Unnamed Player"
+		}
+
+		entries = append(entries, newScoreProvenanceEntry(score, name))
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]ScoreProvenanceEntry{"scores": entries})
+}
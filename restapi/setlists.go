@@ -0,0 +1,150 @@
+package restapi
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	database "rb3server/database"
+	"rb3server/models"
+)
+
+// the orders shared setlists can be browsed in
+var setlistSortOrders = map[string]bson.D{
+	"popular":   {{Key: "sync_count", Value: -1}, {Key: "follower_count", Value: -1}, {Key: "setlist_id", Value: -1}},
+	"followers": {{Key: "follower_count", Value: -1}, {Key: "sync_count", Value: -1}, {Key: "setlist_id", Value: -1}},
+	"newest":    {{Key: "created", Value: -1}, {Key: "setlist_id", Value: -1}},
+}
+
+// a shared setlist as anyone browsing setlists sees it
+type SetlistInfo struct {
+	SetlistID     int      `json:"setlist_id"`
+	GUID          string   `json:"guid"`
+	Title         string   `json:"title"`
+	Description   string   `json:"description"`
+	Type          int      `json:"type"`
+	Owner         string   `json:"owner"`
+	SongIDs       []int    `json:"song_ids"`
+	SongNames     []string `json:"song_names"`
+	CreatedAt     int64    `json:"created_at"`
+	SyncCount     int      `json:"sync_count"`
+	FollowerCount int      `json:"follower_count"`
+}
+
+func newSetlistInfo(setlist models.Setlist) SetlistInfo {
+	return SetlistInfo{
+		SetlistID:     setlist.SetlistID,
+		GUID:          setlist.GUID,
+		Title:         setlist.Title,
+		Description:   setlist.Desc,
+		Type:          setlist.Type,
+		Owner:         setlist.Owner,
+		SongIDs:       setlist.SongIDs,
+		SongNames:     setlist.SongNames,
+		CreatedAt:     setlist.Created,
+		SyncCount:     setlist.SyncCount,
+		FollowerCount: setlist.FollowerCount,
+	}
+}
+
+// Searches and browses shared setlists from every player, not just friends.
+// All filters are optional and combined, e.g. ?title=metal&owner=foo&song_id=1048
+// ?sort=popular (default, by how often the setlist is synced), followers or newest
+func SetlistSearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := database.DiscoverableSetlistsFilter(time.Now())
+
+	if title := query.Get("title"); title != "" {
+		filter["title"] = bson.M{"$regex": regexp.QuoteMeta(title), "$options": "i"}
+	}
+
+	if owner := query.Get("owner"); owner != "" {
+		filter["owner"] = bson.M{"$regex": "^" + regexp.QuoteMeta(owner) + "$", "$options": "i"}
+	}
+
+	if songIDStr := query.Get("song_id"); songIDStr != "" {
+		songID, err := strconv.Atoi(songIDStr)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid song_id")
+			return
+		}
+		filter["s_ids"] = songID
+	}
+
+	sortName := query.Get("sort")
+	if sortName == "" {
+		sortName = "popular"
+	}
+	sort, ok := setlistSortOrders[sortName]
+	if !ok {
+		sendError(w, http.StatusBadRequest, "Invalid sort, must be popular, followers or newest")
+		return
+	}
+
+	page, pageSize, ok := getPagination(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	findOptions := options.Find().
+		SetSort(sort).
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+
+	cursor, err := database.GocentralDatabase.Collection("setlists").Find(ctx, filter, findOptions)
+	if err != nil {
+		log.Printf("ERROR: could not search setlists: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to search setlists")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var setlists []models.Setlist
+	if err := cursor.All(ctx, &setlists); err != nil {
+		log.Printf("ERROR: could not decode setlists: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to read setlists")
+		return
+	}
+
+	results := []SetlistInfo{}
+	for _, setlist := range setlists {
+		results = append(results, newSetlistInfo(setlist))
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]SetlistInfo{"setlists": results})
+}
+
+// Returns a single shared setlist by its ID.
+func SetlistHandler(w http.ResponseWriter, r *http.Request) {
+	setlistID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid setlist ID")
+		return
+	}
+
+	filter := database.DiscoverableSetlistsFilter(time.Now())
+	filter["setlist_id"] = setlistID
+
+	var setlist models.Setlist
+	err = database.GocentralDatabase.Collection("setlists").FindOne(r.Context(), filter).Decode(&setlist)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			sendError(w, http.StatusNotFound, "Setlist not found")
+			return
+		}
+		log.Printf("ERROR: could not get setlist %d: %v", setlistID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query setlist")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, newSetlistInfo(setlist))
+}
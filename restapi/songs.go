@@ -0,0 +1,239 @@
+package restapi
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	database "rb3server/database"
+	"rb3server/models"
+	"rb3server/serialization/dta"
+)
+
+// the full on-disc songs.dta is a bit over 1MB, so this leaves plenty of room for DLC and RBN packs
+const maxSongImportSize = 16 << 20
+
+type SongImportResponse struct {
+	Parsed   int `json:"parsed"`
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+}
+
+// Searches the song catalog. All filters are optional and case-insensitive substring matches, e.g. ?q=foo&artist=bar&source=dlc
+// q matches against the title, artist, album or shortname.
+func SongSearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := bson.M{}
+
+	if q := query.Get("q"); q != "" {
+		pattern := bson.M{"$regex": regexp.QuoteMeta(q), "$options": "i"}
+		filter["$or"] = bson.A{
+			bson.M{"title": pattern},
+			bson.M{"artist": pattern},
+			bson.M{"album": pattern},
+			bson.M{"shortname": pattern},
+		}
+	}
+
+	for _, field := range []string{"artist", "genre"} {
+		if value := query.Get(field); value != "" {
+			filter[field] = bson.M{"$regex": regexp.QuoteMeta(value), "$options": "i"}
+		}
+	}
+
+	if source := query.Get("source"); source != "" {
+		if source != "rb3" && source != "dlc" && source != "rbn" {
+			sendError(w, http.StatusBadRequest, "Invalid source")
+			return
+		}
+		filter["source"] = source
+	}
+
+	page, pageSize, ok := getPagination(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	songsCollection := database.GocentralDatabase.Collection("songs")
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "song_id", Value: 1}}).
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+
+	cursor, err := songsCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		log.Printf("ERROR: could not search songs: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to query songs")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	songs := []models.Song{}
+	if err := cursor.All(ctx, &songs); err != nil {
+		log.Printf("ERROR: could not decode songs: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to read songs")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]models.Song{"songs": songs})
+}
+
+// Returns a single song from the catalog by its song ID.
+func SongHandler(w http.ResponseWriter, r *http.Request) {
+	songID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid song ID")
+		return
+	}
+
+	song, err := database.GetSongByID(r.Context(), database.GocentralDatabase, songID)
+	if err != nil {
+		log.Printf("ERROR: could not get song %d: %v", songID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to query song")
+		return
+	}
+	if song == nil {
+		sendError(w, http.StatusNotFound, "Song not found")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, song)
+}
+
+// Imports song metadata into the song catalog from a songs.dta file sent as the raw request body.
+// Songs are keyed by song ID, so importing a song that already exists replaces it.
+// Requires a valid admin API token in the Authorization header.
+func ImportSongsHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSongImportSize))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Could not read request body")
+		return
+	}
+
+	songs, err := dta.SongsDeserializer{}.Deserialize(body)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(songs) == 0 {
+		sendError(w, http.StatusBadRequest, "No songs found in DTA")
+		return
+	}
+
+	inserted, updated, err := database.UpsertSongs(r.Context(), database.GocentralDatabase, songs)
+	if err != nil {
+		log.Printf("ERROR: could not import songs: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to import songs")
+		return
+	}
+
+	log.Printf("Imported %d songs into the song catalog (%d new, %d updated)", len(songs), inserted, updated)
+
+	sendJSON(w, http.StatusOK, SongImportResponse{
+		Parsed:   len(songs),
+		Inserted: inserted,
+		Updated:  updated,
+	})
+}
+
+type SongLimitsImportEntry struct {
+	SongID         int   `json:"song_id"`
+	RoleID         int   `json:"role_id"`
+	DiffID         int   `json:"diff_id"`
+	MaxScore       *int  `json:"max_score"`
+	NoteCount      *int  `json:"note_count"`
+	StarThresholds []int `json:"star_thresholds"`
+}
+
+type SongLimitsImportRequest struct {
+	Limits []SongLimitsImportEntry `json:"limits"`
+}
+
+// Imports plausibility limits for songs in the catalog. Every part of a song that is included replaces all of that song's existing limits,
+// so a song should be imported with all of its parts at once. Songs have to be imported from a songs.dta first.
+// Requires a valid admin API token in the Authorization header.
+func ImportSongLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	var req SongLimitsImportRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSongImportSize)).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.Limits) == 0 {
+		sendError(w, http.StatusBadRequest, "limits are required")
+		return
+	}
+
+	// group the parts by song, keeping the order songs first appeared in
+	limitsBySong := make(map[int][]models.SongLimits)
+	songIDs := []int{}
+	for _, entry := range req.Limits {
+		// a missing note count would otherwise look like the part doesn't exist, which would quarantine every score on it
+		if entry.MaxScore == nil || entry.NoteCount == nil {
+			sendError(w, http.StatusBadRequest, "max_score and note_count are required for every part")
+			return
+		}
+		if entry.RoleID < 0 || entry.RoleID > 10 || entry.DiffID < 0 || entry.DiffID > 4 {
+			sendError(w, http.StatusBadRequest, "Invalid role_id or diff_id")
+			return
+		}
+		if len(entry.StarThresholds) > 6 {
+			sendError(w, http.StatusBadRequest, "star_thresholds can have at most 6 entries")
+			return
+		}
+
+		if _, ok := limitsBySong[entry.SongID]; !ok {
+			songIDs = append(songIDs, entry.SongID)
+		}
+		limitsBySong[entry.SongID] = append(limitsBySong[entry.SongID], models.SongLimits{
+			RoleID:         entry.RoleID,
+			DiffID:         entry.DiffID,
+			MaxScore:       *entry.MaxScore,
+			NoteCount:      *entry.NoteCount,
+			StarThresholds: entry.StarThresholds,
+		})
+	}
+
+	ctx := r.Context()
+	updatedSongIDs := []int{}
+	unknownSongIDs := []int{}
+
+	for _, songID := range songIDs {
+		found, err := database.SetSongLimits(ctx, database.GocentralDatabase, songID, limitsBySong[songID])
+		if err != nil {
+			log.Printf("ERROR: could not set limits for song %d: %v", songID, err)
+			sendError(w, http.StatusInternalServerError, "Failed to import song limits")
+			return
+		}
+		if !found {
+			unknownSongIDs = append(unknownSongIDs, songID)
+			continue
+		}
+		updatedSongIDs = append(updatedSongIDs, songID)
+	}
+
+	// scores already on the leaderboards for these songs were never checked against the new limits
+	quarantined := 0
+	if len(updatedSongIDs) > 0 {
+		quarantined = database.QuarantineImplausibleScores(ctx, updatedSongIDs)
+	}
+
+	log.Printf("Imported limits for %d songs (%d not in the song catalog), quarantined %d implausible scores", len(updatedSongIDs), len(unknownSongIDs), quarantined)
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":            true,
+		"songs_updated":      len(updatedSongIDs),
+		"unknown_song_ids":   unknownSongIDs,
+		"scores_quarantined": quarantined,
+	})
+}
@@ -0,0 +1,13 @@
+package restapi
+
+import (
+	"net/http"
+
+	"rb3server/authentication"
+)
+
+// Lists how many tickets each verifier has accepted, rejected or couldn't check, per console type. The "chain" verifier is the final verdict for each ticket, including ones accepted from the cache.
+// Requires a valid admin API token in the Authorization header.
+func TicketVerificationMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, http.StatusOK, map[string][]authentication.VerifierMetric{"metrics": authentication.DefaultVerifierMetrics.Snapshot()})
+}
@@ -0,0 +1,124 @@
+package restapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	database "rb3server/database"
+)
+
+type LinkAccountRequest struct {
+	Code        string `json:"code"`
+	WebIdentity string `json:"web_identity"` // whatever the website identifies the signed-in player by
+}
+
+type UnlinkAccountRequest struct {
+	PID         int    `json:"pid"`
+	WebIdentity string `json:"web_identity"`
+}
+
+type LinkedAccount struct {
+	PID         int       `json:"pid"`
+	Username    string    `json:"username"`
+	ConsoleType int       `json:"console_type"`
+	LinkedAt    time.Time `json:"linked_at"`
+}
+
+// Links the game account a link code was shown to with the website's signed-in player. Codes can only be used once.
+// Requires a valid web API token in the Authorization header.
+func LinkAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var req LinkAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Code == "" || req.WebIdentity == "" {
+		sendError(w, http.StatusBadRequest, "code and web_identity are required")
+		return
+	}
+
+	user, err := database.RedeemLinkCode(r.Context(), database.GocentralDatabase, req.Code, req.WebIdentity)
+	if err == database.ErrInvalidLinkCode {
+		sendError(w, http.StatusNotFound, "Link code is invalid or has expired")
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: could not redeem link code: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to link account")
+		return
+	}
+
+	log.Printf("Linked %s (PID %d) to web account %s", user.Username, user.PID, req.WebIdentity)
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"pid":      user.PID,
+		"username": user.Username,
+	})
+}
+
+// Lists the game accounts linked to one of the website's players.
+// Requires a valid web API token in the Authorization header.
+func LinkedAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	webIdentity := r.URL.Query().Get("web_identity")
+	if webIdentity == "" {
+		sendError(w, http.StatusBadRequest, "web_identity is required")
+		return
+	}
+
+	users, err := database.GetUsersForWebIdentity(r.Context(), database.GocentralDatabase, webIdentity)
+	if err != nil {
+		log.Printf("ERROR: could not get accounts linked to %s: %v", webIdentity, err)
+		sendError(w, http.StatusInternalServerError, "Failed to get linked accounts")
+		return
+	}
+
+	accounts := make([]LinkedAccount, 0, len(users))
+	for _, user := range users {
+		accounts = append(accounts, LinkedAccount{
+			PID:         int(user.PID),
+			Username:    user.Username,
+			ConsoleType: user.ConsoleType,
+			LinkedAt:    user.WebLinkedAt,
+		})
+	}
+
+	sendJSON(w, http.StatusOK, map[string][]LinkedAccount{"accounts": accounts})
+}
+
+// Unlinks a game account from one of the website's players. The game shows a new link code the next time it asks for one.
+// Requires a valid web API token in the Authorization header.
+func UnlinkAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var req UnlinkAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.PID == 0 || req.WebIdentity == "" {
+		sendError(w, http.StatusBadRequest, "pid and web_identity are required")
+		return
+	}
+
+	unlinked, err := database.UnlinkWebIdentity(r.Context(), database.GocentralDatabase, req.PID, req.WebIdentity)
+	if err != nil {
+		log.Printf("ERROR: could not unlink PID %d from %s: %v", req.PID, req.WebIdentity, err)
+		sendError(w, http.StatusInternalServerError, "Failed to unlink account")
+		return
+	}
+
+	if !unlinked {
+		sendError(w, http.StatusNotFound, "Account is not linked to this web account")
+		return
+	}
+
+	log.Printf("Unlinked PID %d from web account %s", req.PID, req.WebIdentity)
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"pid":     req.PID,
+	})
+}
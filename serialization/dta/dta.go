@@ -0,0 +1,235 @@
+package dta
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DTA is the plain-text s-expression format Harmonix uses for most of their data files, including songs.dta
+// this only implements enough of it to read metadata out of song files, preprocessor directives are skipped and macros are not expanded
+
+// a bare or 'quoted' symbol, kept distinct from strings since DTA treats them differently
+type Symbol string
+
+// an array, either (parenthesized), {a command} or [a macro reference]
+// elements are one of int, float64, string, Symbol or Array
+type Array []interface{}
+
+// returns the first element of the array as a key if it is a symbol, e.g. "name" for (name "Song Title")
+func (a Array) Key() string {
+	if len(a) == 0 {
+		return ""
+	}
+	if sym, ok := a[0].(Symbol); ok {
+		return string(sym)
+	}
+	return ""
+}
+
+// finds the first child array with the given key
+func (a Array) Find(key string) (Array, bool) {
+	for _, node := range a {
+		if child, ok := node.(Array); ok && child.Key() == key {
+			return child, true
+		}
+	}
+	return nil, false
+}
+
+// returns the element at idx as a string, accepting both strings and symbols
+func (a Array) String(idx int) (string, bool) {
+	if idx >= len(a) {
+		return "", false
+	}
+	switch v := a[idx].(type) {
+	case string:
+		return v, true
+	case Symbol:
+		return string(v), true
+	}
+	return "", false
+}
+
+// returns the element at idx as an int, truncating floats
+func (a Array) Int(idx int) (int, bool) {
+	if idx >= len(a) {
+		return 0, false
+	}
+	switch v := a[idx].(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// looks up (key value) and returns value as a string
+func (a Array) FindString(key string) (string, bool) {
+	child, ok := a.Find(key)
+	if !ok {
+		return "", false
+	}
+	return child.String(1)
+}
+
+// looks up (key value) and returns value as an int
+func (a Array) FindInt(key string) (int, bool) {
+	child, ok := a.Find(key)
+	if !ok {
+		return 0, false
+	}
+	return child.Int(1)
+}
+
+type parser struct {
+	data []byte
+	pos  int
+	line int
+}
+
+// parses DTA text and returns the top level nodes
+func Parse(data []byte) (Array, error) {
+	p := &parser{data: data, line: 1}
+
+	root, err := p.parseNodes(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+var closers = map[byte]byte{'(': ')', '{': '}', '[': ']'}
+
+// parses nodes until the closing character is reached, or EOF if closer is 0
+func (p *parser) parseNodes(closer byte) (Array, error) {
+	nodes := Array{}
+
+	for {
+		p.skipWhitespaceAndComments()
+
+		if p.pos >= len(p.data) {
+			if closer != 0 {
+				return nil, fmt.Errorf("line %d: unexpected end of file, expected '%c'", p.line, closer)
+			}
+			return nodes, nil
+		}
+
+		c := p.data[p.pos]
+
+		switch {
+		case c == closer:
+			p.pos++
+			return nodes, nil
+		case c == ')' || c == '}' || c == ']':
+			return nil, fmt.Errorf("line %d: unexpected '%c'", p.line, c)
+		case closers[c] != 0:
+			p.pos++
+			child, err := p.parseNodes(closers[c])
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, child)
+		case c == '"':
+			str, err := p.parseQuoted('"')
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, str)
+		case c == '\'':
+			str, err := p.parseQuoted('\'')
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, Symbol(str))
+		case c == '#':
+			// preprocessor directives like #include or #ifdef take up the rest of the line
+			p.skipLine()
+		default:
+			nodes = append(nodes, p.parseAtom())
+		}
+	}
+}
+
+func (p *parser) skipWhitespaceAndComments() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		switch {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == ';':
+			p.skipLine()
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) skipLine() {
+	for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+		p.pos++
+	}
+}
+
+func (p *parser) parseQuoted(quote byte) (string, error) {
+	startLine := p.line
+	p.pos++
+
+	var sb strings.Builder
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+
+		switch c {
+		case quote:
+			return sb.String(), nil
+		case '\n':
+			p.line++
+		case '\\':
+			// \q is how Harmonix escapes double quotes inside of strings
+			if p.pos < len(p.data) {
+				switch p.data[p.pos] {
+				case 'q':
+					sb.WriteByte('"')
+					p.pos++
+					continue
+				case 'n':
+					sb.WriteByte('\n')
+					p.pos++
+					continue
+				}
+			}
+		}
+		sb.WriteByte(c)
+	}
+
+	return "", fmt.Errorf("line %d: unterminated string", startLine)
+}
+
+// parses a bare word, which is an int, a float, or otherwise a symbol
+func (p *parser) parseAtom() interface{} {
+	start := p.pos
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == ';' || c == '"' || closers[c] != 0 || c == ')' || c == '}' || c == ']' {
+			break
+		}
+		p.pos++
+	}
+
+	word := string(p.data[start:p.pos])
+
+	if i, err := strconv.Atoi(word); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(word, 64); err == nil {
+		return f
+	}
+
+	return Symbol(word)
+}
@@ -0,0 +1,135 @@
+package dta
+
+import (
+	"fmt"
+	"rb3server/models"
+	"strconv"
+)
+
+// lower rank bounds for tiers 2 through 7 on each instrument, anything nonzero below the first bound is tier 1
+// these are the same cutoffs the game uses to draw the difficulty dots
+var tierThresholds = map[string][6]int{
+	"drum":        {124, 151, 178, 242, 345, 448},
+	"guitar":      {139, 176, 221, 267, 333, 409},
+	"bass":        {135, 181, 228, 293, 364, 436},
+	"vocals":      {132, 175, 218, 279, 353, 427},
+	"keys":        {153, 211, 269, 327, 385, 443},
+	"real_keys":   {153, 211, 269, 327, 385, 443},
+	"real_guitar": {150, 205, 264, 323, 382, 442},
+	"real_bass":   {150, 208, 267, 325, 384, 442},
+	"band":        {163, 215, 243, 267, 292, 345},
+}
+
+// converts a raw DTA rank into a difficulty tier, 0 meaning the instrument has no part
+func RankToTier(instrument string, rank int) int {
+	if rank <= 0 {
+		return 0
+	}
+
+	thresholds, ok := tierThresholds[instrument]
+	if !ok {
+		return 0
+	}
+
+	tier := 1
+	for _, threshold := range thresholds {
+		if rank >= threshold {
+			tier++
+		}
+	}
+
+	return tier
+}
+
+// maps the game_origin symbol to the catalog source
+func sourceFromOrigin(origin string) string {
+	switch origin {
+	case "rb3":
+		return "rb3"
+	case "ugc", "ugc_plus", "ugc1", "ugc2":
+		return "rbn"
+	default:
+		return "dlc"
+	}
+}
+
+type SongsDeserializer struct{}
+
+// reads every song entry out of a songs.dta file
+// entries without a numeric song_id can't be matched against scores so they are skipped
+func (d SongsDeserializer) Deserialize(data []byte) ([]models.Song, error) {
+	root, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse DTA: %v", err)
+	}
+
+	songs := []models.Song{}
+
+	for _, node := range root {
+		entry, ok := node.(Array)
+		if !ok || entry.Key() == "" {
+			continue
+		}
+
+		song, ok := songFromEntry(entry)
+		if !ok {
+			continue
+		}
+
+		songs = append(songs, song)
+	}
+
+	return songs, nil
+}
+
+func songFromEntry(entry Array) (models.Song, bool) {
+	var song models.Song
+
+	songID, ok := entry.FindInt("song_id")
+	if !ok {
+		// some customs store the song_id as a string
+		idStr, strOK := entry.FindString("song_id")
+		if !strOK {
+			return song, false
+		}
+		var err error
+		songID, err = strconv.Atoi(idStr)
+		if err != nil {
+			return song, false
+		}
+	}
+	if songID <= 0 {
+		return song, false
+	}
+
+	song.SongID = songID
+	song.ShortName = entry.Key()
+	song.Title, _ = entry.FindString("name")
+	song.Artist, _ = entry.FindString("artist")
+	song.Album, _ = entry.FindString("album_name")
+	song.Year, _ = entry.FindInt("year_released")
+	song.Genre, _ = entry.FindString("genre")
+
+	origin, _ := entry.FindString("game_origin")
+	song.Source = sourceFromOrigin(origin)
+
+	song.Ranks = map[string]int{}
+	song.Tiers = map[string]int{}
+
+	if rank, ok := entry.Find("rank"); ok {
+		for _, node := range rank[1:] {
+			instRank, ok := node.(Array)
+			if !ok {
+				continue
+			}
+			value, ok := instRank.Int(1)
+			if !ok {
+				continue
+			}
+			song.Ranks[instRank.Key()] = value
+			song.Tiers[instRank.Key()] = RankToTier(instRank.Key(), value)
+		}
+	}
+
+	return song, true
+}
@@ -19,6 +19,8 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 
+	"rb3server/authentication"
+	"rb3server/binarydata"
 	database "rb3server/database"
 	"rb3server/restapi"
 	"rb3server/servers"
@@ -111,11 +113,46 @@ func main() {
 		}
 	}
 
+	// bring any documents written by older versions up to date
+	database.RunMigrations()
+
+	if err := database.EnsureBattleResultIndexes(context.Background(), database.GocentralDatabase); err != nil {
+		log.Println("Could not create battle result indexes: ", err)
+	}
+
+	if err := database.EnsureBinaryDataIndexes(context.Background(), database.GocentralDatabase); err != nil {
+		log.Println("Could not create binary data indexes: ", err)
+	}
+
+	if err := database.EnsureUsernameRedirectIndexes(context.Background(), database.GocentralDatabase); err != nil {
+		log.Println("Could not create username redirect indexes: ", err)
+	}
+
+	// without this index two players could end up holding the same link code, so it isn't safe to carry on without it
+	if err := database.EnsureLinkCodeIndexes(context.Background(), database.GocentralDatabase); err != nil {
+		log.Fatalln("Could not create link code indexes: ", err)
+	}
+
+	// setlist art, battle art and band logos go to the filesystem unless another store is configured
+	binaryDataStore, err := binarydata.NewStoreFromEnv(database.GocentralDatabase)
+	if err != nil {
+		log.Fatalln("Could not set up the binary data store: ", err)
+	}
+	binarydata.SetDefaultStore(binaryDataStore)
+
+	// which verifiers check the tickets each console presents when connecting
+	ticketVerifierChains, err := authentication.NewVerifierChainsFromEnv()
+	if err != nil {
+		log.Fatalln("Could not set up ticket verification: ", err)
+	}
+	servers.SetTicketVerifierChains(ticketVerifierChains)
+
 	// seed randomness with current time
 	rand.Seed(time.Now().UnixNano())
 
 	// Initialize the in-memory message store
 	servers.InitMessageStore()
+	servers.LoadBattleWinnerMessages()
 
 	go servers.StartAuthServer()
 	go servers.StartSecureServer()
@@ -148,6 +185,19 @@ func main() {
 
 		r.Get("/song_list", restapi.SongListHandler)
 
+		// song catalog lookup, populated by the admin song import
+		r.Get("/songs", restapi.SongSearchHandler)
+		r.Get("/songs/{id}", restapi.SongHandler)
+
+		// shared setlists from every player, not just friends
+		r.Get("/setlists", restapi.SetlistSearchHandler)
+		r.Get("/setlists/{id}", restapi.SetlistHandler)
+
+		// art uploaded from any console, as PNGs for the web
+		r.Get("/setlists/{id}/art.png", restapi.SetlistArtHandler)
+		r.Get("/battles/{id}/art.png", restapi.BattleArtHandler)
+		r.Get("/bands/{id}/logo.png", restapi.BandLogoHandler)
+
 		// legacy endpoint, will keep around for now
 		r.Get("/leaderboards", restapi.LeaderboardHandler)
 
@@ -156,18 +206,106 @@ func main() {
 
 		r.Get("/battles", restapi.BattleListHandler)
 
+		// final standings of battles that have closed
+		r.Get("/battles/results", restapi.BattleResultsHandler)
+		r.Get("/battles/results/{id}", restapi.BattleResultHandler)
+		r.Get("/players/{pid}/battles", restapi.PlayerBattleHistoryHandler)
+
+		// public player profiles, players can hide theirs through the website or ask an admin to
+		r.Get("/players/{player}", restapi.PlayerProfileHandler)
+
+		// account linking for players signed in to the website, which vouches for who they are
+		r.Route("/web", func(r chi.Router) {
+			r.Use(restapi.WebTokenAuth)
+
+			r.Post("/link", restapi.LinkAccountHandler)
+			r.Delete("/link", restapi.UnlinkAccountHandler)
+			r.Get("/accounts", restapi.LinkedAccountsHandler)
+			r.Delete("/accounts/{pid}", restapi.WebEraseAccountHandler)
+			r.Get("/accounts/{pid}/export", restapi.WebExportAccountHandler)
+			r.Put("/accounts/{pid}/profile", restapi.WebProfileVisibilityHandler)
+		})
+
 		r.Route("/admin", func(r chi.Router) {
 			r.Use(restapi.AdminTokenAuth)
 
 			// battle management
 			r.Post("/battles/create", restapi.CreateBattleHandler)
 			r.Delete("/battles", restapi.DeleteBattleHandler)
+			r.Get("/battles", restapi.AdminBattleListHandler)
+			r.Get("/battles/counts", restapi.PlayerBattleCountsHandler)
+			r.Patch("/battles/{id}", restapi.UpdateBattleHandler)
+			r.Post("/battles/{id}/transfer", restapi.TransferBattleHandler)
+			r.Post("/battles/{id}/hide", restapi.HideBattleHandler)
+			r.Post("/battles/{id}/unhide", restapi.UnhideBattleHandler)
+			r.Delete("/battles/{id}/scores", restapi.ResetBattleLeaderboardHandler)
+
+			// recurring battles that housekeeping creates on a schedule
+			r.Get("/battles/templates", restapi.BattleTemplateListHandler)
+			r.Post("/battles/templates", restapi.CreateBattleTemplateHandler)
+			r.Put("/battles/templates/{id}", restapi.UpdateBattleTemplateHandler)
+			r.Delete("/battles/templates/{id}", restapi.DeleteBattleTemplateHandler)
+			r.Post("/battles/templates/{id}/queue", restapi.QueueBattleSongsHandler)
+
+			// server-curated Harmonix Recommends setlists
+			r.Get("/setlists/curated", restapi.CuratedSetlistListHandler)
+			r.Post("/setlists/curated", restapi.CreateCuratedSetlistHandler)
+			r.Post("/setlists/curated/reorder", restapi.ReorderCuratedSetlistsHandler)
+			r.Patch("/setlists/curated/{id}", restapi.UpdateCuratedSetlistHandler)
+			r.Post("/setlists/curated/{id}/schedule", restapi.ScheduleCuratedSetlistHandler)
+			r.Post("/setlists/curated/{id}/retire", restapi.RetireCuratedSetlistHandler)
+			r.Post("/setlists/curated/{id}/unretire", restapi.UnretireCuratedSetlistHandler)
+			r.Post("/setlists/curated/{id}/art", restapi.UploadCuratedSetlistArtHandler)
 
 			// ban Management
 			r.Get("/players/banned", restapi.ListBannedPlayersHandler)
 			r.Post("/players/ban", restapi.BanPlayerHandler)
 			r.Post("/players/unban", restapi.UnbanPlayerHandler)
 			r.Delete("/players/scores", restapi.DeletePlayerScoresHandler)
+			r.Post("/players/battle_limit", restapi.SetPlayerBattleLimitHandler)
+			r.Post("/players/secret", restapi.RotateSecretHandler)
+			r.Post("/players/merge", restapi.MergeAccountsHandler)
+
+			// account erasure and personal data export
+			r.Delete("/players/{pid}", restapi.EraseAccountHandler)
+			r.Get("/players/{pid}/export", restapi.ExportAccountHandler)
+
+			// hiding public profiles for players who haven't linked the website
+			r.Put("/players/{pid}/profile", restapi.ProfileVisibilityHandler)
+
+			// group memberships and the privileges they grant
+			r.Get("/groups", restapi.GroupListHandler)
+			r.Get("/groups/{group}/users", restapi.GroupMembersHandler)
+			r.Get("/players/groups", restapi.PlayerGroupsHandler)
+			r.Post("/players/groups", restapi.AddPlayerToGroupHandler)
+			r.Delete("/players/groups", restapi.RemovePlayerFromGroupHandler)
+
+			// score investigation
+			r.Get("/scores", restapi.ScoreSearchHandler)
+			r.Get("/scores/quarantine", restapi.QuarantineListHandler)
+			r.Post("/scores/quarantine/{id}/approve", restapi.ApproveQuarantinedScoreHandler)
+			r.Post("/scores/quarantine/{id}/reject", restapi.RejectQuarantinedScoreHandler)
+
+			// moderation of band logos and setlist art uploaded by players
+			r.Get("/moderation", restapi.ModerationListHandler)
+			r.Get("/moderation/{id}/image.png", restapi.ModerationImageHandler)
+			r.Post("/moderation/{id}/approve", restapi.ApproveModerationHandler)
+			r.Post("/moderation/{id}/replace", restapi.ReplaceModerationHandler)
+
+			// the profanity filter for names, titles, descriptions and messages players write
+			r.Get("/profanity", restapi.ProfanityListsHandler)
+			r.Post("/profanity/filters", restapi.AddProfanityEntryHandler)
+			r.Delete("/profanity/filters", restapi.RemoveProfanityEntryHandler)
+			r.Post("/profanity/allowlist", restapi.AddProfanityAllowlistHandler)
+			r.Delete("/profanity/allowlist", restapi.RemoveProfanityAllowlistHandler)
+			r.Post("/profanity/test", restapi.TestProfanityHandler)
+
+			// how the tickets consoles present when connecting have been judged
+			r.Get("/tickets/verifications", restapi.TicketVerificationMetricsHandler)
+
+			// song catalog
+			r.Post("/songs/import", restapi.ImportSongsHandler)
+			r.Post("/songs/limits", restapi.ImportSongLimitsHandler)
 		})
 
 		httpPort := os.Getenv("HTTPPORT")
@@ -208,10 +346,13 @@ func main() {
 					database.CleanupDuplicateScores()
 					database.PruneOldSessions()
 					database.CleanupInvalidScores()
+					database.ScheduleTemplateBattles()
+					servers.NotifyBattleWinners(database.ArchiveClosedBattles())
 					database.DeleteExpiredBattles()
 					database.CleanupBannedUserScores()
 					database.CleanupBannedUserAccomplishments()
 					database.CleanupInvalidUsers()
+					database.CleanupOrphanedBinaryData()
 				case <-quit:
 					return
 				}
@@ -0,0 +1,129 @@
+package servers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"rb3server/database"
+	"rb3server/models"
+	"rb3server/serialization/message"
+	"time"
+)
+
+// how many of the top placements of a battle get told they won
+const battleWinnerCount = 3
+
+// winners have a week to log in and see their message before it expires
+const battleWinnerMessageLifetime = 7 * 24 * 60 * 60
+
+// turns a stored battle winner message into what the message store hands to the game
+func battleWinnerTextMessage(stored models.BattleWinnerMessage, now time.Time) message.TextMessage {
+	return message.TextMessage{
+		UserMessage: message.UserMessage{
+			ID:            stored.MessageID,
+			IDRecipient:   stored.RecipientPID,
+			RecipientType: 1,
+			ReceptionTime: message.DateTime{Value: stored.ReceptionTime},
+			LifeTime:      uint32(stored.ExpiresAt.Sub(now) / time.Second),
+			Subject:       stored.Subject,
+			Sender:        "GoCentral",
+		},
+		TextBody: stored.TextBody,
+	}
+}
+
+// sends an in-game message to the winners of each newly archived battle
+// the messages are also written to the database, since the battle itself is gone by the time winners log in
+func NotifyBattleWinners(results []models.BattleResult) {
+	if GlobalMessageStore == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, result := range results {
+		var winnerPIDs []int
+		for _, standing := range result.Standings {
+			if standing.Rank <= battleWinnerCount {
+				winnerPIDs = append(winnerPIDs, standing.PID)
+			}
+		}
+
+		recipients, err := database.GetMessageRecipientPIDs(ctx, database.GocentralDatabase, winnerPIDs)
+		if err != nil {
+			log.Printf("Could not look up who to message about battle %d: %v\n", result.BattleID, err)
+			continue
+		}
+
+		now := time.Now()
+		var messages []models.BattleWinnerMessage
+
+		for _, standing := range result.Standings {
+			if standing.Rank > battleWinnerCount {
+				break
+			}
+
+			// battles are hidden for things like profane titles, so those aren't repeated back to the winners
+			text := fmt.Sprintf("You placed #%d of %d in the battle \"%s\" with a score of %d!", standing.Rank, result.Participants, result.Title, standing.Score)
+			if result.Hidden {
+				text = fmt.Sprintf("You placed #%d of %d in a battle with a score of %d!", standing.Rank, result.Participants, standing.Score)
+			}
+
+			stored := models.BattleWinnerMessage{
+				MessageID:     GlobalMessageStore.NextMessageID(),
+				RecipientPID:  recipients[standing.PID],
+				BattleID:      result.BattleID,
+				Subject:       "Battle Results",
+				TextBody:      fmt.Sprintf("1:0:%s", text), // recipient type:gathering ID:message, same as what clients send
+				ReceptionTime: message.NewDateTime(now).Value,
+				ExpiresAt:     now.Add(battleWinnerMessageLifetime * time.Second),
+			}
+			messages = append(messages, stored)
+
+			GlobalMessageStore.AddMessage(stored.RecipientPID, battleWinnerTextMessage(stored, now))
+		}
+
+		if err := database.SaveBattleWinnerMessages(ctx, database.GocentralDatabase, messages); err != nil {
+			log.Printf("Could not save winner messages for battle %d, they will be lost on restart: %v\n", result.BattleID, err)
+		}
+
+		log.Printf("Notified winners of battle %d (%s)\n", result.BattleID, result.Title)
+	}
+}
+
+// puts the battle winner messages that haven't been read yet back into the message store after a restart
+func LoadBattleWinnerMessages() {
+	if GlobalMessageStore == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	messages, err := database.GetPendingBattleWinnerMessages(ctx, database.GocentralDatabase)
+	if err != nil {
+		log.Printf("Could not load battle winner messages: %v\n", err)
+		return
+	}
+
+	now := time.Now()
+	for _, stored := range messages {
+		GlobalMessageStore.reserveMessageID(stored.MessageID)
+		GlobalMessageStore.AddMessage(stored.RecipientPID, battleWinnerTextMessage(stored, now))
+	}
+
+	if len(messages) > 0 {
+		log.Printf("Loaded %d battle winner messages\n", len(messages))
+	}
+}
+
+// forgets battle winner messages once the game has deleted them, so they aren't loaded again after a restart
+func forgetBattleWinnerMessages(recipientPID uint32, messageIDs []uint32) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := database.DeleteBattleWinnerMessages(ctx, database.GocentralDatabase, recipientPID, messageIDs); err != nil {
+		log.Printf("Could not delete battle winner messages for PID %d: %v\n", recipientPID, err)
+	}
+}
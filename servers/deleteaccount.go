@@ -3,6 +3,7 @@ package servers
 import (
 	"context"
 	"log"
+	"rb3server/binarydata"
 	"rb3server/database"
 	"rb3server/models"
 	"rb3server/quazal"
@@ -38,8 +39,8 @@ func DeleteAccount(err error, client *nex.Client, callID uint32, pid uint32) {
 		return
 	}
 
-	// delete the user
-	if _, err = usersCollection.DeleteOne(nil, bson.M{"pid": pid}); err != nil {
+	// delete the user along with everything else stored about them
+	if _, err = database.EraseAccount(context.TODO(), database.GocentralDatabase, binarydata.DefaultStore(), int(pid)); err != nil {
 		log.Printf("Could not delete user with PID %d: %+v\n", pid, err)
 		SendErrorCode(SecureServer, client, nexproto.AccountManagementProtocolID, callID, quazal.OperationError)
 		return
@@ -19,6 +19,7 @@ func DeleteMessages(err error, client *nex.Client, callID uint32, pid uint32, re
 
 	// Delete messages from the in-memory store
 	GlobalMessageStore.DeleteMessages(pid, messageIDs)
+	forgetBattleWinnerMessages(pid, messageIDs)
 
 	rmcResponseStream := nex.NewStream()
 	// No response data for DeleteMessages
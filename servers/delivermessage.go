@@ -1,13 +1,55 @@
 package servers
 
 import (
+	"context"
 	"log"
+	"rb3server/database"
 	"rb3server/serialization/message"
 
 	"github.com/ihatecompvir/nex-go"
 	nexproto "github.com/ihatecompvir/nex-protocols-go"
 )
 
+// runs some text through the profanity filter, returning false if it is rejected or can't be checked
+func filterMessageText(text string) (string, bool) {
+	result, err := database.FilterText(context.TODO(), text)
+	if err != nil {
+		log.Printf("Could not get profanity filter: %v\n", err)
+		return text, false
+	}
+
+	return result.Masked, !result.Rejected()
+}
+
+// runs the subject and text of a message through the profanity filter, masking what it masks
+// returns false if the message should not be delivered at all
+func filterTextMessage(msg *message.TextMessage) bool {
+	subject, ok := filterMessageText(msg.Subject)
+	if !ok {
+		return false
+	}
+	msg.Subject = subject
+
+	// only the message part of the body is what the player typed, the recipient type and gathering ID in front of it are left alone
+	body, err := msg.ParseBody()
+	if err != nil {
+		text, ok := filterMessageText(msg.TextBody)
+		if !ok {
+			return false
+		}
+		msg.TextBody = text
+		return true
+	}
+
+	text, ok := filterMessageText(body.Message)
+	if !ok {
+		return false
+	}
+	msg.TextBody = msg.TextBody[:len(msg.TextBody)-len(body.Message)] + text
+
+	return true
+}
+
 func DeliverMessage(err error, client *nex.Client, callID uint32, data []byte) {
 
 	res, _ := ValidateClientPID(SecureServer, client, callID, nexproto.MessageDeliveryProtocolID)
@@ -21,6 +63,8 @@ func DeliverMessage(err error, client *nex.Client, callID uint32, data []byte) {
 	msg, err := deserializer.Deserialize(data)
 	if err != nil {
 		log.Printf("Failed to deserialize TextMessage: %v\n", err)
+	} else if !filterTextMessage(&msg) {
+		log.Printf("Not delivering message from machine PID %d to recipient %d, the profanity filter rejected it\n", client.MachineID(), msg.IDRecipient)
 	} else {
 		// Store the message in the in-memory store for the recipient
 		if GlobalMessageStore != nil {
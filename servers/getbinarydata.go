@@ -4,14 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"os"
-	"path/filepath"
+	"rb3server/binarydata"
 	"rb3server/database"
 	"rb3server/models"
 	"rb3server/quazal"
-	"strings"
 	"time"
 
 	"github.com/ihatecompvir/nex-go"
@@ -21,17 +18,7 @@ import (
 )
 
 func SanitizePath(path string) string {
-	// List of invalid characters except the path separators and drive letter colon
-	invalidChars := []string{"*", "?", "\"", "<", ">", "|", "\r", "\n", "\x0a", "\x0d", "\x00", "."}
-
-	for _, char := range invalidChars {
-		path = strings.ReplaceAll(path, char, "_")
-	}
-
-	// Block path traversal sequences
-	path = strings.ReplaceAll(path, "..", "__")
-
-	return path
+	return binarydata.SanitizePath(path)
 }
 
 func GetBinaryData(err error, client *nex.Client, callID uint32, metadata string) {
@@ -60,27 +47,13 @@ func GetBinaryData(err error, client *nex.Client, callID uint32, metadata string
 		return
 	}
 
-	// try to get base path from environment variables
-	basePath := os.Getenv("BASEBINARYDATAPATH")
-
-	if basePath == "" {
-		basePath = "binary_data"
-	}
-
-	var filePath string
-
-	var platformExtension string
+	var dataKey string
+	var revision int64
 
 	// we don't want to try to send a png_ps3 to xbox and etc.
 	// so we need to check the platform and set the extension accordingly
-	switch client.Platform() {
-	case 0:
-		platformExtension = "png_xbox"
-	case 1, 3:
-		platformExtension = "png_ps3"
-	case 2:
-		platformExtension = "png_wii"
-	default:
+	platformExtension, ok := binarydata.PlatformExtension(client.Platform())
+	if !ok {
 		log.Printf("Unsupported platform %d in requested metadata", client.Platform())
 		SendErrorCode(SecureServer, client, nexproto.RBBinaryDataProtocolID, callID, quazal.UnknownError)
 		return
@@ -123,11 +96,16 @@ func GetBinaryData(err error, client *nex.Client, callID uint32, metadata string
 			return
 		}
 
-		revision := int64(revisionFloat)
+		revision = int64(revisionFloat)
+
+		// art for curated setlists is uploaded by admins, so always serve the latest upload
+		if setlist.ArtRevision != 0 {
+			revision = int64(setlist.ArtRevision)
+		}
 
-		filePath = filepath.Join(basePath, "setlist_art", SanitizePath(setlistGUID), fmt.Sprintf("%d.%s", revision, platformExtension))
+		dataKey = SanitizePath(setlistGUID)
 
-		log.Printf("Serving setlist art at path %v", filePath)
+		log.Printf("Serving setlist art %v revision %d", dataKey, revision)
 
 	case "battle_art":
 		revisionFloat, ok := metadataMap["revision"].(float64)
@@ -138,7 +116,7 @@ func GetBinaryData(err error, client *nex.Client, callID uint32, metadata string
 			return
 		}
 
-		revision := int64(revisionFloat)
+		revision = int64(revisionFloat)
 
 		// battle_art can optionally have battle_id; try to get it, but don't fail if it can't be found
 		battleID, _ := metadataMap["battle_id"].(float64)
@@ -162,9 +140,9 @@ func GetBinaryData(err error, client *nex.Client, callID uint32, metadata string
 			return
 		}
 
-		filePath = filepath.Join(basePath, "battle_art", fmt.Sprintf("%d", int64(battleID)), fmt.Sprintf("%d.%s", revision, platformExtension))
+		dataKey = fmt.Sprintf("%d", int64(battleID))
 
-		log.Printf("Serving battle art at path %v", filePath)
+		log.Printf("Serving battle art %v revision %d", dataKey, revision)
 
 	case "band_logo":
 		bandIDFloat, ok := metadataMap["band_id"].(float64)
@@ -185,11 +163,11 @@ func GetBinaryData(err error, client *nex.Client, callID uint32, metadata string
 			return
 		}
 
-		revision := int64(revisionFloat)
+		revision = int64(revisionFloat)
 
-		filePath = filepath.Join(basePath, "band_logo", fmt.Sprintf("%d", bandID), fmt.Sprintf("%d.%s", revision, platformExtension))
+		dataKey = fmt.Sprintf("%d", bandID)
 
-		log.Printf("Serving band logo at path %v", filePath)
+		log.Printf("Serving band logo %v revision %d", dataKey, revision)
 
 	default:
 		log.Println("Unsupported type ", dataType, " in requested metadata")
@@ -197,20 +175,13 @@ func GetBinaryData(err error, client *nex.Client, callID uint32, metadata string
 		return
 	}
 
-	// Check if the path is still valid and under basePath
-	cleanBasePath := filepath.Clean(basePath)
-	cleanFilePath := filepath.Clean(filePath)
-
-	if !strings.HasPrefix(cleanFilePath, cleanBasePath) {
-		log.Println("Invalid path: ", cleanFilePath)
-		SendErrorCode(SecureServer, client, nexproto.RBBinaryDataProtocolID, callID, quazal.UnknownError)
-		return
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	// Read the file from disk
-	data, err := ioutil.ReadFile(cleanFilePath)
+	// art uploaded from another console is converted the first time it's asked for
+	data, err := database.LoadBinaryData(ctx, database.GocentralDatabase, binarydata.DefaultStore(), dataType, dataKey, revision, platformExtension)
 	if err != nil {
-		log.Println("Error reading file from disk: ", err)
+		log.Println("Error reading binary data from the store: ", err)
 		SendErrorCode(SecureServer, client, nexproto.RBBinaryDataProtocolID, callID, quazal.UnknownError)
 		return
 	}
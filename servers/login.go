@@ -9,6 +9,7 @@ import (
 	"log"
 	"math/rand"
 	"os"
+	"rb3server/authentication"
 	"rb3server/database"
 	"rb3server/models"
 	"rb3server/quazal"
@@ -39,10 +40,7 @@ var (
 )
 
 func deriveKerberosKey(userPID uint32, pwd string) []byte {
-	pwdToUse := pwd
-	if pwd == "" {
-		pwdToUse = os.Getenv("USERPASSWORD")
-	}
+	pwdToUse := authentication.PlatformSecret(pwd)
 	cacheKey := fmt.Sprintf("%d:%s", userPID, pwdToUse)
 
 	// check if it is in cache first
@@ -56,11 +54,7 @@ func deriveKerberosKey(userPID uint32, pwd string) []byte {
 	kerberosKeyCacheMu.RUnlock()
 
 	// was not cached - compute it
-	kerberosTicketKey := []byte(pwdToUse)
-
-	for i := 0; i < 65000+(int(userPID)%1024); i++ {
-		kerberosTicketKey = nex.MD5Hash(kerberosTicketKey)
-	}
+	kerberosTicketKey := authentication.DeriveKerberosKey(userPID, pwdToUse)
 
 	// put it in cache
 	kerberosKeyCacheMu.Lock()
@@ -73,6 +67,16 @@ func deriveKerberosKey(userPID uint32, pwd string) []byte {
 	return kerberosTicketKey
 }
 
+// the key to encrypt an account's tickets with, which is the account's own key if it has one
+// otherwise it comes from the platform's password, which is the friend code on Wii
+func accountKerberosKey(storedKey []byte, userPID uint32, wiiFriendCode string) []byte {
+	if storedKey != nil {
+		return storedKey
+	}
+
+	return deriveKerberosKey(userPID, wiiFriendCode)
+}
+
 func generateGUID() (string, error) {
 	bytes := make([]byte, 16)
 	_, err := rand.Read(bytes)
@@ -84,7 +88,7 @@ func generateGUID() (string, error) {
 	return strings.ToLower(guid), nil
 }
 
-func generateKerberosTicket(userPID uint32, serverPID uint32, keySize int, pwd string) ([]byte, []byte) {
+func generateKerberosTicket(userPID uint32, serverPID uint32, keySize int, kerberosTicketKey []byte) []byte {
 
 	sessionKey := []byte{0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0x9, 0xa, 0xb, 0xc, 0xd, 0xe, 0xf, 0x10}
 
@@ -98,8 +102,6 @@ func generateKerberosTicket(userPID uint32, serverPID uint32, keySize int, pwd s
 	encryptedTicketInfo := ticketInfoEncryption.Encrypt(ticketInfoStream.Bytes())
 
 	// Create ticket
-	kerberosTicketKey := deriveKerberosKey(userPID, pwd)
-
 	ticketEncryption := nex.NewKerberosEncryption(kerberosTicketKey)
 	ticketStream := nex.NewStream()
 	ticketStream.Grow(int64(24))
@@ -108,7 +110,7 @@ func generateKerberosTicket(userPID uint32, serverPID uint32, keySize int, pwd s
 	ticketStream.WriteU32LENext([]uint32{1})
 	ticketStream.WriteU32LENext([]uint32{0x24})
 	ticketStream.WriteBuffer(encryptedTicketInfo)
-	return ticketEncryption.Encrypt(ticketStream.Bytes()), kerberosTicketKey
+	return ticketEncryption.Encrypt(ticketStream.Bytes())
 }
 
 func Login(err error, client *nex.Client, callID uint32, username string) {
@@ -176,9 +178,13 @@ func Login(err error, client *nex.Client, callID uint32, username string) {
 		}
 	}
 
+	// the account's own Kerberos key, if it has one
+	var storedKerberosKey []byte
+
 	switch machineType {
 	case 0, 1:
-		if err = users.FindOne(nil, database.CaseInsensitiveUsername(username)).Decode(&user); err != nil {
+		var existingUser *models.User
+		if existingUser, err = database.FindUserByUsername(context.TODO(), database.GocentralDatabase, username); err != nil {
 			log.Printf("%s has never connected before - create DB entry\n", username)
 
 			guid, err := generateGUID()
@@ -191,13 +197,14 @@ func Login(err error, client *nex.Client, callID uint32, username string) {
 				return
 			}
 
-			_, err = users.InsertOne(nil, bson.D{
+			userDoc := bson.D{
 				{Key: "username", Value: username},
 				{Key: "pid", Value: newPID},
 				{Key: "console_type", Value: machineType},
 				{Key: "guid", Value: guid},
-				{Key: "link_code", Value: database.GenerateLinkCode(10)},
-			})
+			}
+
+			_, err = users.InsertOne(nil, userDoc)
 
 			// invalidate console type cache since a new user was created
 			database.InvalidateConsoleTypePIDsCache(machineType)
@@ -209,7 +216,14 @@ func Login(err error, client *nex.Client, callID uint32, username string) {
 			}
 
 		} else {
-			// update console type and link code if needed for existing users
+			// usernames of accounts merged into another one log in to the account they were merged into
+			user = *existingUser
+			if !strings.EqualFold(user.Username, username) {
+				log.Printf("%s was merged into %s, logging in as PID %d\n", username, user.Username, user.PID)
+				username = user.Username
+			}
+
+			// update console type if needed for existing users, link codes are made when the game asks for one
 			updateFields := bson.D{}
 
 			// always update console type to reflect current login platform
@@ -221,12 +235,6 @@ func Login(err error, client *nex.Client, callID uint32, username string) {
 				database.InvalidateConsoleTypePIDsCache(machineType)
 			}
 
-			// generate link code if missing (which will be true for legacy accounts created before i made this system)
-			if user.LinkCode == "" {
-				linkCode := database.GenerateLinkCode(10)
-				updateFields = append(updateFields, bson.E{Key: "link_code", Value: linkCode})
-			}
-
 			// only perform update if there are fields to update
 			if len(updateFields) > 0 {
 				_, err = users.UpdateOne(context.TODO(), database.CaseInsensitiveUsername(username), bson.D{
@@ -239,6 +247,8 @@ func Login(err error, client *nex.Client, callID uint32, username string) {
 				}
 			}
 		}
+
+		storedKerberosKey = database.DecodeKerberosKey(user.KerberosKey)
 	case 2:
 		// check if the machine ID is already in the DB
 		machinesCollection := database.GocentralDatabase.Collection("machines")
@@ -265,12 +275,14 @@ func Login(err error, client *nex.Client, callID uint32, username string) {
 				return
 			}
 
-			_, err = machinesCollection.InsertOne(context.TODO(), bson.D{
+			machineDoc := bson.D{
 				{Key: "wii_friend_code", Value: res[1]},
 				{Key: "console_type", Value: 2},
 				{Key: "machine_id", Value: newMachineID},
 				{Key: "status", Value: ""},
-			})
+			}
+
+			_, err = machinesCollection.InsertOne(context.TODO(), machineDoc)
 
 			if err != nil {
 				log.Printf("Could not create Wii with friend code %v: %s\n", res[1], err)
@@ -279,6 +291,7 @@ func Login(err error, client *nex.Client, callID uint32, username string) {
 			}
 		} else {
 			user.PID = uint32(machine.MachineID)
+			storedKerberosKey = database.DecodeKerberosKey(machine.KerberosKey)
 			client.WiiFC = machine.WiiFriendCode
 			log.Printf("Wii client detected with friend code %v, pid %v, username %v %v\n", client.WiiFC, user.PID, username, client.Username)
 		}
@@ -293,12 +306,13 @@ func Login(err error, client *nex.Client, callID uint32, username string) {
 
 	log.Printf("%s requesting log in, has PID %v\n", username, user.PID)
 
-	// generate the ticket and pass the friend code as the pwd on Wii, or use static password on PS3
+	// generate the ticket with the account's own key, or from the friend code on Wii and the static password on PS3
 	if machineType == 2 {
-		encryptedTicket, kerberosKey = generateKerberosTicket(user.PID, uint32(serverPID), 16, client.WiiFC)
+		kerberosKey = accountKerberosKey(storedKerberosKey, user.PID, client.WiiFC)
 	} else {
-		encryptedTicket, kerberosKey = generateKerberosTicket(user.PID, uint32(serverPID), 16, "")
+		kerberosKey = accountKerberosKey(storedKerberosKey, user.PID, "")
 	}
+	encryptedTicket = generateKerberosTicket(user.PID, uint32(serverPID), 16, kerberosKey)
 	mac := hmac.New(md5.New, kerberosKey)
 	mac.Write(encryptedTicket)
 	calculatedHmac := mac.Sum(nil)
@@ -31,7 +31,8 @@ func LookupOrCreateAccount(err error, client *nex.Client, callID uint32, usernam
 
 	var user models.User
 
-	if result := users.FindOne(context.TODO(), database.CaseInsensitiveUsername(username)).Decode(&user); result != nil {
+	existingUser, result := database.FindUserByUsername(context.TODO(), database.GocentralDatabase, username)
+	if result != nil {
 		log.Printf("%s has never connected before - create DB entry\n", username)
 
 		guid, err := generateGUID()
@@ -44,23 +45,19 @@ func LookupOrCreateAccount(err error, client *nex.Client, callID uint32, usernam
 			return
 		}
 
+		userDoc := bson.D{
+			{Key: "username", Value: username},
+			{Key: "pid", Value: newPID},
+			{Key: "console_type", Value: client.Platform()},
+			{Key: "guid", Value: guid},
+		}
+
 		if client.Platform() == 2 {
-			_, err = users.InsertOne(context.TODO(), bson.D{
-				{Key: "username", Value: username},
-				{Key: "pid", Value: newPID},
-				{Key: "console_type", Value: client.Platform()},
-				{Key: "guid", Value: guid},
-				{Key: "created_by_machine_id", Value: client.MachineID()},
-			})
-		} else {
-			_, err = users.InsertOne(context.TODO(), bson.D{
-				{Key: "username", Value: username},
-				{Key: "pid", Value: newPID},
-				{Key: "console_type", Value: client.Platform()},
-				{Key: "guid", Value: guid},
-			})
+			userDoc = append(userDoc, bson.E{Key: "created_by_machine_id", Value: client.MachineID()})
 		}
 
+		_, err = users.InsertOne(context.TODO(), userDoc)
+
 		// invalidate console type cache since a new user was created
 		database.InvalidateConsoleTypePIDsCache(client.Platform())
 
@@ -81,6 +78,10 @@ func LookupOrCreateAccount(err error, client *nex.Client, callID uint32, usernam
 				return
 			}
 		}
+	} else {
+		// usernames of accounts merged into another one log in to the account they were merged into
+		user = *existingUser
+		username = user.Username
 	}
 
 	log.Printf("%s requesting to lookup or create an account\n", username)
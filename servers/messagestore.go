@@ -47,6 +47,16 @@ func (ms *MessageStore) NextMessageID() uint32 {
 	return atomic.AddUint32(&ms.nextMessageID, 1)
 }
 
+// reserveMessageID makes sure NextMessageID never hands out an ID that was already used, e.g. by a message loaded from the database
+func (ms *MessageStore) reserveMessageID(id uint32) {
+	for {
+		current := atomic.LoadUint32(&ms.nextMessageID)
+		if current >= id || atomic.CompareAndSwapUint32(&ms.nextMessageID, current, id) {
+			return
+		}
+	}
+}
+
 // AddMessage stores a message for the specified recipient
 func (ms *MessageStore) AddMessage(recipientPID uint32, msg message.TextMessage) {
 	ms.mu.Lock()
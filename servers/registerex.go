@@ -1,15 +1,17 @@
 package servers
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"log"
-	"os"
 	"rb3server/database"
 	"rb3server/models"
 	"rb3server/quazal"
 	"rb3server/utils"
 	"regexp"
+	"strings"
+	"sync"
+	"time"
 
 	"rb3server/authentication"
 
@@ -19,27 +21,43 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// shouldVerifyTicket checks if ticket verification is enabled for the given console type.
-// Console types: 0 = Xbox 360, 1 = PS3, 2 = Wii, 3 = RPCS3
-// Set environment variables to "1" or "true" to enable verification:
-// VERIFY_XBOX360_TICKETS, VERIFY_PS3_TICKETS, VERIFY_WII_TICKETS, VERIFY_RPCS3_TICKETS
-func shouldVerifyTicket(consoleType int) bool {
-	var envVar string
-	switch consoleType {
-	case 0:
-		envVar = "VERIFY_XBOX360_TICKETS"
-	case 1:
-		envVar = "VERIFY_PS3_TICKETS"
-	case 2:
-		envVar = "VERIFY_WII_TICKETS"
-	case 3:
-		envVar = "VERIFY_RPCS3_TICKETS"
-	default:
+var (
+	ticketVerifierChains   map[int]*authentication.VerifierChain
+	ticketVerifierChainsMu sync.RWMutex
+)
+
+// SetTicketVerifierChains sets the chain that checks tickets for each console type
+// console types without a chain aren't checked
+func SetTicketVerifierChains(chains map[int]*authentication.VerifierChain) {
+	ticketVerifierChainsMu.Lock()
+	defer ticketVerifierChainsMu.Unlock()
+
+	ticketVerifierChains = chains
+}
+
+func getTicketVerifierChain(consoleType int) *authentication.VerifierChain {
+	ticketVerifierChainsMu.RLock()
+	defer ticketVerifierChainsMu.RUnlock()
+
+	return ticketVerifierChains[consoleType]
+}
+
+// whether a verified NPTicket was issued to the account the client logged in to
+// the online ID is looked up like a login would be, so a ticket for an account that was merged into another still matches
+func npTicketBelongsToUser(body authentication.NPTicketBody, user models.User) bool {
+	if strings.EqualFold(body.OnlineID, user.Username) {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	owner, err := database.FindUserByUsername(ctx, database.GocentralDatabase, body.OnlineID)
+	if err != nil {
 		return false
 	}
 
-	value := os.Getenv(envVar)
-	return value == "1" || value == "true"
+	return owner.PID == user.PID
 }
 
 var ipRegex = regexp.MustCompile(`(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)(\.(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)){3}`)
@@ -103,24 +121,23 @@ func RegisterEx(err error, client *nex.Client, callID uint32, stationUrls []stri
 		// className is "RPCN" if the emulator is RPCS3
 
 		consoleType := 0
-
-		var ticketDataToEncode []byte
+		var npTicket *authentication.NPTicket
 
 		switch className {
 		case "XboxUserInfo":
 			consoleType = 0
 		case "SonyNPTicket":
-			ticketDataToEncode = ticketData[8:]
-			rpcn := []byte("RPCN")
-			isRPCN := bytes.Contains(ticketData, rpcn)
+			// RPCN tickets carry their own cipher ID in the footer, anything else is treated as coming from a PS3
+			consoleType = 1
 
-			if isRPCN {
-				consoleType = 3
+			deserializer := &authentication.NPTicketDeserializer{}
+			if ticket, err := deserializer.Deserialize(ticketData); err != nil {
+				log.Printf("Could not parse NPTicket from %s, treating it as a PS3 ticket: %v", client.Username, err)
 			} else {
-				consoleType = 1
+				npTicket = &ticket
+				consoleType = ticket.ConsoleType()
 			}
 		case "NintendoToken":
-			ticketDataToEncode = ticketData
 			consoleType = 2
 
 		default:
@@ -129,19 +146,36 @@ func RegisterEx(err error, client *nex.Client, callID uint32, stationUrls []stri
 			return
 		}
 
-		if os.Getenv("TICKETVERIFIERENDPOINT") != "" && shouldVerifyTicket(consoleType) {
-			ticketVerifier := &authentication.TicketVerifier{TicketVerifierEndpoint: os.Getenv("TICKETVERIFIERENDPOINT")}
+		ticketValid := true
+
+		if chain := getTicketVerifierChain(consoleType); chain != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			verifiedBy, err := chain.Verify(ctx, ticketData, consoleType)
+			cancel()
+
+			if err != nil {
+				log.Printf("Ticket for %s failed verification: %v", client.Username, err)
+				ticketValid = false
+			} else if (consoleType == 1 || consoleType == 3) && verifiedBy != (&authentication.AllowAllVerifier{}).Name() && npTicket != nil {
+				// ownership only means something for a ticket that was really checked, allowall lets anything through on purpose
+				if body, err := npTicket.ParseBody(); err != nil {
+					log.Printf("Could not parse the body of the ticket presented by %s, not checking whose it is: %v", client.Username, err)
+				} else if !npTicketBelongsToUser(body, user) {
+					log.Printf("Ticket presented by %s was issued to someone else", client.Username)
+					ticketValid = false
+				}
+			}
+		}
 
-			if !ticketVerifier.VerifyTicket(ticketDataToEncode, consoleType) {
-				log.Println("Invalid ticket presented, could not verify ticket")
+		if !ticketValid {
+			log.Println("Invalid ticket presented, could not verify ticket")
 
-				// reject the client and then reset their stuff
-				SendErrorCode(SecureServer, client, nexproto.SecureProtocolID, callID, quazal.AccessDenied)
-				client.Reset()
-				SecureServer.Kick(client)
-				client.SetPlayerID(0)
-				return
-			}
+			// reject the client and then reset their stuff
+			SendErrorCode(SecureServer, client, nexproto.SecureProtocolID, callID, quazal.AccessDenied)
+			client.Reset()
+			SecureServer.Kick(client)
+			client.SetPlayerID(0)
+			return
 		}
 
 		// update station URLs and current console type
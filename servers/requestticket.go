@@ -1,9 +1,11 @@
 package servers
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/md5"
 	"log"
+	"rb3server/database"
 	"rb3server/quazal"
 
 	"github.com/ihatecompvir/nex-go"
@@ -20,7 +22,15 @@ func RequestTicket(err error, client *nex.Client, callID uint32, userPID uint32,
 
 	log.Printf("PID %v requesting ticket...\n", userPID)
 
-	encryptedTicket, kerberosKey := generateKerberosTicket(userPID, uint32(serverPID), 16, client.WiiFC)
+	storedKerberosKey, err := database.GetKerberosKey(context.TODO(), database.GocentralDatabase, userPID)
+	if err != nil {
+		log.Printf("Could not get Kerberos key for PID %v: %v\n", userPID, err)
+		SendErrorCode(AuthServer, client, nexproto.AuthenticationProtocolID, callID, quazal.OperationError)
+		return
+	}
+
+	kerberosKey := accountKerberosKey(storedKerberosKey, userPID, client.WiiFC)
+	encryptedTicket := generateKerberosTicket(userPID, uint32(serverPID), 16, kerberosKey)
 	mac := hmac.New(md5.New, kerberosKey)
 	mac.Write(encryptedTicket)
 	calculatedHmac := mac.Sum(nil)
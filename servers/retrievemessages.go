@@ -21,6 +21,9 @@ func RetrieveMessages(err error, client *nex.Client, callID uint32, pid uint32,
 	// Retrieve messages from the in-memory store
 	// If leaveOnServer is false, delete them after retrieval
 	messages := GlobalMessageStore.GetMessagesByIDs(pid, messageIDs, !leaveOnServer)
+	if !leaveOnServer {
+		forgetBattleWinnerMessages(pid, messageIDs)
+	}
 
 	rmcResponseStream := nex.NewStream()
 	rmcResponseStream.WriteUInt32LE(uint32(len(messages)))
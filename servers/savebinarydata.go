@@ -1,13 +1,15 @@
 package servers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
+	"rb3server/binarydata"
+	"rb3server/database"
 	"rb3server/quazal"
-	"strings"
+	"time"
 
 	"github.com/ihatecompvir/nex-go"
 	nexproto "github.com/ihatecompvir/nex-protocols-go"
@@ -40,26 +42,12 @@ func SaveBinaryData(err error, client *nex.Client, callID uint32, metadata strin
 		return
 	}
 
-	// try to get base path from environment variables
-	basePath := os.Getenv("BASEBINARYDATAPATH")
-
-	if basePath == "" {
-		basePath = "binary_data"
-	}
-
-	var filePath string
-
-	var platformExtension string
+	var dataKey string
+	var revision int64
 
 	// set platform-specific extension so files are saved correctly per platform
-	switch client.Platform() {
-	case 0:
-		platformExtension = "png_xbox"
-	case 1, 3:
-		platformExtension = "png_ps3"
-	case 2:
-		platformExtension = "png_wii"
-	default:
+	platformExtension, ok := binarydata.PlatformExtension(client.Platform())
+	if !ok {
 		log.Printf("Unsupported platform %d in requested metadata", client.Platform())
 		SendErrorCode(SecureServer, client, nexproto.RBBinaryDataProtocolID, callID, quazal.OperationError)
 		return
@@ -87,9 +75,9 @@ func SaveBinaryData(err error, client *nex.Client, callID uint32, metadata strin
 		}
 
 		// convert float64 to int64
-		revision := int64(revisionFloat)
+		revision = int64(revisionFloat)
 
-		filePath = filepath.Join(basePath, "setlist_art", SanitizePath(setlistGUID), fmt.Sprintf("%d.%s", revision, platformExtension))
+		dataKey = SanitizePath(setlistGUID)
 
 	case "battle_art":
 		// get the revision
@@ -102,12 +90,12 @@ func SaveBinaryData(err error, client *nex.Client, callID uint32, metadata strin
 		}
 
 		// convert float64 to int64
-		revision := int64(revisionFloat)
+		revision = int64(revisionFloat)
 
 		// battle_art can optionally have battle_id; try to get it, but dont fail if it cant be found
 		battleID, _ := metadataMap["battle_id"].(float64)
 
-		filePath = filepath.Join(basePath, "battle_art", fmt.Sprintf("%d", int64(battleID)), fmt.Sprintf("%d.%s", revision, platformExtension))
+		dataKey = fmt.Sprintf("%d", int64(battleID))
 
 	case "band_logo":
 		// get the band id
@@ -132,9 +120,9 @@ func SaveBinaryData(err error, client *nex.Client, callID uint32, metadata strin
 		}
 
 		// convert float64 to int64
-		revision := int64(revisionFloat)
+		revision = int64(revisionFloat)
 
-		filePath = filepath.Join(basePath, "band_logo", fmt.Sprintf("%d", bandID), fmt.Sprintf("%d.%s", revision, platformExtension))
+		dataKey = fmt.Sprintf("%d", bandID)
 
 	default:
 		log.Printf("Unsupported type %s in requested metadata", dataType)
@@ -142,33 +130,58 @@ func SaveBinaryData(err error, client *nex.Client, callID uint32, metadata strin
 		return
 	}
 
-	// Sanitize and validate the path
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	// Check if the path is still valid and under basePath
-	cleanBasePath := filepath.Clean(basePath)
-	cleanFilePath := filepath.Clean(filePath)
+	uploadsPerHour := defaultUploadsPerHour
+	if config, err := database.GetCachedConfig(ctx); err == nil && config.BinaryDataUploadsPerHour > 0 {
+		uploadsPerHour = config.BinaryDataUploadsPerHour
+	}
 
-	if !strings.HasPrefix(cleanFilePath, cleanBasePath) {
-		log.Println("Invalid path: ", cleanFilePath)
-		SendErrorCode(SecureServer, client, nexproto.RBBinaryDataProtocolID, callID, quazal.OperationError)
+	if !GlobalUploadLimiter.Allow(client.PlayerID(), uploadsPerHour, time.Now()) {
+		log.Printf("Player with PID %d has uploaded more than %d pieces of binary data in the last hour, not saving %s %s", client.PlayerID(), uploadsPerHour, dataType, dataKey)
+		SendErrorCode(SecureServer, client, nexproto.RBBinaryDataProtocolID, callID, quazal.AccessDenied)
 		return
 	}
 
-	// Create the directory if it doesn't exist
-	dir := filepath.Dir(cleanFilePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		log.Println("Error creating directory: ", err)
-		SendErrorCode(SecureServer, client, nexproto.RBBinaryDataProtocolID, callID, quazal.OperationError)
-		return
+	// art that doesn't look like something the console could have made is only refused when the config asks for it,
+	// otherwise it is logged so the texture checks can be compared against what consoles really send
+	if err := binarydata.ValidateTexture(data, platformExtension); err != nil {
+		if config, configErr := database.GetCachedConfig(ctx); configErr == nil && config.RejectInvalidTextures {
+			log.Printf("Rejecting %s %s from PID %d: %v", dataType, dataKey, client.PlayerID(), err)
+			SendErrorCode(SecureServer, client, nexproto.RBBinaryDataProtocolID, callID, quazal.ValidationError)
+			return
+		}
+		log.Printf("Saving %s %s from PID %d even though it failed texture validation: %v", dataType, dataKey, client.PlayerID(), err)
 	}
 
-	if err := os.WriteFile(cleanFilePath, data, 0644); err != nil {
-		log.Println("Error writing file: ", err)
+	// keeps the revision history and enforces the player's quota on top of writing the data to the store
+	err = database.StoreBinaryData(ctx, database.GocentralDatabase, binarydata.DefaultStore(), dataType, dataKey, revision, platformExtension, int(client.PlayerID()), data)
+	if err != nil {
+		if errors.Is(err, database.ErrBinaryDataQuotaExceeded) {
+			log.Printf("Player with PID %d is over their binary data quota, not saving %s %s", client.PlayerID(), dataType, dataKey)
+		} else {
+			log.Println("Error saving binary data: ", err)
+		}
 		SendErrorCode(SecureServer, client, nexproto.RBBinaryDataProtocolID, callID, quazal.OperationError)
 		return
 	}
 
-	log.Printf("Successfully saved binary data to %s", cleanFilePath)
+	log.Printf("Successfully saved binary data %s", binarydata.Key(dataType, dataKey, revision, platformExtension))
+
+	if err := database.QueueBinaryDataForModeration(ctx, database.GocentralDatabase, dataType, dataKey, revision, platformExtension, int(client.PlayerID())); err != nil {
+		log.Printf("Could not queue %s %s for moderation: %v", dataType, dataKey, err)
+	}
+
+	// make the art available to players on other consoles and the web without holding up the response
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := database.ConvertBinaryData(ctx, database.GocentralDatabase, binarydata.DefaultStore(), dataType, dataKey, revision, platformExtension, data); err != nil {
+			log.Printf("Could not convert %s for other platforms: %v", binarydata.Key(dataType, dataKey, revision, platformExtension), err)
+		}
+	}()
 
 	rmcResponseStream := nex.NewStream()
 
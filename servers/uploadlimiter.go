@@ -0,0 +1,50 @@
+package servers
+
+import (
+	"sync"
+	"time"
+)
+
+// how many uploads a player can make in an hour when the config doesn't say otherwise
+const defaultUploadsPerHour = 20
+
+// UploadLimiter keeps track of recent binary data uploads so a single player can't flood the store
+// it only lives in memory, so limits reset when the server restarts
+type UploadLimiter struct {
+	mu      sync.Mutex
+	uploads map[uint32][]time.Time // keyed by PID, oldest first
+	window  time.Duration
+}
+
+var GlobalUploadLimiter = NewUploadLimiter(time.Hour)
+
+// NewUploadLimiter creates a limiter that counts uploads over a sliding window
+func NewUploadLimiter(window time.Duration) *UploadLimiter {
+	return &UploadLimiter{
+		uploads: make(map[uint32][]time.Time),
+		window:  window,
+	}
+}
+
+// Allow records an upload for the PID and returns true, or returns false without recording it if the PID already made limit uploads in the window
+func (ul *UploadLimiter) Allow(pid uint32, limit int, now time.Time) bool {
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+
+	// drop the uploads that have fallen out of the window
+	recent := ul.uploads[pid]
+	cutoff := now.Add(-ul.window)
+	i := 0
+	for i < len(recent) && !recent[i].After(cutoff) {
+		i++
+	}
+	recent = recent[i:]
+
+	if len(recent) >= limit {
+		ul.uploads[pid] = recent
+		return false
+	}
+
+	ul.uploads[pid] = append(recent, now)
+	return true
+}
@@ -0,0 +1,400 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"rb3server/binarydata"
+	"rb3server/database"
+	"rb3server/models"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// exercises the basic operations every store has to support
+func testStore(t *testing.T, store binarydata.Store) {
+	ctx := context.Background()
+	key := binarydata.Key("setlist_art", "store-test-guid", 1, "png_ps3")
+
+	if _, err := store.Get(ctx, key); err != binarydata.ErrNotFound {
+		t.Fatalf("Expected ErrNotFound before anything is stored, got %v", err)
+	}
+
+	if err := store.Put(ctx, key, []byte("first")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := store.Put(ctx, key, []byte("second")); err != nil {
+		t.Fatalf("Put over an existing key failed: %v", err)
+	}
+	if err := store.Put(ctx, binarydata.Key("band_logo", "77", 1, "png_wii"), []byte("logo")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	data, err := store.Get(ctx, key)
+	if err != nil || string(data) != "second" {
+		t.Errorf("Expected to get back the latest data, got %q, %v", data, err)
+	}
+
+	objects, err := store.List(ctx, "setlist_art/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Key != key || objects[0].Size != int64(len("second")) {
+		t.Errorf("Expected only %s to be listed, got %+v", key, objects)
+	}
+
+	objects, _ = store.List(ctx, "")
+	if len(objects) != 2 {
+		t.Errorf("Expected 2 objects in total, got %+v", objects)
+	}
+
+	// listing a single key's revisions only looks in its own directory
+	objects, err = store.List(ctx, "band_logo/77/")
+	if err != nil || len(objects) != 1 || objects[0].Key != binarydata.Key("band_logo", "77", 1, "png_wii") {
+		t.Errorf("Expected only band 77's logo to be listed, got %+v, %v", objects, err)
+	}
+	objects, err = store.List(ctx, "band_logo/78/")
+	if err != nil || len(objects) != 0 {
+		t.Errorf("Expected nothing to be listed for a band without a logo, got %+v, %v", objects, err)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Expected deleting a missing key to not be an error, got %v", err)
+	}
+	if _, err := store.Get(ctx, key); err != binarydata.ErrNotFound {
+		t.Errorf("Expected ErrNotFound after deleting, got %v", err)
+	}
+}
+
+func TestFilesystemStore(t *testing.T) {
+	store := binarydata.NewFilesystemStore(t.TempDir())
+	testStore(t, store)
+
+	if err := store.Put(context.Background(), "../escaped", []byte("nope")); err == nil {
+		t.Error("Expected a key outside the base path to be rejected")
+	}
+}
+
+func TestGridFSStore(t *testing.T) {
+	store, err := binarydata.NewGridFSStore(database.GocentralDatabase)
+	if err != nil {
+		t.Fatalf("Could not create GridFS store: %v", err)
+	}
+	defer database.GocentralDatabase.Collection("binary_data.files").Drop(context.Background())
+	defer database.GocentralDatabase.Collection("binary_data.chunks").Drop(context.Background())
+
+	testStore(t, store)
+}
+
+// a tiny in-memory stand-in for an S3-compatible object store
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	t       *testing.T
+}
+
+func (s3 *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s3.mu.Lock()
+	defer s3.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-access/") {
+		s3.t.Errorf("Expected a signed request, got Authorization %q", r.Header.Get("Authorization"))
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != "art" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s3.objects[key] = data
+	case r.Method == http.MethodGet && key == "":
+		type content struct {
+			Key          string
+			Size         int64
+			LastModified time.Time
+		}
+		result := struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []content
+		}{}
+		keys := []string{}
+		for objectKey := range s3.objects {
+			if strings.HasPrefix(objectKey, r.URL.Query().Get("prefix")) {
+				keys = append(keys, objectKey)
+			}
+		}
+		sort.Strings(keys)
+		for _, objectKey := range keys {
+			result.Contents = append(result.Contents, content{objectKey, int64(len(s3.objects[objectKey])), time.Now()})
+		}
+		xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodGet:
+		data, ok := s3.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(s3.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: map[string][]byte{}, t: t})
+	defer server.Close()
+
+	testStore(t, &binarydata.S3Store{
+		Endpoint:  server.URL,
+		Bucket:    "art",
+		Region:    "us-east-1",
+		AccessKey: "test-access",
+		SecretKey: "test-secret",
+	})
+}
+
+func TestParseKey(t *testing.T) {
+	dataType, key, revision, extension, ok := binarydata.ParseKey(binarydata.Key("battle_art", "42", 7, "png_xbox"))
+	if !ok || dataType != "battle_art" || key != "42" || revision != 7 || extension != "png_xbox" {
+		t.Errorf("Expected battle_art/42/7.png_xbox to parse, got %s %s %d %s %v", dataType, key, revision, extension, ok)
+	}
+
+	if _, _, _, _, ok := binarydata.ParseKey("battle_art/42/latest.png_xbox"); ok {
+		t.Error("Expected a key without a numeric revision to not parse")
+	}
+}
+
+// Tests revision retention, deduplication and quotas when storing binary data
+func TestStoreBinaryData(t *testing.T) {
+	ctx := context.Background()
+	store := binarydata.NewFilesystemStore(t.TempDir())
+	objectsCollection := database.GocentralDatabase.Collection("binary_data_objects")
+	defer objectsCollection.DeleteMany(ctx, bson.M{"key": bson.M{"$in": []string{"retention-guid", "quota-guid"}}})
+
+	for revision := int64(1); revision <= 5; revision++ {
+		err := database.StoreBinaryData(ctx, database.GocentralDatabase, store, "setlist_art", "retention-guid", revision, "png_ps3", 77301, []byte("revision data"))
+		if err != nil {
+			t.Fatalf("Could not store revision %d: %v", revision, err)
+		}
+	}
+
+	var object models.BinaryDataObject
+	objectsCollection.FindOne(ctx, bson.M{"key": "retention-guid"}).Decode(&object)
+	if len(object.Revisions) != 3 || object.Revisions[0].Revision != 3 {
+		t.Errorf("Expected the newest 3 revisions to be kept, got %+v", object.Revisions)
+	}
+	if object.TotalSize != 3*int64(len("revision data")) {
+		t.Errorf("Expected the total size of the kept revisions, got %d", object.TotalSize)
+	}
+	if object.Revisions[0].Hash == "" {
+		t.Error("Expected revisions to have a content hash")
+	}
+
+	if _, err := store.Get(ctx, binarydata.Key("setlist_art", "retention-guid", 2, "png_ps3")); err != binarydata.ErrNotFound {
+		t.Errorf("Expected revision 2 to be deleted from the store, got %v", err)
+	}
+	if _, err := store.Get(ctx, binarydata.Key("setlist_art", "retention-guid", 5, "png_ps3")); err != nil {
+		t.Errorf("Expected revision 5 to be in the store, got %v", err)
+	}
+
+	usage, _ := database.GetBinaryDataUsage(ctx, database.GocentralDatabase, 77301)
+	if usage != object.TotalSize {
+		t.Errorf("Expected usage of %d bytes, got %d", object.TotalSize, usage)
+	}
+
+	// the default quota is 16 MB
+	tooBig := make([]byte, 17<<20)
+	err := database.StoreBinaryData(ctx, database.GocentralDatabase, store, "setlist_art", "quota-guid", 1, "png_ps3", 77301, tooBig)
+	if err != database.ErrBinaryDataQuotaExceeded {
+		t.Errorf("Expected the quota to be enforced, got %v", err)
+	}
+
+	// admin uploads have no quota
+	err = database.StoreBinaryData(ctx, database.GocentralDatabase, store, "setlist_art", "quota-guid", 1, "png_ps3", 0, tooBig)
+	if err != nil {
+		t.Errorf("Expected an admin upload to ignore the quota, got %v", err)
+	}
+}
+
+// Tests garbage collecting binary data whose setlist, battle or band is gone
+func TestCollectOrphanedBinaryData(t *testing.T) {
+	ctx := context.Background()
+	store := binarydata.NewFilesystemStore(t.TempDir())
+	setlistsCollection := database.GocentralDatabase.Collection("setlists")
+	objectsCollection := database.GocentralDatabase.Collection("binary_data_objects")
+	defer setlistsCollection.DeleteMany(ctx, bson.M{"guid": "gc-live-guid"})
+	defer objectsCollection.DeleteMany(ctx, bson.M{"key": bson.M{"$in": []string{"gc-live-guid", "gc-dead-guid"}}})
+
+	setlistsCollection.InsertOne(ctx, models.Setlist{SetlistID: 88201, GUID: "gc-live-guid", Type: 0, Shared: "t"})
+
+	database.StoreBinaryData(ctx, database.GocentralDatabase, store, "setlist_art", "gc-live-guid", 1, "png_ps3", 0, []byte("live"))
+	database.StoreBinaryData(ctx, database.GocentralDatabase, store, "setlist_art", "gc-dead-guid", 1, "png_ps3", 0, []byte("dead"))
+	store.Put(ctx, binarydata.Key("band_logo", "889999", 1, "png_wii"), []byte("legacy logo"))
+
+	// nothing is collected during the grace period
+	deleted, err := database.CollectOrphanedBinaryData(ctx, database.GocentralDatabase, store, time.Now())
+	if err != nil || deleted != 0 {
+		t.Fatalf("Expected nothing to be collected yet, got %d, %v", deleted, err)
+	}
+
+	deleted, err = database.CollectOrphanedBinaryData(ctx, database.GocentralDatabase, store, time.Now().Add(48*time.Hour))
+	if err != nil || deleted != 2 {
+		t.Fatalf("Expected the dead setlist art and band logo to be collected, got %d, %v", deleted, err)
+	}
+
+	if _, err := store.Get(ctx, binarydata.Key("setlist_art", "gc-live-guid", 1, "png_ps3")); err != nil {
+		t.Errorf("Expected art for an existing setlist to be kept, got %v", err)
+	}
+	if count, _ := objectsCollection.CountDocuments(ctx, bson.M{"key": "gc-dead-guid"}); count != 0 {
+		t.Error("Expected the revision history of collected art to be deleted")
+	}
+}
+
+// makes a test image with a few solid blocks of colour and a fully transparent corner
+func makeTestTexture() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			switch {
+			case x < 8 && y < 8:
+				img.SetNRGBA(x, y, color.NRGBA{255, 0, 0, 255})
+			case x >= 8 && y < 8:
+				img.SetNRGBA(x, y, color.NRGBA{0, 0, 255, 255})
+			case x < 8:
+				img.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 255})
+			default:
+				img.SetNRGBA(x, y, color.NRGBA{0, 0, 0, 0})
+			}
+		}
+	}
+	return img
+}
+
+// Tests that art survives being encoded to and decoded from every console's texture format
+func TestTextureRoundTrip(t *testing.T) {
+	source := makeTestTexture()
+
+	for _, platformExtension := range binarydata.PlatformExtensions {
+		data, err := binarydata.EncodeTexture(source, platformExtension)
+		if err != nil {
+			t.Fatalf("Could not encode %s: %v", platformExtension, err)
+		}
+
+		decoded, err := binarydata.DecodeTexture(data, platformExtension)
+		if err != nil {
+			t.Fatalf("Could not decode %s: %v", platformExtension, err)
+		}
+
+		if decoded.Bounds() != source.Bounds() {
+			t.Fatalf("Expected %s to keep the image size, got %v", platformExtension, decoded.Bounds())
+		}
+
+		// block compression is lossy, but solid blocks come back close to exact
+		for _, point := range []image.Point{{2, 2}, {12, 2}, {2, 12}} {
+			want := source.NRGBAAt(point.X, point.Y)
+			got := decoded.NRGBAAt(point.X, point.Y)
+			if absDiff(want.R, got.R) > 8 || absDiff(want.G, got.G) > 8 || absDiff(want.B, got.B) > 8 || got.A != 255 {
+				t.Errorf("Expected %s pixel %v to be close to %v, got %v", platformExtension, point, want, got)
+			}
+		}
+		if got := decoded.NRGBAAt(12, 12); got.A != 0 {
+			t.Errorf("Expected %s to keep transparency, got %v", platformExtension, got)
+		}
+	}
+
+	if _, err := binarydata.DecodeTexture([]byte("not a texture"), "png_ps3"); err == nil {
+		t.Error("Expected garbage to fail to decode")
+	}
+}
+
+func absDiff(a uint8, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// Tests that art uploaded from one console can be loaded in every other format and as a PNG
+func TestConvertBinaryData(t *testing.T) {
+	ctx := context.Background()
+	store := binarydata.NewFilesystemStore(t.TempDir())
+	defer database.GocentralDatabase.Collection("binary_data_objects").DeleteMany(ctx, bson.M{"key": "convert-guid"})
+
+	wiiArt, err := binarydata.EncodeTexture(makeTestTexture(), "png_wii")
+	if err != nil {
+		t.Fatalf("Could not encode Wii art: %v", err)
+	}
+	store.Put(ctx, binarydata.Key("setlist_art", "convert-guid", 3, "png_wii"), wiiArt)
+
+	data, err := database.LoadBinaryData(ctx, database.GocentralDatabase, store, "setlist_art", "convert-guid", 3, "png_xbox")
+	if err != nil {
+		t.Fatalf("Expected Wii art to be converted for the Xbox, got %v", err)
+	}
+	if _, err := binarydata.DecodeTexture(data, "png_xbox"); err != nil {
+		t.Errorf("Expected the converted art to be a valid Xbox texture, got %v", err)
+	}
+
+	data, err = database.LoadCanonicalImage(ctx, database.GocentralDatabase, store, "setlist_art", "convert-guid")
+	if err != nil {
+		t.Fatalf("Expected a canonical PNG, got %v", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("Expected the canonical image to be a PNG, got %v", err)
+	}
+
+	if _, err := database.LoadCanonicalImage(ctx, database.GocentralDatabase, store, "setlist_art", "missing-guid"); err != binarydata.ErrNotFound {
+		t.Errorf("Expected ErrNotFound for missing art, got %v", err)
+	}
+}
+
+// Tests that uploads have to be well-formed textures for the console that sent them
+func TestValidateTexture(t *testing.T) {
+	for _, platformExtension := range binarydata.PlatformExtensions {
+		data, _ := binarydata.EncodeTexture(makeTestTexture(), platformExtension)
+		if err := binarydata.ValidateTexture(data, platformExtension); err != nil {
+			t.Errorf("Expected a %s texture to be valid, got %v", platformExtension, err)
+		}
+
+		if err := binarydata.ValidateTexture(data[:len(data)-1], platformExtension); err == nil {
+			t.Errorf("Expected a truncated %s texture to be rejected", platformExtension)
+		}
+
+		if err := binarydata.ValidateTexture(append(data, make([]byte, 1024)...), platformExtension); err == nil {
+			t.Errorf("Expected a %s texture with junk on the end to be rejected", platformExtension)
+		}
+	}
+
+	ps3Art, _ := binarydata.EncodeTexture(makeTestTexture(), "png_ps3")
+	if err := binarydata.ValidateTexture(ps3Art, "png_wii"); !errors.Is(err, binarydata.ErrInvalidTexture) {
+		t.Errorf("Expected PS3 art uploaded from a Wii to be rejected, got %v", err)
+	}
+
+	tooBig, _ := binarydata.EncodeTexture(image.NewNRGBA(image.Rect(0, 0, 1024, 1024)), "png_ps3")
+	if err := binarydata.ValidateTexture(tooBig, "png_ps3"); err == nil {
+		t.Error("Expected art larger than 512x512 to be rejected")
+	}
+
+	if err := binarydata.ValidateTexture([]byte("art"), "png_xbox"); err == nil {
+		t.Error("Expected garbage to be rejected")
+	}
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/hex"
+	"rb3server/authentication"
+	"rb3server/database"
+	"testing"
+
+	"github.com/ihatecompvir/nex-go"
+)
+
+func TestDeriveKerberosKey(t *testing.T) {
+	// the same derivation Quazal clients do, which tickets have always been encrypted with
+	expected := []byte("password")
+	for i := 0; i < 65000+(1234%1024); i++ {
+		expected = nex.MD5Hash(expected)
+	}
+
+	if key := authentication.DeriveKerberosKey(1234, "password"); !bytes.Equal(key, expected) {
+		t.Errorf("Expected %x, got %x", expected, key)
+	}
+
+	if bytes.Equal(authentication.DeriveKerberosKey(1234, "password"), authentication.DeriveKerberosKey(1235, "password")) {
+		t.Error("Expected the key to depend on the PID")
+	}
+}
+
+func TestAccountSecrets(t *testing.T) {
+	t.Setenv("USERPASSWORD", "shared")
+
+	if authentication.PlatformSecret("") != "shared" || authentication.PlatformSecret("1234567890123456") != "1234567890123456" {
+		t.Error("Expected the platform's password to be USERPASSWORD, or the friend code on Wii")
+	}
+
+	secret, err := authentication.GenerateAccountSecret()
+	if err != nil {
+		t.Fatalf("Could not generate secret: %v", err)
+	}
+	if secret == "" || secret == "shared" {
+		t.Errorf("Expected a generated secret, got %q", secret)
+	}
+
+	if other, _ := authentication.GenerateAccountSecret(); other == secret {
+		t.Error("Expected every account to get a different secret")
+	}
+
+	key := authentication.DeriveKerberosKey(1234, secret)
+	if stored := database.DecodeKerberosKey(hex.EncodeToString(key)); !bytes.Equal(stored, key) {
+		t.Errorf("Expected a stored key to decode, got %x", stored)
+	}
+	if database.DecodeKerberosKey("") != nil || database.DecodeKerberosKey("abcd") != nil {
+		t.Error("Expected a missing or malformed key to mean the platform's password")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"rb3server/database"
+	"rb3server/models"
 	"strings"
 	"testing"
 	"time"
@@ -854,7 +855,10 @@ func TestGenerateLinkCode(t *testing.T) {
 	t.Run("Generates correct length", func(t *testing.T) {
 		lengths := []int{5, 10, 15, 20}
 		for _, length := range lengths {
-			code := database.GenerateLinkCode(length)
+			code, err := database.GenerateLinkCode(length)
+			if err != nil {
+				t.Fatalf("Failed to generate link code: %v", err)
+			}
 			if len(code) != length {
 				t.Errorf("Expected link code of length %d, got %d", length, len(code))
 			}
@@ -863,7 +867,7 @@ func TestGenerateLinkCode(t *testing.T) {
 
 	t.Run("Contains only valid characters", func(t *testing.T) {
 		validChars := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
-		code := database.GenerateLinkCode(100) // Generate a long code to test randomness
+		code, _ := database.GenerateLinkCode(100) // Generate a long code to test randomness
 
 		for _, char := range code {
 			found := false
@@ -882,7 +886,7 @@ func TestGenerateLinkCode(t *testing.T) {
 	t.Run("Generates unique codes", func(t *testing.T) {
 		codes := make(map[string]bool)
 		for i := 0; i < 100; i++ {
-			code := database.GenerateLinkCode(10)
+			code, _ := database.GenerateLinkCode(10)
 			if codes[code] {
 				t.Logf("Warning: Duplicate code generated: %s (this can happen rarely due to randomness)", code)
 			}
@@ -895,7 +899,7 @@ func TestGenerateLinkCode(t *testing.T) {
 	})
 
 	t.Run("Zero length returns empty string", func(t *testing.T) {
-		code := database.GenerateLinkCode(0)
+		code, _ := database.GenerateLinkCode(0)
 		if code != "" {
 			t.Errorf("Expected empty string for length 0, got %q", code)
 		}
@@ -1534,3 +1538,136 @@ func TestFriendsLeaderboard_EmptyFriendsList(t *testing.T) {
 
 	t.Logf("User with no friends has %d entries in friends leaderboard", count)
 }
+
+// Tests checking privileges through group memberships
+func TestHasPrivilege(t *testing.T) {
+	// 500 is in the admin group, which doesn't grant anything that changes what happens in-game
+	if !database.HasPrivilege(500, database.PrivilegeVerifiedBadge) || !database.HasPrivilege(500, database.PrivilegeModeratorMessaging) {
+		t.Error("Expected an admin to have the admin privileges")
+	}
+	if database.HasPrivilege(500, database.PrivilegeUnlimitedBattles) || database.HasPrivilege(500, database.PrivilegeCreateHarmonixBattles) {
+		t.Error("Expected an admin not to get in-game privileges without being in their groups")
+	}
+
+	// 501 is in no groups
+	if database.HasPrivilege(501, database.PrivilegeUnlimitedBattles) {
+		t.Error("Expected a user with no groups to have no privileges")
+	}
+
+	if database.HasPrivilege(500, "not_a_privilege") {
+		t.Error("Expected an unknown privilege to never be granted")
+	}
+
+	if groups := database.GetGroupsWithPrivilege(database.PrivilegeCreateHarmonixBattles); len(groups) != 1 || groups[0] != "battle_admin" {
+		t.Errorf("Expected only battle_admin to grant creating Harmonix battles, got %v", groups)
+	}
+}
+
+// Tests which clients see a curated setlist
+func TestIsCuratedSetlistVisible(t *testing.T) {
+	now := time.Now()
+
+	if !database.IsCuratedSetlistVisible(models.Setlist{Type: 2}, 0, "us", now) {
+		t.Error("Expected an untargeted curated setlist to be visible")
+	}
+	if database.IsCuratedSetlistVisible(models.Setlist{Type: 2, Retired: true}, 0, "us", now) {
+		t.Error("Expected a retired curated setlist to be hidden")
+	}
+
+	thisWeek := models.Setlist{Type: 2, VisibleFrom: now.Add(-24 * time.Hour).Unix(), VisibleUntil: now.Add(6 * 24 * time.Hour).Unix()}
+	if !database.IsCuratedSetlistVisible(thisWeek, 0, "us", now) {
+		t.Error("Expected this week's curated setlist to be visible")
+	}
+	if database.IsCuratedSetlistVisible(thisWeek, 0, "us", now.Add(7*24*time.Hour)) {
+		t.Error("Expected this week's curated setlist to be hidden next week")
+	}
+	if database.IsCuratedSetlistVisible(thisWeek, 0, "us", now.Add(-2*24*time.Hour)) {
+		t.Error("Expected this week's curated setlist to be hidden before it is scheduled")
+	}
+
+	ps3Only := models.Setlist{Type: 2, Platforms: []int{1}}
+	if !database.IsCuratedSetlistVisible(ps3Only, 3, "us", now) {
+		t.Error("Expected a PS3 curated setlist to be visible on RPCS3")
+	}
+	if database.IsCuratedSetlistVisible(ps3Only, 2, "us", now) {
+		t.Error("Expected a PS3 curated setlist to be hidden on Wii")
+	}
+
+	europeOnly := models.Setlist{Type: 2, Regions: []string{"eu"}}
+	if !database.IsCuratedSetlistVisible(europeOnly, 0, "EU", now) {
+		t.Error("Expected region matching to ignore case")
+	}
+	if database.IsCuratedSetlistVisible(europeOnly, 0, "us", now) {
+		t.Error("Expected a European curated setlist to be hidden in the US")
+	}
+}
+
+// Tests the rules character and band names are checked against
+func TestCheckEntityNames(t *testing.T) {
+	ctx := context.Background()
+	configCollection := database.GocentralDatabase.Collection("config")
+	charactersCollection := database.GocentralDatabase.Collection("characters")
+	bandsCollection := database.GocentralDatabase.Collection("bands")
+
+	_, err := configCollection.UpdateOne(ctx, bson.M{}, bson.M{"$set": bson.M{
+		"profanity_list":         []string{"badword"},
+		"profanity_filters":      []models.ProfanityEntry{{Term: "heck", Match: "word", Severity: "mask"}},
+		"entity_name_max_length": 12,
+		"unique_entity_names":    true,
+	}})
+	if err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+	defer func() {
+		configCollection.UpdateOne(ctx, bson.M{}, bson.M{
+			"$set":   bson.M{"profanity_list": []string{}},
+			"$unset": bson.M{"profanity_filters": "", "entity_name_max_length": "", "unique_entity_names": ""},
+		})
+		database.InvalidateConfigCache()
+	}()
+	database.InvalidateConfigCache()
+
+	charactersCollection.InsertOne(ctx, bson.M{"owner_pid": 501, "name": "Taken Name", "guid": "name-check-character"})
+	defer charactersCollection.DeleteOne(ctx, bson.M{"guid": "name-check-character"})
+	bandsCollection.InsertOne(ctx, bson.M{"owner_pid": 501, "name": "Taken Band", "band_id": 88801})
+	defer bandsCollection.DeleteOne(ctx, bson.M{"band_id": 88801})
+
+	testCases := []struct {
+		name     string
+		pid      int
+		expected database.NameCheckResult
+	}{
+		{"Stagehand", 500, database.NameAllowed},
+		{"Ünïcødé Fan", 500, database.NameAllowed},
+		{"", 500, database.NameInvalid},
+		{"   ", 500, database.NameInvalid},
+		{"Far Too Long A Name", 500, database.NameInvalid},
+		{"Tab\tName", 500, database.NameInvalid},
+		{"BadWordHaver", 500, database.NameProfane},
+		{"taken name", 500, database.NameTaken},
+		{"Taken Name", 501, database.NameAllowed}, // players can reuse their own names
+	}
+
+	for _, tc := range testCases {
+		result, _, err := database.CheckCharacterName(ctx, database.GocentralDatabase, tc.name, tc.pid)
+		if err != nil {
+			t.Fatalf("Could not check %q: %v", tc.name, err)
+		}
+		if result != tc.expected {
+			t.Errorf("Expected %v for character name %q from PID %d, got %v", tc.expected, tc.name, tc.pid, result)
+		}
+	}
+
+	// masked words don't stop a name from being used, they are just stored masked
+	result, name, err := database.CheckCharacterName(ctx, database.GocentralDatabase, "Heck Yeah", 500)
+	if err != nil || result != database.NameAllowed || name != "**** Yeah" {
+		t.Errorf("Expected \"Heck Yeah\" to be allowed as \"**** Yeah\", got %v %q (%v)", result, name, err)
+	}
+
+	if result, _, _ := database.CheckBandName(ctx, database.GocentralDatabase, "Taken Band", 500); result != database.NameTaken {
+		t.Errorf("Expected another player's band name to be taken, got %v", result)
+	}
+	if result, _, _ := database.CheckBandName(ctx, database.GocentralDatabase, "Taken Name", 500); result != database.NameAllowed {
+		t.Errorf("Expected band names not to clash with character names, got %v", result)
+	}
+}
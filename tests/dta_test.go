@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"rb3server/serialization/dta"
+	"testing"
+)
+
+const testSongsDTA = `; a comment that should be ignored
+#include ../macros.dta
+(spoonman
+   (name "Spoonman")
+   (artist 'Soundgarden')
+   (master TRUE)
+   (song_id 1048)
+   (song (name "songs/spoonman/spoonman") (tracks ((drum (0 1)) (bass 2))))
+   (rank
+      (drum 320)
+      (guitar 240)
+      (bass 0)
+      (vocals 100)
+      (keys 0)
+      (real_keys 0)
+      (band 250)
+   )
+   (genre grunge)
+   (year_released 1994)
+   (album_name "Superunknown")
+   (game_origin rb3)
+)
+(ugcsong
+   (name "Some \qQuoted\q Song")
+   (artist "Someone")
+   (song_id 2000123)
+   (game_origin ugc_plus)
+   (rank (guitar 500))
+)
+(dlcsong
+   (name "A DLC Song")
+   (song_id 10234)
+   (game_origin rb2)
+)
+(broken_id
+   (name "Has No Numeric ID")
+   (song_id not_a_number)
+)
+`
+
+func TestDTAParse(t *testing.T) {
+	root, err := dta.Parse([]byte(testSongsDTA))
+	if err != nil {
+		t.Fatalf("Failed to parse DTA: %v", err)
+	}
+
+	if len(root) != 4 {
+		t.Fatalf("Expected 4 top level nodes, got %d", len(root))
+	}
+
+	spoonman, ok := root[0].(dta.Array)
+	if !ok || spoonman.Key() != "spoonman" {
+		t.Fatalf("Expected first node to be the spoonman array, got %v", root[0])
+	}
+
+	if artist, _ := spoonman.FindString("artist"); artist != "Soundgarden" {
+		t.Errorf("Expected artist Soundgarden, got %q", artist)
+	}
+
+	if year, _ := spoonman.FindInt("year_released"); year != 1994 {
+		t.Errorf("Expected year 1994, got %d", year)
+	}
+}
+
+func TestDTAParse_Errors(t *testing.T) {
+	for _, input := range []string{"(unclosed (name \"x\")", "(name \"unterminated)", "(a))"} {
+		if _, err := dta.Parse([]byte(input)); err == nil {
+			t.Errorf("Expected an error parsing %q", input)
+		}
+	}
+}
+
+func TestSongsDeserializer(t *testing.T) {
+	songs, err := dta.SongsDeserializer{}.Deserialize([]byte(testSongsDTA))
+	if err != nil {
+		t.Fatalf("Failed to deserialize songs: %v", err)
+	}
+
+	if len(songs) != 3 {
+		t.Fatalf("Expected 3 songs, got %d", len(songs))
+	}
+
+	spoonman := songs[0]
+	if spoonman.SongID != 1048 || spoonman.ShortName != "spoonman" || spoonman.Title != "Spoonman" {
+		t.Errorf("Unexpected song identity: %+v", spoonman)
+	}
+	if spoonman.Album != "Superunknown" || spoonman.Genre != "grunge" || spoonman.Source != "rb3" {
+		t.Errorf("Unexpected song metadata: %+v", spoonman)
+	}
+	if spoonman.Ranks["drum"] != 320 || spoonman.Tiers["drum"] != 5 {
+		t.Errorf("Expected drum rank 320 and tier 5, got %d and %d", spoonman.Ranks["drum"], spoonman.Tiers["drum"])
+	}
+	if spoonman.Tiers["bass"] != 0 {
+		t.Errorf("Expected no bass part, got tier %d", spoonman.Tiers["bass"])
+	}
+
+	if songs[1].Source != "rbn" || songs[1].Title != `Some "Quoted" Song` {
+		t.Errorf("Unexpected RBN song: %+v", songs[1])
+	}
+
+	if songs[2].Source != "dlc" {
+		t.Errorf("Expected DLC source, got %q", songs[2].Source)
+	}
+}
+
+func TestRankToTier(t *testing.T) {
+	testCases := []struct {
+		instrument string
+		rank       int
+		expected   int
+	}{
+		{"guitar", 0, 0},
+		{"guitar", 1, 1},
+		{"guitar", 138, 1},
+		{"guitar", 139, 2},
+		{"guitar", 408, 6},
+		{"guitar", 409, 7},
+		{"band", 345, 7},
+		{"kazoo", 300, 0},
+	}
+
+	for _, tc := range testCases {
+		if tier := dta.RankToTier(tc.instrument, tc.rank); tier != tc.expected {
+			t.Errorf("RankToTier(%q, %d) = %d, expected %d", tc.instrument, tc.rank, tier, tc.expected)
+		}
+	}
+}
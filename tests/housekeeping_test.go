@@ -726,3 +726,448 @@ func TestCleanupBannedUserScores_CaseVariations(t *testing.T) {
 		})
 	}
 }
+
+func TestMigrateScoreProvenance(t *testing.T) {
+	ctx := context.Background()
+	scoresCollection := database.GocentralDatabase.Collection("scores")
+
+	// PID 500 is a PS3 user in the mock data, 999999 has no user at all
+	legacyID := insertTestScore(t, map[string]interface{}{"pid": 500, "song_id": 7001, "role_id": 0, "score": 1234})
+	orphanID := insertTestScore(t, map[string]interface{}{"pid": 999999, "song_id": 7001, "role_id": 0, "score": 1234})
+	modernID := insertTestScore(t, map[string]interface{}{"pid": 500, "song_id": 7002, "role_id": 0, "score": 1234, "submitted_at": int64(42), "console_type": 3})
+	defer deleteTestScores(t, []primitive.ObjectID{legacyID, orphanID, modernID})
+
+	configCollection := database.GocentralDatabase.Collection("config")
+	configCollection.UpdateOne(ctx, bson.M{}, bson.M{"$pull": bson.M{"completed_migrations": "score_provenance"}})
+
+	database.MigrateScoreProvenance()
+	// running it twice must not change anything
+	database.MigrateScoreProvenance()
+
+	if count, _ := configCollection.CountDocuments(ctx, bson.M{"completed_migrations": "score_provenance"}); count != 1 {
+		t.Error("Expected the migration to be recorded as completed")
+	}
+
+	// once it has finished it doesn't scan the scores again
+	laterID := insertTestScore(t, map[string]interface{}{"pid": 500, "song_id": 7003, "role_id": 0, "score": 1234})
+	defer deleteTestScores(t, []primitive.ObjectID{laterID})
+	database.MigrateScoreProvenance()
+	if count, _ := scoresCollection.CountDocuments(ctx, bson.M{"_id": laterID, "submitted_at": bson.M{"$exists": false}}); count != 1 {
+		t.Error("Expected a completed migration not to run again")
+	}
+
+	var legacy models.Score
+	if err := scoresCollection.FindOne(ctx, bson.M{"_id": legacyID}).Decode(&legacy); err != nil {
+		t.Fatalf("Failed to find legacy score: %v", err)
+	}
+	if legacy.SubmittedAt != legacyID.Timestamp().Unix() {
+		t.Errorf("Expected submitted_at %d, got %d", legacyID.Timestamp().Unix(), legacy.SubmittedAt)
+	}
+	if legacy.ConsoleType != 1 {
+		t.Errorf("Expected console_type 1, got %d", legacy.ConsoleType)
+	}
+
+	count, _ := scoresCollection.CountDocuments(ctx, bson.M{"_id": orphanID, "console_type": bson.M{"$exists": false}})
+	if count != 1 {
+		t.Error("Expected score without a known owner to keep an unset console_type")
+	}
+
+	var modern models.Score
+	scoresCollection.FindOne(ctx, bson.M{"_id": modernID}).Decode(&modern)
+	if modern.SubmittedAt != 42 || modern.ConsoleType != 3 {
+		t.Errorf("Expected existing provenance to be untouched, got submitted_at=%d console_type=%d", modern.SubmittedAt, modern.ConsoleType)
+	}
+}
+
+// Tests that scores breaking imported song limits are moved into quarantine
+func TestQuarantineImplausibleScores(t *testing.T) {
+	ctx := context.Background()
+	songsCollection := database.GocentralDatabase.Collection("songs")
+	quarantineCollection := database.GocentralDatabase.Collection("quarantined_scores")
+
+	testPID := 777901
+	testSongID := 88801
+
+	songsCollection.InsertOne(ctx, models.Song{
+		SongID: testSongID,
+		Title:  "Quarantine Test Song",
+		Limits: []models.SongLimits{
+			{RoleID: 1, DiffID: 3, MaxScore: 100000, NoteCount: 500, StarThresholds: []int{10000, 20000, 30000, 40000, 50000, 80000}},
+			{RoleID: 4, DiffID: 3, MaxScore: 0, NoteCount: 0},
+		},
+	})
+	defer songsCollection.DeleteMany(ctx, bson.M{"song_id": testSongID})
+	defer quarantineCollection.DeleteMany(ctx, bson.M{"score.pid": testPID})
+	defer database.GocentralDatabase.Collection("scores").DeleteMany(ctx, bson.M{"pid": testPID})
+
+	insertTestScore(t, map[string]interface{}{"pid": testPID, "song_id": testSongID, "role_id": 1, "diff_id": 3, "score": 95000, "stars": 6, "notespct": 100})  // valid
+	insertTestScore(t, map[string]interface{}{"pid": testPID, "song_id": testSongID, "role_id": 1, "diff_id": 3, "score": 150000, "stars": 6, "notespct": 100}) // above max score
+	insertTestScore(t, map[string]interface{}{"pid": testPID, "song_id": testSongID, "role_id": 1, "diff_id": 3, "score": 15000, "stars": 5, "notespct": 90})   // stars not earned
+	insertTestScore(t, map[string]interface{}{"pid": testPID, "song_id": testSongID, "role_id": 4, "diff_id": 3, "score": 5000, "stars": 1, "notespct": 50})    // part doesn't exist
+	insertTestScore(t, map[string]interface{}{"pid": testPID, "song_id": testSongID, "role_id": 2, "diff_id": 3, "score": 999999, "stars": 6, "notespct": 100}) // no limits for this part
+
+	// only the songs asked about are looked at
+	if quarantined := database.QuarantineImplausibleScores(ctx, []int{testSongID + 1}); quarantined != 0 {
+		t.Errorf("Expected nothing to be quarantined for another song, got %d", quarantined)
+	}
+
+	if quarantined := database.QuarantineImplausibleScores(ctx, []int{testSongID}); quarantined != 3 {
+		t.Errorf("Expected 3 scores to be quarantined, got %d", quarantined)
+	}
+
+	if remaining := countScores(t, bson.M{"pid": testPID}); remaining != 2 {
+		t.Errorf("Expected 2 scores to remain, got %d", remaining)
+	}
+
+	quarantined, _ := quarantineCollection.CountDocuments(ctx, bson.M{"score.pid": testPID, "status": "pending"})
+	if quarantined != 3 {
+		t.Errorf("Expected 3 quarantined scores, got %d", quarantined)
+	}
+}
+
+// Tests the individual plausibility checks
+func TestValidateScoreAgainstLimits(t *testing.T) {
+	limits := &models.SongLimits{RoleID: 0, DiffID: 3, MaxScore: 200000, NoteCount: 800, StarThresholds: []int{20000, 40000, 60000, 80000, 100000, 150000}}
+
+	testCases := []struct {
+		name    string
+		score   models.Score
+		limits  *models.SongLimits
+		invalid bool
+	}{
+		{"Valid gold stars", models.Score{Score: 160000, Stars: 6}, limits, false},
+		{"Valid with fewer stars than earned", models.Score{Score: 160000, Stars: 3}, limits, false},
+		{"Above max score", models.Score{Score: 200001, Stars: 6}, limits, true},
+		{"Stars not earned", models.Score{Score: 79999, Stars: 4}, limits, true},
+		{"Part doesn't exist", models.Score{Score: 100, Stars: 0}, &models.SongLimits{NoteCount: 0}, true},
+		{"No limits", models.Score{Score: 99999999, Stars: 6}, nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reasons := database.ValidateScoreAgainstLimits(tc.score, tc.limits)
+			if tc.invalid != (len(reasons) > 0) {
+				t.Errorf("Expected invalid=%v, got reasons %v", tc.invalid, reasons)
+			}
+		})
+	}
+}
+
+// Tests moving the old single accomplishments document into one document per player per goal
+func TestMigrateAccomplishments(t *testing.T) {
+	ctx := context.Background()
+	legacyCollection := database.GocentralDatabase.Collection("accomplishments")
+	accomplishmentsCollection := database.GocentralDatabase.Collection("accomplishment_scores")
+
+	testPIDs := []int{78801, 78802}
+	defer accomplishmentsCollection.DeleteMany(ctx, bson.M{"pid": bson.M{"$in": testPIDs}})
+
+	legacyCollection.InsertOne(ctx, bson.M{
+		"lb_goal_value_acc_millionpoints": bson.A{
+			bson.M{"pid": 78801, "score": 5},
+			bson.M{"pid": 78802, "score": 9},
+		},
+		"lb_goal_value_campaign_metascore": bson.A{
+			bson.M{"pid": 78801, "score": 1200},
+		},
+	})
+	defer legacyCollection.DeleteMany(ctx, bson.M{})
+
+	// a value recorded after the old document was written should win over the old value
+	database.RecordAccomplishmentScores(ctx, database.GocentralDatabase, 78802, map[string]int{"acc_millionpoints": 20})
+
+	database.MigrateAccomplishments()
+
+	if count, _ := accomplishmentsCollection.CountDocuments(ctx, bson.M{"pid": bson.M{"$in": testPIDs}}); count != 3 {
+		t.Errorf("Expected 3 migrated accomplishment entries, got %d", count)
+	}
+
+	var entry models.AccomplishmentScore
+	accomplishmentsCollection.FindOne(ctx, bson.M{"acc_id": "acc_millionpoints", "pid": 78802}).Decode(&entry)
+	if entry.Score != 20 {
+		t.Errorf("Expected newer score of 20 to be kept, got %d", entry.Score)
+	}
+
+	if count, _ := legacyCollection.CountDocuments(ctx, bson.M{}); count != 0 {
+		t.Errorf("Expected the legacy accomplishments document to be deleted, got %d documents", count)
+	}
+
+	// running it again should do nothing
+	database.MigrateAccomplishments()
+	if count, _ := accomplishmentsCollection.CountDocuments(ctx, bson.M{"pid": bson.M{"$in": testPIDs}}); count != 3 {
+		t.Errorf("Expected 3 accomplishment entries after rerunning, got %d", count)
+	}
+}
+
+// Tests that permanently banned users are removed from every accomplishment leaderboard
+func TestCleanupBannedUserAccomplishments(t *testing.T) {
+	ctx := context.Background()
+	configCollection := database.GocentralDatabase.Collection("config")
+	usersCollection := database.GocentralDatabase.Collection("users")
+	accomplishmentsCollection := database.GocentralDatabase.Collection("accomplishment_scores")
+
+	bannedUser := "BannedAccUserTest"
+	bannedPID := 78811
+	normalPID := 78812
+
+	usersCollection.InsertOne(ctx, bson.M{"pid": bannedPID, "username": bannedUser})
+	defer usersCollection.DeleteOne(ctx, bson.M{"pid": bannedPID})
+
+	configCollection.UpdateOne(ctx, bson.M{}, bson.M{"$push": bson.M{"banned_players": models.BannedPlayer{Username: bannedUser, Reason: "Test Ban", CreatedAt: time.Now()}}})
+	defer configCollection.UpdateOne(ctx, bson.M{}, bson.M{"$pull": bson.M{"banned_players": bson.M{"username": bannedUser}}})
+	database.InvalidateConfigCache()
+	defer database.InvalidateConfigCache()
+
+	database.RecordAccomplishmentScores(ctx, database.GocentralDatabase, bannedPID, map[string]int{"acc_millionpoints": 1, "acc_careersaves": 2})
+	database.RecordAccomplishmentScores(ctx, database.GocentralDatabase, normalPID, map[string]int{"acc_millionpoints": 1})
+	defer accomplishmentsCollection.DeleteMany(ctx, bson.M{"pid": bson.M{"$in": []int{bannedPID, normalPID}}})
+
+	database.CleanupBannedUserAccomplishments()
+
+	if count, _ := accomplishmentsCollection.CountDocuments(ctx, bson.M{"pid": bannedPID}); count != 0 {
+		t.Errorf("Expected 0 accomplishment entries for banned user, got %d", count)
+	}
+	if count, _ := accomplishmentsCollection.CountDocuments(ctx, bson.M{"pid": normalPID}); count != 1 {
+		t.Errorf("Expected 1 accomplishment entry for normal user, got %d", count)
+	}
+}
+
+// Tests that goal IDs added in the config are used alongside the built in ones
+func TestGetAccomplishmentGoalIDs(t *testing.T) {
+	ctx := context.Background()
+	configCollection := database.GocentralDatabase.Collection("config")
+
+	configCollection.UpdateOne(ctx, bson.M{}, bson.M{"$set": bson.M{"accomplishment_goal_ids": []string{"acc_custom_goal", "acc_millionpoints"}}})
+	defer configCollection.UpdateOne(ctx, bson.M{}, bson.M{"$unset": bson.M{"accomplishment_goal_ids": ""}})
+	database.InvalidateConfigCache()
+	defer database.InvalidateConfigCache()
+
+	goalIDs := database.GetAccomplishmentGoalIDs(ctx)
+
+	if len(goalIDs) != len(database.DefaultAccomplishmentGoalIDs)+1 {
+		t.Errorf("Expected %d goal IDs, got %d", len(database.DefaultAccomplishmentGoalIDs)+1, len(goalIDs))
+	}
+	if goalIDs[len(goalIDs)-1] != "acc_custom_goal" {
+		t.Errorf("Expected custom goal to be added last, got %q", goalIDs[len(goalIDs)-1])
+	}
+}
+
+// Tests that a closed battle's standings are frozen into battle_results and every participant's placement into battle_history
+func TestArchiveBattleResult(t *testing.T) {
+	ctx := context.Background()
+	scoresCollection := database.GocentralDatabase.Collection("scores")
+	resultsCollection := database.GocentralDatabase.Collection("battle_results")
+	historyCollection := database.GocentralDatabase.Collection("battle_history")
+
+	database.EnsureBattleResultIndexes(ctx, database.GocentralDatabase)
+
+	battleID := 78821
+	battle := models.Setlist{
+		SetlistID:    battleID,
+		Type:         1002,
+		Title:        "Archive Test Battle",
+		Created:      time.Now().Add(-48 * time.Hour).Unix(),
+		TimeEndVal:   1,
+		TimeEndUnits: "days",
+		Instrument:   1,
+		SongIDs:      []int{1048},
+	}
+
+	// 501 and 502 tie, the earlier submission should place higher
+	scoresCollection.InsertMany(ctx, []interface{}{
+		bson.M{"battle_id": battleID, "pid": 500, "score": 1000, "submitted_at": 100},
+		bson.M{"battle_id": battleID, "pid": 502, "score": 5000, "submitted_at": 300},
+		bson.M{"battle_id": battleID, "pid": 501, "score": 5000, "submitted_at": 200},
+	})
+	defer scoresCollection.DeleteMany(ctx, bson.M{"battle_id": battleID})
+	defer resultsCollection.DeleteMany(ctx, bson.M{"battle_id": battleID})
+	defer historyCollection.DeleteMany(ctx, bson.M{"battle_id": battleID})
+
+	result, isNew, err := database.ArchiveBattleResult(ctx, database.GocentralDatabase, battle)
+	if err != nil {
+		t.Fatalf("Could not archive battle: %v", err)
+	}
+	if !isNew {
+		t.Error("Expected the battle to be newly archived")
+	}
+
+	if result.Participants != 3 || len(result.Standings) != 3 {
+		t.Fatalf("Expected 3 participants and standings, got %d and %d", result.Participants, len(result.Standings))
+	}
+	expectedOrder := []int{501, 502, 500}
+	for idx, standing := range result.Standings {
+		if standing.PID != expectedOrder[idx] || standing.Rank != idx+1 {
+			t.Errorf("Expected PID %d at rank %d, got PID %d at rank %d", expectedOrder[idx], idx+1, standing.PID, standing.Rank)
+		}
+		if standing.Name == "" {
+			t.Errorf("Expected a name to be stored for PID %d", standing.PID)
+		}
+	}
+
+	var history models.BattleHistoryEntry
+	historyCollection.FindOne(ctx, bson.M{"battle_id": battleID, "pid": 500}).Decode(&history)
+	if history.Rank != 3 || history.Score != 1000 || history.Title != battle.Title {
+		t.Errorf("Unexpected battle history entry: %+v", history)
+	}
+
+	// archiving again should leave the original result alone
+	_, isNew, err = database.ArchiveBattleResult(ctx, database.GocentralDatabase, battle)
+	if err != nil || isNew {
+		t.Errorf("Expected archiving twice to be a no-op, got isNew %v and error %v", isNew, err)
+	}
+	if count, _ := resultsCollection.CountDocuments(ctx, bson.M{"battle_id": battleID}); count != 1 {
+		t.Errorf("Expected 1 battle result, got %d", count)
+	}
+}
+
+// Tests working out when a recurring battle next starts
+func TestNextBattleOccurrence(t *testing.T) {
+	// a Wednesday
+	after := time.Date(2024, time.May, 15, 12, 0, 0, 0, time.UTC)
+
+	weekly := models.BattleRecurrence{Frequency: "weekly", Weekday: int(time.Friday), Hour: 20}
+	if next := database.NextBattleOccurrence(weekly, after); !next.Equal(time.Date(2024, time.May, 17, 20, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the weekly battle to start on Friday the 17th at 20:00, got %v", next)
+	}
+
+	// an occurrence that starts exactly at the given time is not the next one
+	if next := database.NextBattleOccurrence(weekly, time.Date(2024, time.May, 17, 20, 0, 0, 0, time.UTC)); !next.Equal(time.Date(2024, time.May, 24, 20, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the following weekly battle to start on the 24th, got %v", next)
+	}
+
+	daily := models.BattleRecurrence{Frequency: "daily", Hour: 9, Minute: 30}
+	if next := database.NextBattleOccurrence(daily, after); !next.Equal(time.Date(2024, time.May, 16, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected the daily battle to start tomorrow at 09:30, got %v", next)
+	}
+}
+
+// Tests that housekeeping opens the battles of due battle templates exactly once
+func TestScheduleTemplateBattles(t *testing.T) {
+	ctx := context.Background()
+	templatesCollection := database.GocentralDatabase.Collection("battle_templates")
+	setlistsCollection := database.GocentralDatabase.Collection("setlists")
+
+	templateID := 78841
+	start := time.Now().UTC().Add(-1 * time.Hour).Truncate(time.Minute)
+
+	templatesCollection.InsertOne(ctx, models.BattleTemplate{
+		TemplateID: templateID,
+		Title:      "Scheduled Test Battle",
+		Enabled:    true,
+		Recurrence: models.BattleRecurrence{Frequency: "daily", Hour: start.Hour(), Minute: start.Minute(), DurationHours: 48},
+		SongPool:   models.BattleSongPool{Strategy: "queue", SongIDs: []int{1048}, Queue: [][]int{{2000123, 10234}}},
+		CreatedAt:  start.Add(-1 * time.Hour).Unix(),
+	})
+	defer templatesCollection.DeleteMany(ctx, bson.M{"template_id": templateID})
+	defer setlistsCollection.DeleteMany(ctx, bson.M{"template_id": templateID})
+
+	database.ScheduleTemplateBattles()
+
+	var battle models.Setlist
+	if err := setlistsCollection.FindOne(ctx, bson.M{"template_id": templateID}).Decode(&battle); err != nil {
+		t.Fatalf("Expected the template to create a battle: %v", err)
+	}
+	if battle.Type != 1002 || battle.Created != start.Unix() || battle.TimeEndVal != 48*60*60 {
+		t.Errorf("Unexpected scheduled battle: %+v", battle)
+	}
+	if len(battle.SongIDs) != 2 || battle.SongIDs[0] != 2000123 {
+		t.Errorf("Expected the battle to use the queued songs, got %v", battle.SongIDs)
+	}
+
+	template, _ := database.GetBattleTemplate(ctx, database.GocentralDatabase, templateID)
+	if template.LastStartAt != start.Unix() || template.LastBattleID != battle.SetlistID {
+		t.Errorf("Expected the template to remember the battle it created, got %+v", template)
+	}
+	if len(template.SongPool.Queue) != 0 {
+		t.Errorf("Expected the used song list to be removed from the queue, got %v", template.SongPool.Queue)
+	}
+
+	// the next occurrence is tomorrow, so nothing else should be created
+	database.ScheduleTemplateBattles()
+	if count, _ := setlistsCollection.CountDocuments(ctx, bson.M{"template_id": templateID}); count != 1 {
+		t.Errorf("Expected 1 scheduled battle, got %d", count)
+	}
+}
+
+// Tests that battles scheduled for later are not treated as started
+func TestIsBattleStarted(t *testing.T) {
+	if !database.IsBattleStarted(models.Setlist{Created: time.Now().Add(-1 * time.Minute).Unix()}) {
+		t.Error("Expected a battle that started a minute ago to be started")
+	}
+	if database.IsBattleStarted(models.Setlist{Created: time.Now().Add(1 * time.Hour).Unix()}) {
+		t.Error("Expected a battle starting in an hour to not be started")
+	}
+}
+
+// Tests checking battle score submissions against the rules of the battle
+func TestValidateBattleSubmission(t *testing.T) {
+	now := time.Now()
+	battle := &models.Setlist{
+		SetlistID:    78851,
+		Type:         1002,
+		Created:      now.Add(-1 * time.Hour).Unix(),
+		TimeEndVal:   2,
+		TimeEndUnits: "hours",
+		SongIDs:      []int{1048, 10234},
+		Instrument:   1<<0 | 1<<1, // guitar and drums
+	}
+
+	if reasons := database.ValidateBattleSubmission(battle, database.BattleSubmission{SongID: 1048, RoleIDs: []int{0}}); len(reasons) != 0 {
+		t.Errorf("Expected a valid submission to pass, got %v", reasons)
+	}
+
+	// clients that don't report the song or role only get the battle checked
+	if reasons := database.ValidateBattleSubmission(battle, database.BattleSubmission{}); len(reasons) != 0 {
+		t.Errorf("Expected a submission without details to pass, got %v", reasons)
+	}
+
+	if reasons := database.ValidateBattleSubmission(battle, database.BattleSubmission{SongID: 2000123, RoleIDs: []int{2}}); len(reasons) != 2 {
+		t.Errorf("Expected the song and role to be rejected, got %v", reasons)
+	}
+
+	// band scores need every instrument that played to be allowed
+	if reasons := database.ValidateBattleSubmission(battle, database.BattleSubmission{RoleIDs: []int{10}, BandMask: 1<<0 | 1<<2}); len(reasons) != 1 {
+		t.Errorf("Expected a band with a disallowed instrument to be rejected, got %v", reasons)
+	}
+
+	notStarted := *battle
+	notStarted.Created = now.Add(1 * time.Hour).Unix()
+	if reasons := database.ValidateBattleSubmission(&notStarted, database.BattleSubmission{}); len(reasons) != 1 {
+		t.Errorf("Expected a battle that hasn't started to be rejected, got %v", reasons)
+	}
+
+	expired := *battle
+	expired.Created = now.Add(-3 * time.Hour).Unix()
+	if reasons := database.ValidateBattleSubmission(&expired, database.BattleSubmission{}); len(reasons) != 1 {
+		t.Errorf("Expected an expired battle to be rejected, got %v", reasons)
+	}
+
+	if reasons := database.ValidateBattleSubmission(nil, database.BattleSubmission{}); len(reasons) != 1 {
+		t.Errorf("Expected a missing battle to be rejected, got %v", reasons)
+	}
+}
+
+// Tests that rejected battle score submissions end up in the audit collection
+func TestLogBattleScoreRejection(t *testing.T) {
+	ctx := context.Background()
+	rejectionsCollection := database.GocentralDatabase.Collection("battle_score_rejections")
+	defer rejectionsCollection.DeleteMany(ctx, bson.M{"battle_id": 78852})
+
+	err := database.LogBattleScoreRejection(ctx, database.GocentralDatabase, models.BattleScoreRejection{
+		BattleID: 78852,
+		PIDs:     []int{500},
+		Score:    1234,
+		Reasons:  []string{"battle has expired"},
+	})
+	if err != nil {
+		t.Fatalf("Could not log battle score rejection: %v", err)
+	}
+
+	var rejection models.BattleScoreRejection
+	if err := rejectionsCollection.FindOne(ctx, bson.M{"battle_id": 78852}).Decode(&rejection); err != nil {
+		t.Fatalf("Expected the rejection to be stored: %v", err)
+	}
+	if rejection.RejectedAt == 0 || len(rejection.Reasons) != 1 {
+		t.Errorf("Unexpected stored rejection: %+v", rejection)
+	}
+}
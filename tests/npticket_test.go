@@ -0,0 +1,305 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"rb3server/authentication"
+	"testing"
+	"time"
+)
+
+var testTicketNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func testTicketBody() authentication.NPTicketBody {
+	return authentication.NPTicketBody{
+		Serial:    []byte("0123456789abcdefghij"),
+		IssuerID:  0x100,
+		IssuedAt:  testTicketNow.Add(-time.Minute),
+		ExpiresAt: testTicketNow.Add(10 * time.Minute),
+		UserID:    0x1122334455667788,
+		OnlineID:  "testuser",
+		Region:    "us",
+		Domain:    "un",
+		ServiceID: "UP8802-BLUS30463_00",
+		Status:    0,
+	}
+}
+
+// signs a digest on a curve with a private key, returning r and s back to back
+func signWithCurve(curve *authentication.Curve, d *big.Int, digest []byte) []byte {
+	size := (curve.N.BitLen() + 7) / 8
+
+	e := new(big.Int).SetBytes(digest)
+	if excess := len(digest)*8 - curve.N.BitLen(); excess > 0 {
+		e.Rsh(e, uint(excess))
+	}
+
+	for {
+		k, _ := rand.Int(rand.Reader, curve.N)
+		if k.Sign() == 0 {
+			continue
+		}
+
+		x, _ := curve.ScalarMult(curve.Gx, curve.Gy, k)
+		r := new(big.Int).Mod(x, curve.N)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		s := new(big.Int).Mul(r, d)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, curve.N))
+		s.Mod(s, curve.N)
+		if s.Sign() == 0 {
+			continue
+		}
+
+		return append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+}
+
+// generates a key pair on a curve, returning the private key and the hex-encoded uncompressed public key
+func generateCurveKey(curve *authentication.Curve) (*big.Int, string) {
+	d, _ := rand.Int(rand.Reader, new(big.Int).Sub(curve.N, big.NewInt(1)))
+	d.Add(d, big.NewInt(1))
+
+	x, y := curve.ScalarMult(curve.Gx, curve.Gy, d)
+	size := (curve.P.BitLen() + 7) / 8
+	publicKey := append([]byte{4}, x.FillBytes(make([]byte, size))...)
+	publicKey = append(publicKey, y.FillBytes(make([]byte, size))...)
+
+	return d, hex.EncodeToString(publicKey)
+}
+
+// builds a ticket the way RegisterEx receives it, signed by sign
+func buildTestTicket(body authentication.NPTicketBody, issuer *authentication.NPTicketIssuer, signatureSize int, sign func(digest []byte) []byte) []byte {
+	bodyBytes := body.Bytes()
+
+	ticket := authentication.NPTicket{
+		Version:    0x21,
+		Unknown:    0x01,
+		BodyType:   0x3000,
+		BodySize:   uint16(len(bodyBytes)),
+		Body:       bodyBytes,
+		FooterType: 0x3002,
+		FooterSize: uint16(12 + signatureSize),
+		Footer: authentication.NPTicketFooter{
+			CipherIDType:  8,
+			CipherIDSize:  4,
+			CipherID:      issuer.CipherID,
+			SignatureType: 8,
+			SignatureSize: uint16(signatureSize),
+			Signature:     make([]byte, signatureSize),
+		},
+	}
+	ticket.TicketSize = uint32(4 + len(bodyBytes) + 4 + 12 + signatureSize)
+	ticket.Size1 = ticket.TicketSize + 8
+	ticket.Size2 = ticket.Size1
+
+	ticket.Footer.Signature = sign(issuer.Hash(issuer.SignedData(&ticket)))
+
+	// DER signatures vary in length, which is only fine when the sizes aren't signed
+	if len(ticket.Footer.Signature) != signatureSize && !issuer.SignsWholeTicket {
+		signatureSize = len(ticket.Footer.Signature)
+		ticket.Footer.SignatureSize = uint16(signatureSize)
+		ticket.FooterSize = uint16(12 + signatureSize)
+		ticket.TicketSize = uint32(4 + len(bodyBytes) + 4 + 12 + signatureSize)
+	}
+
+	return ticket.Bytes()
+}
+
+func TestTicketCurves(t *testing.T) {
+	for _, curve := range []*authentication.Curve{authentication.Secp192r1, authentication.Secp224k1} {
+		if !curve.IsOnCurve(curve.Gx, curve.Gy) {
+			t.Errorf("Expected the generator of %s to be on the curve", curve.Name)
+		}
+
+		if x, _ := curve.ScalarMult(curve.Gx, curve.Gy, curve.N); x != nil {
+			t.Errorf("Expected the order of %s to take the generator to infinity", curve.Name)
+		}
+	}
+}
+
+func TestNPTicketParseBody(t *testing.T) {
+	body := testTicketBody()
+	issuer := authentication.NPTicketIssuer{CipherID: authentication.RPCNTicketCipherID, Hash: func(data []byte) []byte { return nil }}
+	data := buildTestTicket(body, &issuer, 8, func(digest []byte) []byte { return make([]byte, 8) })
+
+	deserializer := &authentication.NPTicketDeserializer{}
+	ticket, err := deserializer.Deserialize(data)
+	if err != nil {
+		t.Fatalf("Could not deserialize ticket: %v", err)
+	}
+
+	parsed, err := ticket.ParseBody()
+	if err != nil {
+		t.Fatalf("Could not parse ticket body: %v", err)
+	}
+
+	if parsed.OnlineID != "testuser" || parsed.Region != "us" || parsed.Domain != "un" || parsed.ServiceID != "UP8802-BLUS30463_00" {
+		t.Errorf("Expected string fields to round trip without padding, got %+v", parsed)
+	}
+	if parsed.UserID != body.UserID || parsed.IssuerID != 0x100 || string(parsed.Serial) != string(body.Serial) {
+		t.Errorf("Expected numeric fields to round trip, got %+v", parsed)
+	}
+	if !parsed.ExpiresAt.Equal(body.ExpiresAt) || !parsed.IssuedAt.Equal(body.IssuedAt) {
+		t.Errorf("Expected dates to round trip, got %v and %v", parsed.IssuedAt, parsed.ExpiresAt)
+	}
+
+	// the body's fields have to be well-formed
+	ticket.Body = ticket.Body[:len(ticket.Body)-2]
+	if _, err := ticket.ParseBody(); err == nil {
+		t.Error("Expected a truncated body to fail to parse")
+	}
+}
+
+// Tests verifying tickets signed with locally generated keys on the curves PSN and RPCN use
+func TestNPTicketVerifier(t *testing.T) {
+	psnKey, psnPublicKey := generateCurveKey(authentication.Secp192r1)
+	rpcnKey, rpcnPublicKey := generateCurveKey(authentication.Secp224k1)
+
+	psn, err := authentication.PSNTicketIssuer(psnPublicKey)
+	if err != nil {
+		t.Fatalf("Could not create PSN issuer: %v", err)
+	}
+	rpcn, err := authentication.RPCNTicketIssuer(rpcnPublicKey)
+	if err != nil {
+		t.Fatalf("Could not create RPCN issuer: %v", err)
+	}
+
+	verifier := &authentication.NPTicketVerifier{
+		Issuers:    []authentication.NPTicketIssuer{psn, rpcn},
+		ServiceIDs: []string{"UP8802-BLUS30463_00"},
+		Now:        func() time.Time { return testTicketNow },
+	}
+
+	signPSN := func(digest []byte) []byte { return signWithCurve(authentication.Secp192r1, psnKey, digest) }
+	signRPCN := func(digest []byte) []byte { return signWithCurve(authentication.Secp224k1, rpcnKey, digest) }
+
+	psnTicket := buildTestTicket(testTicketBody(), &psn, 48, signPSN)
+	body, err := verifier.Verify(psnTicket, 1)
+	if err != nil {
+		t.Fatalf("Expected a PSN ticket to verify, got %v", err)
+	}
+	if body.OnlineID != "testuser" {
+		t.Errorf("Expected the verified body to be returned, got %+v", body)
+	}
+
+	// real PSN tickets carry a DER signature zero-padded out to the size of the signature field
+	paddedTicket := buildTestTicket(testTicketBody(), &psn, 56, func(digest []byte) []byte {
+		raw := signPSN(digest)
+		der, err := asn1.Marshal(struct{ R, S *big.Int }{new(big.Int).SetBytes(raw[:24]), new(big.Int).SetBytes(raw[24:])})
+		if err != nil {
+			t.Fatalf("Could not DER encode signature: %v", err)
+		}
+		return append(der, make([]byte, 56-len(der))...)
+	})
+	if _, err := verifier.Verify(paddedTicket, 1); err != nil {
+		t.Errorf("Expected a PSN ticket with a zero-padded DER signature to verify, got %v", err)
+	}
+
+	rpcnTicket := buildTestTicket(testTicketBody(), &rpcn, 58, signRPCN)
+	if _, err := verifier.Verify(rpcnTicket, 3); err != nil {
+		t.Fatalf("Expected an RPCN ticket to verify, got %v", err)
+	}
+
+	// the console type comes from the cipher ID in the footer, not from what the rest of the ticket contains
+	deserializer := &authentication.NPTicketDeserializer{}
+	for _, tc := range []struct {
+		ticketData  []byte
+		consoleType int
+	}{{psnTicket, 1}, {rpcnTicket, 3}} {
+		ticket, err := deserializer.Deserialize(tc.ticketData)
+		if err != nil {
+			t.Fatalf("Could not deserialize test ticket: %v", err)
+		}
+		if ticket.ConsoleType() != tc.consoleType {
+			t.Errorf("Expected a ticket with cipher ID %08X to be from console type %d, got %d", ticket.Footer.CipherID, tc.consoleType, ticket.ConsoleType())
+		}
+	}
+
+	// an RPCN ticket presented by a real PS3 isn't accepted
+	if _, err := verifier.Verify(rpcnTicket, 1); !errors.Is(err, authentication.ErrUnknownTicketIssuer) {
+		t.Errorf("Expected ErrUnknownTicketIssuer for the wrong console type, got %v", err)
+	}
+
+	// change the online ID after signing
+	forged := append([]byte{}, psnTicket...)
+	for i := 0; i+8 <= len(forged); i++ {
+		if string(forged[i:i+8]) == "testuser" {
+			copy(forged[i:], "attacker")
+			break
+		}
+	}
+	if _, err := verifier.Verify(forged, 1); !errors.Is(err, authentication.ErrInvalidTicketSignature) {
+		t.Errorf("Expected a forged ticket to fail verification, got %v", err)
+	}
+
+	// signed with someone else's key
+	otherKey, _ := generateCurveKey(authentication.Secp224k1)
+	selfSigned := buildTestTicket(testTicketBody(), &rpcn, 58, func(digest []byte) []byte { return signWithCurve(authentication.Secp224k1, otherKey, digest) })
+	if _, err := verifier.Verify(selfSigned, 3); !errors.Is(err, authentication.ErrInvalidTicketSignature) {
+		t.Errorf("Expected a ticket signed with another key to fail verification, got %v", err)
+	}
+
+	expiredBody := testTicketBody()
+	expiredBody.ExpiresAt = testTicketNow.Add(-time.Second)
+	if _, err := verifier.Verify(buildTestTicket(expiredBody, &rpcn, 58, signRPCN), 3); !errors.Is(err, authentication.ErrTicketExpired) {
+		t.Errorf("Expected an expired ticket to be rejected, got %v", err)
+	}
+
+	otherGameBody := testTicketBody()
+	otherGameBody.ServiceID = "UP9000-BCUS98148_00"
+	if _, err := verifier.Verify(buildTestTicket(otherGameBody, &psn, 48, signPSN), 1); !errors.Is(err, authentication.ErrWrongTicketService) {
+		t.Errorf("Expected a ticket for another game to be rejected, got %v", err)
+	}
+
+	if _, err := verifier.Verify(psnTicket[:30], 1); err == nil {
+		t.Error("Expected a truncated ticket to be rejected")
+	}
+}
+
+// Tests the curve arithmetic against signatures made by crypto/ecdsa
+func TestNPTicketVerifierStandardCurve(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+
+	issuer := authentication.NPTicketIssuer{
+		Name:        "test",
+		ConsoleType: 3,
+		CipherID:    0x54455354,
+		Curve:       authentication.CurveFromParams(elliptic.P256().Params()),
+		Hash: func(data []byte) []byte {
+			digest := sha256.Sum256(data)
+			return digest[:]
+		},
+		PublicKeyX: key.X,
+		PublicKeyY: key.Y,
+	}
+
+	verifier := &authentication.NPTicketVerifier{Issuers: []authentication.NPTicketIssuer{issuer}, Now: func() time.Time { return testTicketNow }}
+
+	for _, der := range []bool{false, true} {
+		data := buildTestTicket(testTicketBody(), &issuer, 64, func(digest []byte) []byte {
+			if der {
+				signature, _ := ecdsa.SignASN1(rand.Reader, key, digest)
+				return signature
+			}
+			r, s, _ := ecdsa.Sign(rand.Reader, key, digest)
+			return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		})
+
+		if _, err := verifier.Verify(data, 3); err != nil {
+			t.Errorf("Expected a P-256 ticket to verify (DER: %v), got %v", der, err)
+		}
+	}
+}
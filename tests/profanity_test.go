@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"rb3server/models"
+	"rb3server/profanity"
+	"testing"
+)
+
+func newTestProfanityFilter(t *testing.T, allowlist []string, entries ...models.ProfanityEntry) *profanity.Filter {
+	filter, err := profanity.NewFilter(entries, allowlist)
+	if err != nil {
+		t.Fatalf("Failed to build profanity filter: %v", err)
+	}
+
+	return filter
+}
+
+func TestProfanityFilter(t *testing.T) {
+	filter := newTestProfanityFilter(t, []string{"Scunthorpe"},
+		models.ProfanityEntry{Term: "ass"},
+		models.ProfanityEntry{Term: "shit", Match: profanity.MatchSubstring},
+		models.ProfanityEntry{Term: "cunt", Match: profanity.MatchSubstring},
+		models.ProfanityEntry{Term: "heck", Severity: profanity.SeverityMask},
+		models.ProfanityEntry{Term: "dang it", Severity: profanity.SeverityMask},
+		models.ProfanityEntry{Term: `f+r+a+c+k`, Match: profanity.MatchRegex},
+		models.ProfanityEntry{Term: `\bb\d+d\b`, Match: profanity.MatchRegex},
+	)
+
+	testCases := []struct {
+		text     string
+		severity string
+		masked   string
+	}{
+		{"Band Assistant", "", "Band Assistant"}, // word entries only match whole words
+		{"Kick Ass", profanity.SeverityReject, "Kick ***"},
+		{"Kick Ass!", profanity.SeverityReject, "Kick ***!"}, // trailing punctuation isn't part of the word
+		{"Bullshitters", profanity.SeverityReject, "Bull****ters"},
+		{"$h1t happens", profanity.SeverityReject, "**** happens"}, // leetspeak
+		{"SHÍT", profanity.SeverityReject, "****"},                 // accents
+		{"ｓｈｉｔ", profanity.SeverityReject, "****"},                 // fullwidth letters
+		{"Kick Аss", profanity.SeverityReject, "Kick ***"},         // cyrillic lookalike letters
+		{"Scunthorpe United", "", "Scunthorpe United"},             // allowlisted word
+		{"What the heck", profanity.SeverityMask, "What the ****"},
+		{"Oh dang it all", profanity.SeverityMask, "Oh **** ** all"},
+		{"Heck, kick ass", profanity.SeverityReject, "****, kick ***"}, // reject wins over mask
+		{"Frrraaack", profanity.SeverityReject, "*********"},
+		{"B4d idea", profanity.SeverityReject, "*** idea"}, // regex entries see the digits as typed
+		{"Bad idea", "", "Bad idea"},
+		{"", "", ""},
+	}
+
+	for _, tc := range testCases {
+		result := filter.Check(tc.text)
+		if result.Severity != tc.severity {
+			t.Errorf("Expected severity %q for %q, got %q (matches %+v)", tc.severity, tc.text, result.Severity, result.Matches)
+		}
+		if result.Masked != tc.masked {
+			t.Errorf("Expected %q to be masked as %q, got %q", tc.text, tc.masked, result.Masked)
+		}
+		if result.Rejected() != (tc.severity == profanity.SeverityReject) {
+			t.Errorf("Expected Rejected() for %q to be %v", tc.text, tc.severity == profanity.SeverityReject)
+		}
+	}
+}
+
+func TestProfanityFilter_InvalidEntries(t *testing.T) {
+	filter, err := profanity.NewFilter([]models.ProfanityEntry{
+		{Term: "bad"},
+		{Term: "("},
+		{Term: "([", Match: profanity.MatchRegex},
+		{Term: "worse", Match: "fuzzy"},
+		{Term: "worst", Severity: "ban"},
+	}, nil)
+	if err == nil {
+		t.Fatal("Expected an error for the invalid entries")
+	}
+
+	// the valid entry is still used
+	if !filter.Check("so bad").Rejected() {
+		t.Error("Expected the valid entry to still reject text")
+	}
+
+	entry, err := profanity.ValidateEntry(models.ProfanityEntry{Term: "  bad  "})
+	if err != nil {
+		t.Fatalf("Expected a plain term to be valid: %v", err)
+	}
+	if entry.Term != "bad" || entry.Match != profanity.MatchWord || entry.Severity != profanity.SeverityReject {
+		t.Errorf("Expected defaults to be filled in, got %+v", entry)
+	}
+}
@@ -4,16 +4,26 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"rb3server/authentication"
+	"rb3server/binarydata"
 	"rb3server/database"
 	"rb3server/models"
 	"rb3server/restapi"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Helper to make a request and return the response
@@ -1097,3 +1107,1560 @@ func TestDeletePlayerScoresHandler(t *testing.T) {
 	}
 }
 
+// Tests searching scores by their provenance fields
+func TestScoreSearchHandler(t *testing.T) {
+	ctx := context.Background()
+	scoresCollection := database.GocentralDatabase.Collection("scores")
+
+	testPID := 77801
+	now := time.Now().Unix()
+
+	scoresCollection.InsertOne(ctx, models.Score{OwnerPID: testPID, SongID: 4242, Score: 1000, SubmittedAt: now - 3600, ConsoleType: 1, MachineID: "machine-a", SessionGUID: "session-a", Region: "us", BandPIDs: []int{77802}})
+	scoresCollection.InsertOne(ctx, models.Score{OwnerPID: testPID, SongID: 4243, Score: 2000, SubmittedAt: now, ConsoleType: 2, MachineID: "machine-b", SessionGUID: "session-b", Region: "eu"})
+	defer scoresCollection.DeleteMany(ctx, bson.M{"pid": testPID})
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected int
+	}{
+		{"By PID", "?pid=77801", 2},
+		{"By machine ID", "?pid=77801&machine_id=machine-a", 1},
+		{"By session GUID", "?pid=77801&session_guid=session-b", 1},
+		{"By console type", "?pid=77801&console_type=2", 1},
+		{"By region", "?pid=77801&region=us", 1},
+		{"By band mate", "?pid=77801&band_pid=77802", 1},
+		{"Since", "?pid=77801&since=" + strconv.FormatInt(now-60, 10), 1},
+		{"Until", "?pid=77801&until=" + strconv.FormatInt(now-60, 10), 1},
+		{"No matches", "?pid=77801&machine_id=machine-c", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := makeRequest(t, "GET", "/admin/scores"+tc.query, nil, restapi.ScoreSearchHandler)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d (body: %s)", rr.Code, rr.Body.String())
+			}
+
+			var response map[string][]restapi.ScoreProvenanceEntry
+			decodeResponse(t, rr, &response)
+
+			if len(response["scores"]) != tc.expected {
+				t.Errorf("Expected %d scores, got %d", tc.expected, len(response["scores"]))
+			}
+		})
+	}
+}
+
+// Tests that invalid provenance filters are rejected
+func TestScoreSearchHandler_InvalidParams(t *testing.T) {
+	for _, query := range []string{"?console_type=abc", "?since=yesterday", "?page=0", "?username=NoSuchUserForScoreSearch", "?username=testuser&pid=501"} {
+		rr := makeRequest(t, "GET", "/admin/scores"+query, nil, restapi.ScoreSearchHandler)
+
+		if rr.Code != http.StatusBadRequest && rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 400 or 404 for %q, got %d", query, rr.Code)
+		}
+	}
+}
+
+// Tests importing a songs.dta into the song catalog and looking songs back up
+func TestSongCatalogHandlers(t *testing.T) {
+	ctx := context.Background()
+	songsCollection := database.GocentralDatabase.Collection("songs")
+	defer songsCollection.DeleteMany(ctx, bson.M{"song_id": bson.M{"$in": []int{1048, 2000123, 10234}}})
+
+	req := httptest.NewRequest("POST", "/admin/songs/import", bytes.NewReader([]byte(testSongsDTA)))
+	rr := httptest.NewRecorder()
+	restapi.ImportSongsHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 importing songs, got %d (body: %s)", rr.Code, rr.Body.String())
+	}
+
+	var importResponse restapi.SongImportResponse
+	decodeResponse(t, rr, &importResponse)
+	if importResponse.Parsed != 3 {
+		t.Errorf("Expected 3 parsed songs, got %d", importResponse.Parsed)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/songs", restapi.SongSearchHandler)
+	router.Get("/songs/{id}", restapi.SongHandler)
+
+	rr = makeRequest(t, "GET", "/songs/1048", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 looking up song, got %d", rr.Code)
+	}
+
+	var song models.Song
+	decodeResponse(t, rr, &song)
+	if song.Title != "Spoonman" || song.Artist != "Soundgarden" {
+		t.Errorf("Unexpected song returned: %+v", song)
+	}
+
+	rr = makeRequest(t, "GET", "/songs/999999999", nil, router.ServeHTTP)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown song, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "GET", "/songs?q=spoon&source=rb3", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 searching songs, got %d", rr.Code)
+	}
+
+	var searchResponse map[string][]models.Song
+	decodeResponse(t, rr, &searchResponse)
+	if len(searchResponse["songs"]) != 1 || searchResponse["songs"][0].SongID != 1048 {
+		t.Errorf("Expected search to find only Spoonman, got %+v", searchResponse["songs"])
+	}
+
+	// names should now show up wherever song IDs are turned into setlists
+	names := database.GetSongNamesInOrder(ctx, database.GocentralDatabase, []int{10234, 424242, 1048})
+	if len(names) != 3 || names[0] != "A DLC Song" || names[1] != "" || names[2] != "Spoonman" {
+		t.Errorf("Unexpected song names: %v", names)
+	}
+}
+
+// Tests that a malformed import is rejected
+func TestImportSongsHandler_Invalid(t *testing.T) {
+	for _, body := range []string{"(unclosed", "; nothing but a comment"} {
+		req := httptest.NewRequest("POST", "/admin/songs/import", bytes.NewReader([]byte(body)))
+		rr := httptest.NewRecorder()
+		restapi.ImportSongsHandler(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %q, got %d", body, rr.Code)
+		}
+	}
+}
+
+// Tests listing, approving and rejecting quarantined scores
+func TestQuarantineHandlers(t *testing.T) {
+	ctx := context.Background()
+	quarantineCollection := database.GocentralDatabase.Collection("quarantined_scores")
+	scoresCollection := database.GocentralDatabase.Collection("scores")
+
+	testPID := 77901
+	defer quarantineCollection.DeleteMany(ctx, bson.M{"score.pid": testPID})
+	defer scoresCollection.DeleteMany(ctx, bson.M{"pid": testPID})
+
+	for _, score := range []int{5000, 6000} {
+		err := database.QuarantineScore(ctx, database.GocentralDatabase, models.Score{OwnerPID: testPID, SongID: 88802, RoleID: 1, DiffID: 3, Score: score, Stars: 5}, []string{"test"})
+		if err != nil {
+			t.Fatalf("Failed to quarantine score: %v", err)
+		}
+	}
+
+	router := chi.NewRouter()
+	router.Get("/admin/scores/quarantine", restapi.QuarantineListHandler)
+	router.Post("/admin/scores/quarantine/{id}/approve", restapi.ApproveQuarantinedScoreHandler)
+	router.Post("/admin/scores/quarantine/{id}/reject", restapi.RejectQuarantinedScoreHandler)
+
+	rr := makeRequest(t, "GET", "/admin/scores/quarantine?page_size=100", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var listResponse map[string][]restapi.QuarantinedScoreEntry
+	decodeResponse(t, rr, &listResponse)
+
+	ids := map[int]int{}
+	for _, entry := range listResponse["scores"] {
+		if entry.Score.PID == testPID {
+			ids[entry.Score.Score] = entry.QuarantineID
+		}
+	}
+	if len(ids) != 2 {
+		t.Fatalf("Expected 2 pending quarantined scores, got %d", len(ids))
+	}
+
+	rr = makeRequest(t, "POST", "/admin/scores/quarantine/"+strconv.Itoa(ids[6000])+"/approve", restapi.QuarantineReviewRequest{Note: "legit"}, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 approving, got %d (body: %s)", rr.Code, rr.Body.String())
+	}
+
+	rr = makeRequest(t, "POST", "/admin/scores/quarantine/"+strconv.Itoa(ids[5000])+"/reject", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 rejecting, got %d (body: %s)", rr.Code, rr.Body.String())
+	}
+
+	if count := countScores(t, bson.M{"pid": testPID, "score": 6000}); count != 1 {
+		t.Errorf("Expected approved score on the leaderboards, got %d", count)
+	}
+	if count := countScores(t, bson.M{"pid": testPID, "score": 5000}); count != 0 {
+		t.Errorf("Expected rejected score to stay off the leaderboards, got %d", count)
+	}
+
+	// reviewing twice is not allowed
+	rr = makeRequest(t, "POST", "/admin/scores/quarantine/"+strconv.Itoa(ids[6000])+"/reject", nil, router.ServeHTTP)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 reviewing twice, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/scores/quarantine/999999999/approve", nil, router.ServeHTTP)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown quarantine ID, got %d", rr.Code)
+	}
+}
+
+// Tests filtering song and battle leaderboards down to a single platform
+func TestLeaderboardHandlers_PlatformFilter(t *testing.T) {
+	ctx := context.Background()
+	scoresCollection := database.GocentralDatabase.Collection("scores")
+
+	testSongID := 444450
+	testBattleID := 444451
+
+	// 500 is a PS3 user, 501 and 502 are Wii users
+	for i, pid := range []int{500, 501, 502} {
+		scoresCollection.InsertOne(ctx, models.Score{OwnerPID: pid, SongID: testSongID, RoleID: 1, Score: 1000 * (i + 1)})
+		scoresCollection.InsertOne(ctx, models.Score{OwnerPID: pid, BattleID: testBattleID, RoleID: 1, Score: 1000 * (i + 1)})
+	}
+	defer scoresCollection.DeleteMany(ctx, bson.M{"song_id": testSongID})
+	defer scoresCollection.DeleteMany(ctx, bson.M{"battle_id": testBattleID})
+
+	testCases := []struct {
+		name     string
+		path     string
+		handler  http.HandlerFunc
+		expected int
+	}{
+		{"Song, all platforms", "/leaderboards/song?song_id=444450&role_id=1", restapi.LeaderboardHandler, 3},
+		{"Song, Wii by name", "/leaderboards/song?song_id=444450&role_id=1&platform=wii", restapi.LeaderboardHandler, 2},
+		{"Song, PS3 by number", "/leaderboards/song?song_id=444450&role_id=1&platform=1", restapi.LeaderboardHandler, 1},
+		{"Song, Xbox", "/leaderboards/song?song_id=444450&role_id=1&platform=xbox", restapi.LeaderboardHandler, 0},
+		{"Battle, Wii", "/leaderboards/battle?battle_id=444451&platform=WII", restapi.BattleLeaderboardHandler, 2},
+		{"Battle, PS3", "/leaderboards/battle?battle_id=444451&platform=ps3", restapi.BattleLeaderboardHandler, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := makeRequest(t, "GET", tc.path, nil, tc.handler)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d (body: %s)", rr.Code, rr.Body.String())
+			}
+
+			var response map[string][]json.RawMessage
+			decodeResponse(t, rr, &response)
+
+			if len(response["leaderboard"]) != tc.expected {
+				t.Errorf("Expected %d entries, got %d", tc.expected, len(response["leaderboard"]))
+			}
+		})
+	}
+
+	rr := makeRequest(t, "GET", "/leaderboards/song?song_id=444450&role_id=1&platform=dreamcast", nil, restapi.LeaderboardHandler)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid platform, got %d", rr.Code)
+	}
+}
+
+// Tests the past battle results and player battle history endpoints
+func TestBattleResultHandlers(t *testing.T) {
+	ctx := context.Background()
+	resultsCollection := database.GocentralDatabase.Collection("battle_results")
+	historyCollection := database.GocentralDatabase.Collection("battle_history")
+
+	battleID := 78831
+	resultsCollection.InsertOne(ctx, models.BattleResult{
+		BattleID:     battleID,
+		Type:         1002,
+		Title:        "REST Result Battle",
+		EndedAt:      time.Now().Unix(),
+		Participants: 1,
+		Standings:    []models.BattleStanding{{Rank: 1, PID: 501, Name: "someone", Score: 1234}},
+	})
+	historyCollection.InsertOne(ctx, models.BattleHistoryEntry{BattleID: battleID, PID: 501, Title: "REST Result Battle", Rank: 1, Score: 1234, EndedAt: time.Now().Unix()})
+	defer resultsCollection.DeleteMany(ctx, bson.M{"battle_id": battleID})
+	defer historyCollection.DeleteMany(ctx, bson.M{"battle_id": battleID})
+
+	router := chi.NewRouter()
+	router.Get("/battles/results", restapi.BattleResultsHandler)
+	router.Get("/battles/results/{id}", restapi.BattleResultHandler)
+	router.Get("/players/{pid}/battles", restapi.PlayerBattleHistoryHandler)
+
+	rr := makeRequest(t, "GET", "/battles/results?global=1", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 listing battle results, got %d", rr.Code)
+	}
+	var listResponse map[string][]models.BattleResult
+	decodeResponse(t, rr, &listResponse)
+	if len(listResponse["results"]) == 0 || listResponse["results"][0].BattleID != battleID {
+		t.Errorf("Expected the newest battle result to be listed first, got %+v", listResponse["results"])
+	}
+
+	rr = makeRequest(t, "GET", "/battles/results/78831", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 getting battle result, got %d", rr.Code)
+	}
+	var result models.BattleResult
+	decodeResponse(t, rr, &result)
+	if len(result.Standings) != 1 || result.Standings[0].Score != 1234 {
+		t.Errorf("Unexpected battle result: %+v", result)
+	}
+
+	rr = makeRequest(t, "GET", "/battles/results/999999", nil, router.ServeHTTP)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown battle result, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "GET", "/players/501/battles", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 getting battle history, got %d", rr.Code)
+	}
+	var historyResponse map[string][]models.BattleHistoryEntry
+	decodeResponse(t, rr, &historyResponse)
+	if len(historyResponse["battles"]) == 0 || historyResponse["battles"][0].BattleID != battleID {
+		t.Errorf("Expected battle %d in the player's history, got %+v", battleID, historyResponse["battles"])
+	}
+
+	rr = makeRequest(t, "GET", "/players/abc/battles", nil, router.ServeHTTP)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid PID, got %d", rr.Code)
+	}
+
+	// battles an admin hid are kept out of everything public
+	if err := database.SetBattleResultHidden(ctx, database.GocentralDatabase, battleID, true); err != nil {
+		t.Fatalf("Failed to hide battle result: %v", err)
+	}
+
+	rr = makeRequest(t, "GET", "/battles/results/78831", nil, router.ServeHTTP)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a hidden battle result, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "GET", "/battles/results?global=1", nil, router.ServeHTTP)
+	listResponse = nil
+	decodeResponse(t, rr, &listResponse)
+	for _, listed := range listResponse["results"] {
+		if listed.BattleID == battleID {
+			t.Error("Expected a hidden battle not to be listed")
+		}
+	}
+
+	rr = makeRequest(t, "GET", "/players/501/battles", nil, router.ServeHTTP)
+	historyResponse = nil
+	decodeResponse(t, rr, &historyResponse)
+	for _, entry := range historyResponse["battles"] {
+		if entry.BattleID == battleID {
+			t.Error("Expected a hidden battle not to be in the player's history")
+		}
+	}
+}
+
+// Tests creating, listing, queueing songs for and deleting battle templates
+func TestBattleTemplateHandlers(t *testing.T) {
+	ctx := context.Background()
+	templatesCollection := database.GocentralDatabase.Collection("battle_templates")
+	defer templatesCollection.DeleteMany(ctx, bson.M{"title": "Battle Night"})
+
+	router := chi.NewRouter()
+	router.Get("/admin/battles/templates", restapi.BattleTemplateListHandler)
+	router.Post("/admin/battles/templates", restapi.CreateBattleTemplateHandler)
+	router.Put("/admin/battles/templates/{id}", restapi.UpdateBattleTemplateHandler)
+	router.Delete("/admin/battles/templates/{id}", restapi.DeleteBattleTemplateHandler)
+	router.Post("/admin/battles/templates/{id}/queue", restapi.QueueBattleSongsHandler)
+
+	invalid := map[string]interface{}{
+		"title":      "Battle Night",
+		"recurrence": map[string]interface{}{"frequency": "monthly", "duration_hours": 48},
+		"song_pool":  map[string]interface{}{"strategy": "fixed", "song_ids": []int{1048}},
+	}
+	rr := makeRequest(t, "POST", "/admin/battles/templates", invalid, router.ServeHTTP)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid recurrence, got %d", rr.Code)
+	}
+
+	template := map[string]interface{}{
+		"title":      "Battle Night",
+		"enabled":    true,
+		"recurrence": map[string]interface{}{"frequency": "weekly", "weekday": 5, "hour": 20, "duration_hours": 48},
+		"song_pool":  map[string]interface{}{"strategy": "queue"},
+	}
+	rr = makeRequest(t, "POST", "/admin/battles/templates", template, router.ServeHTTP)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 creating a battle template, got %d (body: %s)", rr.Code, rr.Body.String())
+	}
+
+	var createResponse map[string]interface{}
+	decodeResponse(t, rr, &createResponse)
+	templateID := int(createResponse["template_id"].(float64))
+	nextStartAt := time.Unix(int64(createResponse["next_start_at"].(float64)), 0).UTC()
+	if nextStartAt.Weekday() != time.Friday || nextStartAt.Hour() != 20 {
+		t.Errorf("Expected the next battle to start on a Friday at 20:00, got %v", nextStartAt)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/battles/templates/"+strconv.Itoa(templateID)+"/queue", map[string]interface{}{"song_ids": []int{1048, 10234}}, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 queueing songs, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "GET", "/admin/battles/templates", nil, router.ServeHTTP)
+	var listResponse map[string][]models.BattleTemplate
+	decodeResponse(t, rr, &listResponse)
+	found := false
+	for _, listed := range listResponse["templates"] {
+		if listed.TemplateID == templateID {
+			found = true
+			if len(listed.SongPool.Queue) != 1 {
+				t.Errorf("Expected 1 queued song list, got %v", listed.SongPool.Queue)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected battle template %d to be listed", templateID)
+	}
+
+	template["enabled"] = false
+	rr = makeRequest(t, "PUT", "/admin/battles/templates/"+strconv.Itoa(templateID), template, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 updating a battle template, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "DELETE", "/admin/battles/templates/"+strconv.Itoa(templateID), nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 deleting a battle template, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "DELETE", "/admin/battles/templates/"+strconv.Itoa(templateID), nil, router.ServeHTTP)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting a missing battle template, got %d", rr.Code)
+	}
+}
+
+// Tests listing, editing, transferring, hiding and resetting battles as an admin
+func TestAdminBattleHandlers(t *testing.T) {
+	ctx := context.Background()
+	setlistsCollection := database.GocentralDatabase.Collection("setlists")
+	scoresCollection := database.GocentralDatabase.Collection("scores")
+
+	battleID := 78861
+	setlistsCollection.InsertOne(ctx, models.Setlist{
+		SetlistID:    battleID,
+		PID:          501,
+		Owner:        "testuser2",
+		Title:        "Player Battle",
+		Type:         1000,
+		Shared:       "t",
+		SongIDs:      []int{1048},
+		Created:      time.Now().Unix(),
+		TimeEndVal:   1,
+		TimeEndUnits: "days",
+	})
+	defer setlistsCollection.DeleteMany(ctx, bson.M{"setlist_id": battleID})
+
+	scoresCollection.InsertOne(ctx, bson.M{"battle_id": battleID, "pid": 501, "score": 1000})
+	defer scoresCollection.DeleteMany(ctx, bson.M{"battle_id": battleID})
+
+	router := chi.NewRouter()
+	router.Get("/admin/battles", restapi.AdminBattleListHandler)
+	router.Patch("/admin/battles/{id}", restapi.UpdateBattleHandler)
+	router.Post("/admin/battles/{id}/transfer", restapi.TransferBattleHandler)
+	router.Post("/admin/battles/{id}/hide", restapi.HideBattleHandler)
+	router.Post("/admin/battles/{id}/unhide", restapi.UnhideBattleHandler)
+	router.Delete("/admin/battles/{id}/scores", restapi.ResetBattleLeaderboardHandler)
+
+	rr := makeRequest(t, "GET", "/admin/battles?username=testuser2&type=1000", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 listing battles, got %d", rr.Code)
+	}
+	var listResponse map[string][]restapi.AdminBattleInfo
+	decodeResponse(t, rr, &listResponse)
+	if len(listResponse["battles"]) != 1 || listResponse["battles"][0].BattleID != battleID {
+		t.Errorf("Expected the player's battle to be listed, got %+v", listResponse["battles"])
+	}
+
+	expiresAt := time.Now().Add(3 * time.Hour).UTC()
+	rr = makeRequest(t, "PATCH", "/admin/battles/78861", map[string]interface{}{"title": "Renamed Battle", "expires_at": expiresAt, "song_ids": []int{1048, 10234}}, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 updating battle, got %d (body: %s)", rr.Code, rr.Body.String())
+	}
+
+	battle, _ := database.GetBattle(ctx, database.GocentralDatabase, battleID)
+	if battle.Title != "Renamed Battle" || len(battle.SongIDs) != 2 || battle.TimeEndUnits != "seconds" {
+		t.Errorf("Unexpected battle after update: %+v", battle)
+	}
+	if _, newExpiry := database.GetSetlistBattleExpiryInfo(*battle); newExpiry.Unix() != expiresAt.Unix() {
+		t.Errorf("Expected the battle to expire at %v, got %v", expiresAt, newExpiry)
+	}
+
+	rr = makeRequest(t, "PATCH", "/admin/battles/78861", map[string]interface{}{"song_ids": []int{}}, router.ServeHTTP)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 clearing the song list, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/battles/78861/transfer", map[string]interface{}{"username": "testuser3"}, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 transferring battle, got %d", rr.Code)
+	}
+	battle, _ = database.GetBattle(ctx, database.GocentralDatabase, battleID)
+	if battle.PID != 502 || battle.Owner != "testuser3" {
+		t.Errorf("Expected the battle to belong to testuser3, got PID %d owner %s", battle.PID, battle.Owner)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/battles/78861/hide", map[string]interface{}{"reason": "profanity"}, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 hiding battle, got %d", rr.Code)
+	}
+	battle, _ = database.GetBattle(ctx, database.GocentralDatabase, battleID)
+	if !battle.Hidden || battle.HiddenReason != "profanity" {
+		t.Errorf("Expected the battle to be hidden, got %+v", battle)
+	}
+	if count, _ := scoresCollection.CountDocuments(ctx, bson.M{"battle_id": battleID}); count != 1 {
+		t.Errorf("Expected hiding a battle to keep its scores, got %d scores", count)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/battles/78861/unhide", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 unhiding battle, got %d", rr.Code)
+	}
+	battle, _ = database.GetBattle(ctx, database.GocentralDatabase, battleID)
+	if battle.Hidden {
+		t.Error("Expected the battle to be visible again")
+	}
+
+	rr = makeRequest(t, "DELETE", "/admin/battles/78861/scores", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 resetting battle leaderboard, got %d", rr.Code)
+	}
+	if count, _ := scoresCollection.CountDocuments(ctx, bson.M{"battle_id": battleID}); count != 0 {
+		t.Errorf("Expected the battle's scores to be deleted, got %d", count)
+	}
+
+	rr = makeRequest(t, "PATCH", "/admin/battles/999999", map[string]interface{}{"title": "Nope"}, router.ServeHTTP)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 updating a missing battle, got %d", rr.Code)
+	}
+}
+
+// Tests the per-player battle counts and battle limit overrides
+func TestPlayerBattleLimitHandlers(t *testing.T) {
+	ctx := context.Background()
+	setlistsCollection := database.GocentralDatabase.Collection("setlists")
+	usersCollection := database.GocentralDatabase.Collection("users")
+
+	setlistsCollection.InsertMany(ctx, []interface{}{
+		models.Setlist{SetlistID: 78871, PID: 501, Type: 1000, Title: "Count One"},
+		models.Setlist{SetlistID: 78872, PID: 501, Type: 1001, Title: "Count Two"},
+	})
+	defer setlistsCollection.DeleteMany(ctx, bson.M{"setlist_id": bson.M{"$in": []int{78871, 78872}}})
+	defer usersCollection.UpdateOne(ctx, bson.M{"pid": 501}, bson.M{"$unset": bson.M{"battle_limit": ""}})
+
+	rr := makeRequest(t, "POST", "/admin/players/battle_limit", map[string]interface{}{"username": "testuser2", "battle_limit": 10}, restapi.SetPlayerBattleLimitHandler)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 setting battle limit, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "GET", "/admin/battles/counts?page_size=100", nil, restapi.PlayerBattleCountsHandler)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 getting battle counts, got %d", rr.Code)
+	}
+	var countsResponse map[string][]restapi.PlayerBattleCount
+	decodeResponse(t, rr, &countsResponse)
+
+	found := false
+	for _, player := range countsResponse["players"] {
+		if player.PID == 501 {
+			found = true
+			if player.BattleCount < 2 || player.BattleLimit != 10 || !player.HasOverride {
+				t.Errorf("Unexpected battle count entry: %+v", player)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected PID 501 to be in the battle counts")
+	}
+
+	rr = makeRequest(t, "POST", "/admin/players/battle_limit", map[string]interface{}{"username": "testuser2", "battle_limit": nil}, restapi.SetPlayerBattleLimitHandler)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 removing battle limit override, got %d", rr.Code)
+	}
+
+	var user models.User
+	usersCollection.FindOne(ctx, bson.M{"pid": 501}).Decode(&user)
+	if user.BattleLimit != nil {
+		t.Errorf("Expected the battle limit override to be removed, got %d", *user.BattleLimit)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/players/battle_limit", map[string]interface{}{"username": "testuser2", "battle_limit": -1}, restapi.SetPlayerBattleLimitHandler)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a negative battle limit, got %d", rr.Code)
+	}
+}
+
+// Tests listing groups and managing a player's group memberships
+func TestGroupHandlers(t *testing.T) {
+	ctx := context.Background()
+	usersCollection := database.GocentralDatabase.Collection("users")
+	defer usersCollection.UpdateOne(ctx, bson.M{"pid": 501}, bson.M{"$unset": bson.M{"groups": ""}})
+
+	router := chi.NewRouter()
+	router.Get("/admin/groups", restapi.GroupListHandler)
+	router.Get("/admin/groups/{group}/users", restapi.GroupMembersHandler)
+	router.Get("/admin/players/groups", restapi.PlayerGroupsHandler)
+	router.Post("/admin/players/groups", restapi.AddPlayerToGroupHandler)
+	router.Delete("/admin/players/groups", restapi.RemovePlayerFromGroupHandler)
+
+	rr := makeRequest(t, "POST", "/admin/players/groups", map[string]string{"username": "testuser2", "group": "not_a_group"}, router.ServeHTTP)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown group, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/players/groups", map[string]string{"username": "testuser2", "group": "verified"}, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 adding player to group, got %d", rr.Code)
+	}
+	if !database.HasPrivilege(501, database.PrivilegeVerifiedBadge) {
+		t.Error("Expected the player to have the verified badge privilege")
+	}
+
+	rr = makeRequest(t, "GET", "/admin/players/groups?username=testuser2", nil, router.ServeHTTP)
+	var playerGroups restapi.PlayerGroupsResponse
+	decodeResponse(t, rr, &playerGroups)
+	if len(playerGroups.Groups) != 1 || playerGroups.Groups[0] != "verified" {
+		t.Errorf("Expected the player to be in the verified group, got %v", playerGroups.Groups)
+	}
+
+	rr = makeRequest(t, "GET", "/admin/groups/verified/users", nil, router.ServeHTTP)
+	var membersResponse map[string][]restapi.GroupMember
+	decodeResponse(t, rr, &membersResponse)
+	if len(membersResponse["users"]) != 1 || membersResponse["users"][0].PID != 501 {
+		t.Errorf("Expected PID 501 to be the only verified user, got %+v", membersResponse["users"])
+	}
+
+	rr = makeRequest(t, "GET", "/admin/groups", nil, router.ServeHTTP)
+	var groupsResponse map[string][]restapi.GroupInfo
+	decodeResponse(t, rr, &groupsResponse)
+	if len(groupsResponse["groups"]) != len(database.GroupCatalog) {
+		t.Errorf("Expected %d groups, got %d", len(database.GroupCatalog), len(groupsResponse["groups"]))
+	}
+
+	rr = makeRequest(t, "DELETE", "/admin/players/groups", map[string]string{"username": "testuser2", "group": "verified"}, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 removing player from group, got %d", rr.Code)
+	}
+	if database.HasPrivilege(501, database.PrivilegeVerifiedBadge) {
+		t.Error("Expected the player to lose the verified badge privilege")
+	}
+
+	rr = makeRequest(t, "GET", "/admin/groups/not_a_group/users", nil, router.ServeHTTP)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown group, got %d", rr.Code)
+	}
+}
+
+// Tests creating, scheduling, reordering, retiring and uploading art for curated setlists
+func TestCuratedSetlistHandlers(t *testing.T) {
+	t.Setenv("BASEBINARYDATAPATH", t.TempDir())
+
+	ctx := context.Background()
+	setlistsCollection := database.GocentralDatabase.Collection("setlists")
+	defer setlistsCollection.DeleteMany(ctx, bson.M{"type": 2, "owner": "Harmonix"})
+
+	router := chi.NewRouter()
+	router.Get("/admin/setlists/curated", restapi.CuratedSetlistListHandler)
+	router.Post("/admin/setlists/curated", restapi.CreateCuratedSetlistHandler)
+	router.Post("/admin/setlists/curated/reorder", restapi.ReorderCuratedSetlistsHandler)
+	router.Patch("/admin/setlists/curated/{id}", restapi.UpdateCuratedSetlistHandler)
+	router.Post("/admin/setlists/curated/{id}/schedule", restapi.ScheduleCuratedSetlistHandler)
+	router.Post("/admin/setlists/curated/{id}/retire", restapi.RetireCuratedSetlistHandler)
+	router.Post("/admin/setlists/curated/{id}/art", restapi.UploadCuratedSetlistArtHandler)
+
+	rr := makeRequest(t, "POST", "/admin/setlists/curated", map[string]interface{}{"title": "Empty"}, router.ServeHTTP)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a setlist with no songs, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/setlists/curated", map[string]interface{}{"title": "Wii Only", "song_ids": []int{1048}, "platforms": []int{2}}, router.ServeHTTP)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 creating a curated setlist, got %d (body: %s)", rr.Code, rr.Body.String())
+	}
+	var createResponse map[string]interface{}
+	decodeResponse(t, rr, &createResponse)
+	firstID := int(createResponse["setlist_id"].(float64))
+
+	rr = makeRequest(t, "POST", "/admin/setlists/curated", map[string]interface{}{"title": "Metal Week", "song_ids": []int{1048, 10234}}, router.ServeHTTP)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 creating a curated setlist, got %d", rr.Code)
+	}
+	decodeResponse(t, rr, &createResponse)
+	secondID := int(createResponse["setlist_id"].(float64))
+
+	rr = makeRequest(t, "POST", "/admin/setlists/curated/reorder", map[string]interface{}{"setlist_ids": []int{secondID, firstID}}, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 reordering curated setlists, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "GET", "/admin/setlists/curated", nil, router.ServeHTTP)
+	var listResponse map[string][]restapi.CuratedSetlistInfo
+	decodeResponse(t, rr, &listResponse)
+	if len(listResponse["setlists"]) != 2 || listResponse["setlists"][0].SetlistID != secondID {
+		t.Errorf("Expected setlist %d to be listed first, got %+v", secondID, listResponse["setlists"])
+	}
+
+	from := time.Now().Add(24 * time.Hour)
+	schedule := map[string]interface{}{"visible_from": from, "visible_until": from.Add(7 * 24 * time.Hour)}
+	rr = makeRequest(t, "POST", "/admin/setlists/curated/"+strconv.Itoa(secondID)+"/schedule", schedule, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 scheduling a curated setlist, got %d", rr.Code)
+	}
+
+	var scheduled models.Setlist
+	setlistsCollection.FindOne(ctx, bson.M{"setlist_id": secondID}).Decode(&scheduled)
+	if database.IsCuratedSetlistVisible(scheduled, 0, "us", time.Now()) {
+		t.Error("Expected the scheduled setlist to be hidden until next week")
+	}
+
+	rr = makeRequest(t, "PATCH", "/admin/setlists/curated/"+strconv.Itoa(firstID), map[string]interface{}{"platforms": []int{7}}, router.ServeHTTP)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown platform, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/setlists/curated/"+strconv.Itoa(firstID)+"/retire", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 retiring a curated setlist, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "GET", "/admin/setlists/curated?retired=0", nil, router.ServeHTTP)
+	decodeResponse(t, rr, &listResponse)
+	if len(listResponse["setlists"]) != 1 || listResponse["setlists"][0].SetlistID != secondID {
+		t.Errorf("Expected only setlist %d to not be retired, got %+v", secondID, listResponse["setlists"])
+	}
+
+	uploadArt := func(art []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("png_ps3", "art.png_ps3")
+		part.Write(art)
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/admin/setlists/curated/"+strconv.Itoa(secondID)+"/art", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// art that fails texture validation is only refused when the config asks for it
+	configCollection := database.GocentralDatabase.Collection("config")
+	configCollection.UpdateOne(ctx, bson.M{}, bson.M{"$set": bson.M{"reject_invalid_textures": true}})
+	database.InvalidateConfigCache()
+	rr = uploadArt([]byte("art"))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 uploading art that isn't a texture, got %d", rr.Code)
+	}
+	configCollection.UpdateOne(ctx, bson.M{}, bson.M{"$unset": bson.M{"reject_invalid_textures": ""}})
+	database.InvalidateConfigCache()
+
+	art, _ := binarydata.EncodeTexture(image.NewNRGBA(image.Rect(0, 0, 8, 8)), "png_ps3")
+	for revision := 1; revision <= 2; revision++ {
+		rr = uploadArt(art)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 uploading curated setlist art, got %d (body: %s)", rr.Code, rr.Body.String())
+		}
+
+		var artResponse map[string]interface{}
+		decodeResponse(t, rr, &artResponse)
+		if int(artResponse["art_revision"].(float64)) != revision {
+			t.Errorf("Expected art revision %d, got %v", revision, artResponse["art_revision"])
+		}
+	}
+}
+
+// Tests searching shared setlists and following them by GUID
+func TestSetlistSearchHandlers(t *testing.T) {
+	ctx := context.Background()
+	setlistsCollection := database.GocentralDatabase.Collection("setlists")
+	defer setlistsCollection.DeleteMany(ctx, bson.M{"setlist_id": bson.M{"$in": []int{88101, 88102, 88103}}})
+
+	now := time.Now().Unix()
+	setlistsCollection.InsertOne(ctx, models.Setlist{SetlistID: 88101, GUID: "search-guid-1", Title: "Metal Marathon", Type: 0, PID: 501, Owner: "testuser2", Shared: "t", SongIDs: []int{1048}, Created: now, SyncCount: 5})
+	setlistsCollection.InsertOne(ctx, models.Setlist{SetlistID: 88102, GUID: "search-guid-2", Title: "Metal Lite", Type: 0, PID: 502, Owner: "testuser3", Shared: "t", SongIDs: []int{10234}, Created: now - 60, SyncCount: 50})
+	setlistsCollection.InsertOne(ctx, models.Setlist{SetlistID: 88103, GUID: "search-guid-3", Title: "Private Metal", Type: 0, PID: 502, Owner: "testuser3", Shared: "f", SongIDs: []int{1048}, Created: now})
+
+	router := chi.NewRouter()
+	router.Get("/setlists", restapi.SetlistSearchHandler)
+	router.Get("/setlists/{id}", restapi.SetlistHandler)
+
+	rr := makeRequest(t, "GET", "/setlists?title=metal", nil, router.ServeHTTP)
+	var searchResponse map[string][]restapi.SetlistInfo
+	decodeResponse(t, rr, &searchResponse)
+	if len(searchResponse["setlists"]) != 2 || searchResponse["setlists"][0].SetlistID != 88102 {
+		t.Errorf("Expected the 2 shared metal setlists, most synced first, got %+v", searchResponse["setlists"])
+	}
+
+	rr = makeRequest(t, "GET", "/setlists?title=metal&song_id=1048&owner=TESTUSER2", nil, router.ServeHTTP)
+	decodeResponse(t, rr, &searchResponse)
+	if len(searchResponse["setlists"]) != 1 || searchResponse["setlists"][0].SetlistID != 88101 {
+		t.Errorf("Expected only setlist 88101, got %+v", searchResponse["setlists"])
+	}
+
+	rr = makeRequest(t, "GET", "/setlists?sort=loudest", nil, router.ServeHTTP)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown sort, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "GET", "/setlists/88103", nil, router.ServeHTTP)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a setlist that isn't shared, got %d", rr.Code)
+	}
+
+	found, err := database.FollowSetlist(ctx, database.GocentralDatabase, "search-guid-1", 500)
+	if err != nil || !found {
+		t.Fatalf("Expected following a shared setlist to succeed, got %v, %v", found, err)
+	}
+	// following twice must not count the follower twice
+	database.FollowSetlist(ctx, database.GocentralDatabase, "search-guid-1", 500)
+
+	if found, _ := database.FollowSetlist(ctx, database.GocentralDatabase, "search-guid-3", 500); found {
+		t.Error("Expected following a setlist that isn't shared to fail")
+	}
+
+	rr = makeRequest(t, "GET", "/setlists/88101", nil, router.ServeHTTP)
+	var setlist restapi.SetlistInfo
+	decodeResponse(t, rr, &setlist)
+	if setlist.FollowerCount != 1 {
+		t.Errorf("Expected 1 follower, got %d", setlist.FollowerCount)
+	}
+
+	if unfollowed, _ := database.UnfollowSetlist(ctx, database.GocentralDatabase, "search-guid-1", 500); !unfollowed {
+		t.Error("Expected unfollowing a followed setlist to succeed")
+	}
+
+	// a player's game polls over and over, but they only count once
+	database.RecordSetlistSyncs(ctx, database.GocentralDatabase, 502, []int{88101})
+	database.RecordSetlistSyncs(ctx, database.GocentralDatabase, 502, []int{88101})
+	rr = makeRequest(t, "GET", "/setlists/88101", nil, router.ServeHTTP)
+	decodeResponse(t, rr, &setlist)
+	if setlist.FollowerCount != 0 || setlist.SyncCount != 6 {
+		t.Errorf("Expected 0 followers and 6 syncs, got %d and %d", setlist.FollowerCount, setlist.SyncCount)
+	}
+}
+
+// Tests serving band logos uploaded from a console as PNGs
+func TestImageHandlers(t *testing.T) {
+	ctx := context.Background()
+	store := binarydata.NewFilesystemStore(t.TempDir())
+	binarydata.SetDefaultStore(store)
+	defer binarydata.SetDefaultStore(nil)
+	defer database.GocentralDatabase.Collection("binary_data_objects").DeleteMany(ctx, bson.M{"type": "band_logo", "key": "88301"})
+
+	router := chi.NewRouter()
+	router.Get("/bands/{id}/logo.png", restapi.BandLogoHandler)
+
+	rr := makeRequest(t, "GET", "/bands/88301/logo.png", nil, router.ServeHTTP)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a band with no logo, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "GET", "/bands/abc/logo.png", nil, router.ServeHTTP)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid band ID, got %d", rr.Code)
+	}
+
+	logo, _ := binarydata.EncodeTexture(image.NewNRGBA(image.Rect(0, 0, 8, 8)), "png_ps3")
+	store.Put(ctx, binarydata.Key("band_logo", "88301", 1, "png_ps3"), logo)
+	store.Put(ctx, binarydata.Key("band_logo", "88301", 2, "png_ps3"), logo)
+
+	rr = makeRequest(t, "GET", "/bands/88301/logo.png", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (body: %s)", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("Expected an image/png content type, got %q", rr.Header().Get("Content-Type"))
+	}
+	if _, err := png.Decode(rr.Body); err != nil {
+		t.Errorf("Expected a valid PNG, got %v", err)
+	}
+
+	// the newest revision is the one converted
+	if _, err := store.Get(ctx, binarydata.Key("band_logo", "88301", 2, database.CanonicalImageExtension)); err != nil {
+		t.Errorf("Expected revision 2 to have been converted, got %v", err)
+	}
+}
+
+// Tests reviewing uploaded band logos and replacing them with a placeholder
+func TestModerationHandlers(t *testing.T) {
+	ctx := context.Background()
+	store := binarydata.NewFilesystemStore(t.TempDir())
+	binarydata.SetDefaultStore(store)
+	defer binarydata.SetDefaultStore(nil)
+	moderationCollection := database.GocentralDatabase.Collection("binary_data_moderation")
+	defer moderationCollection.DeleteMany(ctx, bson.M{"key": bson.M{"$in": []string{"88401", "88402"}}})
+	defer database.GocentralDatabase.Collection("binary_data_objects").DeleteMany(ctx, bson.M{"key": bson.M{"$in": []string{"88401", "88402"}}})
+
+	logo, _ := binarydata.EncodeTexture(image.NewNRGBA(image.Rect(0, 0, 16, 16)), "png_wii")
+	for _, bandID := range []string{"88401", "88402"} {
+		database.StoreBinaryData(ctx, database.GocentralDatabase, store, "band_logo", bandID, 1, "png_wii", 501, logo)
+		if err := database.QueueBinaryDataForModeration(ctx, database.GocentralDatabase, "band_logo", bandID, 1, "png_wii", 501); err != nil {
+			t.Fatalf("Could not queue band logo %s: %v", bandID, err)
+		}
+	}
+
+	// saving the same revision again doesn't queue it twice, and battle art isn't moderated
+	database.QueueBinaryDataForModeration(ctx, database.GocentralDatabase, "band_logo", "88401", 1, "png_wii", 501)
+	database.QueueBinaryDataForModeration(ctx, database.GocentralDatabase, "battle_art", "88401", 1, "png_wii", 501)
+	if count, _ := moderationCollection.CountDocuments(ctx, bson.M{"key": "88401"}); count != 1 {
+		t.Errorf("Expected band logo 88401 to be queued once, got %d", count)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/admin/moderation", restapi.ModerationListHandler)
+	router.Get("/admin/moderation/{id}/image.png", restapi.ModerationImageHandler)
+	router.Post("/admin/moderation/{id}/approve", restapi.ApproveModerationHandler)
+	router.Post("/admin/moderation/{id}/replace", restapi.ReplaceModerationHandler)
+
+	rr := makeRequest(t, "GET", "/admin/moderation", nil, router.ServeHTTP)
+	var listResponse map[string][]restapi.ModerationEntry
+	decodeResponse(t, rr, &listResponse)
+
+	ids := map[string]int{}
+	for _, entry := range listResponse["uploads"] {
+		ids[entry.Key] = entry.ModerationID
+		if entry.Key == "88401" && entry.OwnerName == "Unnamed Player" {
+			t.Errorf("Expected the uploader's name, got %q", entry.OwnerName)
+		}
+	}
+	if ids["88401"] == 0 || ids["88402"] == 0 {
+		t.Fatalf("Expected both band logos to be pending, got %+v", listResponse["uploads"])
+	}
+
+	rr = makeRequest(t, "GET", "/admin/moderation/"+strconv.Itoa(ids["88401"])+"/image.png", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("Expected a PNG preview, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	rr = makeRequest(t, "POST", "/admin/moderation/"+strconv.Itoa(ids["88401"])+"/approve", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 approving, got %d (body: %s)", rr.Code, rr.Body.String())
+	}
+
+	rr = makeRequest(t, "POST", "/admin/moderation/"+strconv.Itoa(ids["88401"])+"/approve", nil, router.ServeHTTP)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 approving twice, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/moderation/"+strconv.Itoa(ids["88402"])+"/replace", map[string]string{"note": "offensive"}, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 replacing, got %d (body: %s)", rr.Code, rr.Body.String())
+	}
+
+	for _, platformExtension := range binarydata.PlatformExtensions {
+		data, err := store.Get(ctx, binarydata.Key("band_logo", "88402", 1, platformExtension))
+		if err != nil {
+			t.Fatalf("Expected a %s placeholder, got %v", platformExtension, err)
+		}
+		img, err := binarydata.DecodeTexture(data, platformExtension)
+		if err != nil || img.Bounds().Dx() != 16 || img.NRGBAAt(0, 0).A != 255 {
+			t.Errorf("Expected an opaque 16x16 %s placeholder, got %v", platformExtension, err)
+		}
+	}
+
+	rr = makeRequest(t, "POST", "/admin/moderation/"+strconv.Itoa(ids["88402"])+"/approve", nil, router.ServeHTTP)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 approving replaced art, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "GET", "/admin/moderation?status=replaced", nil, router.ServeHTTP)
+	decodeResponse(t, rr, &listResponse)
+	found := false
+	for _, entry := range listResponse["uploads"] {
+		if entry.ModerationID == ids["88402"] {
+			found = entry.ReviewNote == "offensive"
+		}
+	}
+	if !found {
+		t.Errorf("Expected the replaced logo and its note to be listed, got %+v", listResponse["uploads"])
+	}
+}
+
+// Tests rotating a player's and a Wii's secret and going back to the platform's password
+func TestRotateSecretHandler(t *testing.T) {
+	ctx := context.Background()
+	usersCollection := database.GocentralDatabase.Collection("users")
+	machinesCollection := database.GocentralDatabase.Collection("machines")
+
+	defer usersCollection.UpdateOne(ctx, bson.M{"pid": 501}, bson.M{"$unset": bson.M{"kerberos_key": "", "kerberos_key_rotated_at": ""}})
+	defer machinesCollection.UpdateOne(ctx, bson.M{"machine_id": 1000000000}, bson.M{"$unset": bson.M{"kerberos_key": "", "kerberos_key_rotated_at": ""}})
+
+	rr := makeRequest(t, "POST", "/admin/players/secret", map[string]interface{}{"username": "testuser2"}, restapi.RotateSecretHandler)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 rotating a player's secret, got %d", rr.Code)
+	}
+	var response struct {
+		PID    int    `json:"pid"`
+		Secret string `json:"secret"`
+	}
+	decodeResponse(t, rr, &response)
+
+	key, err := database.GetKerberosKey(ctx, database.GocentralDatabase, 501)
+	if err != nil || response.Secret == "" || !bytes.Equal(key, authentication.DeriveKerberosKey(501, response.Secret)) {
+		t.Errorf("Expected the stored key to come from the returned secret, got %x (%v)", key, err)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/players/secret", map[string]interface{}{"username": "testuser2", "use_platform_password": true}, restapi.RotateSecretHandler)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 going back to the platform's password, got %d", rr.Code)
+	}
+	if key, _ := database.GetKerberosKey(ctx, database.GocentralDatabase, 501); key != nil {
+		t.Errorf("Expected the stored key to be removed, got %x", key)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/players/secret", map[string]interface{}{"machine_id": 1000000000}, restapi.RotateSecretHandler)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 rotating a Wii's secret, got %d", rr.Code)
+	}
+	decodeResponse(t, rr, &response)
+	if key, _ := database.GetKerberosKey(ctx, database.GocentralDatabase, 1000000000); !bytes.Equal(key, authentication.DeriveKerberosKey(1000000000, response.Secret)) {
+		t.Errorf("Expected the Wii's stored key to come from the returned secret, got %x", key)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/players/secret", map[string]interface{}{"username": "nobody-here"}, restapi.RotateSecretHandler)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown player, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "POST", "/admin/players/secret", map[string]interface{}{"username": "testuser2", "machine_id": 1000000000}, restapi.RotateSecretHandler)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for both a username and a machine ID, got %d", rr.Code)
+	}
+}
+
+// Tests linking a game account to a web account with its link code
+func TestWebLinkHandlers(t *testing.T) {
+	ctx := context.Background()
+	usersCollection := database.GocentralDatabase.Collection("users")
+	defer usersCollection.UpdateOne(ctx, bson.M{"pid": 501}, bson.M{"$unset": bson.M{"web_identity": "", "web_linked_at": "", "link_code": "", "link_code_expires_at": ""}})
+
+	code, err := database.GetOrRegenerateLinkCode(ctx, database.GocentralDatabase, 501, time.Hour)
+	if err != nil || len(code) != 10 {
+		t.Fatalf("Could not get link code: %q (%v)", code, err)
+	}
+	if again, _ := database.GetOrRegenerateLinkCode(ctx, database.GocentralDatabase, 501, time.Hour); again != code {
+		t.Errorf("Expected the same code while it is still valid, got %q and %q", code, again)
+	}
+
+	rr := makeRequest(t, "POST", "/web/link", map[string]interface{}{"code": code, "web_identity": "discord:1234"}, restapi.LinkAccountHandler)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 linking an account, got %d", rr.Code)
+	}
+
+	if linked, _ := database.IsWebLinked(ctx, database.GocentralDatabase, 501); !linked {
+		t.Error("Expected the account to be linked")
+	}
+
+	rr = makeRequest(t, "POST", "/web/link", map[string]interface{}{"code": code, "web_identity": "discord:5678"}, restapi.LinkAccountHandler)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 using a link code twice, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "GET", "/web/accounts?web_identity=discord:1234", nil, restapi.LinkedAccountsHandler)
+	var accountsResponse map[string][]restapi.LinkedAccount
+	decodeResponse(t, rr, &accountsResponse)
+	if len(accountsResponse["accounts"]) != 1 || accountsResponse["accounts"][0].PID != 501 {
+		t.Errorf("Expected PID 501 to be the only linked account, got %+v", accountsResponse["accounts"])
+	}
+
+	// a used code is replaced with a new one the next time the game asks
+	newCode, _ := database.GetOrRegenerateLinkCode(ctx, database.GocentralDatabase, 501, time.Hour)
+	if newCode == "" || newCode == code {
+		t.Errorf("Expected a new code after the old one was used, got %q", newCode)
+	}
+
+	usersCollection.UpdateOne(ctx, bson.M{"pid": 501}, bson.M{"$set": bson.M{"link_code_expires_at": time.Now().Add(-time.Minute)}})
+	rr = makeRequest(t, "POST", "/web/link", map[string]interface{}{"code": newCode, "web_identity": "discord:1234"}, restapi.LinkAccountHandler)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an expired link code, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "DELETE", "/web/link", map[string]interface{}{"pid": 501, "web_identity": "discord:5678"}, restapi.UnlinkAccountHandler)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 unlinking from the wrong web account, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "DELETE", "/web/link", map[string]interface{}{"pid": 501, "web_identity": "discord:1234"}, restapi.UnlinkAccountHandler)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 unlinking an account, got %d", rr.Code)
+	}
+	if linked, _ := database.IsWebLinked(ctx, database.GocentralDatabase, 501); linked {
+		t.Error("Expected the account to no longer be linked")
+	}
+}
+
+// Tests that link codes from older servers, which could be shared between players, don't stop the link code index being created
+func TestEnsureLinkCodeIndexesClearsLegacyCodes(t *testing.T) {
+	ctx := context.Background()
+	usersCollection := database.GocentralDatabase.Collection("users")
+
+	usersCollection.Indexes().DropOne(ctx, "link_code_1")
+	usersCollection.InsertMany(ctx, []interface{}{
+		bson.M{"pid": 9101, "username": "legacylink1", "link_code": "SAMECODE00"},
+		bson.M{"pid": 9102, "username": "legacylink2", "link_code": "SAMECODE00"},
+	})
+	defer usersCollection.DeleteMany(ctx, bson.M{"pid": bson.M{"$in": []int{9101, 9102}}})
+
+	if err := database.EnsureLinkCodeIndexes(ctx, database.GocentralDatabase); err != nil {
+		t.Fatalf("Expected the index to be created, got %v", err)
+	}
+
+	if count, _ := usersCollection.CountDocuments(ctx, bson.M{"link_code": "SAMECODE00"}); count != 0 {
+		t.Errorf("Expected the legacy link codes to be cleared, %d are left", count)
+	}
+
+	// new codes still can't be shared
+	usersCollection.UpdateOne(ctx, bson.M{"pid": 9101}, bson.M{"$set": bson.M{"link_code": "NEWCODE000", "link_code_expires_at": time.Now().Add(time.Hour)}})
+	if _, err := usersCollection.UpdateOne(ctx, bson.M{"pid": 9102}, bson.M{"$set": bson.M{"link_code": "NEWCODE000", "link_code_expires_at": time.Now().Add(time.Hour)}}); !mongo.IsDuplicateKeyError(err) {
+		t.Errorf("Expected a duplicate link code to be refused, got %v", err)
+	}
+}
+
+// Tests that the web endpoints only accept the web API token, not the admin one
+func TestWebTokenAuth(t *testing.T) {
+	ctx := context.Background()
+	configCollection := database.GocentralDatabase.Collection("config")
+
+	cleanup := setupTestAdminToken(t, "admin-secret")
+	defer cleanup()
+
+	configCollection.UpdateOne(ctx, bson.M{}, bson.M{"$set": bson.M{"web_api_token": "web-secret"}})
+	database.InvalidateConfigCache()
+	defer func() {
+		configCollection.UpdateOne(ctx, bson.M{}, bson.M{"$unset": bson.M{"web_api_token": ""}})
+		database.InvalidateConfigCache()
+	}()
+
+	protectedHandler := restapi.WebTokenAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	if rr := makeAuthRequest(t, "GET", "/web/accounts", nil, protectedHandler, "web-secret"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with the web token, got %d", rr.Code)
+	}
+	if rr := makeAuthRequest(t, "GET", "/web/accounts", nil, protectedHandler, "admin-secret"); rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 with the admin token, got %d", rr.Code)
+	}
+}
+
+// Tests exporting everything stored about a player and then erasing it
+func TestAccountErasureHandlers(t *testing.T) {
+	ctx := context.Background()
+	store := binarydata.NewFilesystemStore(t.TempDir())
+	binarydata.SetDefaultStore(store)
+	defer binarydata.SetDefaultStore(nil)
+
+	const pid = 77701
+	db := database.GocentralDatabase
+	cleanupFilter := bson.M{"$or": bson.A{bson.M{"pid": pid}, bson.M{"owner_pid": pid}, bson.M{"setlist_id": bson.M{"$in": []int{77711, 77712}}}}}
+	for _, collection := range []string{"users", "scores", "bands", "characters", "setlists", "accomplishment_scores", "battle_history", "binary_data_objects", "username_redirects"} {
+		defer db.Collection(collection).DeleteMany(ctx, cleanupFilter)
+	}
+	defer db.Collection("battle_results").DeleteMany(ctx, bson.M{"battle_id": 77712})
+	defer db.Collection("users").UpdateOne(ctx, bson.M{"pid": 501}, bson.M{"$pull": bson.M{"friends": pid}})
+
+	db.Collection("users").InsertOne(ctx, bson.M{"pid": pid, "username": "erase_me", "console_type": 1, "web_identity": "discord:777"})
+	db.Collection("username_redirects").InsertOne(ctx, models.UsernameRedirect{Username: "erase_me_old", PID: pid})
+	db.Collection("users").UpdateOne(ctx, bson.M{"pid": 501}, bson.M{"$push": bson.M{"friends": pid}})
+	db.Collection("scores").InsertMany(ctx, []interface{}{
+		models.Score{SongID: 1, OwnerPID: pid, Score: 1000},
+		models.Score{SongID: 1, OwnerPID: 501, Score: 900, BandPIDs: []int{pid}},
+	})
+	db.Collection("bands").InsertOne(ctx, bson.M{"owner_pid": pid, "band_id": 77721, "name": "Erased Band"})
+	db.Collection("characters").InsertOne(ctx, bson.M{"owner_pid": pid, "character_id": 77731, "name": "Erased Character"})
+	db.Collection("setlists").InsertMany(ctx, []interface{}{
+		models.Setlist{SetlistID: 77711, PID: pid, Type: 2, Title: "Mine", GUID: "erase-me-setlist"},
+		models.Setlist{SetlistID: 77712, PID: pid, Type: 1000, Title: "My Battle", Owner: "erase_me"},
+	})
+	db.Collection("accomplishment_scores").InsertOne(ctx, models.AccomplishmentScore{AccID: "acc_test", PID: pid, Score: 5})
+	db.Collection("battle_results").InsertOne(ctx, models.BattleResult{BattleID: 77712, Standings: []models.BattleStanding{{Rank: 1, PID: pid, Name: "erase_me"}, {Rank: 2, PID: 501, Name: "testuser2"}}})
+
+	logo, _ := binarydata.EncodeTexture(image.NewNRGBA(image.Rect(0, 0, 16, 16)), "png_wii")
+	if err := database.StoreBinaryData(ctx, db, store, "band_logo", "77721", 1, "png_wii", pid, logo); err != nil {
+		t.Fatalf("Could not store band logo: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/admin/players/{pid}/export", restapi.ExportAccountHandler)
+	router.Delete("/admin/players/{pid}", restapi.EraseAccountHandler)
+	router.Get("/web/accounts/{pid}/export", restapi.WebExportAccountHandler)
+
+	rr := makeRequest(t, "GET", "/admin/players/77701/export", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 exporting an account, got %d", rr.Code)
+	}
+	var export struct {
+		User       models.User                 `json:"user"`
+		Scores     []json.RawMessage           `json:"scores"`
+		Bands      []json.RawMessage           `json:"bands"`
+		Setlists   []json.RawMessage           `json:"setlists"`
+		BinaryData []database.BinaryDataExport `json:"binary_data"`
+	}
+	decodeResponse(t, rr, &export)
+	if export.User.Username != "erase_me" || len(export.Scores) != 1 || len(export.Bands) != 1 || len(export.Setlists) != 2 {
+		t.Errorf("Unexpected export: %+v", export)
+	}
+	if len(export.BinaryData) != 1 || !bytes.Equal(export.BinaryData[0].Data, logo) {
+		t.Errorf("Expected the band logo to be exported, got %d pieces of binary data", len(export.BinaryData))
+	}
+
+	if rr := makeRequest(t, "GET", "/web/accounts/77701/export?web_identity=discord:999", nil, router.ServeHTTP); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 exporting an account linked to someone else, got %d", rr.Code)
+	}
+	if rr := makeRequest(t, "GET", "/web/accounts/77701/export?web_identity=discord:777", nil, router.ServeHTTP); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 exporting a linked account, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, "DELETE", "/admin/players/77701", nil, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 erasing an account, got %d", rr.Code)
+	}
+
+	for _, collection := range []string{"users", "scores", "accomplishment_scores", "username_redirects"} {
+		if count, _ := db.Collection(collection).CountDocuments(ctx, bson.M{"pid": pid}); count != 0 {
+			t.Errorf("Expected nothing left in %s, got %d", collection, count)
+		}
+	}
+	for _, collection := range []string{"bands", "characters", "binary_data_objects"} {
+		if count, _ := db.Collection(collection).CountDocuments(ctx, bson.M{"owner_pid": pid}); count != 0 {
+			t.Errorf("Expected nothing left in %s, got %d", collection, count)
+		}
+	}
+	if _, err := store.Get(ctx, binarydata.Key("band_logo", "77721", 1, "png_wii")); err != binarydata.ErrNotFound {
+		t.Errorf("Expected the band logo to be deleted from the store, got %v", err)
+	}
+
+	var battle models.Setlist
+	db.Collection("setlists").FindOne(ctx, bson.M{"setlist_id": 77712}).Decode(&battle)
+	if battle.PID != 0 || battle.Owner != "Harmonix" {
+		t.Errorf("Expected the battle to be handed to the server, got PID %d owned by %q", battle.PID, battle.Owner)
+	}
+	if count, _ := db.Collection("setlists").CountDocuments(ctx, bson.M{"setlist_id": 77711}); count != 0 {
+		t.Error("Expected the player's own setlist to be deleted")
+	}
+
+	var result models.BattleResult
+	db.Collection("battle_results").FindOne(ctx, bson.M{"battle_id": 77712}).Decode(&result)
+	if result.Standings[0].PID != 0 || result.Standings[0].Name != "Deleted Player" || result.Standings[1].Name != "testuser2" {
+		t.Errorf("Expected only the erased player's standing to be anonymized, got %+v", result.Standings)
+	}
+
+	if count, _ := db.Collection("scores").CountDocuments(ctx, bson.M{"band_pids": pid}); count != 0 {
+		t.Error("Expected the player to be removed from other players' band scores")
+	}
+	if count, _ := db.Collection("users").CountDocuments(ctx, bson.M{"friends": pid}); count != 0 {
+		t.Error("Expected the player to be removed from friend lists")
+	}
+
+	if rr := makeRequest(t, "DELETE", "/admin/players/77701", nil, router.ServeHTTP); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 erasing an account that no longer exists, got %d", rr.Code)
+	}
+}
+
+func TestMergeAccountsHandler(t *testing.T) {
+	ctx := context.Background()
+
+	const fromPID, toPID = 77801, 77802
+	db := database.GocentralDatabase
+	cleanupFilter := bson.M{"$or": bson.A{bson.M{"pid": bson.M{"$in": []int{fromPID, toPID}}}, bson.M{"owner_pid": bson.M{"$in": []int{fromPID, toPID}}}}}
+	for _, collection := range []string{"users", "scores", "bands", "setlists", "accomplishment_scores"} {
+		defer db.Collection(collection).DeleteMany(ctx, cleanupFilter)
+	}
+	defer db.Collection("username_redirects").DeleteMany(ctx, bson.M{"username": "old_name"})
+	defer db.Collection("users").UpdateOne(ctx, bson.M{"pid": 501}, bson.M{"$pull": bson.M{"friends": bson.M{"$in": []int{fromPID, toPID}}}})
+
+	db.Collection("users").InsertMany(ctx, []interface{}{
+		bson.M{"pid": fromPID, "username": "old_name", "console_type": 1, "friends": []int{501}, "groups": []string{"testers"}},
+		bson.M{"pid": toPID, "username": "new_name", "console_type": 0, "guid": "new-guid"},
+	})
+	db.Collection("users").UpdateOne(ctx, bson.M{"pid": 501}, bson.M{"$push": bson.M{"friends": fromPID}})
+	db.Collection("scores").InsertMany(ctx, []interface{}{
+		models.Score{SongID: 1, RoleID: 0, OwnerPID: fromPID, Score: 1000, DiffID: 3}, // better than the new account's
+		models.Score{SongID: 1, RoleID: 0, OwnerPID: toPID, Score: 900, DiffID: 2},
+		models.Score{SongID: 2, RoleID: 0, OwnerPID: fromPID, Score: 500}, // worse than the new account's
+		models.Score{SongID: 2, RoleID: 0, OwnerPID: toPID, Score: 800},
+		models.Score{SongID: 3, RoleID: 1, OwnerPID: fromPID, Score: 700}, // only the old account has one
+	})
+	db.Collection("accomplishment_scores").InsertMany(ctx, []interface{}{
+		models.AccomplishmentScore{AccID: "acc_merge", PID: fromPID, Score: 5},
+		models.AccomplishmentScore{AccID: "acc_merge", PID: toPID, Score: 10},
+	})
+	db.Collection("bands").InsertOne(ctx, bson.M{"owner_pid": fromPID, "band_id": 77821, "name": "Merged Band"})
+	db.Collection("setlists").InsertOne(ctx, models.Setlist{SetlistID: 77811, PID: fromPID, Type: 2, Title: "Merged", Owner: "old_name"})
+
+	router := chi.NewRouter()
+	router.Post("/admin/players/merge", restapi.MergeAccountsHandler)
+
+	if rr := makeRequest(t, "POST", "/admin/players/merge", map[string]interface{}{"from_username": "old_name", "to_username": "old_name"}, router.ServeHTTP); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 merging an account into itself, got %d", rr.Code)
+	}
+
+	var response struct {
+		Report database.AccountMergeReport `json:"report"`
+	}
+
+	rr := makeRequest(t, "POST", "/admin/players/merge", map[string]interface{}{"from_username": "old_name", "to_username": "new_name"}, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for a dry run, got %d", rr.Code)
+	}
+	decodeResponse(t, rr, &response)
+
+	expectedScores := database.MergeConflicts{Moved: 1, Replaced: 1, Dropped: 1}
+	if !response.Report.DryRun || response.Report.Scores != expectedScores || response.Report.Moved["bands"] != 1 || response.Report.Moved["friends"] != 1 {
+		t.Errorf("Unexpected dry run report: %+v", response.Report)
+	}
+	if count, _ := db.Collection("users").CountDocuments(ctx, bson.M{"pid": fromPID}); count != 1 {
+		t.Fatal("Expected a dry run not to change anything")
+	}
+
+	rr = makeRequest(t, "POST", "/admin/players/merge", map[string]interface{}{"from_username": "old_name", "to_username": "new_name", "confirm": true}, router.ServeHTTP)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 merging accounts, got %d", rr.Code)
+	}
+	decodeResponse(t, rr, &response)
+	if response.Report.DryRun || response.Report.Scores != expectedScores {
+		t.Errorf("Unexpected merge report: %+v", response.Report)
+	}
+
+	var scores []models.Score
+	cursor, _ := db.Collection("scores").Find(ctx, bson.M{"pid": toPID}, options.Find().SetSort(bson.D{{Key: "song_id", Value: 1}}))
+	cursor.All(ctx, &scores)
+	if len(scores) != 3 || scores[0].Score != 1000 || scores[0].DiffID != 3 || scores[1].Score != 800 || scores[2].Score != 700 {
+		t.Errorf("Expected the best score for every song and role to be kept, got %+v", scores)
+	}
+	if count, _ := db.Collection("scores").CountDocuments(ctx, bson.M{"pid": fromPID}); count != 0 {
+		t.Errorf("Expected no scores left on the old account, got %d", count)
+	}
+
+	var accomplishment models.AccomplishmentScore
+	db.Collection("accomplishment_scores").FindOne(ctx, bson.M{"pid": toPID, "acc_id": "acc_merge"}).Decode(&accomplishment)
+	if accomplishment.Score != 10 {
+		t.Errorf("Expected the better accomplishment score to be kept, got %d", accomplishment.Score)
+	}
+
+	var setlist models.Setlist
+	db.Collection("setlists").FindOne(ctx, bson.M{"setlist_id": 77811}).Decode(&setlist)
+	if setlist.PID != toPID || setlist.Owner != "new_name" {
+		t.Errorf("Expected the setlist to move to the new account, got PID %d owned by %q", setlist.PID, setlist.Owner)
+	}
+	if count, _ := db.Collection("bands").CountDocuments(ctx, bson.M{"owner_pid": toPID}); count != 1 {
+		t.Error("Expected the band to move to the new account")
+	}
+
+	var friend, merged models.User
+	db.Collection("users").FindOne(ctx, bson.M{"pid": 501}).Decode(&friend)
+	db.Collection("users").FindOne(ctx, bson.M{"pid": toPID}).Decode(&merged)
+	if slices.Contains(friend.Friends, fromPID) || !slices.Contains(friend.Friends, toPID) {
+		t.Errorf("Expected friend lists to point at the new account, got %v", friend.Friends)
+	}
+	if !slices.Contains(merged.Friends, 501) || !slices.Contains(merged.Groups, "testers") {
+		t.Errorf("Expected the new account to pick up friends and groups, got %v and %v", merged.Friends, merged.Groups)
+	}
+
+	if count, _ := db.Collection("users").CountDocuments(ctx, bson.M{"pid": fromPID}); count != 0 {
+		t.Error("Expected the old account to be gone")
+	}
+	user, err := database.FindUserByUsername(ctx, db, "Old_Name")
+	if err != nil || user.PID != toPID {
+		t.Errorf("Expected the old username to log in to the new account, got %v (%v)", user, err)
+	}
+}
+
+// Tests that a document the merge can't read stops it before anything is changed
+func TestMergeAccountsUndecodableDocument(t *testing.T) {
+	ctx := context.Background()
+
+	const fromPID, toPID = 77803, 77804
+	db := database.GocentralDatabase
+	for _, collection := range []string{"users", "scores", "battle_history"} {
+		defer db.Collection(collection).DeleteMany(ctx, bson.M{"pid": bson.M{"$in": []int{fromPID, toPID}}})
+	}
+
+	db.Collection("users").InsertMany(ctx, []interface{}{
+		bson.M{"pid": fromPID, "username": "broken_old", "console_type": 1},
+		bson.M{"pid": toPID, "username": "broken_new", "console_type": 1},
+	})
+	db.Collection("scores").InsertOne(ctx, models.Score{SongID: 1, RoleID: 0, OwnerPID: fromPID, Score: 1000})
+	db.Collection("battle_history").InsertOne(ctx, bson.M{"pid": fromPID, "battle_id": 1, "rank": "first"})
+
+	if _, err := database.MergeAccounts(ctx, db, fromPID, toPID, false); err == nil {
+		t.Fatal("Expected the merge to fail on a battle history entry it can't decode")
+	}
+
+	if count, _ := db.Collection("scores").CountDocuments(ctx, bson.M{"pid": fromPID}); count != 1 {
+		t.Error("Expected the old account's scores to be left alone")
+	}
+	if count, _ := db.Collection("users").CountDocuments(ctx, bson.M{"pid": fromPID}); count != 1 {
+		t.Error("Expected the old account to still exist")
+	}
+}
+
+func TestPlayerProfileHandler(t *testing.T) {
+	ctx := context.Background()
+
+	const pid = 77901
+	db := database.GocentralDatabase
+	cleanupFilter := bson.M{"$or": bson.A{bson.M{"pid": pid}, bson.M{"owner_pid": pid}}}
+	for _, collection := range []string{"users", "scores", "bands", "characters", "setlists", "accomplishment_scores"} {
+		defer db.Collection(collection).DeleteMany(ctx, cleanupFilter)
+	}
+
+	db.Collection("users").InsertOne(ctx, bson.M{"pid": pid, "username": "profile_player", "console_type": 1, "guid": "secret-guid", "friends": []int{501}, "web_identity": "discord:779"})
+	db.Collection("bands").InsertOne(ctx, bson.M{"owner_pid": pid, "band_id": 77921, "name": "Profile Band"})
+	db.Collection("characters").InsertOne(ctx, bson.M{"owner_pid": pid, "character_id": 77931, "name": "Profile Character"})
+	db.Collection("scores").InsertMany(ctx, []interface{}{
+		models.Score{SongID: 1, RoleID: 0, OwnerPID: pid, Score: 500, MachineID: "secret-machine"},
+		models.Score{SongID: 2, RoleID: 1, OwnerPID: pid, Score: 900},
+		models.Score{SongID: 3, RoleID: 1, OwnerPID: pid, Score: 10000, BattleID: 77941},
+	})
+	db.Collection("setlists").InsertMany(ctx, []interface{}{
+		models.Setlist{SetlistID: 77911, PID: pid, Type: 2, Title: "Shared", Shared: "t"},
+		models.Setlist{SetlistID: 77912, PID: pid, Type: 2, Title: "Not Shared", Shared: "f"},
+	})
+	db.Collection("accomplishment_scores").InsertOne(ctx, models.AccomplishmentScore{AccID: "acc_profile", PID: pid, Score: 5})
+
+	router := chi.NewRouter()
+	router.Get("/players/{player}", restapi.PlayerProfileHandler)
+	router.Put("/web/accounts/{pid}/profile", restapi.WebProfileVisibilityHandler)
+	router.Put("/admin/players/{pid}/profile", restapi.ProfileVisibilityHandler)
+
+	for _, player := range []string{"Profile_Player", "77901"} {
+		rr := makeRequest(t, "GET", "/players/"+player, nil, router.ServeHTTP)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 getting the profile of %s, got %d", player, rr.Code)
+		}
+
+		body := rr.Body.String()
+		for _, private := range []string{"secret-guid", "secret-machine", "discord:779", "friends"} {
+			if strings.Contains(body, private) {
+				t.Errorf("Expected the profile not to contain %q, got %s", private, body)
+			}
+		}
+
+		var profile restapi.PlayerProfile
+		decodeResponse(t, rr, &profile)
+		if profile.PID != pid || profile.Name != "profile_player [PS3]" || profile.Platform != "PS3" {
+			t.Errorf("Unexpected player in profile: %+v", profile)
+		}
+		if len(profile.Bands) != 1 || profile.Bands[0].Name != "Profile Band" || len(profile.Characters) != 1 {
+			t.Errorf("Expected the player's band and character, got %+v and %+v", profile.Bands, profile.Characters)
+		}
+		if profile.OverallRank == 0 || len(profile.RoleRanks) != 2 {
+			t.Errorf("Expected an overall rank and a rank for both instruments played, got %d and %+v", profile.OverallRank, profile.RoleRanks)
+		}
+		if len(profile.TopScores) != 2 || profile.TopScores[0].Score != 900 {
+			t.Errorf("Expected the song scores best first without battle scores, got %+v", profile.TopScores)
+		}
+		if len(profile.Setlists) != 1 || profile.Setlists[0].Title != "Shared" {
+			t.Errorf("Expected only the shared setlist, got %+v", profile.Setlists)
+		}
+		if len(profile.Accomplishments) != 1 || profile.Accomplishments[0].Rank != 1 {
+			t.Errorf("Expected the accomplishment standing, got %+v", profile.Accomplishments)
+		}
+	}
+
+	if rr := makeRequest(t, "GET", "/players/nobody_77901", nil, router.ServeHTTP); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a player that doesn't exist, got %d", rr.Code)
+	}
+
+	if rr := makeRequest(t, "PUT", "/web/accounts/77901/profile", map[string]interface{}{"web_identity": "discord:999", "hidden": true}, router.ServeHTTP); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 hiding the profile of an account linked to someone else, got %d", rr.Code)
+	}
+	if rr := makeRequest(t, "PUT", "/web/accounts/77901/profile", map[string]interface{}{"web_identity": "discord:779", "hidden": true}, router.ServeHTTP); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 hiding a linked account's profile, got %d", rr.Code)
+	}
+	if rr := makeRequest(t, "GET", "/players/profile_player", nil, router.ServeHTTP); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a hidden profile, got %d", rr.Code)
+	}
+
+	// admins can show or hide the profile of any player, linked or not
+	if rr := makeRequest(t, "PUT", "/admin/players/77901/profile", map[string]interface{}{"hidden": false}, router.ServeHTTP); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 showing a profile as an admin, got %d", rr.Code)
+	}
+	if rr := makeRequest(t, "GET", "/players/profile_player", nil, router.ServeHTTP); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for a profile shown again, got %d", rr.Code)
+	}
+	if rr := makeRequest(t, "PUT", "/admin/players/77999/profile", map[string]interface{}{"hidden": true}, router.ServeHTTP); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 hiding the profile of a player that doesn't exist, got %d", rr.Code)
+	}
+}
+
+// Tests managing the profanity filter through the admin API
+func TestProfanityHandlers(t *testing.T) {
+	ctx := context.Background()
+	configCollection := database.GocentralDatabase.Collection("config")
+
+	configCollection.UpdateOne(ctx, bson.M{}, bson.M{"$set": bson.M{"profanity_list": []string{"oldword"}}})
+	defer func() {
+		configCollection.UpdateOne(ctx, bson.M{}, bson.M{
+			"$set":   bson.M{"profanity_list": []string{}},
+			"$unset": bson.M{"profanity_filters": "", "profanity_allowlist": ""},
+		})
+		database.InvalidateConfigCache()
+	}()
+	database.InvalidateConfigCache()
+
+	router := chi.NewRouter()
+	router.Get("/admin/profanity", restapi.ProfanityListsHandler)
+	router.Post("/admin/profanity/filters", restapi.AddProfanityEntryHandler)
+	router.Delete("/admin/profanity/filters", restapi.RemoveProfanityEntryHandler)
+	router.Post("/admin/profanity/allowlist", restapi.AddProfanityAllowlistHandler)
+	router.Delete("/admin/profanity/allowlist", restapi.RemoveProfanityAllowlistHandler)
+	router.Post("/admin/profanity/test", restapi.TestProfanityHandler)
+
+	if rr := makeRequest(t, "POST", "/admin/profanity/filters", map[string]string{"term": "(", "match": "regex"}, router.ServeHTTP); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid regex, got %d", rr.Code)
+	}
+	if rr := makeRequest(t, "POST", "/admin/profanity/filters", map[string]string{"term": "darn", "severity": "mask"}, router.ServeHTTP); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 adding an entry, got %d", rr.Code)
+	}
+	// adding the same term and match again replaces the entry
+	if rr := makeRequest(t, "POST", "/admin/profanity/filters", map[string]string{"term": "darn"}, router.ServeHTTP); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 replacing an entry, got %d", rr.Code)
+	}
+	if rr := makeRequest(t, "POST", "/admin/profanity/allowlist", map[string]string{"word": "darnold"}, router.ServeHTTP); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 adding an allowlisted word, got %d", rr.Code)
+	}
+
+	var lists restapi.ProfanityListsResponse
+	rr := makeRequest(t, "GET", "/admin/profanity", nil, router.ServeHTTP)
+	json.Unmarshal(rr.Body.Bytes(), &lists)
+	if len(lists.Filters) != 2 || lists.Filters[0].Term != "darn" || lists.Filters[0].Severity != "reject" || lists.Filters[1].Term != "oldword" {
+		t.Errorf("Expected the darn entry and the old profanity list, got %+v", lists.Filters)
+	}
+	if len(lists.Allowlist) != 1 || lists.Allowlist[0] != "darnold" {
+		t.Errorf("Expected darnold on the allowlist, got %v", lists.Allowlist)
+	}
+
+	testCases := []struct {
+		text     string
+		rejected bool
+	}{
+		{"d4rn it", true},
+		{"Darnold fan club", false},
+		{"oldwords", true},
+	}
+	for _, tc := range testCases {
+		var result struct {
+			Severity string `json:"severity"`
+		}
+		rr := makeRequest(t, "POST", "/admin/profanity/test", map[string]string{"text": tc.text}, router.ServeHTTP)
+		json.Unmarshal(rr.Body.Bytes(), &result)
+		if (result.Severity == "reject") != tc.rejected {
+			t.Errorf("Expected %q rejected to be %v, got severity %q", tc.text, tc.rejected, result.Severity)
+		}
+	}
+
+	if rr := makeRequest(t, "DELETE", "/admin/profanity/filters", map[string]string{"term": "oldword", "match": "substring"}, router.ServeHTTP); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 removing an old profanity list word, got %d", rr.Code)
+	}
+	if rr := makeRequest(t, "DELETE", "/admin/profanity/filters", map[string]string{"term": "oldword", "match": "substring"}, router.ServeHTTP); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 removing it again, got %d", rr.Code)
+	}
+	if rr := makeRequest(t, "DELETE", "/admin/profanity/allowlist", map[string]string{"word": "darnold"}, router.ServeHTTP); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 removing an allowlisted word, got %d", rr.Code)
+	}
+
+	if result, err := database.FilterText(ctx, "oldword darnold"); err != nil || result.Severity != "" {
+		t.Errorf("Expected nothing to match after removing the entries, got %+v", result.Matches)
+	}
+}
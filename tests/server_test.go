@@ -597,3 +597,34 @@ func TestMachineRegistration(t *testing.T) {
 
 	t.Log("MachineRegistration: machine correctly registered")
 }
+
+// ============================================
+// Upload Rate Limit Tests
+// ============================================
+
+func TestUploadLimiter(t *testing.T) {
+	limiter := servers.NewUploadLimiter(time.Hour)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow(500, 3, now.Add(time.Duration(i)*time.Minute)) {
+			t.Fatalf("Expected upload %d to be allowed", i+1)
+		}
+	}
+
+	if limiter.Allow(500, 3, now.Add(10*time.Minute)) {
+		t.Error("Expected the fourth upload in an hour to be rejected")
+	}
+
+	if !limiter.Allow(501, 3, now.Add(10*time.Minute)) {
+		t.Error("Expected another player's upload to be allowed")
+	}
+
+	// the first upload has left the window, so there is room for one more
+	if !limiter.Allow(500, 3, now.Add(60*time.Minute + 30*time.Second)) {
+		t.Error("Expected an upload to be allowed once the oldest one is an hour old")
+	}
+	if limiter.Allow(500, 3, now.Add(60*time.Minute + 30*time.Second)) {
+		t.Error("Expected rejected uploads to not make room for more")
+	}
+}
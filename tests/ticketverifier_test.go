@@ -0,0 +1,206 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"rb3server/authentication"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// a verifier that always gives the same answer and counts how often it was asked
+type fakeVerifier struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (verifier *fakeVerifier) Name() string {
+	return verifier.name
+}
+
+func (verifier *fakeVerifier) VerifyTicket(ctx context.Context, ticketData []byte, consoleType int) error {
+	verifier.calls++
+	return verifier.err
+}
+
+func TestVerifierChain(t *testing.T) {
+	ctx := context.Background()
+	down := &fakeVerifier{name: "down", err: authentication.ErrVerifierUnavailable}
+	rejects := &fakeVerifier{name: "rejects", err: authentication.ErrTicketRejected}
+	accepts := &fakeVerifier{name: "accepts"}
+	metrics := authentication.NewVerifierMetrics()
+
+	chain := &authentication.VerifierChain{ConsoleType: 1, Verifiers: []authentication.Verifier{down, rejects, accepts}, Metrics: metrics}
+	if err := chain.VerifyTicket(ctx, []byte("ticket"), 1); !errors.Is(err, authentication.ErrTicketRejected) {
+		t.Errorf("Expected the first verdict to win, got %v", err)
+	}
+	if accepts.calls != 0 {
+		t.Error("Expected verifiers after a verdict to not be asked")
+	}
+
+	chain = &authentication.VerifierChain{ConsoleType: 1, Verifiers: []authentication.Verifier{down, down}, Metrics: metrics}
+	if err := chain.VerifyTicket(ctx, []byte("ticket"), 1); !errors.Is(err, authentication.ErrVerifierUnavailable) {
+		t.Errorf("Expected a ticket no verifier could check to be rejected, got %v", err)
+	}
+
+	chain = &authentication.VerifierChain{
+		ConsoleType: 1,
+		Verifiers:   []authentication.Verifier{down, accepts},
+		Cache:       authentication.NewVerificationCache(time.Minute),
+		Metrics:     metrics,
+	}
+	for i := 0; i < 3; i++ {
+		if err := chain.VerifyTicket(ctx, []byte("ticket"), 1); err != nil {
+			t.Fatalf("Expected the ticket to be accepted, got %v", err)
+		}
+	}
+	if accepts.calls != 1 {
+		t.Errorf("Expected accepted tickets to be cached, verifier was asked %d times", accepts.calls)
+	}
+
+	// the cache is per ticket and console type
+	chain.VerifyTicket(ctx, []byte("ticket"), 3)
+	if accepts.calls != 2 {
+		t.Errorf("Expected the same ticket from another console type to be verified again, verifier was asked %d times", accepts.calls)
+	}
+
+	// the chain says who accepted a ticket, so an allowall pass isn't mistaken for a checked ticket
+	allowAll := &authentication.VerifierChain{
+		ConsoleType: 1,
+		Verifiers:   []authentication.Verifier{&authentication.AllowAllVerifier{}},
+		Cache:       authentication.NewVerificationCache(time.Minute),
+	}
+	for i := 0; i < 2; i++ {
+		if verifiedBy, err := allowAll.Verify(ctx, []byte("ticket"), 1); err != nil || verifiedBy != "allowall" {
+			t.Errorf("Expected allowall to accept the ticket every time without caching it, got %q and %v", verifiedBy, err)
+		}
+	}
+	if verifiedBy, _ := chain.Verify(ctx, []byte("ticket"), 1); verifiedBy != authentication.VerdictCached {
+		t.Errorf("Expected a ticket accepted before to come from the cache, got %q", verifiedBy)
+	}
+
+	counts := map[string]uint64{}
+	for _, metric := range metrics.Snapshot() {
+		if metric.ConsoleType == 1 {
+			counts[metric.Verifier+"/"+metric.Verdict] = metric.Count
+		}
+	}
+	expected := map[string]uint64{
+		"down/unavailable":  4,
+		"rejects/rejected":  1,
+		"accepts/accepted":  1,
+		"chain/rejected":    1,
+		"chain/unavailable": 1,
+		"chain/accepted":    1,
+		"chain/cached":      3,
+	}
+	for key, count := range expected {
+		if counts[key] != count {
+			t.Errorf("Expected %s to be %d, got %d (all: %v)", key, count, counts[key], counts)
+		}
+	}
+}
+
+func TestRemoteVerifier(t *testing.T) {
+	var requests int32
+	var status int32 = http.StatusOK
+	var failuresBeforeStatus int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+
+		if r.Method != http.MethodPost {
+			t.Errorf("Expected a POST, got %s", r.Method)
+		}
+		if ticket, _ := base64.StdEncoding.DecodeString(r.FormValue("ticket")); string(ticket) != "ticket" || r.FormValue("platform") != "1" {
+			t.Errorf("Expected the ticket without its sizes and the platform, got %q and %q", ticket, r.FormValue("platform"))
+		}
+
+		if n <= atomic.LoadInt32(&failuresBeforeStatus) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	verifier := authentication.NewRemoteVerifier(server.URL)
+	verifier.Backoff = time.Millisecond
+	verifier.FailureLimit = 2
+	verifier.Cooldown = time.Hour
+
+	ctx := context.Background()
+	ticket := append([]byte("SIZESIZE"), "ticket"...)
+
+	if err := verifier.VerifyTicket(ctx, ticket, 1); err != nil {
+		t.Errorf("Expected a 200 to accept the ticket, got %v", err)
+	}
+
+	atomic.StoreInt32(&status, http.StatusForbidden)
+	if err := verifier.VerifyTicket(ctx, ticket, 1); !errors.Is(err, authentication.ErrTicketRejected) {
+		t.Errorf("Expected a 403 to reject the ticket, got %v", err)
+	}
+
+	// two failures then an answer is within the two retries
+	atomic.StoreInt32(&requests, 0)
+	atomic.StoreInt32(&failuresBeforeStatus, 2)
+	atomic.StoreInt32(&status, http.StatusOK)
+	if err := verifier.VerifyTicket(ctx, ticket, 1); err != nil {
+		t.Errorf("Expected the ticket to be accepted after retrying, got %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("Expected 3 attempts, got %d", requests)
+	}
+
+	// the service being down twice in a row opens the breaker
+	atomic.StoreInt32(&failuresBeforeStatus, 1000)
+	for i := 0; i < 2; i++ {
+		if err := verifier.VerifyTicket(ctx, ticket, 1); !errors.Is(err, authentication.ErrVerifierUnavailable) {
+			t.Errorf("Expected the verifier to be unavailable, got %v", err)
+		}
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	if err := verifier.VerifyTicket(ctx, ticket, 1); !errors.Is(err, authentication.ErrVerifierUnavailable) {
+		t.Errorf("Expected the verifier to be unavailable while the breaker is open, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no requests while the breaker is open, got %d", requests)
+	}
+}
+
+func TestNewVerifierChainsFromEnv(t *testing.T) {
+	t.Setenv("TICKETVERIFIERENDPOINT", "http://localhost:1/verify")
+	t.Setenv("PS3TICKETVERIFIERS", "remote, allowall")
+	t.Setenv("VERIFY_WII_TICKETS", "true")
+
+	chains, err := authentication.NewVerifierChainsFromEnv()
+	if err != nil {
+		t.Fatalf("Could not create chains: %v", err)
+	}
+
+	if chain := chains[1]; chain == nil || len(chain.Verifiers) != 2 || chain.Verifiers[0].Name() != "remote" || chain.Verifiers[1].Name() != "allowall" {
+		t.Errorf("Expected the PS3 chain to be remote then allowall, got %+v", chains[1])
+	}
+	if chain := chains[2]; chain == nil || len(chain.Verifiers) != 1 || chain.Verifiers[0].Name() != "remote" {
+		t.Errorf("Expected the old Wii switch to verify with the remote service, got %+v", chains[2])
+	}
+	if chains[0] != nil || chains[3] != nil {
+		t.Error("Expected console types without verifiers to not have a chain")
+	}
+
+	t.Setenv("RPCS3TICKETVERIFIERS", "npticket")
+	if _, err := authentication.NewVerifierChainsFromEnv(); err == nil {
+		t.Error("Expected npticket without any issuer keys to be an error")
+	}
+
+	t.Setenv("RPCS3TICKETVERIFIERS", "magic")
+	if _, err := authentication.NewVerifierChainsFromEnv(); err == nil {
+		t.Error("Expected an unknown verifier to be an error")
+	}
+}